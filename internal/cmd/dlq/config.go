@@ -0,0 +1,61 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dlq
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// Config contains the connection and schema options shared by the dlq
+// subcommands.
+type Config struct {
+	logical.BaseConfig
+
+	// The SQL schema in the target cluster which contains the DLQ table.
+	TargetSchema ident.Schema
+}
+
+var _ logical.Config = (*Config)(nil)
+
+// Bind adds flags to the set.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	c.BaseConfig.Bind(f)
+
+	f.Var(ident.NewSchemaFlag(&c.TargetSchema), "targetSchema",
+		"the SQL database schema in the target cluster that contains the dead-letter queue table")
+}
+
+// Preflight implements [logical.Config].
+func (c *Config) Preflight() error {
+	if err := c.BaseConfig.Preflight(); err != nil {
+		return err
+	}
+	if c.TargetSchema.Empty() {
+		return errors.New("no target schema specified")
+	}
+	return nil
+}
+
+// ProvideScriptTarget exports [Config.TargetSchema] for the script
+// engine.
+func ProvideScriptTarget(cfg *Config) script.TargetSchema {
+	return script.TargetSchema(cfg.TargetSchema)
+}
@@ -0,0 +1,199 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package dlq contains commands to inspect and replay the contents of
+// dead-letter queues.
+package dlq
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Tool contains the services used by the dlq subcommands.
+type Tool struct {
+	Admin      *dlq.Admin
+	Config     *Config
+	UserScript *script.UserScript
+}
+
+// Command returns the dlq command group.
+func Command() *cobra.Command {
+	cfg := &Config{}
+	cmd := &cobra.Command{
+		Short: "inspect and replay dead-letter queues",
+		Use:   "dlq",
+	}
+	cfg.Bind(cmd.PersistentFlags())
+	cmd.AddCommand(
+		listCommand(cfg),
+		purgeCommand(cfg),
+		replayCommand(cfg),
+		showCommand(cfg),
+	)
+	return cmd
+}
+
+func listCommand(cfg *Config) *cobra.Command {
+	return &cobra.Command{
+		Args:  cobra.NoArgs,
+		Short: "summarize the dead-letter queues in the target schema",
+		Use:   "list",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tool, cancel, err := newTool(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer cancel()
+
+			summaries, err := tool.Admin.List(cmd.Context(), cfg.TargetSchema)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tCOUNT\tOLDEST\tNEWEST")
+			for _, s := range summaries {
+				fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", s.Name, s.Count,
+					s.Oldest.Format(time.RFC3339Nano), s.Newest.Format(time.RFC3339Nano))
+			}
+			return errors.WithStack(w.Flush())
+		},
+	}
+}
+
+func purgeCommand(cfg *Config) *cobra.Command {
+	return &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Short: "delete all entries in a dead-letter queue",
+		Use:   "purge <name>",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tool, cancel, err := newTool(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer cancel()
+
+			count, err := tool.Admin.Purge(cmd.Context(), cfg.TargetSchema, args[0])
+			if err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{
+				"dlq":     args[0],
+				"deleted": count,
+			}).Info("purged dead-letter queue")
+			return nil
+		},
+	}
+}
+
+func replayCommand(cfg *Config) *cobra.Command {
+	var failedName string
+	var table ident.Ident
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Short: "apply the entries in a dead-letter queue to a target table",
+		Long: `Replay applies the entries in a dead-letter queue to the given table,
+in time order. If a userscript is configured, its map and merge
+functions for the table will be used. Entries are deleted once they
+have been applied. Entries which cannot be applied are left in place,
+or moved to the queue named by --failedDLQ.`,
+		Use: "replay <name>",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if table.Empty() {
+				return errors.New("no target table specified")
+			}
+			tool, cancel, err := newTool(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer cancel()
+
+			opts := &dlq.ReplayOptions{
+				FailedName: failedName,
+				Name:       args[0],
+				Target:     ident.NewTable(cfg.TargetSchema, table),
+			}
+			if tgt, ok := tool.UserScript.Targets.Get(opts.Target); ok && tgt.Map != nil {
+				opts.Map = tgt.Map
+			}
+
+			res, err := tool.Admin.Replay(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{
+				"applied": res.Applied,
+				"dlq":     opts.Name,
+				"dropped": res.Dropped,
+				"failed":  res.Failed,
+				"skipped": res.Skipped,
+			}).Info("replayed dead-letter queue")
+			if res.Failed > 0 {
+				return errors.Errorf("%d entries could not be applied", res.Failed)
+			}
+			return nil
+		},
+	}
+	f := cmd.Flags()
+	f.StringVar(&failedName, "failedDLQ", "",
+		"move entries which cannot be applied to this dead-letter queue")
+	f.Var(ident.NewValue("", &table), "table",
+		"the table within the target schema to apply the entries to")
+	return cmd
+}
+
+func showCommand(cfg *Config) *cobra.Command {
+	var limit int
+	cmd := &cobra.Command{
+		Args:  cobra.ExactArgs(1),
+		Short: "print the entries in a dead-letter queue as JSON",
+		Use:   "show <name>",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tool, cancel, err := newTool(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer cancel()
+
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			count := 0
+			return tool.Admin.Read(cmd.Context(), cfg.TargetSchema, args[0], func(entry *dlq.Entry) error {
+				if limit > 0 && count >= limit {
+					return dlq.ErrStop
+				}
+				count++
+				return errors.WithStack(enc.Encode(map[string]any{
+					"after":  entry.Mutation.Data,
+					"before": entry.Mutation.Before,
+					"error":  entry.Error,
+					"time":   entry.Mutation.Time,
+				}))
+			})
+		},
+	}
+	cmd.Flags().IntVar(&limit, "limit", 100,
+		"the maximum number of entries to print, or 0 for all entries")
+	return cmd
+}
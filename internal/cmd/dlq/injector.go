@@ -0,0 +1,51 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build wireinject
+// +build wireinject
+
+package dlq
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target"
+	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/google/wire"
+)
+
+// newTool connects to the target database and constructs the services
+// used by the dlq subcommands.
+func newTool(ctx context.Context, config *Config) (*Tool, func(), error) {
+	panic(wire.Build(
+		wire.Bind(new(logical.Config), new(*Config)),
+		wire.Struct(new(Tool), "*"),
+		ProvideScriptTarget,
+		applycfg.Set,
+		diag.New,
+		logical.ProvideApplyConfig,
+		logical.ProvideBaseConfig,
+		logical.ProvideDLQConfig,
+		logical.ProvideTargetPool,
+		logical.ProvideTargetStatements,
+		logical.ProvideUserScriptConfig,
+		script.Set,
+		target.Set,
+	))
+}
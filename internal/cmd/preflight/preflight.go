@@ -87,9 +87,9 @@ func testTargetConnection(ctx context.Context, connString string) error {
 	log.Info("connected to the database")
 
 	switch pool.Product {
-	case types.ProductCockroachDB, types.ProductPostgreSQL:
+	case types.ProductCockroachDB, types.ProductPostgreSQL, types.ProductRedshift:
 		var result int
-		log.Info("Cockroach/Postgres DB detected")
+		log.Info("Cockroach/Postgres/Redshift DB detected")
 		log.Info("Testing basic query")
 		row := pool.DB.QueryRowContext(ctx, "SELECT 1")
 		if err := row.Scan(&result); err != nil {
@@ -120,6 +120,39 @@ func testTargetConnection(ctx context.Context, connString string) error {
 		if result != 1 {
 			return errors.Errorf("SELECT 1 from dual; returned %d instead", result)
 		}
+	case types.ProductSQLite:
+		log.Info("SQLite DB detected")
+		log.Info("Testing basic query")
+		var result int
+		row := pool.DB.QueryRowContext(ctx, "SELECT 1")
+		if err := row.Scan(&result); err != nil {
+			return err
+		}
+		if result != 1 {
+			return errors.Errorf("SELECT 1 returned %d instead", result)
+		}
+	case types.ProductClickHouse:
+		log.Info("ClickHouse DB detected")
+		log.Info("Testing basic query")
+		var result int
+		row := pool.DB.QueryRowContext(ctx, "SELECT 1")
+		if err := row.Scan(&result); err != nil {
+			return err
+		}
+		if result != 1 {
+			return errors.Errorf("SELECT 1 returned %d instead", result)
+		}
+	case types.ProductSQLServer:
+		log.Info("SQL Server DB detected")
+		log.Info("Testing basic query")
+		var result int
+		row := pool.DB.QueryRowContext(ctx, "SELECT 1")
+		if err := row.Scan(&result); err != nil {
+			return err
+		}
+		if result != 1 {
+			return errors.Errorf("SELECT 1 returned %d instead", result)
+		}
 	default:
 		return errors.Errorf("Database type %s not supported.", pool.Product)
 	}
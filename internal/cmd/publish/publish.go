@@ -0,0 +1,38 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package publish contains a command to start a changefeed server that
+// publishes resolved mutations to Kafka.
+package publish
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/source/publish"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
+	"github.com/spf13/cobra"
+)
+
+// Command returns the publish subcommand.
+func Command() *cobra.Command {
+	cfg := &publish.Config{}
+	return stdlogical.New(&stdlogical.Template{
+		Bind:  cfg.Bind,
+		Short: "start a server that publishes resolved changefeed mutations to Kafka",
+		Start: func(cmd *cobra.Command) (any, func(), error) {
+			return publish.NewServer(cmd.Context(), cfg)
+		},
+		Use: "publish",
+	})
+}
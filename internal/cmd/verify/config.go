@@ -0,0 +1,85 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package verify
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/verify"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdpool"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// Config contains the options for the verify command.
+type Config struct {
+	logical.BaseConfig
+
+	// The connection string for the source database.
+	SourceConn string
+	// Controls the comparison of the source and target.
+	Verify verify.Config
+}
+
+var _ logical.Config = (*Config)(nil)
+
+// Bind adds flags to the set.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	c.BaseConfig.Bind(f)
+	c.Verify.Bind(f)
+
+	f.StringVar(&c.SourceConn, "sourceConn", "", "the source database's connection string")
+}
+
+// Preflight implements [logical.Config].
+func (c *Config) Preflight() error {
+	if err := c.BaseConfig.Preflight(); err != nil {
+		return err
+	}
+	if err := c.Verify.Preflight(); err != nil {
+		return err
+	}
+	if c.SourceConn == "" {
+		return errors.New("sourceConn must be set")
+	}
+	return nil
+}
+
+// ProvideSourcePool is called by Wire to open a connection to the
+// source database.
+func ProvideSourcePool(
+	ctx context.Context, cfg *Config, diags *diag.Diagnostics,
+) (*types.SourcePool, func(), error) {
+	ret, cancel, err := stdpool.OpenTarget(ctx, cfg.SourceConn,
+		stdpool.WithConnectionLifetime(5*time.Minute),
+		stdpool.WithDiagnostics(diags, "source"),
+		stdpool.WithMetrics("source"),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	return (*types.SourcePool)(ret), cancel, nil
+}
+
+// ProvideVerifyConfig is called by Wire.
+func ProvideVerifyConfig(cfg *Config) *verify.Config {
+	return &cfg.Verify
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package verify contains a command to compare the rows in a source
+// database with those in a target database.
+package verify
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/cockroachdb/cdc-sink/internal/target/verify"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// Tool contains the services used by the verify command.
+type Tool struct {
+	Config   *Config
+	Verifier *verify.Verifier
+}
+
+// Command returns the verify command.
+func Command() *cobra.Command {
+	cfg := &Config{}
+	cmd := &cobra.Command{
+		Args:  cobra.NoArgs,
+		Short: "compare the rows in a source database with a target schema",
+		Long: `Verify reads every table in the target schema, and the table with the
+same name in the source schema, in primary-key order. The rows are
+compared in chunks, using checksums to skip chunks that agree. Rows
+that are missing from the target, extra in the target, or differ are
+reported. If --repairDLQ is set, mutations which would bring the target
+into agreement with the source are written to a dead-letter queue for
+each table, which may be applied with "dlq replay".
+
+The command exits with an error if any differences are found.`,
+		Use: "verify",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tool, cancel, err := newTool(cmd.Context(), cfg)
+			if err != nil {
+				return err
+			}
+			defer cancel()
+
+			reports, err := tool.Verifier.Verify(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if err := printReports(cmd.OutOrStdout(), reports); err != nil {
+				return err
+			}
+
+			var differ int
+			for _, report := range reports {
+				if !report.OK() {
+					differ++
+				}
+			}
+			if differ > 0 {
+				return errors.Errorf("%d of %d tables differ", differ, len(reports))
+			}
+			return nil
+		},
+	}
+	cfg.Bind(cmd.Flags())
+	return cmd
+}
+
+// printReports writes a summary table, followed by the details of any
+// tables that differ.
+func printReports(out io.Writer, reports []*verify.Report) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tSOURCE\tTARGET\tCHUNKS\tMISSING\tEXTRA\tMISMATCHED\tREPAIRED")
+	for _, r := range reports {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d/%d\t%d\t%d\t%d\t%d\n",
+			r.Table, r.SourceRows, r.TargetRows,
+			len(r.MismatchedChunks), r.Chunks,
+			r.MissingCount, r.ExtraCount, r.MismatchedCount, r.Repaired)
+	}
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, r := range reports {
+		if r.OK() {
+			continue
+		}
+		fmt.Fprintf(out, "\n%s:\n", r.Table)
+		for _, chunk := range r.MismatchedChunks {
+			fmt.Fprintf(out, "  chunk %s differs\n", chunk)
+		}
+		printKeys(out, "missing", r.Missing, r.MissingCount)
+		printKeys(out, "extra", r.Extra, r.ExtraCount)
+		printKeys(out, "mismatched", r.Mismatched, r.MismatchedCount)
+	}
+	return nil
+}
+
+func printKeys(out io.Writer, label string, keys []string, count int) {
+	for _, key := range keys {
+		fmt.Fprintf(out, "  %s %s\n", label, key)
+	}
+	if count > len(keys) {
+		fmt.Fprintf(out, "  ... and %d more %s\n", count-len(keys), label)
+	}
+}
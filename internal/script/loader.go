@@ -79,10 +79,14 @@ type sourceJS struct {
 // field is ignored, since that can be taken care of by the Map
 // function.
 type targetJS struct {
+	// Stage data in files and load with COPY.
+	BulkLoad bool `goja:"bulk"`
 	// Column names.
 	CASColumns []string `goja:"cas"`
 	// Column to duration.
 	Deadlines map[string]string `goja:"deadlines"`
+	// Name of a DLQ for mutations which cannot be applied.
+	ErrorDLQ string `goja:"errorDLQ"`
 	// Column to SQL expression to pass through.
 	Exprs map[string]string `goja:"exprs"`
 	// Column name.
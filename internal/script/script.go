@@ -160,6 +160,7 @@ func (s *UserScript) bind(loader *Loader) error {
 		tgt := &Target{Config: *applycfg.NewConfig()}
 		s.Targets.Put(table, tgt)
 
+		tgt.BulkLoad = bag.BulkLoad
 		for _, cas := range bag.CASColumns {
 			tgt.CASColumns = append(tgt.CASColumns, ident.New(cas))
 		}
@@ -170,6 +171,7 @@ func (s *UserScript) bind(loader *Loader) error {
 			}
 			tgt.Deadlines.Put(ident.New(k), d)
 		}
+		tgt.ErrorDLQ = bag.ErrorDLQ
 		for k, v := range bag.Exprs {
 			tgt.Exprs.Put(ident.New(k), v)
 		}
@@ -133,11 +133,13 @@ func TestScript(t *testing.T) {
 	tbl := ident.NewTable(schema, ident.New("all_features"))
 	if cfg := s.Targets.GetZero(tbl); a.NotNil(cfg) {
 		expectedApply := applycfg.Config{
+			BulkLoad:   true,
 			CASColumns: []ident.Ident{ident.New("cas0"), ident.New("cas1")},
 			Deadlines: ident.MapOf[time.Duration](
 				ident.New("dl0"), time.Hour,
 				ident.New("dl1"), time.Minute,
 			),
+			ErrorDLQ: "all_features_errors",
 			Exprs: ident.MapOf[string](
 				ident.New("expr0"), "fnv32($0::BYTES)",
 				ident.New("expr1"), "Hello Library!",
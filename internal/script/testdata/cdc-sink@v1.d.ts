@@ -143,6 +143,12 @@ declare module "cdc-sink@v1" {
      * @see configureTable
      */
     type ConfigureTableOptions = {
+        /**
+         * Load batches of data by staging them in a file and issuing
+         * a COPY command, instead of using multi-row statements. This
+         * is only supported by PostgreSQL-compatible targets.
+         */
+        bulk: boolean;
         /**
          * A list of columns to enable compare-and-set behavior.
          */
@@ -152,6 +158,14 @@ declare module "cdc-sink@v1" {
          * named timestamp column is older than the given duration.
          */
         deadlines: { [k: Column]: Duration };
+        /**
+         * The name of a dead-letter queue. If a batch of mutations
+         * cannot be applied, it will be bisected to find the
+         * individual mutations which fail. Those mutations will be
+         * written to the dead-letter queue, along with the error,
+         * and the remainder of the batch will be applied.
+         */
+        errorDLQ: string;
         /**
          * Replacement SQL expressions to use when upserting columns.
          * The placeholder <code>$0</code> will be replaced with the
@@ -47,6 +47,8 @@ api.configureSource("recursive", {
 })
 
 api.configureTable("all_features", {
+    // Load with COPY.
+    bulk: true,
     // Compare-and-set operations.
     cas: ["cas0", "cas1"],
     // Drop old data.
@@ -54,6 +56,8 @@ api.configureTable("all_features", {
         "dl0": "1h",
         "dl1": "1m"
     },
+    // Isolate rows which cannot be applied.
+    errorDLQ: "all_features_errors",
     // Provide alternate SQL expressions to (possibly filtered) data.
     exprs: {
         "expr0": "fnv32($0::BYTES)",
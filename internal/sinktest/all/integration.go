@@ -33,14 +33,26 @@ const (
 	// .github/docker-compose.yml file and the integration matrix
 	// variable in workflows/tests.yaml.
 	FirestoreName = "firestore"
+	// MongoDBName must be kept in alignment with the
+	// .github/docker-compose.yml file and the integration matrix
+	// variable in workflows/tests.yaml.
+	MongoDBName = "mongodb"
 	// MySQLName must be kept in alignment with the
 	// .github/docker-compose.yml file and the integration matrix
 	// variable in workflows/tests.yaml.
 	MySQLName = "mysql"
+	// OracleName must be kept in alignment with the
+	// .github/docker-compose.yml file and the integration matrix
+	// variable in workflows/tests.yaml.
+	OracleName = "oracle"
 	// PostgreSQLName must be kept in alignment with the
 	// .github/docker-compose.yml file and the integration matrix
 	// variable in workflows/tests.yaml.
 	PostgreSQLName = "postgresql"
+	// SQLServerName must be kept in alignment with the
+	// .github/docker-compose.yml file and the integration matrix
+	// variable in workflows/tests.yaml.
+	SQLServerName = "sqlserver"
 )
 
 // IntegrationMain runs the tests if the value of IntegrationEnvName
@@ -24,6 +24,7 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/sinktest/base"
 	"github.com/cockroachdb/cdc-sink/internal/staging"
 	"github.com/cockroachdb/cdc-sink/internal/target"
+	"github.com/cockroachdb/cdc-sink/internal/target/apply"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/google/wire"
@@ -35,12 +36,18 @@ var TestSet = wire.NewSet(
 	staging.Set,
 	target.Set,
 
+	ProvideApplyConfig,
 	ProvideDLQConfig,
 	ProvideWatcher,
 
 	wire.Struct(new(Fixture), "*"),
 )
 
+// ProvideApplyConfig emits a default configuration.
+func ProvideApplyConfig() *apply.Config {
+	return &apply.Config{}
+}
+
 // ProvideDLQConfig emits a default configuration.
 func ProvideDLQConfig() (*dlq.Config, error) {
 	cfg := &dlq.Config{}
@@ -131,7 +131,8 @@ func NewFixture() (*Fixture, func(), error) {
 		return nil, nil, err
 	}
 	dlQs := dlq.ProvideDLQs(config, targetPool, watchers)
-	appliers, cleanup11, err := apply.ProvideFactory(targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	applyConfig := ProvideApplyConfig()
+	appliers, cleanup11, err := apply.ProvideFactory(targetStatements, applyConfig, configs, diagnostics, dlQs, targetPool, watchers)
 	if err != nil {
 		cleanup10()
 		cleanup9()
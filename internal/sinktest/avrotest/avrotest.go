@@ -0,0 +1,46 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package avrotest contains an encoder for the Confluent wire format
+// and an in-memory schema registry, so that tests can produce the Avro
+// messages that a changefeed would emit.
+package avrotest
+
+import (
+	"encoding/binary"
+
+	"github.com/hamba/avro"
+	"github.com/pkg/errors"
+)
+
+// magicByte is the first byte of a message in the Confluent wire
+// format. It is followed by a four-byte, big-endian schema id.
+const magicByte = 0
+
+// AppendMessage encodes the value in the Confluent wire format and
+// appends it to buf. The value is encoded with [avro.Marshal], so a
+// record may be given as a map[string]any with an entry for every
+// field. A union value is given as a map[string]any with a single
+// entry, keyed by the name of the selected type, or as a nil map.
+func AppendMessage(buf []byte, id int, schema avro.Schema, value any) ([]byte, error) {
+	data, err := avro.Marshal(schema, value)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	buf = append(buf, magicByte)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(id))
+	return append(buf, data...), nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package avrotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/hamba/avro"
+	"github.com/pkg/errors"
+)
+
+// Registry is an in-process schema registry. It has the same GetSchema
+// method as the avro registry client, and it implements the
+// schema-retrieval endpoint of the Confluent schema registry API so
+// that it may be used with an [httptest.Server].
+type Registry struct {
+	mu      sync.RWMutex
+	schemas []avro.Schema // Index is id-1.
+}
+
+var _ http.Handler = (*Registry)(nil)
+
+// Register adds a schema to the registry and returns its id.
+func (r *Registry) Register(schema string) (int, error) {
+	parsed, err := avro.Parse(schema)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemas = append(r.schemas, parsed)
+	return len(r.schemas), nil
+}
+
+// GetSchema returns the schema with the given id.
+func (r *Registry) GetSchema(id int) (avro.Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if id < 1 || id > len(r.schemas) {
+		return nil, errors.Errorf("unknown schema id %d", id)
+	}
+	return r.schemas[id-1], nil
+}
+
+// ServeHTTP responds to GET /schemas/ids/{id}.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var id int
+	if _, err := fmt.Sscanf(req.URL.Path, "/schemas/ids/%d", &id); err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	schema, err := r.GetSchema(id)
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"schema": schema.String()})
+}
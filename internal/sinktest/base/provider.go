@@ -281,10 +281,10 @@ func ProvideTargetSchema(
 	// just created. We also need to recreate the statement cache so
 	// that it's associated with the newly-constructed database
 	// connection.
-	if pool.Info().Product == types.ProductPostgreSQL {
+	if product := pool.Info().Product; product == types.ProductPostgreSQL || product == types.ProductRedshift {
 		db, _ := sch.Split()
 		conn := fmt.Sprintf("%s/%s", pool.ConnectionString, db.Raw())
-		next, cancelNext, err := stdpool.OpenPgxAsTarget(ctx, conn, stdpool.WithDiagnostics(diags, "target_reopened"))
+		next, cancelNext, err := stdpool.OpenTarget(ctx, conn, stdpool.WithDiagnostics(diags, "target_reopened"))
 		if err != nil {
 			cancel()
 			return sinktest.TargetSchema{}, nil, err
@@ -314,7 +314,7 @@ func provideSchema[P types.AnyPool](
 	ctx context.Context, pool P, prefix string,
 ) (ident.Schema, func(), error) {
 	switch pool.Info().Product {
-	case types.ProductCockroachDB, types.ProductMySQL, types.ProductPostgreSQL:
+	case types.ProductCockroachDB, types.ProductMySQL, types.ProductPostgreSQL, types.ProductRedshift:
 		return CreateSchema(ctx, pool, prefix)
 
 	case types.ProductOracle:
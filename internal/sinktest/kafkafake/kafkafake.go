@@ -0,0 +1,158 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package kafkafake contains an in-process stand-in for a Kafka
+// cluster. It speaks enough of the Kafka wire protocol to support
+// consumers that read from explicit partitions and offsets. Messages
+// may be written into the Broker directly or by a Producer.
+package kafkafake
+
+import (
+	"sync"
+
+	"github.com/IBM/sarama"
+	"github.com/pkg/errors"
+)
+
+// Version is the protocol version that clients of the Broker should be
+// configured to use.
+var Version = sarama.MinVersion
+
+// fetchBatchSize is the maximum number of messages returned by a
+// single fetch request.
+const fetchBatchSize = 128
+
+// Broker is a single-node, in-memory Kafka cluster.
+type Broker struct {
+	broker *sarama.MockBroker
+	fetch  *sarama.MockFetchResponse
+	t      sarama.TestReporter
+
+	mu struct {
+		sync.Mutex
+		records map[string][]Record // All messages, in the order produced.
+		topics  map[string][]int64  // The next offset for each partition.
+	}
+}
+
+// A Record is a message that has been written to a Broker.
+type Record struct {
+	Key       []byte
+	Offset    int64
+	Partition int32
+	Value     []byte
+}
+
+// New starts a Broker. The caller should call Close when finished.
+func New(t sarama.TestReporter) *Broker {
+	ret := &Broker{
+		broker: sarama.NewMockBroker(t, 1),
+		fetch:  sarama.NewMockFetchResponse(t, fetchBatchSize),
+		t:      t,
+	}
+	ret.mu.records = make(map[string][]Record)
+	ret.mu.topics = make(map[string][]int64)
+	ret.mu.Lock()
+	defer ret.mu.Unlock()
+	ret.refreshLocked()
+	return ret
+}
+
+// Addr returns the network address of the Broker.
+func (b *Broker) Addr() string { return b.broker.Addr() }
+
+// Close shuts down the Broker.
+func (b *Broker) Close() { b.broker.Close() }
+
+// CreateTopic creates an empty topic with the requested number of
+// partitions. This method is a no-op if the topic already exists.
+func (b *Broker) CreateTopic(topic string, partitions int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.createTopicLocked(topic, partitions)
+}
+
+func (b *Broker) createTopicLocked(topic string, partitions int) {
+	if _, ok := b.mu.topics[topic]; ok {
+		return
+	}
+	b.mu.topics[topic] = make([]int64, partitions)
+	b.refreshLocked()
+}
+
+// Produce appends a message to a partition of a topic and returns its
+// offset.
+func (b *Broker) Produce(topic string, partition int32, key, value []byte) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.produceLocked(topic, partition, key, value)
+}
+
+// Records returns the messages that have been written to the topic.
+func (b *Broker) Records(topic string) []Record {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Record(nil), b.mu.records[topic]...)
+}
+
+func (b *Broker) produceLocked(topic string, partition int32, key, value []byte) (int64, error) {
+	offsets, ok := b.mu.topics[topic]
+	if !ok {
+		return 0, errors.Errorf("unknown topic %s", topic)
+	}
+	if partition < 0 || int(partition) >= len(offsets) {
+		return 0, errors.Errorf("unknown partition %s[%d]", topic, partition)
+	}
+	offset := offsets[partition]
+	offsets[partition]++
+
+	var keyEnc sarama.Encoder
+	if key != nil {
+		keyEnc = sarama.ByteEncoder(key)
+	}
+	// The fetch response is internally synchronized.
+	b.fetch.SetMessageWithKey(topic, partition, offset, keyEnc, sarama.ByteEncoder(value))
+	b.mu.records[topic] = append(b.mu.records[topic], Record{
+		Key:       key,
+		Offset:    offset,
+		Partition: partition,
+		Value:     value,
+	})
+	b.refreshLocked()
+	return offset, nil
+}
+
+// refreshLocked replaces the metadata and offset responses, since those
+// types are not safe to update once they are in use.
+func (b *Broker) refreshLocked() {
+	metadata := sarama.NewMockMetadataResponse(b.t).
+		SetBroker(b.broker.Addr(), b.broker.BrokerID()).
+		SetController(b.broker.BrokerID())
+	offsets := sarama.NewMockOffsetResponse(b.t)
+	for topic, parts := range b.mu.topics {
+		for idx, next := range parts {
+			partition := int32(idx)
+			metadata.SetLeader(topic, partition, b.broker.BrokerID())
+			offsets.SetOffset(topic, partition, sarama.OffsetOldest, 0)
+			offsets.SetOffset(topic, partition, sarama.OffsetNewest, next)
+		}
+	}
+	b.broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"FetchRequest":    b.fetch,
+		"MetadataRequest": metadata,
+		"OffsetRequest":   offsets,
+	})
+}
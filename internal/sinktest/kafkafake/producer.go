@@ -0,0 +1,183 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafkafake
+
+import (
+	"sync"
+
+	"github.com/IBM/sarama"
+	"github.com/pkg/errors"
+)
+
+// Producer is an in-process implementation of [sarama.SyncProducer]
+// that writes to a Broker. A transactional Producer buffers messages
+// until the transaction is committed, so aborted messages are never
+// visible to consumers. Topics that do not exist are created with a
+// single partition.
+type Producer struct {
+	broker        *Broker
+	transactional bool
+
+	mu struct {
+		sync.Mutex
+		closed  bool
+		pending []*sarama.ProducerMessage
+		status  sarama.ProducerTxnStatusFlag
+	}
+}
+
+var _ sarama.SyncProducer = (*Producer)(nil)
+
+// NewProducer returns a Producer that writes to the Broker.
+func (b *Broker) NewProducer(transactional bool) *Producer {
+	ret := &Producer{broker: b, transactional: transactional}
+	ret.mu.status = sarama.ProducerTxnFlagReady
+	return ret
+}
+
+// AbortTxn implements sarama.SyncProducer and discards any messages
+// sent within the transaction.
+func (p *Producer) AbortTxn() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mu.status&sarama.ProducerTxnFlagInTransaction == 0 {
+		return sarama.ErrTransactionNotReady
+	}
+	p.mu.pending = nil
+	p.mu.status = sarama.ProducerTxnFlagReady
+	return nil
+}
+
+// AddMessageToTxn implements sarama.SyncProducer. It is unsupported.
+func (p *Producer) AddMessageToTxn(*sarama.ConsumerMessage, string, *string) error {
+	return errors.New("unimplemented")
+}
+
+// AddOffsetsToTxn implements sarama.SyncProducer. It is unsupported.
+func (p *Producer) AddOffsetsToTxn(map[string][]*sarama.PartitionOffsetMetadata, string) error {
+	return errors.New("unimplemented")
+}
+
+// BeginTxn implements sarama.SyncProducer.
+func (p *Producer) BeginTxn() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.transactional || p.mu.closed || p.mu.status != sarama.ProducerTxnFlagReady {
+		return sarama.ErrTransactionNotReady
+	}
+	p.mu.status = sarama.ProducerTxnFlagInTransaction
+	return nil
+}
+
+// Close implements sarama.SyncProducer. Any open transaction is
+// aborted.
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mu.closed = true
+	p.mu.pending = nil
+	return nil
+}
+
+// CommitTxn implements sarama.SyncProducer and writes all messages
+// sent within the transaction to the Broker.
+func (p *Producer) CommitTxn() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mu.status&sarama.ProducerTxnFlagInTransaction == 0 {
+		return sarama.ErrTransactionNotReady
+	}
+	err := p.writeLocked(p.mu.pending)
+	p.mu.pending = nil
+	p.mu.status = sarama.ProducerTxnFlagReady
+	return err
+}
+
+// IsTransactional implements sarama.SyncProducer.
+func (p *Producer) IsTransactional() bool { return p.transactional }
+
+// SendMessage implements sarama.SyncProducer. The partition and offset
+// will not be populated if the message is sent within a transaction.
+func (p *Producer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	if err := p.SendMessages([]*sarama.ProducerMessage{msg}); err != nil {
+		return 0, 0, err
+	}
+	return msg.Partition, msg.Offset, nil
+}
+
+// SendMessages implements sarama.SyncProducer.
+func (p *Producer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mu.closed {
+		return sarama.ErrClosedClient
+	}
+	if !p.transactional {
+		return p.writeLocked(msgs)
+	}
+	if p.mu.status&sarama.ProducerTxnFlagInTransaction == 0 {
+		return sarama.ErrTransactionNotReady
+	}
+	p.mu.pending = append(p.mu.pending, msgs...)
+	return nil
+}
+
+// TxnStatus implements sarama.SyncProducer.
+func (p *Producer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.mu.status
+}
+
+// writeLocked appends the messages to the Broker atomically with
+// respect to other Producers.
+func (p *Producer) writeLocked(msgs []*sarama.ProducerMessage) error {
+	b := p.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, msg := range msgs {
+		b.createTopicLocked(msg.Topic, 1)
+		key, err := encode(msg.Key)
+		if err != nil {
+			return err
+		}
+		value, err := encode(msg.Value)
+		if err != nil {
+			return err
+		}
+		partition, err := sarama.NewHashPartitioner(msg.Topic).
+			Partition(msg, int32(len(b.mu.topics[msg.Topic])))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		offset, err := b.produceLocked(msg.Topic, partition, key, value)
+		if err != nil {
+			return err
+		}
+		msg.Offset = offset
+		msg.Partition = partition
+	}
+	return nil
+}
+
+func encode(enc sarama.Encoder) ([]byte, error) {
+	if enc == nil {
+		return nil, nil
+	}
+	ret, err := enc.Encode()
+	return ret, errors.WithStack(err)
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package parquettest writes Parquet files with a flat schema, so that
+// tests can produce the files that a cloud-storage changefeed emits.
+package parquettest
+
+import (
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"github.com/pkg/errors"
+)
+
+// WriterOptions control the layout of the file produced by a Writer.
+type WriterOptions struct {
+	Compression  compress.Codec // Defaults to uncompressed pages.
+	DataPageV2   bool           // Write version 2 data pages.
+	RowGroupSize int            // The number of rows in each row group.
+}
+
+// A Writer produces Parquet files with a flat schema. Every field of
+// the schema must be a required or optional leaf.
+type Writer struct {
+	fields  []parquet.Field
+	numRows int
+	opts    WriterOptions
+	w       *parquet.Writer
+}
+
+// NewWriter returns a Writer that encodes rows with the schema.
+func NewWriter(out io.Writer, schema *parquet.Schema, opts WriterOptions) *Writer {
+	if opts.RowGroupSize <= 0 {
+		opts.RowGroupSize = 1024
+	}
+	options := []parquet.WriterOption{schema}
+	if opts.Compression != nil {
+		options = append(options, parquet.Compression(opts.Compression))
+	}
+	if opts.DataPageV2 {
+		options = append(options, parquet.DataPageVersion(2))
+	}
+	return &Writer{
+		fields: schema.Fields(),
+		opts:   opts,
+		w:      parquet.NewWriter(out, options...),
+	}
+}
+
+// Write encodes a row, which maps field names to values, and starts a
+// new row group once enough rows have been written. The values are
+// converted with [parquet.ValueOf]. A missing or nil value is written
+// as a null.
+func (w *Writer) Write(values map[string]any) error {
+	row := make(parquet.Row, len(w.fields))
+	for idx, field := range w.fields {
+		value := values[field.Name()]
+		definition := 0
+		if value != nil && field.Optional() {
+			definition = 1
+		}
+		row[idx] = parquet.ValueOf(value).Level(0, definition, idx)
+	}
+	if _, err := w.w.WriteRows([]parquet.Row{row}); err != nil {
+		return errors.WithStack(err)
+	}
+	w.numRows++
+	if w.numRows%w.opts.RowGroupSize == 0 {
+		return errors.WithStack(w.w.Flush())
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the file footer. It does not
+// close the underlying writer.
+func (w *Writer) Close() error {
+	return errors.WithStack(w.w.Close())
+}
@@ -0,0 +1,208 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package s3fake contains an in-process, in-memory stand-in for an
+// S3-compatible object store, such as MinIO. It supports only the
+// path-style requests that are issued by the objstore package.
+package s3fake
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Credentials accepted by the Server.
+const (
+	AccessKey = "fakeaccesskey"
+	SecretKey = "fakesecretkey"
+)
+
+// Server is an in-memory object store.
+type Server struct {
+	srv *httptest.Server
+
+	mu struct {
+		sync.Mutex
+		buckets  map[string]map[string][]byte
+		pageSize int
+	}
+}
+
+// New starts a Server. The caller should call Close when finished.
+func New() *Server {
+	ret := &Server{}
+	ret.mu.buckets = make(map[string]map[string][]byte)
+	ret.srv = httptest.NewServer(ret)
+	return ret
+}
+
+// Close shuts down the Server.
+func (s *Server) Close() { s.srv.Close() }
+
+// CreateBucket creates an empty bucket.
+func (s *Server) CreateBucket(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.mu.buckets[name]; !ok {
+		s.mu.buckets[name] = make(map[string][]byte)
+	}
+}
+
+// Keys returns the sorted names of the objects in the bucket.
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ret []string
+	for k := range s.mu.buckets[bucket] {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// SetPageSize limits the number of keys returned in each page of a
+// listing, to exercise pagination in clients.
+func (s *Server) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mu.pageSize = n
+}
+
+// URL returns an s3:// URL that can be passed to objstore.Open to
+// access the bucket via the Server.
+func (s *Server) URL(bucket, prefix string) string {
+	return fmt.Sprintf("s3://%s:%s@%s/%s?endpoint=%s",
+		AccessKey, SecretKey, bucket, prefix, s.srv.URL)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"),
+		"AWS4-HMAC-SHA256 Credential="+AccessKey+"/") {
+		writeError(w, http.StatusForbidden, "AccessDenied", "bad credentials")
+		return
+	}
+
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.mu.buckets[bucketName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", bucketName)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		s.listLocked(w, r, bucket)
+
+	case r.Method == http.MethodGet:
+		data, ok := bucket[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", key)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		_, _ = w.Write(data)
+
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		bucket[key] = data
+
+	case r.Method == http.MethodDelete:
+		delete(bucket, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+// listLocked implements a subset of ListObjectsV2.
+func (s *Server) listLocked(w http.ResponseWriter, r *http.Request, bucket map[string][]byte) {
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	after := q.Get("start-after")
+	if token := q.Get("continuation-token"); token != "" {
+		after = token
+	}
+	maxKeys := 1000
+	if s.mu.pageSize > 0 {
+		maxKeys = s.mu.pageSize
+	}
+	if s := q.Get("max-keys"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n > 0 && n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	var keys []string
+	for k := range bucket {
+		if strings.HasPrefix(k, prefix) && k > after {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	type contents struct {
+		Key  string
+		Size int
+	}
+	type result struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []contents
+		IsTruncated           bool
+		KeyCount              int
+		NextContinuationToken string `xml:",omitempty"`
+	}
+	var res result
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+		res.IsTruncated = true
+		res.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, k := range keys {
+		res.Contents = append(res.Contents, contents{Key: k, Size: len(bucket[k])})
+	}
+	res.KeyCount = len(keys)
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(&res)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	type errorDoc struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(&errorDoc{Code: code, Message: message})
+}
@@ -0,0 +1,361 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cdc
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/hamba/avro"
+	"github.com/pkg/errors"
+)
+
+// An AvroRegistry provides access to Avro writer schemas by id. It is
+// implemented by the schema registry client in the avro/registry
+// package.
+type AvroRegistry interface {
+	GetSchema(id int) (avro.Schema, error)
+}
+
+// avroMagicByte is the first byte of a message in the Confluent wire
+// format. It is followed by a four-byte, big-endian schema id.
+//
+// https://docs.confluent.io/platform/current/schema-registry/fundamentals/serdes-develop/index.html#wire-format
+const avroMagicByte = 0
+
+// avro decodes a sequence of Avro messages in the Confluent wire
+// format. Each message must have the shape of a CockroachDB changefeed
+// envelope created with the updated option: a record with after,
+// updated, and optional before fields, or a record with a resolved
+// field.
+//
+// The Avro envelope does not include the primary key of the row, so
+// the key is reconstructed from the row data using the target table's
+// primary key columns. If the request does not name a table, the name
+// of the record type of the after field is used.
+func (h *Handler) avro(ctx context.Context, req *request) error {
+	if h.Registry == nil {
+		return errors.New("a schema registry must be configured to accept avro payloads")
+	}
+	r := avro.NewReader(req.body, 4096)
+
+	keysByTable := &ident.TableMap[*ident.Map[int]]{}
+	toProcess := &ident.TableMap[[]types.Mutation]{}
+	var resolved hlc.Time
+	for {
+		var header [5]byte
+		if r.Read(header[:1]); r.Error == io.EOF {
+			break
+		}
+		if r.Read(header[1:]); r.Error != nil {
+			return errors.Wrap(r.Error, "could not read avro message header")
+		}
+		if header[0] != avroMagicByte {
+			return errors.Errorf("unexpected avro magic byte %d", header[0])
+		}
+		id := int(binary.BigEndian.Uint32(header[1:]))
+		schema, err := h.Registry.GetSchema(id)
+		if err != nil {
+			return errors.Wrapf(err, "could not retrieve avro schema id %d", id)
+		}
+		value := readAvro(r, schema)
+		if r.Error != nil {
+			return errors.Wrapf(r.Error, "could not decode avro message with schema id %d", id)
+		}
+		envelope, ok := value.(map[string]any)
+		if !ok {
+			return errors.Errorf("expecting an avro record, got %T", value)
+		}
+
+		if raw, ok := envelope["resolved"].(string); ok && raw != "" {
+			ts, err := hlc.Parse(raw)
+			if err != nil {
+				return err
+			}
+			if hlc.Compare(ts, resolved) > 0 {
+				resolved = ts
+			}
+			continue
+		}
+
+		var table ident.Table
+		switch t := req.target.(type) {
+		case ident.Table:
+			table = t
+		case ident.Schema:
+			name, err := avroTableName(schema)
+			if err != nil {
+				return err
+			}
+			table = ident.NewTable(t, ident.New(name))
+		default:
+			return errors.Errorf("unimplemented %T", t)
+		}
+
+		keys, ok := keysByTable.Get(table)
+		if !ok {
+			keys, err = h.primaryKey(ctx, table)
+			if err != nil {
+				return err
+			}
+			keysByTable.Put(table, keys)
+		}
+
+		mut, err := avroMutation(envelope, keys)
+		if err != nil {
+			return err
+		}
+		toProcess.Put(table, append(toProcess.GetZero(table), mut))
+	}
+
+	var err error
+	if toProcess.Len() > 0 {
+		if h.Config.Immediate {
+			err = h.processMutationsImmediate(ctx, req.target.Schema(), toProcess)
+		} else {
+			err = h.processMutationsDeferred(ctx, toProcess)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if resolved == (hlc.Time{}) {
+		return nil
+	}
+	req.timestamp = resolved
+	return h.resolved(ctx, req)
+}
+
+// avroTableName returns the name of the record type of the envelope's
+// after field.
+func avroTableName(envelope avro.Schema) (string, error) {
+	if rec, ok := envelope.(*avro.RecordSchema); ok {
+		for _, field := range rec.Fields() {
+			if field.Name() != "after" {
+				continue
+			}
+			branches := []avro.Schema{field.Type()}
+			if union, ok := field.Type().(*avro.UnionSchema); ok {
+				branches = union.Types()
+			}
+			for _, typ := range branches {
+				if ref, ok := typ.(*avro.RefSchema); ok {
+					typ = ref.Schema()
+				}
+				if after, ok := typ.(*avro.RecordSchema); ok {
+					return after.Name(), nil
+				}
+			}
+		}
+	}
+	return "", errors.Errorf("avro schema %s has no after record", envelope.String())
+}
+
+// avroMutation converts a decoded changefeed envelope into a mutation.
+func avroMutation(envelope map[string]any, keys *ident.Map[int]) (types.Mutation, error) {
+	updated, ok := envelope["updated"].(string)
+	if !ok || updated == "" {
+		return types.Mutation{}, errors.New(
+			"avro payload has no updated field; add the updated option to the changefeed")
+	}
+	timestamp, err := hlc.Parse(updated)
+	if err != nil {
+		return types.Mutation{}, err
+	}
+	mut := types.Mutation{Time: timestamp}
+
+	after, _ := envelope["after"].(map[string]any)
+	before, _ := envelope["before"].(map[string]any)
+	if after != nil {
+		if mut.Data, err = json.Marshal(after); err != nil {
+			return types.Mutation{}, errors.WithStack(err)
+		}
+	}
+	if before != nil {
+		if mut.Before, err = json.Marshal(before); err != nil {
+			return types.Mutation{}, errors.WithStack(err)
+		}
+	}
+
+	// The key is extracted from the before data of a deletion.
+	keySource := after
+	if keySource == nil {
+		keySource = before
+	}
+	if keySource == nil {
+		return types.Mutation{}, errors.New(
+			"avro deletion has no before data; add the diff option to the changefeed")
+	}
+	var values ident.Map[any]
+	for k, v := range keySource {
+		values.Put(ident.New(k), v)
+	}
+	keyValues := make([]any, keys.Len())
+	if err := keys.Range(func(k ident.Ident, pos int) error {
+		v, ok := values.Get(k)
+		if !ok {
+			return errors.Errorf("missing primary key: %s", k)
+		}
+		keyValues[pos] = v
+		return nil
+	}); err != nil {
+		return types.Mutation{}, err
+	}
+	if mut.Key, err = json.Marshal(keyValues); err != nil {
+		return types.Mutation{}, errors.WithStack(err)
+	}
+	return mut, nil
+}
+
+// readAvro decodes a value from the reader. Records and maps are
+// decoded as map[string]any, arrays as []any, and unions as the value
+// of the selected branch. Logical types are mapped onto the JSON shapes
+// that ColData.Parse expects for the corresponding column types:
+//   - decimal: a json.Number
+//   - date: "2006-01-02"
+//   - time-millis, time-micros: "15:04:05.999999"
+//   - timestamp-millis, timestamp-micros: an RFC 3339 string in UTC
+//   - uuid: the canonical string representation
+//
+// Other bytes and fixed values are encoded as \x-prefixed hex strings.
+//
+// Scalar values are read by the avro package. Bytes and fixed values
+// are read here, since the avro package converts decimals through an
+// int64 and would lose precision. Any decoding error is recorded in
+// the reader.
+func readAvro(r *avro.Reader, schema avro.Schema) any {
+	switch schema.Type() {
+	case avro.Null:
+		return nil
+
+	case avro.Ref:
+		return readAvro(r, schema.(*avro.RefSchema).Schema())
+
+	case avro.Record:
+		fields := schema.(*avro.RecordSchema).Fields()
+		ret := make(map[string]any, len(fields))
+		for _, field := range fields {
+			ret[field.Name()] = readAvro(r, field.Type())
+		}
+		return ret
+
+	case avro.Union:
+		branches := schema.(*avro.UnionSchema).Types()
+		idx := r.ReadLong()
+		if idx < 0 || idx >= int64(len(branches)) {
+			r.ReportError("readAvro", "unknown union type")
+			return nil
+		}
+		return readAvro(r, branches[idx])
+
+	case avro.Array:
+		items := schema.(*avro.ArraySchema).Items()
+		ret := make([]any, 0)
+		r.ReadArrayCB(func(r *avro.Reader) bool {
+			ret = append(ret, readAvro(r, items))
+			return true
+		})
+		return ret
+
+	case avro.Map:
+		values := schema.(*avro.MapSchema).Values()
+		ret := make(map[string]any)
+		r.ReadMapCB(func(r *avro.Reader, key string) bool {
+			ret[key] = readAvro(r, values)
+			return true
+		})
+		return ret
+
+	case avro.Bytes:
+		return avroBytes(schema, r.ReadBytes())
+
+	case avro.Fixed:
+		buf := make([]byte, schema.(*avro.FixedSchema).Size())
+		r.Read(buf)
+		return avroBytes(schema, buf)
+	}
+
+	switch t := r.ReadNext(schema).(type) {
+	case int:
+		return int64(t)
+	case float32:
+		return float64(t)
+	case time.Duration:
+		return time.Time{}.Add(t).Format("15:04:05.999999")
+	case time.Time:
+		if avroLogicalType(schema) == avro.Date {
+			return t.Format("2006-01-02")
+		}
+		return t.Format(time.RFC3339Nano)
+	default:
+		return t
+	}
+}
+
+// avroBytes maps the contents of a bytes or fixed value onto its
+// logical type.
+func avroBytes(schema avro.Schema, buf []byte) any {
+	if avroLogicalType(schema) == avro.Decimal {
+		scale := schema.(avro.LogicalTypeSchema).Logical().(*avro.DecimalLogicalSchema).Scale()
+		return avroDecimal(buf, scale)
+	}
+	return `\x` + hex.EncodeToString(buf)
+}
+
+// avroDecimal interprets buf as a big-endian, two's-complement
+// unscaled integer.
+func avroDecimal(buf []byte, scale int) json.Number {
+	unscaled := new(big.Int).SetBytes(buf)
+	if len(buf) > 0 && buf[0]&0x80 != 0 {
+		// Negative: subtract 2^(8*len).
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*len(buf))))
+	}
+	if scale <= 0 {
+		return json.Number(unscaled.String())
+	}
+
+	digits := new(big.Int).Abs(unscaled).String()
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	point := len(digits) - scale
+	ret := digits[:point] + "." + digits[point:]
+	if unscaled.Sign() < 0 {
+		ret = "-" + ret
+	}
+	return json.Number(ret)
+}
+
+// avroLogicalType returns the logical type of the schema, if any.
+func avroLogicalType(schema avro.Schema) avro.LogicalType {
+	if lts, ok := schema.(avro.LogicalTypeSchema); ok {
+		if logical := lts.Logical(); logical != nil {
+			return logical.Type()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,391 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cdc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/avrotest"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/google/uuid"
+	"github.com/hamba/avro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAvroSchema = `{
+  "type": "record",
+  "name": "envelope",
+  "namespace": "cdc",
+  "fields": [
+    {"name": "after", "type": ["null", {
+      "type": "record",
+      "name": "my_table",
+      "fields": [
+        {"name": "pk", "type": "long"},
+        {"name": "flag", "type": "boolean"},
+        {"name": "i", "type": "int"},
+        {"name": "f", "type": "float"},
+        {"name": "d", "type": "double"},
+        {"name": "s", "type": ["null", "string"]},
+        {"name": "b", "type": "bytes"},
+        {"name": "color", "type": {"type": "enum", "name": "color", "symbols": ["RED", "GREEN"]}},
+        {"name": "arr", "type": {"type": "array", "items": "int"}},
+        {"name": "m", "type": {"type": "map", "values": "string"}},
+        {"name": "dec", "type": {"type": "bytes", "logicalType": "decimal", "precision": 30, "scale": 2}},
+        {"name": "neg", "type": {"type": "fixed", "name": "neg", "size": 2, "logicalType": "decimal", "precision": 4, "scale": 3}},
+        {"name": "day", "type": {"type": "int", "logicalType": "date"}},
+        {"name": "tod", "type": {"type": "long", "logicalType": "time-micros"}},
+        {"name": "ts", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+        {"name": "tsm", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+        {"name": "u", "type": {"type": "string", "logicalType": "uuid"}},
+        {"name": "again", "type": ["null", "color"]},
+        {"name": "next", "type": ["null", "my_table"]}
+      ]
+    }]}
+  ]
+}`
+
+func TestReadAvro(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	schema, err := avro.Parse(testAvroSchema)
+	r.NoError(err)
+	name, err := avroTableName(schema)
+	r.NoError(err)
+	a.Equal("my_table", name)
+
+	ts := time.Date(2023, 4, 5, 6, 7, 8, 9000, time.UTC)
+	u := uuid.MustParse("7f9c24e8-3b12-4fef-91e0-56a2d5a246ec")
+	// This value cannot be represented by an int64.
+	dec, ok := new(big.Rat).SetString("12345678901234567890123.45")
+	r.True(ok)
+
+	inner := map[string]any{
+		"pk":    int64(2),
+		"flag":  false,
+		"i":     0,
+		"f":     float32(0),
+		"d":     float64(0),
+		"s":     map[string]any(nil),
+		"b":     []byte{},
+		"color": "RED",
+		"arr":   []int{},
+		"m":     map[string]string{},
+		"dec":   new(big.Rat),
+		"neg":   new(big.Rat),
+		"day":   time.Unix(0, 0).UTC(),
+		"tod":   time.Duration(0),
+		"ts":    time.Unix(0, 0).UTC(),
+		"tsm":   time.Unix(0, 0).UTC(),
+		"u":     u.String(),
+		"again": map[string]any(nil),
+		"next":  map[string]any(nil),
+	}
+	value := map[string]any{
+		"pk":    int64(1),
+		"flag":  true,
+		"i":     -42,
+		"f":     float32(1.5),
+		"d":     float64(-2.25),
+		"s":     map[string]any{"string": "hello"},
+		"b":     []byte{0xca, 0xfe},
+		"color": "GREEN",
+		"arr":   []int{1, 2, 3},
+		"m":     map[string]string{"k": "v"},
+		"dec":   dec,
+		"neg":   big.NewRat(-2, 1000),
+		"day":   time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC),
+		"tod":   time.Hour + 2*time.Minute + 3*time.Second + 4*time.Microsecond,
+		"ts":    ts,
+		"tsm":   ts,
+		"u":     u.String(),
+		"again": map[string]any{"cdc.color": "RED"},
+		"next":  map[string]any{"cdc.my_table": inner},
+	}
+	buf, err := avro.Marshal(schema, map[string]any{
+		"after": map[string]any{"cdc.my_table": value},
+	})
+	r.NoError(err)
+
+	reader := avro.NewReader(nil, 0).Reset(buf)
+	decoded := readAvro(reader, schema)
+	r.NoError(reader.Error)
+	envelope, ok := decoded.(map[string]any)
+	r.True(ok)
+	m, ok := envelope["after"].(map[string]any)
+	r.True(ok)
+
+	a.Equal(int64(1), m["pk"])
+	a.Equal(true, m["flag"])
+	a.Equal(int64(-42), m["i"])
+	a.Equal(1.5, m["f"])
+	a.Equal(-2.25, m["d"])
+	a.Equal("hello", m["s"])
+	a.Equal(`\xcafe`, m["b"])
+	a.Equal("GREEN", m["color"])
+	a.Equal([]any{int64(1), int64(2), int64(3)}, m["arr"])
+	a.Equal(map[string]any{"k": "v"}, m["m"])
+	a.Equal(json.Number("12345678901234567890123.45"), m["dec"])
+	a.Equal(json.Number("-0.002"), m["neg"])
+	a.Equal("2023-04-05", m["day"])
+	a.Equal("01:02:03.000004", m["tod"])
+	a.Equal("2023-04-05T06:07:08.000009Z", m["ts"])
+	a.Equal("2023-04-05T06:07:08Z", m["tsm"])
+	a.Equal(u.String(), m["u"])
+	a.Equal("RED", m["again"])
+
+	next, ok := m["next"].(map[string]any)
+	r.True(ok)
+	a.Equal(int64(2), next["pk"])
+	a.Nil(next["s"])
+	a.Equal(json.Number("0.00"), next["dec"])
+	a.Equal(`\x`, next["b"])
+	a.Equal("1970-01-01", next["day"])
+	a.Nil(next["next"])
+
+	// Truncated input should be reported.
+	reader = avro.NewReader(nil, 0).Reset(buf[:20])
+	readAvro(reader, schema)
+	a.Error(reader.Error)
+}
+
+func TestAvroRegistry(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	reg, err := ProvideSchemaRegistry(&Config{})
+	r.NoError(err)
+	a.Nil(reg)
+
+	mem := &avrotest.Registry{}
+	id, err := mem.Register(testAvroSchema)
+	r.NoError(err)
+	expected, err := mem.GetSchema(id)
+	r.NoError(err)
+
+	srv := httptest.NewServer(mem)
+	defer srv.Close()
+	reg, err = ProvideSchemaRegistry(&Config{SchemaRegistryURL: srv.URL + "/"})
+	r.NoError(err)
+
+	schema, err := reg.GetSchema(id)
+	r.NoError(err)
+	a.Equal(expected.Fingerprint(), schema.Fingerprint())
+
+	_, err = reg.GetSchema(id + 1)
+	a.ErrorContains(err, "404")
+}
+
+func TestAvroMutation(t *testing.T) {
+	keys := &ident.Map[int]{}
+	keys.Put(ident.New("pk"), 0)
+
+	tcs := []struct {
+		name     string
+		envelope map[string]any
+		expected types.Mutation
+		wantErr  string
+	}{
+		{
+			name: "upsert",
+			envelope: map[string]any{
+				"after":   map[string]any{"PK": int64(1), "v": "a"},
+				"before":  map[string]any{"PK": int64(1), "v": "b"},
+				"updated": "1.0000000002",
+			},
+			expected: types.Mutation{
+				Before: []byte(`{"PK":1,"v":"b"}`),
+				Data:   []byte(`{"PK":1,"v":"a"}`),
+				Key:    []byte(`[1]`),
+				Time:   hlc.New(1, 2),
+			},
+		},
+		{
+			name: "delete",
+			envelope: map[string]any{
+				"before":  map[string]any{"pk": int64(1), "v": "b"},
+				"updated": "1.0",
+			},
+			expected: types.Mutation{
+				Before: []byte(`{"pk":1,"v":"b"}`),
+				Key:    []byte(`[1]`),
+				Time:   hlc.New(1, 0),
+			},
+		},
+		{
+			name:     "delete without diff",
+			envelope: map[string]any{"updated": "1.0"},
+			wantErr:  "add the diff option",
+		},
+		{
+			name:     "no updated",
+			envelope: map[string]any{"after": map[string]any{"pk": int64(1)}},
+			wantErr:  "add the updated option",
+		},
+		{
+			name: "missing key",
+			envelope: map[string]any{
+				"after":   map[string]any{"v": "a"},
+				"updated": "1.0",
+			},
+			wantErr: "missing primary key: pk",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			mut, err := avroMutation(tc.envelope, keys)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, mut)
+		})
+	}
+}
+
+func testAvroHandler(t *testing.T, cfg *fixtureConfig) {
+	t.Helper()
+	fixture, tableInfo := createFixture(t, cfg)
+	ctx := fixture.Context
+	h := fixture.Handler
+	schema := tableInfo.Name().Schema()
+
+	reg := &avrotest.Registry{}
+	h.Registry = reg
+	tableName := tableInfo.Name().Table().Raw()
+
+	// The record type of the after field names the target table.
+	envelopeID, err := reg.Register(fmt.Sprintf(`{
+  "type": "record",
+  "name": "envelope",
+  "fields": [
+    {"name": "after", "type": ["null", {
+      "type": "record",
+      "name": %[1]q,
+      "fields": [{"name": "pk", "type": "long"}, {"name": "v", "type": "long"}]
+    }]},
+    {"name": "before", "type": ["null", %[1]q]},
+    {"name": "updated", "type": ["null", "string"]}
+  ]
+}`, tableName))
+	require.NoError(t, err)
+	resolvedID, err := reg.Register(
+		`{"type": "record", "name": "resolved", "fields": [{"name": "resolved", "type": "string"}]}`)
+	require.NoError(t, err)
+
+	// row returns the after or before value of an envelope.
+	row := func(pk, v int64) map[string]any {
+		return map[string]any{tableName: map[string]any{"pk": pk, "v": v}}
+	}
+	// envelope returns an envelope for an update or a deletion.
+	envelope := func(after, before map[string]any, updated string) map[string]any {
+		return map[string]any{
+			"after":   after,
+			"before":  before,
+			"updated": map[string]any{"string": updated},
+		}
+	}
+
+	// encode returns a sequence of messages. A string is encoded as a
+	// resolved timestamp.
+	encode := func(values ...any) *bytes.Reader {
+		var buf []byte
+		for _, value := range values {
+			id := envelopeID
+			if ts, ok := value.(string); ok {
+				id = resolvedID
+				value = map[string]any{"resolved": ts}
+			}
+			s, err := reg.GetSchema(id)
+			require.NoError(t, err)
+			buf, err = avrotest.AppendMessage(buf, id, s, value)
+			require.NoError(t, err)
+		}
+		return bytes.NewReader(buf)
+	}
+
+	// In async mode, we want to reach into the implementation to
+	// force the marked, resolved timestamp to be operated on.
+	maybeFlush := func(expect hlc.Time) error {
+		if cfg.immediate {
+			return nil
+		}
+		loop, resolver, err := h.Resolvers.get(ctx, schema)
+		if err != nil {
+			return err
+		}
+		waitFor := &resolvedStamp{CommittedTime: expect}
+		resolver.marked.Notify()
+		for cp, updated := loop.GetConsistentPoint(); cp.Less(waitFor); {
+			select {
+			case <-updated:
+				cp, updated = loop.GetConsistentPoint()
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	a := assert.New(t)
+	r := require.New(t)
+
+	r.NoError(h.avro(ctx, &request{
+		target: schema,
+		body: encode(
+			envelope(row(42, 99), nil, "1.0"),
+			envelope(row(99, 42), nil, "1.0"),
+			"2.0",
+		),
+	}))
+	r.NoError(maybeFlush(hlc.New(2, 0)))
+
+	ct, err := tableInfo.RowCount(ctx)
+	r.NoError(err)
+	a.Equal(2, ct)
+
+	// Delete the rows via a table-specific request.
+	r.NoError(h.avro(ctx, &request{
+		target: tableInfo.Name(),
+		body: encode(
+			envelope(nil, row(42, 99), "3.0"),
+			envelope(nil, row(99, 42), "3.0"),
+			"4.0",
+		),
+	}))
+	r.NoError(maybeFlush(hlc.New(4, 0)))
+
+	ct, err = tableInfo.RowCount(ctx)
+	r.NoError(err)
+	a.Equal(0, ct)
+
+	// A registry is required.
+	h.Registry = nil
+	a.ErrorContains(h.avro(ctx, &request{target: schema, body: encode("5.0")}),
+		"schema registry must be configured")
+}
@@ -62,6 +62,10 @@ type Config struct {
 	// The name of the resolved_timestamps table.
 	MetaTableName ident.Ident
 
+	// The base URL of a Confluent-compatible schema registry, used to
+	// decode Avro payloads.
+	SchemaRegistryURL string
+
 	// The number of rows to retrieve when loading staged data.
 	SelectBatchSize int
 
@@ -89,6 +93,8 @@ func (c *Config) Bind(f *pflag.FlagSet) {
 			"increase when source cluster has large blob values")
 	f.Var(ident.NewValue("resolved_timestamps", &c.MetaTableName), "metaTable",
 		"the name of the table in which to store resolved timestamps")
+	f.StringVar(&c.SchemaRegistryURL, "schemaRegistryURL", "",
+		"the base URL of a Confluent-compatible schema registry; enables Avro payloads")
 	f.IntVar(&c.SelectBatchSize, "selectBatchSize", defaultSelectBatchSize,
 		"the number of rows to select at once when reading staged data")
 	f.DurationVar(&c.RetireOffset, "retireOffset", 0,
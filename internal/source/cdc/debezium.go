@@ -0,0 +1,485 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Debezium operation codes.
+// https://debezium.io/documentation/reference/stable/connectors/postgresql.html#postgresql-create-events
+const (
+	debeziumCreate   = "c"
+	debeziumDelete   = "d"
+	debeziumMessage  = "m"
+	debeziumRead     = "r" // Snapshot.
+	debeziumTruncate = "t"
+	debeziumUpdate   = "u"
+)
+
+// Debezium transaction metadata statuses.
+// https://debezium.io/documentation/reference/stable/connectors/postgresql.html#postgresql-transaction-metadata
+const (
+	debeziumBegin = "BEGIN"
+	debeziumEnd   = "END"
+)
+
+// debeziumEvent is the union of a Debezium data-change envelope and a
+// transaction metadata event. Unknown fields are ignored, since the
+// envelope varies by connector.
+type debeziumEvent struct {
+	// Data-change fields.
+	After  json.RawMessage `json:"after"`
+	Before json.RawMessage `json:"before"`
+	Op     string          `json:"op"`
+	Source struct {
+		LSN   json.Number `json:"lsn"`
+		Table string      `json:"table"`
+		TsMs  int64       `json:"ts_ms"`
+		TsNs  int64       `json:"ts_ns"`
+		TsUs  int64       `json:"ts_us"`
+	} `json:"source"`
+	Transaction *struct {
+		ID string `json:"id"`
+	} `json:"transaction"`
+
+	// Transaction metadata fields.
+	EventCount int64  `json:"event_count"`
+	ID         string `json:"id"`
+	Status     string `json:"status"`
+}
+
+// isMetadata returns true if the event is a transaction BEGIN or END
+// marker.
+func (e *debeziumEvent) isMetadata() bool {
+	return e.Status != ""
+}
+
+// time returns the most precise source timestamp that is available for
+// a data-change event. The time at which the event is staged is
+// assigned by debeziumSchemaState.assign.
+func (e *debeziumEvent) time() (hlc.Time, error) {
+	var nanos int64
+	switch {
+	case e.Source.TsNs != 0:
+		nanos = e.Source.TsNs
+	case e.Source.TsUs != 0:
+		nanos = e.Source.TsUs * 1_000
+	case e.Source.TsMs != 0:
+		nanos = e.Source.TsMs * 1_000_000
+	default:
+		return hlc.Time{}, errors.New("debezium event is missing source.ts_ms")
+	}
+	return hlc.New(nanos, 0), nil
+}
+
+// decodeDebeziumEvents reads a sequence of Debezium events. The events
+// may be bare envelopes, or may be wrapped in a {"schema":...,
+// "payload":...} object if the JSON converter has schemas enabled.
+func decodeDebeziumEvents(r io.Reader) ([]*debeziumEvent, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var ret []*debeziumEvent
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return ret, nil
+			}
+			return nil, errors.Wrap(err, "could not decode debezium payload")
+		}
+
+		var wrapper struct {
+			Payload json.RawMessage `json:"payload"`
+			Schema  json.RawMessage `json:"schema"`
+		}
+		if err := json.Unmarshal(raw, &wrapper); err != nil {
+			return nil, errors.Wrap(err, "could not decode debezium payload")
+		}
+		if len(wrapper.Payload) > 0 && len(wrapper.Schema) > 0 {
+			raw = wrapper.Payload
+		}
+
+		evt := &debeziumEvent{}
+		inner := json.NewDecoder(bytes.NewReader(raw))
+		inner.UseNumber()
+		if err := inner.Decode(evt); err != nil {
+			return nil, errors.Wrap(err, "could not decode debezium event")
+		}
+		ret = append(ret, evt)
+	}
+}
+
+// debezium accepts Debezium JSON envelopes, such as those sent by
+// Debezium Server's HTTP sink. The request body may contain any number
+// of data-change events and transaction metadata events.
+//
+// Debezium messages do not include the primary key of the row as part
+// of the envelope, so the key is reconstructed from the row data using
+// the target table's primary key columns.
+//
+// Source timestamps only have millisecond precision for most
+// connectors, so transactions may share a timestamp. Each event is
+// staged at a time that is strictly greater than that of any event
+// previously received for the schema. This ensures that a transaction
+// cannot be staged below the resolved timestamp of a transaction that
+// was received before it.
+//
+// If the connector has provide.transaction.metadata enabled, a
+// resolved timestamp is emitted once the END event for a transaction
+// and all of its data events have been received. Transactions are
+// resolved in the order in which they were first seen. Without
+// transaction metadata, no resolved timestamps are generated, so
+// immediate mode should be used.
+//
+// The progress of each transaction is persisted in the memo table, so
+// that an open transaction can be completed after a restart. Requests
+// for a schema must be sent to a single cdc-sink instance, since the
+// persisted state is only read when the schema is first used.
+func (h *Handler) debezium(ctx context.Context, req *request) error {
+	events, err := decodeDebeziumEvents(req.body)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	target := req.target.Schema()
+
+	state, unlock, err := h.Transactions.acquire(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	times, err := state.assign(events)
+	if err != nil {
+		return err
+	}
+
+	keysByTable := &ident.TableMap[*ident.Map[int]]{}
+	toProcess := &ident.TableMap[[]types.Mutation]{}
+	for idx, evt := range events {
+		if evt.isMetadata() {
+			continue
+		}
+
+		switch evt.Op {
+		case debeziumCreate, debeziumDelete, debeziumRead, debeziumUpdate:
+		case debeziumMessage, debeziumTruncate:
+			log.WithField("op", evt.Op).Trace("ignoring debezium event")
+			continue
+		default:
+			return errors.Errorf("unknown debezium op %q", evt.Op)
+		}
+
+		var table ident.Table
+		switch t := req.target.(type) {
+		case ident.Schema:
+			if evt.Source.Table == "" {
+				return errors.New("debezium event is missing source.table")
+			}
+			table = ident.NewTable(t, ident.New(evt.Source.Table))
+		case ident.Table:
+			table = t
+		default:
+			return errors.Errorf("unimplemented %T", t)
+		}
+
+		keys, ok := keysByTable.Get(table)
+		if !ok {
+			keys, err = h.primaryKey(ctx, table)
+			if err != nil {
+				return err
+			}
+			keysByTable.Put(table, keys)
+		}
+
+		mut, err := evt.asMutation(keys, times[idx])
+		if err != nil {
+			return err
+		}
+		toProcess.Put(table, append(toProcess.GetZero(table), mut))
+	}
+
+	if toProcess.Len() > 0 {
+		if h.Config.Immediate {
+			err = h.processMutationsImmediate(ctx, target, toProcess)
+		} else {
+			err = h.processMutationsDeferred(ctx, toProcess)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only update the transaction state once the data has been
+	// successfully processed.
+	resolved, ok := state.update(events, times)
+	if err := h.Transactions.store(ctx, target, state); err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	req.timestamp = resolved
+	return h.resolved(ctx, req)
+}
+
+// asMutation converts a data-change event into a mutation that will be
+// staged at the given time.
+func (e *debeziumEvent) asMutation(
+	keys *ident.Map[int], timestamp hlc.Time,
+) (types.Mutation, error) {
+	// The key is extracted from the before data of a deletion.
+	mut := types.Mutation{Time: timestamp}
+	keySource := e.After
+	if e.Op == debeziumDelete {
+		keySource = e.Before
+	} else {
+		mut.Data = e.After
+	}
+	if !isNullJSON(e.Before) {
+		mut.Before = e.Before
+	}
+	if isNullJSON(keySource) {
+		return types.Mutation{}, errors.Errorf(
+			"debezium %q event has no row data from which to extract a key", e.Op)
+	}
+
+	var values ident.Map[json.RawMessage]
+	if err := json.Unmarshal(keySource, &values); err != nil {
+		return types.Mutation{}, errors.Wrap(err, "could not parse debezium row data")
+	}
+	keyValues := make([]json.RawMessage, keys.Len())
+	if err := keys.Range(func(k ident.Ident, pos int) error {
+		v, ok := values.Get(k)
+		if !ok {
+			return errors.Errorf("missing primary key: %s", k)
+		}
+		keyValues[pos] = v
+		return nil
+	}); err != nil {
+		return types.Mutation{}, err
+	}
+	key, err := json.Marshal(keyValues)
+	if err != nil {
+		return types.Mutation{}, errors.WithStack(err)
+	}
+	mut.Key = key
+
+	if e.Source.LSN != "" {
+		mut.Meta = map[string]any{"lsn": e.Source.LSN.String()}
+	}
+	return mut, nil
+}
+
+func isNullJSON(data json.RawMessage) bool {
+	return len(data) == 0 || bytes.Equal(data, []byte("null"))
+}
+
+// DebeziumTransactions tracks the progress of Debezium transactions so
+// that a resolved timestamp can be emitted once a transaction has been
+// completely received. The state for each schema is persisted in the
+// memo table.
+type DebeziumTransactions struct {
+	memo        types.Memo
+	stagingPool *types.StagingPool
+
+	mu struct {
+		sync.Mutex
+		schemas ident.SchemaMap[*debeziumSchemaState]
+	}
+}
+
+// ProvideDebeziumTransactions is called by Wire.
+func ProvideDebeziumTransactions(
+	memo types.Memo, stagingPool *types.StagingPool,
+) *DebeziumTransactions {
+	return &DebeziumTransactions{memo: memo, stagingPool: stagingPool}
+}
+
+// acquire returns the state for the target schema, loading it from the
+// memo table if necessary. The state is locked until the returned
+// function is called.
+func (t *DebeziumTransactions) acquire(
+	ctx context.Context, target ident.Schema,
+) (*debeziumSchemaState, func(), error) {
+	t.mu.Lock()
+	state, ok := t.mu.schemas.Get(target)
+	if !ok {
+		state = &debeziumSchemaState{}
+		t.mu.schemas.Put(target, state)
+	}
+	t.mu.Unlock()
+
+	state.mu.Lock()
+	if !state.loaded {
+		data, err := t.memo.Get(ctx, t.stagingPool, debeziumMemoKey(target))
+		if err != nil {
+			state.mu.Unlock()
+			return nil, nil, err
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, state); err != nil {
+				state.mu.Unlock()
+				return nil, nil, errors.Wrapf(err,
+					"could not decode debezium state for %s", target)
+			}
+		}
+		state.loaded = true
+	}
+	return state, state.mu.Unlock, nil
+}
+
+// store persists the state of the target schema. The state must have
+// been acquired by the caller.
+func (t *DebeziumTransactions) store(
+	ctx context.Context, target ident.Schema, state *debeziumSchemaState,
+) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return t.memo.Put(ctx, t.stagingPool, debeziumMemoKey(target), data)
+}
+
+func debeziumMemoKey(target ident.Schema) string {
+	return "debezium." + target.Raw()
+}
+
+// debeziumSchemaState holds the open transactions for a target schema.
+type debeziumSchemaState struct {
+	LastMark hlc.Time      `json:"mark"`  // The last resolved timestamp.
+	LastTime hlc.Time      `json:"time"`  // The last time assigned to an event.
+	Order    []*debeziumTx `json:"order"` // Open transactions, in the order seen.
+
+	byID   map[string]*debeziumTx
+	loaded bool
+	mu     sync.Mutex
+}
+
+type debeziumTx struct {
+	Ended    bool     `json:"ended,omitempty"`
+	Expected int64    `json:"expected,omitempty"` // Set by the END event.
+	ID       string   `json:"id"`
+	MaxTime  hlc.Time `json:"maxTime"`
+	Received int64    `json:"received,omitempty"`
+}
+
+// assign returns the time at which each data-change event should be
+// staged. Each time is strictly greater than the time assigned to the
+// preceding event, or to any event previously recorded by update. The
+// state is not modified until update is called.
+func (s *debeziumSchemaState) assign(events []*debeziumEvent) ([]hlc.Time, error) {
+	times := make([]hlc.Time, len(events))
+	last := s.LastTime
+	for idx, evt := range events {
+		if evt.isMetadata() {
+			continue
+		}
+		ts, err := evt.time()
+		if err != nil {
+			return nil, err
+		}
+		if hlc.Compare(ts, last) <= 0 {
+			ts = hlc.New(last.Nanos(), last.Logical()+1)
+		}
+		times[idx] = ts
+		last = ts
+	}
+	return times, nil
+}
+
+// update records the data and metadata events that have been
+// processed, using the times returned from assign. If one or more
+// transactions have been completed, the timestamp to resolve is
+// returned.
+func (s *debeziumSchemaState) update(
+	events []*debeziumEvent, times []hlc.Time,
+) (hlc.Time, bool) {
+	// The index is not persisted.
+	if s.byID == nil {
+		s.byID = make(map[string]*debeziumTx, len(s.Order))
+		for _, tx := range s.Order {
+			s.byID[tx.ID] = tx
+		}
+	}
+	get := func(id string) *debeziumTx {
+		tx, ok := s.byID[id]
+		if !ok {
+			tx = &debeziumTx{ID: id}
+			s.byID[id] = tx
+			s.Order = append(s.Order, tx)
+		}
+		return tx
+	}
+
+	for idx, evt := range events {
+		if !evt.isMetadata() && hlc.Compare(times[idx], s.LastTime) > 0 {
+			s.LastTime = times[idx]
+		}
+		switch {
+		case evt.Status == debeziumBegin:
+			get(evt.ID)
+		case evt.Status == debeziumEnd:
+			tx := get(evt.ID)
+			tx.Ended = true
+			tx.Expected = evt.EventCount
+		case evt.isMetadata():
+			log.WithField("status", evt.Status).Trace("ignoring debezium transaction status")
+		case evt.Transaction != nil:
+			tx := get(evt.Transaction.ID)
+			tx.Received++
+			if hlc.Compare(times[idx], tx.MaxTime) > 0 {
+				tx.MaxTime = times[idx]
+			}
+		}
+	}
+
+	// Pop completed transactions from the head of the queue.
+	var resolved hlc.Time
+	for len(s.Order) > 0 {
+		head := s.Order[0]
+		if !head.Ended || head.Received < head.Expected {
+			break
+		}
+		s.Order = s.Order[1:]
+		delete(s.byID, head.ID)
+		if hlc.Compare(head.MaxTime, resolved) > 0 {
+			resolved = head.MaxTime
+		}
+	}
+
+	// Resolved timestamps must advance.
+	if hlc.Compare(resolved, s.LastMark) <= 0 {
+		return hlc.Time{}, false
+	}
+	s.LastMark = resolved
+	return resolved, true
+}
@@ -0,0 +1,312 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cdc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebeziumMutation(t *testing.T) {
+	keys := &ident.Map[int]{}
+	keys.Put(ident.New("pk"), 0)
+
+	tcs := []struct {
+		name     string
+		payload  string
+		expected types.Mutation
+		wantErr  string
+	}{
+		{
+			name:    "create",
+			payload: `{"op":"c","after":{"pk":1,"v":2},"source":{"ts_ms":1}}`,
+			expected: types.Mutation{
+				Data: []byte(`{"pk":1,"v":2}`),
+				Key:  []byte(`[1]`),
+				Time: hlc.New(1_000_000, 0),
+			},
+		},
+		{
+			name: "update with schema and transaction",
+			payload: `{"schema":{"type":"struct"},"payload":{"op":"u","before":{"pk":1,"v":2},` +
+				`"after":{"pk":1,"v":3},"source":{"ts_ms":1,"ts_us":1001,"lsn":1234},` +
+				`"transaction":{"id":"tx","total_order":2}}}`,
+			expected: types.Mutation{
+				Before: []byte(`{"pk":1,"v":2}`),
+				Data:   []byte(`{"pk":1,"v":3}`),
+				Key:    []byte(`[1]`),
+				Meta:   map[string]any{"lsn": "1234"},
+				Time:   hlc.New(1_001_000, 0),
+			},
+		},
+		{
+			name:    "delete",
+			payload: `{"op":"d","before":{"pk":1,"v":3},"after":null,"source":{"ts_ns":5}}`,
+			expected: types.Mutation{
+				Before: []byte(`{"pk":1,"v":3}`),
+				Key:    []byte(`[1]`),
+				Time:   hlc.New(5, 0),
+			},
+		},
+		{
+			name:    "delete without before",
+			payload: `{"op":"d","before":null,"source":{"ts_ms":1}}`,
+			wantErr: "no row data",
+		},
+		{
+			name:    "missing key",
+			payload: `{"op":"c","after":{"v":2},"source":{"ts_ms":1}}`,
+			wantErr: "missing primary key: pk",
+		},
+		{
+			name:    "missing timestamp",
+			payload: `{"op":"c","after":{"pk":1}}`,
+			wantErr: "missing source.ts_ms",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+			events, err := decodeDebeziumEvents(strings.NewReader(tc.payload))
+			r.NoError(err)
+			r.Len(events, 1)
+			ts, err := events[0].time()
+			var mut types.Mutation
+			if err == nil {
+				mut, err = events[0].asMutation(keys, ts)
+			}
+			if tc.wantErr != "" {
+				r.ErrorContains(err, tc.wantErr)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tc.expected, mut)
+		})
+	}
+}
+
+func TestDebeziumTransactions(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	state := &debeziumSchemaState{}
+
+	// send decodes the events and updates the transaction state.
+	send := func(payload string) (hlc.Time, bool) {
+		events, err := decodeDebeziumEvents(strings.NewReader(payload))
+		r.NoError(err)
+		times, err := state.assign(events)
+		r.NoError(err)
+		return state.update(events, times)
+	}
+	data := func(tx string, order int) string {
+		return fmt.Sprintf(`{"op":"c","after":{"pk":%[2]d},"source":{"ts_ms":%[2]d},`+
+			`"transaction":{"id":%[1]q,"total_order":%[2]d}}`, tx, order)
+	}
+
+	// Nothing is resolved until END has been received.
+	_, ok := send(`{"status":"BEGIN","id":"tx1"}` + data("tx1", 1))
+	a.False(ok)
+
+	// The second transaction cannot be resolved before the first.
+	_, ok = send(`{"status":"BEGIN","id":"tx2"}` + data("tx2", 3) +
+		`{"status":"END","id":"tx2","event_count":1}`)
+	a.False(ok)
+
+	// The END event can arrive before the data.
+	_, ok = send(`{"status":"END","id":"tx1","event_count":2}`)
+	a.False(ok)
+
+	// Completing the first transaction resolves both. The final event
+	// is staged after the second transaction's event, even though its
+	// source timestamp is earlier.
+	ts, ok := send(data("tx1", 2))
+	a.True(ok)
+	a.Equal(hlc.New(3_000_000, 1), ts)
+
+	// Empty transactions do not advance the resolved timestamp.
+	_, ok = send(`{"status":"BEGIN","id":"tx3"}{"status":"END","id":"tx3","event_count":0}`)
+	a.False(ok)
+
+	// The state can be restored from its persisted form.
+	_, ok = send(`{"status":"BEGIN","id":"tx4"}` + data("tx4", 4))
+	a.False(ok)
+	persisted, err := json.Marshal(state)
+	r.NoError(err)
+	state = &debeziumSchemaState{}
+	r.NoError(json.Unmarshal(persisted, state))
+	ts, ok = send(`{"status":"END","id":"tx4","event_count":1}`)
+	a.True(ok)
+	a.Equal(hlc.New(4_000_000, 0), ts)
+}
+
+// TestDebeziumSameMillisecond verifies that a transaction which
+// commits in the same millisecond as a larger, earlier transaction is
+// staged above the earlier transaction's resolved timestamp.
+func TestDebeziumSameMillisecond(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	state := &debeziumSchemaState{}
+
+	send := func(payload string) ([]hlc.Time, hlc.Time, bool) {
+		events, err := decodeDebeziumEvents(strings.NewReader(payload))
+		r.NoError(err)
+		times, err := state.assign(events)
+		r.NoError(err)
+		resolved, ok := state.update(events, times)
+		return times, resolved, ok
+	}
+	data := func(tx string, order int) string {
+		return fmt.Sprintf(`{"op":"c","after":{"pk":%[2]d},"source":{"ts_ms":5},`+
+			`"transaction":{"id":%[1]q,"total_order":%[2]d}}`, tx, order)
+	}
+
+	_, markA, ok := send(`{"status":"BEGIN","id":"a"}` +
+		data("a", 1) + data("a", 2) + data("a", 3) +
+		`{"status":"END","id":"a","event_count":3}`)
+	r.True(ok)
+	a.Equal(hlc.New(5_000_000, 2), markA)
+
+	times, markB, ok := send(`{"status":"BEGIN","id":"b"}` + data("b", 1) +
+		`{"status":"END","id":"b","event_count":1}`)
+	r.True(ok)
+	a.Equal(hlc.New(5_000_000, 3), times[1])
+	a.Equal(hlc.New(5_000_000, 3), markB)
+	a.Equal(1, hlc.Compare(times[1], markA))
+}
+
+func testDebeziumHandler(t *testing.T, cfg *fixtureConfig) {
+	t.Helper()
+	fixture, tableInfo := createFixture(t, cfg)
+	ctx := fixture.Context
+	h := fixture.Handler
+	schema := tableInfo.Name().Schema()
+	tableName := tableInfo.Name().Table().Raw()
+
+	// In async mode, we want to reach into the implementation to
+	// force the marked, resolved timestamp to be operated on.
+	maybeFlush := func(expect hlc.Time) error {
+		if cfg.immediate {
+			return nil
+		}
+		loop, resolver, err := h.Resolvers.get(ctx, schema)
+		if err != nil {
+			return err
+		}
+		waitFor := &resolvedStamp{CommittedTime: expect}
+		resolver.marked.Notify()
+		for cp, updated := loop.GetConsistentPoint(); cp.Less(waitFor); {
+			select {
+			case <-updated:
+				cp, updated = loop.GetConsistentPoint()
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	t.Run("transaction", func(t *testing.T) {
+		a := assert.New(t)
+		r := require.New(t)
+
+		// The END event is sent before the final data event to ensure
+		// that the transaction isn't resolved early.
+		r.NoError(h.debezium(ctx, &request{
+			target: schema,
+			body: strings.NewReader(fmt.Sprintf(`
+{"status":"BEGIN","id":"tx1"}
+{"op":"c","after":{"pk":42,"v":99},"source":{"table":%[1]q,"ts_ms":1},"transaction":{"id":"tx1","total_order":1}}
+{"status":"END","id":"tx1","event_count":2}
+`, tableName)),
+		}))
+		r.NoError(h.debezium(ctx, &request{
+			target: schema,
+			body: strings.NewReader(fmt.Sprintf(`
+{"op":"c","after":{"pk":99,"v":42},"source":{"table":%[1]q,"ts_ms":1},"transaction":{"id":"tx1","total_order":2}}
+`, tableName)),
+		}))
+		r.NoError(maybeFlush(hlc.New(1_000_000, 1)))
+
+		ct, err := tableInfo.RowCount(ctx)
+		r.NoError(err)
+		a.Equal(2, ct)
+
+		// Delete the rows, using a table-specific endpoint and the
+		// schema-wrapped envelope.
+		r.NoError(h.debezium(ctx, &request{
+			target: tableInfo.Name(),
+			body: strings.NewReader(`
+{"status":"BEGIN","id":"tx2"}
+{"schema":{},"payload":{"op":"d","before":{"pk":42,"v":99},"source":{"ts_ms":2},"transaction":{"id":"tx2","total_order":1}}}
+{"schema":{},"payload":{"op":"d","before":{"pk":99,"v":42},"source":{"ts_ms":2},"transaction":{"id":"tx2","total_order":2}}}
+{"status":"END","id":"tx2","event_count":2}
+`),
+		}))
+		r.NoError(maybeFlush(hlc.New(2_000_000, 1)))
+
+		ct, err = tableInfo.RowCount(ctx)
+		r.NoError(err)
+		a.Equal(0, ct)
+	})
+
+	// The transaction state is persisted, so a transaction can be
+	// completed by a new Handler.
+	t.Run("restart", func(t *testing.T) {
+		a := assert.New(t)
+		r := require.New(t)
+
+		r.NoError(h.debezium(ctx, &request{
+			target: schema,
+			body: strings.NewReader(fmt.Sprintf(`
+{"status":"BEGIN","id":"tx3"}
+{"op":"c","after":{"pk":1,"v":1},"source":{"table":%[1]q,"ts_ms":3},"transaction":{"id":"tx3","total_order":1}}
+`, tableName)),
+		}))
+
+		restarted := *h
+		restarted.Transactions = ProvideDebeziumTransactions(fixture.Memo, fixture.StagingPool)
+		r.NoError(restarted.debezium(ctx, &request{
+			target: schema,
+			body: strings.NewReader(fmt.Sprintf(`
+{"op":"c","after":{"pk":2,"v":2},"source":{"table":%[1]q,"ts_ms":3},"transaction":{"id":"tx3","total_order":2}}
+{"status":"END","id":"tx3","event_count":2}
+`, tableName)),
+		}))
+		r.NoError(maybeFlush(hlc.New(3_000_000, 1)))
+
+		ct, err := tableInfo.RowCount(ctx)
+		r.NoError(err)
+		a.Equal(2, ct)
+	})
+
+	// Verify that an empty post doesn't crash.
+	t.Run("empty", func(t *testing.T) {
+		a := assert.New(t)
+		a.NoError(h.debezium(ctx, &request{
+			target: schema,
+			body:   strings.NewReader(""),
+		}))
+	})
+}
@@ -22,6 +22,7 @@ package cdc
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"strings"
 
@@ -40,13 +41,15 @@ var sanitizer = strings.NewReplacer("\n", " ", "\r", " ")
 // Handler is an http.Handler for processing webhook requests
 // from a CockroachDB changefeed.
 type Handler struct {
-	Authenticator types.Authenticator // Access checks.
-	Config        *Config             // Runtime options.
-	Immediate     *Immediate          // Non-transactional mutations.
-	Resolvers     *Resolvers          // Process resolved timestamps.
-	StagingPool   *types.StagingPool  // Access to the staging cluster.
-	Stores        types.Stagers       // Record incoming json blobs.
-	TargetPool    *types.TargetPool   // Access to the target cluster.
+	Authenticator types.Authenticator   // Access checks.
+	Config        *Config               // Runtime options.
+	Immediate     *Immediate            // Non-transactional mutations.
+	Registry      AvroRegistry          // Avro writer schemas; may be nil.
+	Resolvers     *Resolvers            // Process resolved timestamps.
+	StagingPool   *types.StagingPool    // Access to the staging cluster.
+	Stores        types.Stagers         // Record incoming json blobs.
+	TargetPool    *types.TargetPool     // Access to the target cluster.
+	Transactions  *DebeziumTransactions // Track Debezium transactions.
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -95,6 +98,21 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// ServeFile processes a file written by a cloud-storage changefeed that
+// has been retrieved by some means other than an HTTP request. The name
+// of the file is relative to the changefeed's destination, e.g.
+// 2020-04-02/202004022058072107140000000000000-56087568dba1e6b8-1-72-00000000-my_table-1.ndjson.
+// No access checks are performed.
+func (h *Handler) ServeFile(
+	ctx context.Context, target ident.Schema, name string, body io.Reader,
+) error {
+	req, err := h.newFileRequest(target, name, body)
+	if err != nil {
+		return err
+	}
+	return req.leaf(ctx, req)
+}
+
 func (h *Handler) checkAccess(
 	ctx context.Context, r *http.Request, target ident.Schema,
 ) (bool, error) {
@@ -61,6 +61,9 @@ func TestHandler(t *testing.T) {
 	for _, tc := range tcs {
 		t.Run(fmt.Sprintf("%s-feed", tc.name), func(t *testing.T) { testHandler(t, tc.cfg) })
 		t.Run(fmt.Sprintf("%s-query", tc.name), func(t *testing.T) { testQueryHandler(t, tc.cfg) })
+		t.Run(fmt.Sprintf("%s-avro", tc.name), func(t *testing.T) { testAvroHandler(t, tc.cfg) })
+		t.Run(fmt.Sprintf("%s-debezium", tc.name), func(t *testing.T) { testDebeziumHandler(t, tc.cfg) })
+		t.Run(fmt.Sprintf("%s-parquet", tc.name), func(t *testing.T) { testParquetHandler(t, tc.cfg) })
 	}
 }
 
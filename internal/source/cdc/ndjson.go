@@ -77,52 +77,9 @@ func parseNdjsonMutation(_ context.Context, _ *request, rawBytes []byte) (types.
 func (h *Handler) ndjson(ctx context.Context, req *request, parser parseMutation) error {
 	target := req.target.(ident.Table)
 
-	var commit func() error
-	var flush func(muts []types.Mutation) error
-
-	if h.Config.Immediate {
-		batcher, err := h.Immediate.Get(ctx, target.Schema())
-		if err != nil {
-			return err
-		}
-		batch, err := batcher.OnBegin(ctx)
-		if err != nil {
-			return err
-		}
-		source := script.SourceName(target)
-		// Push the data into the pipeline.
-		flush = func(muts []types.Mutation) error {
-			for idx := range muts {
-				// Index needed since it's not a pointer type. We don't
-				// create metadata in the scan phase, because this
-				// computation is only relevant to immediate mode. It's
-				// going to be re-computed in deferred mode.
-				script.AddMeta("cdc", target, &muts[idx])
-			}
-			return batch.OnData(ctx, source, target, muts)
-		}
-		commit = func() error {
-			select {
-			case err := <-batch.OnCommit(ctx):
-				return err
-			case <-ctx.Done():
-				return ctx.Err()
-			}
-		}
-
-	} else {
-		eg, egCtx := errgroup.WithContext(ctx)
-
-		store, err := h.Stores.Get(ctx, target)
-		if err != nil {
-			return err
-		}
-		// Start a goroutine to stage the data so we can keep decoding.
-		flush = func(muts []types.Mutation) error {
-			eg.Go(func() error { return store.Store(egCtx, h.StagingPool, muts) })
-			return nil
-		}
-		commit = eg.Wait
+	flush, commit, err := h.mutationSink(ctx, target, -1)
+	if err != nil {
+		return err
 	}
 
 	muts := make([]types.Mutation, 0, batches.Size())
@@ -157,3 +114,58 @@ func (h *Handler) ndjson(ctx context.Context, req *request, parser parseMutation
 
 	return commit()
 }
+
+// mutationSink returns a function to accept batches of mutations for
+// the target table and a function to call once all batches have been
+// sent. In deferred mode, batches are staged in the background, with
+// at most maxInFlight batches being stored at once; a negative value
+// means no limit.
+func (h *Handler) mutationSink(
+	ctx context.Context, target ident.Table, maxInFlight int,
+) (flush func([]types.Mutation) error, commit func() error, err error) {
+	if h.Config.Immediate {
+		batcher, err := h.Immediate.Get(ctx, target.Schema())
+		if err != nil {
+			return nil, nil, err
+		}
+		batch, err := batcher.OnBegin(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		source := script.SourceName(target)
+		// Push the data into the pipeline.
+		flush = func(muts []types.Mutation) error {
+			for idx := range muts {
+				// Index needed since it's not a pointer type. We don't
+				// create metadata in the scan phase, because this
+				// computation is only relevant to immediate mode. It's
+				// going to be re-computed in deferred mode.
+				script.AddMeta("cdc", target, &muts[idx])
+			}
+			return batch.OnData(ctx, source, target, muts)
+		}
+		commit = func() error {
+			select {
+			case err := <-batch.OnCommit(ctx):
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return flush, commit, nil
+	}
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(maxInFlight)
+
+	store, err := h.Stores.Get(ctx, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Start a goroutine to stage the data so we can keep decoding.
+	flush = func(muts []types.Mutation) error {
+		eg.Go(func() error { return store.Store(egCtx, h.StagingPool, muts) })
+		return nil
+	}
+	return flush, eg.Wait, nil
+}
@@ -58,6 +58,17 @@ func (h *Handler) getPrimaryKey(ctx context.Context, req *request) (*ident.Map[i
 	if !ok {
 		return nil, errors.Errorf("expecting ident.Table, got %T", req.target)
 	}
+	keys, err := h.primaryKey(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	req.keys = keys
+	return req.keys, nil
+}
+
+// primaryKey returns the primary-key columns of the table and their
+// ordinal position within the key.
+func (h *Handler) primaryKey(ctx context.Context, table ident.Table) (*ident.Map[int], error) {
 	watcher, err := h.Resolvers.watchers.Get(ctx, table.Schema())
 	if err != nil {
 		return nil, err
@@ -66,11 +77,11 @@ func (h *Handler) getPrimaryKey(ctx context.Context, req *request) (*ident.Map[i
 	if !ok {
 		return nil, errors.Errorf("table %q not found", table)
 	}
-	req.keys = &ident.Map[int]{}
+	ret := &ident.Map[int]{}
 	for i, col := range columns {
 		if col.Primary {
-			req.keys.Put(col.Name, i)
+			ret.Put(col.Name, i)
 		}
 	}
-	return req.keys, nil
+	return ret, nil
 }
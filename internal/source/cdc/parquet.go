@@ -0,0 +1,419 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cdc
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/batches"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/pkg/errors"
+)
+
+// Metadata columns added to parquet changefeed files.
+const (
+	parquetMetaPrefix = "__crdb__"
+	parquetEventType  = parquetMetaPrefix + "event_type"
+	parquetUpdated    = parquetMetaPrefix + "updated"
+)
+
+// parquetMaxInFlight limits the number of batches that are being
+// staged while the next rows are decoded.
+const parquetMaxInFlight = 4
+
+// parquetReadSize is the number of rows that are decoded at once.
+const parquetReadSize = 64
+
+// parquet ingests a file written by a cloud-storage changefeed created
+// with the parquet format and the updated option. Each row contains
+// the columns of the table, followed by metadata columns that identify
+// the type of event and its timestamp.
+//
+// The Parquet footer is at the end of the file, so the body is spooled
+// to a temporary file. Rows are then decoded incrementally and their
+// mutations staged, which bounds the memory that is used by large
+// files.
+func (h *Handler) parquet(ctx context.Context, req *request) error {
+	target := req.target.(ident.Table)
+
+	spool, err := os.CreateTemp("", "cdc-sink-*.parquet")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}()
+	size, err := io.Copy(spool, req.body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	file, err := parquet.OpenFile(spool, size)
+	if err != nil {
+		return errors.Wrap(err, "could not open parquet file")
+	}
+	keys, err := h.primaryKey(ctx, target)
+	if err != nil {
+		return err
+	}
+	layout, err := newParquetLayout(file.Schema(), keys)
+	if err != nil {
+		return err
+	}
+
+	flush, commit, err := h.mutationSink(ctx, target, parquetMaxInFlight)
+	if err != nil {
+		return err
+	}
+	muts := make([]types.Mutation, 0, batches.Size())
+	for _, group := range file.RowGroups() {
+		if err := layout.readRows(group, func(mut types.Mutation) error {
+			muts = append(muts, mut)
+			if len(muts) < cap(muts) {
+				return nil
+			}
+			if err := flush(muts); err != nil {
+				return err
+			}
+			muts = make([]types.Mutation, 0, batches.Size())
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if len(muts) > 0 {
+		if err := flush(muts); err != nil {
+			return err
+		}
+	}
+	return commit()
+}
+
+// parquetLayout locates the data, key, and metadata columns within a
+// parquet changefeed file.
+type parquetLayout struct {
+	columns   []parquet.Field
+	data      []int // Indexes of the table's columns.
+	eventType int   // Index of the event type, or -1.
+	keys      []int // Indexes of the primary key columns, in order.
+	updated   int   // Index of the update timestamp.
+}
+
+// newParquetLayout requires a flat schema, in which every column is a
+// required or optional leaf of the root. The index of each field is
+// therefore also the index of its column.
+func newParquetLayout(schema *parquet.Schema, pks *ident.Map[int]) (*parquetLayout, error) {
+	columns := schema.Fields()
+	ret := &parquetLayout{
+		columns:   columns,
+		eventType: -1,
+		keys:      make([]int, pks.Len()),
+		updated:   -1,
+	}
+	var positions ident.Map[int]
+	for idx, col := range columns {
+		name := col.Name()
+		if !col.Leaf() || col.Repeated() {
+			return nil, errors.Errorf("nested parquet column %q is not supported", name)
+		}
+		switch {
+		case name == parquetEventType:
+			ret.eventType = idx
+		case name == parquetUpdated:
+			ret.updated = idx
+		case strings.HasPrefix(name, parquetMetaPrefix):
+			// Ignore other metadata, such as the MVCC timestamp.
+		default:
+			ret.data = append(ret.data, idx)
+			positions.Put(ident.New(name), idx)
+		}
+	}
+	if ret.updated < 0 {
+		return nil, errors.New("CREATE CHANGEFEED must specify the 'WITH updated' option")
+	}
+	if err := pks.Range(func(pk ident.Ident, pos int) error {
+		idx, ok := positions.Get(pk)
+		if !ok {
+			return errors.Errorf("missing primary key: %s", pk)
+		}
+		ret.keys[pos] = idx
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// readRows decodes the rows of the group and passes the resulting
+// mutations to the callback.
+func (l *parquetLayout) readRows(group parquet.RowGroup, fn func(types.Mutation) error) error {
+	rows := group.Rows()
+	defer func() { _ = rows.Close() }()
+
+	buf := make([]parquet.Row, parquetReadSize)
+	for {
+		count, err := rows.ReadRows(buf)
+		for _, row := range buf[:count] {
+			mut, err := l.mutation(row)
+			if err != nil {
+				return err
+			}
+			if err := fn(mut); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "could not read parquet rows")
+		}
+	}
+}
+
+// mutation converts a row of the file into a mutation.
+func (l *parquetLayout) mutation(row parquet.Row) (types.Mutation, error) {
+	// The byte arrays in the row may be reused by the next read, so
+	// each value is converted before the row is returned to the caller.
+	values := make([]any, len(l.columns))
+	for _, value := range row {
+		idx := value.Column()
+		if idx < 0 || idx >= len(values) {
+			return types.Mutation{}, errors.Errorf("unexpected parquet column index %d", idx)
+		}
+		values[idx] = parquetValue(l.columns[idx].Type(), value)
+	}
+
+	updated, ok := values[l.updated].(string)
+	if !ok || updated == "" {
+		return types.Mutation{}, errors.Errorf("row has no %s value", parquetUpdated)
+	}
+	timestamp, err := hlc.Parse(updated)
+	if err != nil {
+		return types.Mutation{}, err
+	}
+	mut := types.Mutation{Time: timestamp}
+
+	deletion := false
+	if l.eventType >= 0 {
+		switch event := values[l.eventType]; event {
+		case "c", "u":
+		case "d":
+			deletion = true
+		default:
+			return types.Mutation{}, errors.Errorf("unknown %s value %v", parquetEventType, event)
+		}
+	}
+
+	keyValues := make([]any, len(l.keys))
+	for pos, idx := range l.keys {
+		keyValues[pos] = values[idx]
+	}
+	if mut.Key, err = json.Marshal(keyValues); err != nil {
+		return types.Mutation{}, errors.WithStack(err)
+	}
+
+	// Deletions only carry the primary key, so Data is left empty.
+	if !deletion {
+		data := make(map[string]any, len(l.data))
+		for _, idx := range l.data {
+			data[l.columns[idx].Name()] = values[idx]
+		}
+		if mut.Data, err = json.Marshal(data); err != nil {
+			return types.Mutation{}, errors.WithStack(err)
+		}
+	}
+	return mut, nil
+}
+
+// parquetDecimalText matches the textual form of a decimal number.
+var parquetDecimalText = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][-+]?\d+)?$`)
+
+// parquetValue converts a value into the shape that ColData.Parse
+// expects for the corresponding column type. Null values are returned
+// as nil.
+//
+// Byte arrays without a textual annotation are rendered in the \x
+// hex-escaped form. CockroachDB writes decimal values as text, so a
+// decimal byte array that holds a decimal literal is passed through
+// as-is; otherwise, the value is decoded as a big-endian, two's
+// complement unscaled integer.
+func parquetValue(typ parquet.Type, value parquet.Value) any {
+	if value.IsNull() {
+		return nil
+	}
+	logical := typ.LogicalType()
+	if logical == nil {
+		logical = &format.LogicalType{}
+	}
+	unsigned := logical.Integer != nil && !logical.Integer.IsSigned
+
+	switch value.Kind() {
+	case parquet.Boolean:
+		return value.Boolean()
+
+	case parquet.Int32:
+		v := value.Int32()
+		switch {
+		case logical.Date != nil:
+			return time.Unix(int64(v)*86400, 0).UTC().Format("2006-01-02")
+		case logical.Decimal != nil:
+			return parquetDecimal(big.NewInt(int64(v)), logical.Decimal.Scale)
+		case logical.Time != nil:
+			return parquetTime(int64(v), logical.Time.Unit)
+		case unsigned:
+			return uint32(v)
+		}
+		return int64(v)
+
+	case parquet.Int64:
+		v := value.Int64()
+		switch {
+		case logical.Decimal != nil:
+			return parquetDecimal(big.NewInt(v), logical.Decimal.Scale)
+		case logical.Time != nil:
+			return parquetTime(v, logical.Time.Unit)
+		case logical.Timestamp != nil:
+			return parquetTimestamp(v, logical.Timestamp.Unit)
+		case unsigned:
+			return uint64(v)
+		}
+		return v
+
+	case parquet.Int96:
+		// A legacy timestamp, which consists of the nanoseconds within
+		// the day followed by a Julian day number.
+		const julianEpoch = 2440588 // 1970-01-01
+		v := value.Int96()
+		nanos := int64(uint64(v[1])<<32 | uint64(v[0]))
+		days := int64(int32(v[2])) - julianEpoch
+		return time.Unix(days*86400, nanos).UTC().Format(time.RFC3339Nano)
+
+	case parquet.Float:
+		if f := float64(value.Float()); math.IsNaN(f) || math.IsInf(f, 0) {
+			return parquetNonFinite(f)
+		}
+		return json.Number(strconv.FormatFloat(float64(value.Float()), 'g', -1, 32))
+
+	case parquet.Double:
+		if f := value.Double(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return parquetNonFinite(f)
+		}
+		return value.Double()
+
+	default:
+		buf := value.ByteArray()
+		switch {
+		case logical.UTF8 != nil, logical.Enum != nil:
+			return string(buf)
+		case logical.Json != nil:
+			if json.Valid(buf) {
+				return json.RawMessage(append([]byte(nil), buf...))
+			}
+			return string(buf)
+		case logical.Decimal != nil:
+			if value.Kind() == parquet.ByteArray && parquetDecimalText.Match(buf) {
+				return json.Number(buf)
+			}
+			unscaled := new(big.Int).SetBytes(buf)
+			if len(buf) > 0 && buf[0]&0x80 != 0 {
+				unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*len(buf))))
+			}
+			return parquetDecimal(unscaled, logical.Decimal.Scale)
+		case logical.UUID != nil && len(buf) == 16:
+			return fmt.Sprintf("%x-%x-%x-%x-%x", buf[0:4], buf[4:6], buf[6:8], buf[8:10], buf[10:16])
+		}
+		return `\x` + hex.EncodeToString(buf)
+	}
+}
+
+// parquetDecimal renders an unscaled integer with the given scale.
+func parquetDecimal(unscaled *big.Int, scale int32) json.Number {
+	if scale <= 0 {
+		return json.Number(unscaled.String())
+	}
+	digits := new(big.Int).Abs(unscaled).String()
+	if len(digits) <= int(scale) {
+		digits = strings.Repeat("0", int(scale)-len(digits)+1) + digits
+	}
+	point := len(digits) - int(scale)
+	ret := digits[:point] + "." + digits[point:]
+	if unscaled.Sign() < 0 {
+		ret = "-" + ret
+	}
+	return json.Number(ret)
+}
+
+// parquetNonFinite uses the same spelling as CockroachDB's JSON output.
+func parquetNonFinite(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case f > 0:
+		return "Infinity"
+	default:
+		return "-Infinity"
+	}
+}
+
+// parquetDuration returns the length of a time unit.
+func parquetDuration(unit format.TimeUnit) time.Duration {
+	switch {
+	case unit.Micros != nil:
+		return time.Microsecond
+	case unit.Nanos != nil:
+		return time.Nanosecond
+	default:
+		return time.Millisecond
+	}
+}
+
+// parquetTime renders a time of day.
+func parquetTime(value int64, unit format.TimeUnit) string {
+	d := time.Duration(value) * parquetDuration(unit)
+	return time.Unix(0, 0).UTC().Add(d).Format("15:04:05.999999999")
+}
+
+// parquetTimestamp renders an offset from the epoch.
+func parquetTimestamp(value int64, unit format.TimeUnit) string {
+	var ts time.Time
+	switch {
+	case unit.Micros != nil:
+		ts = time.UnixMicro(value)
+	case unit.Nanos != nil:
+		ts = time.Unix(0, value)
+	default:
+		ts = time.UnixMilli(value)
+	}
+	return ts.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,240 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cdc
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/parquettest"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parquetTestSchema matches the tables created by createFixture.
+var parquetTestSchema = parquet.NewSchema("changefeed", parquet.Group{
+	"pk":                     parquet.Int(64),
+	"v":                      parquet.Optional(parquet.Int(64)),
+	parquetEventType:         parquet.String(),
+	parquetUpdated:           parquet.String(),
+	"__crdb__mvcc_timestamp": parquet.Optional(parquet.String()),
+})
+
+// writeParquet returns a file containing the rows.
+func writeParquet(t *testing.T, schema *parquet.Schema, rows ...map[string]any) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	w := parquettest.NewWriter(&buf, schema, parquettest.WriterOptions{
+		Compression:  &parquet.Snappy,
+		RowGroupSize: 2,
+	})
+	for _, row := range rows {
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Close())
+	return bytes.NewReader(buf.Bytes())
+}
+
+// parquetRow returns a row for parquetTestSchema.
+func parquetRow(pk int64, v any, event, updated string) map[string]any {
+	return map[string]any{
+		"pk":             pk,
+		"v":              v,
+		parquetEventType: event,
+		parquetUpdated:   updated,
+	}
+}
+
+func TestParquetMutation(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	keys := &ident.Map[int]{}
+	keys.Put(ident.New("pk"), 0)
+
+	body := writeParquet(t, parquetTestSchema,
+		parquetRow(1, int64(10), "c", "1.0000000002"),
+		parquetRow(2, int64(20), "u", "2.0"),
+		parquetRow(3, nil, "d", "3.0"),
+		parquetRow(4, int64(40), "x", "4.0"),
+	)
+	file, err := parquet.OpenFile(body, body.Size())
+	r.NoError(err)
+	r.Len(file.RowGroups(), 2)
+	layout, err := newParquetLayout(file.Schema(), keys)
+	r.NoError(err)
+
+	var muts []types.Mutation
+	err = layout.readRows(file.RowGroups()[0], func(mut types.Mutation) error {
+		muts = append(muts, mut)
+		return nil
+	})
+	r.NoError(err)
+	a.Equal([]types.Mutation{
+		{Data: []byte(`{"pk":1,"v":10}`), Key: []byte(`[1]`), Time: hlc.New(1, 2)},
+		{Data: []byte(`{"pk":2,"v":20}`), Key: []byte(`[2]`), Time: hlc.New(2, 0)},
+	}, muts)
+
+	muts = nil
+	err = layout.readRows(file.RowGroups()[1], func(mut types.Mutation) error {
+		muts = append(muts, mut)
+		return nil
+	})
+	a.ErrorContains(err, "unknown __crdb__event_type value x")
+	a.Equal([]types.Mutation{{Key: []byte(`[3]`), Time: hlc.New(3, 0)}}, muts)
+
+	// The updated option is required.
+	_, err = newParquetLayout(parquet.NewSchema("changefeed", parquet.Group{
+		"pk":             parquet.Int(64),
+		parquetEventType: parquet.String(),
+	}), keys)
+	a.ErrorContains(err, "WITH updated")
+
+	// The primary key must be present.
+	missing := &ident.Map[int]{}
+	missing.Put(ident.New("other"), 0)
+	_, err = newParquetLayout(file.Schema(), missing)
+	a.ErrorContains(err, "missing primary key: other")
+
+	// Nested columns are rejected.
+	_, err = newParquetLayout(parquet.NewSchema("changefeed", parquet.Group{
+		"pk":           parquet.Int(64),
+		"arr":          parquet.Repeated(parquet.Int(64)),
+		parquetUpdated: parquet.String(),
+	}), keys)
+	a.ErrorContains(err, `nested parquet column "arr"`)
+}
+
+func TestParquetValue(t *testing.T) {
+	ts := time.Date(2023, 4, 5, 6, 7, 8, 9000, time.UTC)
+	tcs := []struct {
+		name     string
+		node     parquet.Node
+		value    any
+		expected any
+	}{
+		{"null", parquet.Optional(parquet.Int(64)), nil, nil},
+		{"bool", parquet.Leaf(parquet.BooleanType), true, true},
+		{"int32", parquet.Int(32), int32(-42), int64(-42)},
+		{"uint32", parquet.Uint(32), int32(-1), uint32(math.MaxUint32)},
+		{"int64", parquet.Int(64), int64(42), int64(42)},
+		{"float", parquet.Leaf(parquet.FloatType), float32(1.5), json.Number("1.5")},
+		{"nan", parquet.Leaf(parquet.DoubleType), math.NaN(), "NaN"},
+		{"-inf", parquet.Leaf(parquet.DoubleType), math.Inf(-1), "-Infinity"},
+		{"double", parquet.Leaf(parquet.DoubleType), -2.25, -2.25},
+		{"string", parquet.String(), "hello", "hello"},
+		{"bytes", parquet.Leaf(parquet.ByteArrayType), []byte{0xca, 0xfe}, `\xcafe`},
+		{"json", parquet.JSON(), `{"a":1}`, json.RawMessage(`{"a":1}`)},
+		{"decimal text", parquet.Decimal(2, 10, parquet.ByteArrayType), "123.45", json.Number("123.45")},
+		{"decimal bytes", parquet.Decimal(3, 4, parquet.FixedLenByteArrayType(2)), []byte{0xff, 0xfe}, json.Number("-0.002")},
+		{"decimal int64", parquet.Decimal(2, 18, parquet.Int64Type), int64(12345), json.Number("123.45")},
+		{"date", parquet.Date(), int32(19452), "2023-04-05"},
+		{"time", parquet.Time(parquet.Microsecond), int64(3723000004), "01:02:03.000004"},
+		{"timestamp micros", parquet.Timestamp(parquet.Microsecond), ts.UnixMicro(), "2023-04-05T06:07:08.000009Z"},
+		{"timestamp millis", parquet.Timestamp(parquet.Millisecond), ts.UnixMilli(), "2023-04-05T06:07:08Z"},
+		{
+			"uuid",
+			parquet.UUID(),
+			[]byte{0x7f, 0x9c, 0x24, 0xe8, 0x3b, 0x12, 0x4f, 0xef, 0x91, 0xe0, 0x56, 0xa2, 0xd5, 0xa2, 0x46, 0xec},
+			"7f9c24e8-3b12-4fef-91e0-56a2d5a246ec",
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, parquetValue(tc.node.Type(), parquet.ValueOf(tc.value)))
+		})
+	}
+}
+
+func testParquetHandler(t *testing.T, cfg *fixtureConfig) {
+	t.Helper()
+	fixture, tableInfo := createFixture(t, cfg)
+	ctx := fixture.Context
+	h := fixture.Handler
+	schema := tableInfo.Name().Schema()
+
+	// In async mode, we want to reach into the implementation to
+	// force the marked, resolved timestamp to be operated on.
+	maybeFlush := func(expect hlc.Time) error {
+		if cfg.immediate {
+			return nil
+		}
+		if err := h.resolved(ctx, &request{target: schema, timestamp: expect}); err != nil {
+			return err
+		}
+		loop, resolver, err := h.Resolvers.get(ctx, schema)
+		if err != nil {
+			return err
+		}
+		waitFor := &resolvedStamp{CommittedTime: expect}
+		resolver.marked.Notify()
+		for cp, updated := loop.GetConsistentPoint(); cp.Less(waitFor); {
+			select {
+			case <-updated:
+				cp, updated = loop.GetConsistentPoint()
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	a := assert.New(t)
+	r := require.New(t)
+
+	// Multiple row groups are written.
+	r.NoError(h.parquet(ctx, &request{
+		target: tableInfo.Name(),
+		body: writeParquet(t, parquetTestSchema,
+			parquetRow(1, int64(10), "c", "1.0"),
+			parquetRow(2, int64(20), "c", "1.0"),
+			parquetRow(3, int64(30), "c", "1.0"),
+		),
+	}))
+	r.NoError(maybeFlush(hlc.New(2, 0)))
+
+	ct, err := tableInfo.RowCount(ctx)
+	r.NoError(err)
+	a.Equal(3, ct)
+
+	r.NoError(h.parquet(ctx, &request{
+		target: tableInfo.Name(),
+		body: writeParquet(t, parquetTestSchema,
+			parquetRow(1, nil, "d", "3.0"),
+			parquetRow(2, nil, "d", "3.0"),
+			parquetRow(3, int64(33), "u", "3.0"),
+		),
+	}))
+	r.NoError(maybeFlush(hlc.New(4, 0)))
+
+	ct, err = tableInfo.RowCount(ctx)
+	r.NoError(err)
+	a.Equal(1, ct)
+
+	// Reject files that aren't parquet.
+	a.ErrorContains(h.parquet(ctx, &request{
+		target: tableInfo.Name(),
+		body:   bytes.NewReader([]byte("not a parquet file")),
+	}), "parquet")
+}
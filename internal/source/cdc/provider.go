@@ -24,15 +24,18 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/google/wire"
+	"github.com/hamba/avro/registry"
 	"github.com/pkg/errors"
 )
 
 // Set is used by Wire.
 var Set = wire.NewSet(
 	wire.Struct(new(Handler), "*"), // Handler is itself trivial.
+	ProvideDebeziumTransactions,
 	ProvideImmediate,
 	ProvideMetaTable,
 	ProvideResolvers,
+	ProvideSchemaRegistry,
 )
 
 // MetaTable is an injectable configuration point.
@@ -56,6 +59,7 @@ func ProvideMetaTable(cfg *Config) MetaTable {
 // ProvideResolvers is called by Wire.
 func ProvideResolvers(
 	ctx context.Context,
+	appliers types.Appliers,
 	cfg *Config,
 	leases types.Leases,
 	loops *logical.Factory,
@@ -69,6 +73,7 @@ func ProvideResolvers(
 	}
 
 	ret := &Resolvers{
+		appliers:  appliers,
 		cfg:       cfg,
 		leases:    leases,
 		loops:     loops,
@@ -92,3 +97,16 @@ func ProvideResolvers(
 
 	return ret, ret.close, nil
 }
+
+// ProvideSchemaRegistry is called by Wire. It returns nil if no schema
+// registry has been configured.
+func ProvideSchemaRegistry(cfg *Config) (AvroRegistry, error) {
+	if cfg.SchemaRegistryURL == "" {
+		return nil, nil
+	}
+	reg, err := registry.NewClient(cfg.SchemaRegistryURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid schema registry URL")
+	}
+	return reg, nil
+}
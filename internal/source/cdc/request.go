@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"path"
@@ -55,15 +56,50 @@ var (
 	resolvedTimestamp = resolvedRegex.SubexpIndex("timestamp")
 )
 
+// Example: /2020-04-02/202004022058072107140000000000000-56087568dba1e6b8-1-72-00000000-test_table-1.avro
+// The filename format is the same as for ndjson files. The file
+// contains a sequence of Avro messages in the Confluent wire format.
+var (
+	avroRegex = regexp.MustCompile(`^(?P<date>\d{4}-\d{2}-\d{2})/(?P<prelude>([^-]+-){5})(?P<topic>.+)-(?P<schema_id>[^-]+).avro$`)
+	avroTopic = avroRegex.SubexpIndex("topic")
+)
+
+// Example: /2020-04-02/202004022058072107140000000000000-56087568dba1e6b8-1-72-00000000-test_table-1.parquet
+// The filename format is the same as for ndjson files.
+var (
+	parquetRegex = regexp.MustCompile(`^(?P<date>\d{4}-\d{2}-\d{2})/(?P<prelude>([^-]+-){5})(?P<topic>.+)-(?P<schema_id>[^-]+).parquet$`)
+	parquetTopic = parquetRegex.SubexpIndex("topic")
+)
+
+// avroContentTypes identify webhook requests whose bodies contain Avro
+// messages in the Confluent wire format.
+var avroContentTypes = map[string]bool{
+	"application/avro":               true,
+	"application/vnd.confluent.avro": true,
+	"avro/binary":                    true,
+}
+
+// Example: /my_db/public?format=debezium or /my_db/public/my_table?format=debezium
+// Debezium envelopes are identified by a query parameter, rather than
+// by a path segment, so that they cannot be confused with a table
+// name. If a table is not specified, the target table is determined by
+// the source.table field of each event.
+const (
+	formatParam    = "format"
+	debeziumFormat = "debezium"
+)
+
 // This is set by test code to spy on the assignment to [request.leaf],
 // since some of the assignments are closures.
 var requestParsingTestCallback func(decision string)
 
 // A request is configured by the various parseURL methods in Handler.
 type request struct {
-	body    io.Reader
-	handler *Handler
-	leaf    func(ctx context.Context, req *request) error
+	body        io.Reader
+	contentType string // The media type, without parameters.
+	format      string // The value of the format query parameter.
+	handler     *Handler
+	leaf        func(ctx context.Context, req *request) error
 	// keys contains all the columns that make up the primary key
 	// for the target table and their ordinal position within the key.
 	keys      *ident.Map[int]
@@ -77,10 +113,50 @@ func (h *Handler) newRequest(req *http.Request) (*request, error) {
 		body:    req.Body,
 		handler: h,
 	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			ret.contentType = mediaType
+		}
+	}
+	ret.format = req.URL.Query().Get(formatParam)
 	return ret, ret.parseURL(req.URL)
 }
 
+// ErrUnknownFile is returned by [Handler.ServeFile] if the name of the
+// file does not match any of the formats that a cloud-storage
+// changefeed writes.
+var ErrUnknownFile = errors.New("not a changefeed file")
+
+// newFileRequest matches the name of a file written by a cloud-storage
+// changefeed, relative to the changefeed's destination, against the
+// bulk-style request patterns.
+func (h *Handler) newFileRequest(
+	target ident.Schema, name string, body io.Reader,
+) (*request, error) {
+	ret := &request{
+		body:    body,
+		handler: h,
+	}
+	segments := strings.Count(name, "/") + 1
+	for _, pattern := range requestPatterns {
+		if !pattern.bulk || pattern.expectedPathSegments != segments {
+			continue
+		}
+		match := pattern.pattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		ret.target = target
+		if err := pattern.fn(h, match, ret); err != nil {
+			return nil, err
+		}
+		return ret, nil
+	}
+	return nil, errors.Wrap(ErrUnknownFile, name)
+}
+
 type requestPattern struct {
+	bulk                 bool // Matches files written by cloud-storage changefeeds.
 	expectedPathSegments int
 	pattern              *regexp.Regexp
 	fn                   func(h *Handler, match []string, req *request) error
@@ -89,6 +165,7 @@ type requestPattern struct {
 var requestPatterns = []*requestPattern{
 	// Bulk, ndjson payload
 	{
+		bulk:                 true,
 		expectedPathSegments: 2,
 		pattern:              ndjsonRegex,
 		fn: func(h *Handler, match []string, req *request) error {
@@ -123,6 +200,7 @@ var requestPatterns = []*requestPattern{
 	},
 	// Bulk, resolved payload
 	{
+		bulk:                 true,
 		expectedPathSegments: 2,
 		pattern:              resolvedRegex,
 		fn: func(h *Handler, match []string, req *request) error {
@@ -145,9 +223,88 @@ var requestPatterns = []*requestPattern{
 			return nil
 		},
 	},
+	// Bulk, avro payload
+	{
+		bulk:                 true,
+		expectedPathSegments: 2,
+		pattern:              avroRegex,
+		fn: func(h *Handler, match []string, req *request) error {
+			switch t := req.target.(type) {
+			case ident.Schema:
+				if requestParsingTestCallback != nil {
+					requestParsingTestCallback("avro schema")
+				}
+				// Parse the topic as a (qualified) table name, then update the request.
+				tbl, _, err := ident.ParseTableRelative(match[avroTopic], t)
+				if err != nil {
+					return err
+				}
+				req.target = ident.NewTable(t, tbl.Table())
+			case ident.Table:
+				if requestParsingTestCallback != nil {
+					requestParsingTestCallback("avro table")
+				}
+			default:
+				return errors.Errorf("unimplemented %T", t)
+			}
+			req.leaf = h.avro
+			return nil
+		},
+	},
+	// Bulk, parquet payload
+	{
+		bulk:                 true,
+		expectedPathSegments: 2,
+		pattern:              parquetRegex,
+		fn: func(h *Handler, match []string, req *request) error {
+			switch t := req.target.(type) {
+			case ident.Schema:
+				if requestParsingTestCallback != nil {
+					requestParsingTestCallback("parquet schema")
+				}
+				// Parse the topic as a (qualified) table name, then update the request.
+				tbl, _, err := ident.ParseTableRelative(match[parquetTopic], t)
+				if err != nil {
+					return err
+				}
+				req.target = ident.NewTable(t, tbl.Table())
+			case ident.Table:
+				if requestParsingTestCallback != nil {
+					requestParsingTestCallback("parquet table")
+				}
+			default:
+				return errors.Errorf("unimplemented %T", t)
+			}
+			req.leaf = h.parquet
+			return nil
+		},
+	},
 	// Webhook matches anything else
 	{
 		fn: func(h *Handler, match []string, req *request) error {
+			if req.format == debeziumFormat {
+				switch t := req.target.(type) {
+				case ident.Schema:
+					if requestParsingTestCallback != nil {
+						requestParsingTestCallback("debezium schema")
+					}
+				case ident.Table:
+					if requestParsingTestCallback != nil {
+						requestParsingTestCallback("debezium table")
+					}
+				default:
+					return errors.Errorf("unimplemented %T", t)
+				}
+				req.leaf = h.debezium
+				return nil
+			}
+			if avroContentTypes[req.contentType] {
+				if requestParsingTestCallback != nil {
+					requestParsingTestCallback("avro webhook")
+				}
+				req.leaf = h.avro
+				return nil
+			}
 			switch t := req.target.(type) {
 			case ident.Schema:
 				if requestParsingTestCallback != nil {
@@ -245,9 +402,10 @@ func (r *request) schemaSegmentCount() int {
 	switch r.handler.TargetPool.Product {
 	case types.ProductUnknown:
 		return 0
-	case types.ProductOracle, types.ProductMySQL:
+	case types.ProductClickHouse, types.ProductOracle, types.ProductMySQL, types.ProductSQLite:
 		return 1 // e.g. MY_SCHEMA
-	case types.ProductCockroachDB, types.ProductPostgreSQL:
+	case types.ProductCockroachDB, types.ProductPostgreSQL, types.ProductRedshift,
+		types.ProductSQLServer:
 		return 2 // e.g. MY_DB.MY_SCHEMA
 	default:
 		panic(fmt.Sprintf("unimplemented: %s", r.handler.TargetPool.Product))
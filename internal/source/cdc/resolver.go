@@ -64,6 +64,7 @@ type resolver struct {
 	stagers     types.Stagers
 	target      ident.Schema
 	watcher     types.Watcher
+	windows     types.WindowedAppliers // May be nil.
 
 	sql struct {
 		mark            string
@@ -80,6 +81,7 @@ var (
 
 func newResolver(
 	ctx context.Context,
+	appliers types.Appliers,
 	cfg *Config,
 	leases types.Leases,
 	pool *types.StagingPool,
@@ -101,6 +103,11 @@ func newResolver(
 		target:  target,
 		watcher: watcher,
 	}
+	// Targets that don't write to the database must be told where each
+	// batch of mutations begins and ends.
+	if windows, ok := appliers.(types.WindowedAppliers); ok {
+		ret.windows = windows
+	}
 	ret.sql.selectTimestamp = fmt.Sprintf(selectTimestampTemplate, metaTable)
 	ret.sql.mark = fmt.Sprintf(markTemplate, metaTable)
 	ret.sql.record = fmt.Sprintf(recordTemplate, metaTable)
@@ -381,6 +388,18 @@ func (r *resolver) process(ctx context.Context, rs *resolvedStamp, events logica
 		ctx, cancel := context.WithTimeout(ctx, r.cfg.ApplyTimeout)
 		defer cancel()
 
+		// Open a window to receive the mutations if the target
+		// requires one.
+		var window types.Window
+		if r.windows != nil {
+			var err error
+			window, err = r.windows.Begin(ctx)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = window.Rollback() }()
+		}
+
 		// Apply the retrieved data.
 		batch, err := events.OnBegin(ctx)
 		if err != nil {
@@ -412,6 +431,14 @@ func (r *resolver) process(ctx context.Context, rs *resolvedStamp, events logica
 			return ctx.Err()
 		}
 
+		// Publish the window before recording any progress, so that
+		// its mutations will be replayed if the commit fails.
+		if window != nil {
+			if err := window.Commit(); err != nil {
+				return err
+			}
+		}
+
 		// Advance and save the stamp once the flush has completed.
 		if final {
 			rs, err = rs.NewCommitted()
@@ -588,6 +615,7 @@ func (r *resolver) retireLoop(ctx *stopper.Context) {
 
 // Resolvers is a factory for Resolver instances.
 type Resolvers struct {
+	appliers  types.Appliers
 	cfg       *Config
 	leases    types.Leases
 	loops     *logical.Factory
@@ -633,7 +661,7 @@ func (r *Resolvers) get(
 		return found, found.Dialect().(*resolver), nil
 	}
 
-	ret, err := newResolver(ctx, r.cfg, r.leases, r.pool, r.metaTable, r.stagers, target, r.watchers)
+	ret, err := newResolver(ctx, r.appliers, r.cfg, r.leases, r.pool, r.metaTable, r.stagers, target, r.watchers)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -665,6 +693,28 @@ func (r *Resolvers) get(
 	return loop, ret, nil
 }
 
+// Mark records a resolved timestamp for the target schema. The
+// mutations that were previously staged for the schema will be applied
+// asynchronously by the schema's resolver loop, which will be started
+// if necessary.
+func (r *Resolvers) Mark(ctx context.Context, target ident.Schema, ts hlc.Time) error {
+	_, resolver, err := r.get(ctx, target)
+	if err != nil {
+		return err
+	}
+	return resolver.Mark(ctx, ts)
+}
+
+// Record logs a resolved timestamp for the target schema as though it
+// had already been applied. This is used in immediate mode.
+func (r *Resolvers) Record(ctx context.Context, target ident.Schema, ts hlc.Time) error {
+	_, resolver, err := r.get(ctx, target)
+	if err != nil {
+		return err
+	}
+	return resolver.Record(ctx, ts)
+}
+
 const scanForTargetTemplate = `
 SELECT DISTINCT target_schema
 FROM %[1]s
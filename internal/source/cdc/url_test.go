@@ -49,13 +49,19 @@ func TestParseChangefeedURL(t *testing.T) {
 	schemaShrug := `¯\_(ツ)_/¯`
 	tableFlip := `(╯°□°）╯︵ ┻━┻`
 
+	avroFile := strings.Join([]string{ndjsonDate,
+		`202004022058072107140000000000000-56087568dba1e6b8-1-72-00000000-REAL-42-1.avro`}, "/")
+	parquetFile := strings.Join([]string{ndjsonDate,
+		`202004022058072107140000000000000-56087568dba1e6b8-1-72-00000000-REAL-42-1.parquet`}, "/")
+
 	tests := []struct {
-		name      string
-		decision  string
-		timestamp hlc.Time
-		target    ident.Schematic
-		url       string
-		wantErr   string
+		name        string
+		contentType string
+		decision    string
+		timestamp   hlc.Time
+		target      ident.Schematic
+		url         string
+		wantErr     string
 	}{
 		{
 			name:    "empty",
@@ -118,6 +124,62 @@ func TestParseChangefeedURL(t *testing.T) {
 			target:   ident.NewTable(ident.MustSchema(ident.New(dbName), ident.New(resolvedDate)), ident.New(resolvedTimestamp)),
 			url:      strings.Join([]string{"", dbName, resolved}, "/"),
 		},
+		{
+			name:     "avro to schema",
+			decision: "avro schema",
+			target:   ident.NewTable(schemaIdent, ident.New("REAL-42")), // Use topic name from file.
+			url:      strings.Join([]string{"", dbName, schemaName, avroFile}, "/"),
+		},
+		{
+			name:     "avro to table",
+			decision: "avro table",
+			target:   tableIdent,
+			url:      strings.Join([]string{"", dbName, schemaName, tableName, avroFile}, "/"),
+		},
+		{
+			name:     "parquet to schema",
+			decision: "parquet schema",
+			target:   ident.NewTable(schemaIdent, ident.New("REAL-42")), // Use topic name from file.
+			url:      strings.Join([]string{"", dbName, schemaName, parquetFile}, "/"),
+		},
+		{
+			name:     "parquet to table",
+			decision: "parquet table",
+			target:   tableIdent,
+			url:      strings.Join([]string{"", dbName, schemaName, tableName, parquetFile}, "/"),
+		},
+		{
+			name:        "avro webhook to schema",
+			contentType: "application/vnd.confluent.avro; charset=binary",
+			decision:    "avro webhook",
+			target:      schemaIdent,
+			url:         strings.Join([]string{"", dbName, schemaName}, "/"),
+		},
+		{
+			name:        "avro webhook to table",
+			contentType: "avro/binary",
+			decision:    "avro webhook",
+			target:      tableIdent,
+			url:         strings.Join([]string{"", dbName, schemaName, tableName}, "/"),
+		},
+		{
+			name:     "debezium to schema",
+			decision: "debezium schema",
+			target:   schemaIdent,
+			url:      strings.Join([]string{"", dbName, schemaName}, "/") + "?format=debezium",
+		},
+		{
+			name:     "debezium to table",
+			decision: "debezium table",
+			target:   tableIdent,
+			url:      strings.Join([]string{"", dbName, schemaName, tableName}, "/") + "?format=debezium",
+		},
+		{
+			name:     "table named debezium",
+			decision: "webhook table",
+			target:   ident.NewTable(schemaIdent, ident.New("debezium")),
+			url:      strings.Join([]string{"", dbName, schemaName, "debezium"}, "/"),
+		},
 		{
 			name:    "resolved too long",
 			url:     strings.Join([]string{"", dbName, schemaName, "too", "much", resolved}, "/"),
@@ -176,7 +238,76 @@ func TestParseChangefeedURL(t *testing.T) {
 			theURL, err := url.Parse(tt.url)
 			r.NoError(err)
 
-			request, err := h.newRequest(&http.Request{URL: theURL})
+			header := http.Header{}
+			if tt.contentType != "" {
+				header.Set("Content-Type", tt.contentType)
+			}
+			request, err := h.newRequest(&http.Request{Header: header, URL: theURL})
+			if tt.wantErr != "" {
+				a.ErrorContains(err, tt.wantErr)
+				return
+			}
+			a.NoError(err)
+			a.Equal(tt.decision, leafDecision)
+			a.Equalf(tt.target, request.target, "%s vs %s", tt.target, request.target)
+			a.Equal(tt.timestamp, request.timestamp)
+		})
+	}
+}
+
+func TestParseChangefeedFile(t *testing.T) {
+	schemaIdent := ident.MustSchema(ident.New("database"), ident.New("schema"))
+	ndjson := `2020-04-02/202004022058072107140000000000000-56087568dba1e6b8-1-72-00000000-REAL-42-1.ndjson`
+	parquet := `2020-04-02/202004022058072107140000000000000-56087568dba1e6b8-1-72-00000000-REAL-42-1.parquet`
+	resolved := `2020-04-04/202004042351304139680000000000000.RESOLVED`
+
+	tests := []struct {
+		name      string
+		decision  string
+		timestamp hlc.Time
+		target    ident.Schematic
+		wantErr   string
+	}{
+		{
+			name:     ndjson,
+			decision: "ndjson schema",
+			target:   ident.NewTable(schemaIdent, ident.New("REAL-42")),
+		},
+		{
+			name:     parquet,
+			decision: "parquet schema",
+			target:   ident.NewTable(schemaIdent, ident.New("REAL-42")),
+		},
+		{
+			name:      resolved,
+			decision:  "resolved",
+			target:    schemaIdent,
+			timestamp: hlc.New(1586044290413968000, 0),
+		},
+		{
+			name:    "2020-04-02/unknown.txt",
+			wantErr: "not a changefeed file",
+		},
+		{
+			// Webhook and Debezium paths are not files.
+			name:    "debezium",
+			wantErr: "not a changefeed file",
+		},
+		{
+			name:    "extra/" + ndjson,
+			wantErr: "not a changefeed file",
+		},
+	}
+
+	h := &Handler{}
+	var leafDecision string
+	requestParsingTestCallback = func(decision string) { leafDecision = decision }
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+
+			request, err := h.newFileRequest(schemaIdent, tt.name, nil)
 			if tt.wantErr != "" {
 				a.ErrorContains(err, tt.wantErr)
 				return
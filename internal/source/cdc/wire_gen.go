@@ -58,7 +58,8 @@ func newTestFixture(fixture *all.Fixture, config *Config) (*testFixture, func(),
 		return nil, nil, err
 	}
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, cleanup5, err := apply.ProvideFactory(targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	applyConfig := logical.ProvideApplyConfig(baseConfig)
+	appliers, cleanup5, err := apply.ProvideFactory(targetStatements, applyConfig, configs, diagnostics, dlQs, targetPool, watchers)
 	if err != nil {
 		cleanup4()
 		cleanup3()
@@ -121,7 +122,7 @@ func newTestFixture(fixture *all.Fixture, config *Config) (*testFixture, func(),
 	}
 	metaTable := ProvideMetaTable(config)
 	stagers := fixture.Stagers
-	resolvers, cleanup8, err := ProvideResolvers(context, config, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
+	resolvers, cleanup8, err := ProvideResolvers(context, appliers, config, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
 	if err != nil {
 		cleanup7()
 		cleanup6()
@@ -132,14 +133,29 @@ func newTestFixture(fixture *all.Fixture, config *Config) (*testFixture, func(),
 		cleanup()
 		return nil, nil, err
 	}
+	debeziumTransactions := ProvideDebeziumTransactions(memo, stagingPool)
+	avroRegistry, err := ProvideSchemaRegistry(config)
+	if err != nil {
+		cleanup8()
+		cleanup7()
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
 	handler := &Handler{
 		Authenticator: authenticator,
 		Config:        config,
 		Immediate:     immediate,
+		Registry:      avroRegistry,
 		Resolvers:     resolvers,
 		StagingPool:   stagingPool,
 		Stores:        stagers,
 		TargetPool:    targetPool,
+		Transactions:  debeziumTransactions,
 	}
 	cdcTestFixture := &testFixture{
 		Fixture:   fixture,
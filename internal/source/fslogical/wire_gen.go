@@ -82,7 +82,8 @@ func Start(contextContext context.Context, config *Config) (*FSLogical, func(),
 	}
 	dlqConfig := logical.ProvideDLQConfig(baseConfig)
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, cleanup6, err := apply.ProvideFactory(targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	applyConfig := logical.ProvideApplyConfig(baseConfig)
+	appliers, cleanup6, err := apply.ProvideFactory(targetStatements, applyConfig, configs, diagnostics, dlQs, targetPool, watchers)
 	if err != nil {
 		cleanup5()
 		cleanup4()
@@ -230,7 +231,8 @@ func startLoopsFromFixture(fixture *all.Fixture, config *Config) ([]*logical.Loo
 	}
 	dlqConfig := logical.ProvideDLQConfig(baseConfig)
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, cleanup6, err := apply.ProvideFactory(targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	applyConfig := logical.ProvideApplyConfig(baseConfig)
+	appliers, cleanup6, err := apply.ProvideFactory(targetStatements, applyConfig, configs, diagnostics, dlQs, targetPool, watchers)
 	if err != nil {
 		cleanup5()
 		cleanup4()
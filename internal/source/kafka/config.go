@@ -0,0 +1,146 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultClientID      = "cdc-sink"
+	defaultFlushInterval = time.Second
+)
+
+// Config contains the configuration necessary for consuming a
+// CockroachDB changefeed that has been written to Kafka topics.
+// Brokers, Topics, and a TargetSchema are mandatory.
+type Config struct {
+	// Mutations and resolved timestamps are handled in the same manner
+	// as the webhook-based changefeed server.
+	CDC cdc.Config
+	logical.LoopConfig
+
+	Brokers       []string      // Bootstrap addresses of the Kafka cluster.
+	ClientID      string        // Identifies this process to the brokers.
+	FlushInterval time.Duration // The longest that mutations will be buffered.
+	SASLUser      string        // Enables SASL/PLAIN authentication.
+	SASLPassword  string        // Used with SASLUser.
+	TLS           bool          // Connect to the brokers using TLS.
+	Topics        []string      // The changefeed's topics.
+	Version       string        // The Kafka protocol version to use.
+
+	// The fields below are extracted by Preflight.
+
+	version sarama.KafkaVersion
+}
+
+var _ logical.Config = (*Config)(nil)
+
+// Base implements logical.Config.
+func (c *Config) Base() *logical.BaseConfig {
+	return c.CDC.Base()
+}
+
+// Bind adds flags to the set. It delegates to the embedded Config.Bind.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	c.CDC.Bind(f)
+
+	c.LoopConfig.LoopName = "kafka"
+	c.LoopConfig.Bind(f)
+
+	f.StringSliceVar(&c.Brokers, "brokers", nil,
+		"the addresses of one or more Kafka brokers")
+	f.StringVar(&c.ClientID, "kafkaClientID", defaultClientID,
+		"the client id to report to the Kafka brokers")
+	f.DurationVar(&c.FlushInterval, "kafkaFlushInterval", defaultFlushInterval,
+		"the maximum length of time to buffer incoming mutations before staging them")
+	f.StringVar(&c.SASLUser, "kafkaUser", "",
+		"a username for SASL/PLAIN authentication")
+	f.StringVar(&c.SASLPassword, "kafkaPassword", "",
+		"a password for SASL/PLAIN authentication")
+	f.BoolVar(&c.TLS, "kafkaTLS", false,
+		"connect to the Kafka brokers using TLS")
+	f.StringSliceVar(&c.Topics, "topics", nil,
+		"the Kafka topics that the changefeed writes to")
+	f.StringVar(&c.Version, "kafkaVersion", "",
+		"the Kafka protocol version to use (e.g. 2.8.0)")
+}
+
+// Preflight updates the configuration with sane defaults or returns an
+// error if there are missing options for which a default cannot be
+// provided.
+func (c *Config) Preflight() error {
+	if err := c.CDC.Preflight(); err != nil {
+		return err
+	}
+	if err := c.LoopConfig.Preflight(); err != nil {
+		return err
+	}
+
+	if len(c.Brokers) == 0 {
+		return errors.New("no Brokers were configured")
+	}
+	if c.ClientID == "" {
+		c.ClientID = defaultClientID
+	}
+	if c.FlushInterval == 0 {
+		c.FlushInterval = defaultFlushInterval
+	}
+	if c.SASLPassword != "" && c.SASLUser == "" {
+		return errors.New("a SASL password requires a SASL user")
+	}
+	if len(c.Topics) == 0 {
+		return errors.New("no Topics were configured")
+	}
+
+	c.version = sarama.DefaultVersion
+	if c.Version != "" {
+		var err error
+		c.version, err = sarama.ParseKafkaVersion(c.Version)
+		if err != nil {
+			return errors.Wrapf(err, "invalid Kafka version %s", c.Version)
+		}
+	}
+	return nil
+}
+
+// saramaConfig returns the client configuration to use when connecting
+// to the brokers. Preflight must have been called.
+func (c *Config) saramaConfig() *sarama.Config {
+	ret := sarama.NewConfig()
+	ret.ClientID = c.ClientID
+	ret.Consumer.Return.Errors = true
+	ret.Version = c.version
+	if c.SASLUser != "" {
+		ret.Net.SASL.Enable = true
+		ret.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		ret.Net.SASL.User = c.SASLUser
+		ret.Net.SASL.Password = c.SASLPassword
+	}
+	if c.TLS {
+		ret.Net.TLS.Enable = true
+		ret.Net.TLS.Config = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return ret
+}
@@ -0,0 +1,422 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package kafka contains support for consuming a CockroachDB changefeed
+// that has been written to Kafka topics.
+package kafka
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/batches"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// conn reads messages from the Kafka brokers. Incoming mutations are
+// written to the staging tables and resolved timestamps are passed to
+// the same resolver loops that are used by the changefeed webhook
+// server. The consistent point of the loop records the offset of each
+// partition in the topics.
+type conn struct {
+	cfg         *Config
+	leases      types.Leases
+	resolvers   *cdc.Resolvers
+	stagers     types.Stagers
+	stagingPool *types.StagingPool
+}
+
+var (
+	_ logical.Backfiller = (*conn)(nil)
+	_ logical.Dialect    = (*conn)(nil)
+	_ logical.Lessor     = (*conn)(nil)
+)
+
+// Acquire implements logical.Lessor. Only one instance of cdc-sink
+// should consume the topics at any given time.
+func (c *conn) Acquire(ctx context.Context) (types.Lease, error) {
+	return c.leases.Acquire(ctx, c.cfg.LoopName)
+}
+
+// BackfillInto implements logical.Backfiller. It will consume all
+// messages up to the high-water mark of each partition that was present
+// when the method was called.
+func (c *conn) BackfillInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	return c.readInto(ctx, ch, state, true)
+}
+
+// ReadInto implements logical.Dialect.
+func (c *conn) ReadInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	return c.readInto(ctx, ch, state, false)
+}
+
+func (c *conn) readInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State, backfill bool,
+) error {
+	cp, _ := state.GetConsistentPoint()
+	resumeFrom, ok := cp.(*offsetStamp)
+	if !ok {
+		return errors.Errorf("unexpected consistent point %T", cp)
+	}
+
+	client, err := sarama.NewClient(c.cfg.Brokers, c.cfg.saramaConfig())
+	if err != nil {
+		dialFailureCount.Inc()
+		return errors.WithStack(err)
+	}
+	defer func() { _ = client.Close() }()
+	dialSuccessCount.Inc()
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = consumer.Close() }()
+
+	// Determine where to start reading each partition and, if we're
+	// backfilling, where to stop.
+	start := make(map[partitionKey]*partitionOffset)
+	stopAt := make(map[partitionKey]int64)
+	for _, topic := range c.cfg.Topics {
+		parts, err := client.Partitions(topic)
+		if err != nil {
+			return errors.Wrapf(err, "could not list partitions of topic %s", topic)
+		}
+		for _, part := range parts {
+			key := partitionKey{Topic: topic, Partition: part}
+			off, ok := resumeFrom.offset(key)
+			if !ok {
+				oldest, err := client.GetOffset(topic, part, sarama.OffsetOldest)
+				if err != nil {
+					return errors.Wrapf(err, "could not find oldest offset for %s[%d]", topic, part)
+				}
+				off = partitionOffset{Topic: topic, Partition: part, Offset: oldest}
+			}
+			start[key] = &off
+
+			if backfill {
+				newest, err := client.GetOffset(topic, part, sarama.OffsetNewest)
+				if err != nil {
+					return errors.Wrapf(err, "could not find newest offset for %s[%d]", topic, part)
+				}
+				stopAt[key] = newest
+			}
+		}
+	}
+
+	// Send the initial positions that we're reading from.
+	select {
+	case ch <- newOffsetStamp(start):
+	case <-state.Stopping():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	for key, off := range start {
+		key, from := key, off.Offset
+		if backfill && from >= stopAt[key] {
+			continue
+		}
+		eg.Go(func() error {
+			pc, err := consumer.ConsumePartition(key.Topic, key.Partition, from)
+			if err != nil {
+				return errors.Wrapf(err, "could not consume %s[%d]", key.Topic, key.Partition)
+			}
+			defer func() { _ = pc.Close() }()
+
+			log.WithFields(log.Fields{
+				"backfill":  backfill,
+				"offset":    from,
+				"partition": key.Partition,
+				"topic":     key.Topic,
+			}).Debug("consuming partition")
+
+			for {
+				select {
+				case msg, ok := <-pc.Messages():
+					if !ok {
+						return nil
+					}
+					select {
+					case ch <- msg:
+					case <-state.Stopping():
+						return nil
+					case <-egCtx.Done():
+						return nil
+					}
+					if backfill && msg.Offset+1 >= stopAt[key] {
+						return nil
+					}
+				case err := <-pc.Errors():
+					return errors.WithStack(err)
+				case <-state.Stopping():
+					return nil
+				case <-egCtx.Done():
+					return nil
+				}
+			}
+		})
+	}
+	return eg.Wait()
+}
+
+// Process implements logical.Dialect. It receives the initial offsets
+// from ReadInto, followed by the messages from each partition.
+func (c *conn) Process(
+	ctx context.Context, ch <-chan logical.Message, events logical.Events,
+) error {
+	cp, _ := events.GetConsistentPoint()
+	committed, ok := cp.(*offsetStamp)
+	if !ok {
+		return errors.Errorf("unexpected consistent point %T", cp)
+	}
+	p := &processor{
+		conn:      c,
+		committed: committed,
+		events:    events,
+		resolved:  committed.Resolved,
+	}
+	p.reset()
+
+	// Mutations may not be accompanied by a resolved timestamp for some
+	// time, so we'll periodically stage what we have.
+	ticker := time.NewTicker(c.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				// The channel will be closed when switching out of
+				// backfill mode.
+				return p.flush(ctx)
+			}
+			if err := p.onMessage(ctx, msg); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := p.flush(ctx); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// ZeroStamp implements logical.Dialect.
+func (c *conn) ZeroStamp() stamp.Stamp {
+	return &offsetStamp{}
+}
+
+// tableFor returns the target table for a topic. A changefeed names
+// its topics after the source table, optionally using a fully-qualified
+// name, so only the last component of the topic name is used.
+func tableFor(schema ident.Schema, topic string) ident.Table {
+	return ident.NewTable(schema, ident.New(topic[strings.LastIndexByte(topic, '.')+1:]))
+}
+
+// processor holds the state of a single call to Process.
+type processor struct {
+	*conn
+
+	committed  *offsetStamp // The most recent consistent point.
+	count      int          // The number of pending mutations.
+	events     logical.Events
+	partitions map[partitionKey]*partitionOffset // Nil until ReadInto has started.
+	pending    *ident.TableMap[[]types.Mutation]
+	resolved   hlc.Time // The most recent resolved timestamp that was marked.
+}
+
+// flush writes any pending mutations to the staging tables (or to the
+// target, in immediate mode), marks any newly-resolved timestamp, and
+// then advances the consistent point.
+func (p *processor) flush(ctx context.Context) error {
+	if p.partitions == nil {
+		return nil
+	}
+	if p.count > 0 {
+		var err error
+		if p.cfg.CDC.Immediate {
+			err = p.apply(ctx)
+		} else {
+			err = p.stage(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		p.pending = &ident.TableMap[[]types.Mutation]{}
+		p.count = 0
+	}
+
+	next := newOffsetStamp(p.partitions)
+
+	// All partitions have emitted their mutations up to the resolved
+	// timestamp and those mutations have been staged above.
+	if hlc.Compare(next.Resolved, p.resolved) > 0 {
+		target := p.events.GetTargetDB()
+		var err error
+		if p.cfg.CDC.Immediate {
+			err = p.resolvers.Record(ctx, target, next.Resolved)
+		} else {
+			err = p.resolvers.Mark(ctx, target, next.Resolved)
+		}
+		if err != nil {
+			return err
+		}
+		resolvedCount.Inc()
+		p.resolved = next.Resolved
+	}
+
+	// The stamp may not advance if a partition was added to a topic,
+	// since the new partition will not yet have a resolved timestamp.
+	if stamp.Compare(next, p.committed) <= 0 {
+		return nil
+	}
+	if err := p.events.SetConsistentPoint(ctx, next); err != nil {
+		return err
+	}
+	p.committed = next
+	return nil
+}
+
+// apply sends the pending mutations to the target, without waiting for
+// a resolved timestamp.
+func (p *processor) apply(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.CDC.ApplyTimeout)
+	defer cancel()
+
+	batch, err := p.events.OnBegin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = batch.OnRollback(ctx) }()
+
+	if err := p.pending.Range(func(table ident.Table, muts []types.Mutation) error {
+		for idx := range muts {
+			script.AddMeta("kafka", table, &muts[idx])
+		}
+		return batch.OnData(ctx, script.SourceName(table), table, muts)
+	}); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-batch.OnCommit(ctx):
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// onMessage dispatches the messages sent by ReadInto.
+func (p *processor) onMessage(ctx context.Context, msg logical.Message) error {
+	// Discard any partial state and wait for ReadInto to restart.
+	if logical.IsRollback(msg) {
+		p.reset()
+		return nil
+	}
+
+	switch t := msg.(type) {
+	case *offsetStamp:
+		// ReadInto has (re-)started from these offsets.
+		p.reset()
+		p.partitions = make(map[partitionKey]*partitionOffset, len(t.Partitions))
+		for _, part := range t.Partitions {
+			part := part
+			p.partitions[partitionKey{Topic: part.Topic, Partition: part.Partition}] = &part
+		}
+		return nil
+
+	case *sarama.ConsumerMessage:
+		return p.onConsumerMessage(ctx, t)
+
+	default:
+		return errors.Errorf("unexpected message %T", msg)
+	}
+}
+
+// onConsumerMessage decodes a single message from a partition.
+func (p *processor) onConsumerMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	part, ok := p.partitions[partitionKey{Topic: msg.Topic, Partition: msg.Partition}]
+	if !ok {
+		return errors.Errorf("message received from unexpected partition %s[%d]",
+			msg.Topic, msg.Partition)
+	}
+	// Ignore redelivered messages.
+	if msg.Offset < part.Offset {
+		return nil
+	}
+
+	mut, resolved, err := parseMessage(msg.Key, msg.Value)
+	if err != nil {
+		return errors.Wrapf(err, "could not decode %s[%d]@%d", msg.Topic, msg.Partition, msg.Offset)
+	}
+	part.Offset = msg.Offset + 1
+
+	if resolved != hlc.Zero() {
+		if hlc.Compare(resolved, part.Resolved) > 0 {
+			part.Resolved = resolved
+		}
+		// We must stage the mutations that precede the resolved
+		// timestamp before it can be marked.
+		return p.flush(ctx)
+	}
+
+	mutationCount.Inc()
+	table := tableFor(p.events.GetTargetDB(), msg.Topic)
+	p.pending.Put(table, append(p.pending.GetZero(table), mut))
+	p.count++
+	if p.count >= batches.Size() {
+		return p.flush(ctx)
+	}
+	return nil
+}
+
+// reset discards any pending mutations and partition state.
+func (p *processor) reset() {
+	p.count = 0
+	p.partitions = nil
+	p.pending = &ident.TableMap[[]types.Mutation]{}
+}
+
+// stage writes the pending mutations into the staging tables.
+func (p *processor) stage(ctx context.Context) error {
+	return p.pending.Range(func(table ident.Table, muts []types.Mutation) error {
+		store, err := p.stagers.Get(ctx, table)
+		if err != nil {
+			return err
+		}
+		return store.Store(ctx, p.stagingPool, muts)
+	})
+}
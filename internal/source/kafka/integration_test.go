@@ -0,0 +1,196 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/kafkafake"
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/require"
+)
+
+type fixtureConfig struct {
+	backfill  bool
+	immediate bool
+}
+
+func TestKafka(t *testing.T) {
+	t.Run("consistent", func(t *testing.T) { testKafka(t, &fixtureConfig{}) })
+	t.Run("consistent-backfill", func(t *testing.T) {
+		testKafka(t, &fixtureConfig{backfill: true})
+	})
+	t.Run("immediate", func(t *testing.T) {
+		testKafka(t, &fixtureConfig{immediate: true})
+	})
+	t.Run("immediate-backfill", func(t *testing.T) {
+		testKafka(t, &fixtureConfig{backfill: true, immediate: true})
+	})
+}
+
+func testKafka(t *testing.T, fc *fixtureConfig) {
+	r := require.New(t)
+
+	fixture, cancel, err := all.NewFixture()
+	r.NoError(err)
+	defer cancel()
+
+	ctx := fixture.Context
+
+	tbl, err := fixture.CreateTargetTable(ctx,
+		`CREATE TABLE %s (pk INT PRIMARY KEY, v VARCHAR(2048))`)
+	r.NoError(err)
+
+	// Use a fully-qualified topic name to ensure that it is mapped
+	// back onto the table.
+	const partitions = 4
+	topic := fmt.Sprintf("source.public.%s", tbl.Name().Table().Raw())
+	broker := kafkafake.New(t)
+	defer broker.Close()
+	broker.CreateTopic(topic, partitions)
+
+	// The primary key is sent as the message key. The choice of
+	// partition is arbitrary, as long as it's stable for a given key.
+	now := time.Now().UnixNano()
+	produce := func(pk int, value string, ts hlc.Time) {
+		t.Helper()
+		var after string
+		if value == "" {
+			after = "null"
+		} else {
+			after = fmt.Sprintf(`{"pk":%d,"v":%q}`, pk, value)
+		}
+		_, err := broker.Produce(topic, int32(pk%partitions),
+			[]byte(fmt.Sprintf("[%d]", pk)),
+			[]byte(fmt.Sprintf(`{"after":%s,"updated":%q}`, after, ts.String())))
+		r.NoError(err)
+	}
+	resolve := func(ts hlc.Time) {
+		t.Helper()
+		for part := int32(0); part < partitions; part++ {
+			_, err := broker.Produce(topic, part, nil,
+				[]byte(fmt.Sprintf(`{"resolved":%q}`, ts.String())))
+			r.NoError(err)
+		}
+	}
+	waitFor := func(query string, expected int) {
+		t.Helper()
+		for {
+			var count int
+			r.NoError(fixture.TargetPool.QueryRowContext(ctx,
+				fmt.Sprintf(query, tbl.Name())).Scan(&count))
+			if count == expected {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				r.NoError(ctx.Err())
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	// Write some data into the topic before starting the consumer.
+	const rowCount = 128
+	for i := 0; i < rowCount; i++ {
+		produce(i, fmt.Sprintf("v=%d", i), hlc.New(now, i))
+	}
+	resolve(hlc.New(now+1, 0))
+
+	config := &Config{
+		CDC: cdc.Config{
+			BaseConfig: logical.BaseConfig{
+				ApplyTimeout:  2 * time.Minute, // Increase to make using the debugger easier.
+				Immediate:     fc.immediate,
+				RetryDelay:    time.Nanosecond,
+				StagingConn:   fixture.StagingPool.ConnectionString,
+				StagingSchema: fixture.StagingDB.Schema(),
+				TargetConn:    fixture.TargetPool.ConnectionString,
+			},
+			MetaTableName: ident.New("resolved_timestamps"),
+		},
+		LoopConfig: logical.LoopConfig{
+			LoopName:     "kafkatest",
+			TargetSchema: fixture.TargetSchema.Schema(),
+		},
+		Brokers:       []string{broker.Addr()},
+		FlushInterval: 10 * time.Millisecond,
+		Topics:        []string{topic},
+		Version:       kafkafake.Version.String(),
+	}
+	if fc.backfill {
+		config.BackfillWindow = time.Minute
+	}
+	r.NoError(config.Preflight())
+
+	consumer, cancelConsumer, err := Start(ctx, config)
+	r.NoError(err)
+	defer cancelConsumer()
+
+	waitFor("SELECT count(*) FROM %s", rowCount)
+
+	// Update all rows while the consumer is running.
+	for i := 0; i < rowCount; i++ {
+		produce(i, "updated", hlc.New(now+2, i))
+	}
+	resolve(hlc.New(now+3, 0))
+	waitFor("SELECT count(*) FROM %s WHERE v = 'updated'", rowCount)
+
+	// Delete some rows.
+	for i := 0; i < rowCount/2; i++ {
+		produce(i, "", hlc.New(now+4, i))
+	}
+	resolve(hlc.New(now+5, 0))
+	waitFor("SELECT count(*) FROM %s", rowCount/2)
+
+	// Verify that the consumer's offsets have been persisted.
+	for {
+		cp, updated := consumer.Loop.GetConsistentPoint()
+		if hlc.Compare(cp.(*offsetStamp).Resolved, hlc.New(now+5, 0)) >= 0 {
+			var total int64
+			for _, part := range cp.(*offsetStamp).Partitions {
+				total += part.Offset
+			}
+			// Count the inserts, updates, and deletes, plus three
+			// rounds of resolved timestamps in each partition.
+			r.Equal(int64(rowCount*2+rowCount/2+3*partitions), total)
+			break
+		}
+		select {
+		case <-updated:
+		case <-ctx.Done():
+			r.NoError(ctx.Err())
+		}
+	}
+
+	sinktest.CheckDiagnostics(ctx, t, consumer.Diagnostics)
+
+	cancelConsumer()
+	select {
+	case <-ctx.Done():
+		r.Fail("cancelConsumer timed out")
+	case <-consumer.Loop.Stopped():
+		// OK
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
+)
+
+// Kafka is a changefeed consumer loop.
+type Kafka struct {
+	Diagnostics *diag.Diagnostics
+	Loop        *logical.Loop
+}
+
+var (
+	_ stdlogical.HasDiagnostics = (*Kafka)(nil)
+	_ stdlogical.HasStoppable   = (*Kafka)(nil)
+)
+
+// GetDiagnostics implements [stdlogical.HasDiagnostics].
+func (k *Kafka) GetDiagnostics() *diag.Diagnostics {
+	return k.Diagnostics
+}
+
+// GetStoppable implements [stdlogical.HasStoppable].
+func (k *Kafka) GetStoppable() types.Stoppable {
+	return k.Loop
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/pkg/errors"
+)
+
+// payload is the wrapped envelope that a changefeed writes into Kafka.
+// Resolved-timestamp messages will only have the Resolved field set.
+type payload struct {
+	After    json.RawMessage `json:"after"`
+	Before   json.RawMessage `json:"before"`
+	Key      json.RawMessage `json:"key"`
+	Resolved string          `json:"resolved"`
+	Updated  string          `json:"updated"`
+}
+
+// parseMessage decodes the key and value of a Kafka message. If the
+// message contains a resolved timestamp, the returned time will be
+// non-zero. Otherwise, the mutation will be populated.
+func parseMessage(key, value []byte) (types.Mutation, hlc.Time, error) {
+	if len(value) == 0 {
+		return types.Mutation{}, hlc.Zero(),
+			errors.New("empty message; CREATE CHANGEFEED must use the default 'envelope=wrapped' option")
+	}
+	var p payload
+	// Large numbers are not turned into strings, so the UseNumber option for
+	// the decoder is required.
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	if err := dec.Decode(&p); err != nil {
+		return types.Mutation{}, hlc.Zero(), errors.WithStack(err)
+	}
+
+	if p.Resolved != "" {
+		ts, err := hlc.Parse(p.Resolved)
+		return types.Mutation{}, ts, err
+	}
+
+	if p.Updated == "" {
+		return types.Mutation{}, hlc.Zero(),
+			errors.New("CREATE CHANGEFEED must specify the 'WITH updated' option")
+	}
+	ts, err := hlc.Parse(p.Updated)
+	if err != nil {
+		return types.Mutation{}, hlc.Zero(), err
+	}
+
+	// The key is only in the value if key_in_value was specified.
+	if len(p.Key) == 0 {
+		p.Key = key
+	}
+	if len(p.Key) == 0 {
+		return types.Mutation{}, hlc.Zero(), errors.New("message has no key")
+	}
+
+	return types.Mutation{
+		Before: p.Before,
+		Data:   p.After,
+		Key:    p.Key,
+		Time:   ts,
+	}, hlc.Zero(), nil
+}
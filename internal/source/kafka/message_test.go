@@ -0,0 +1,124 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMessage(t *testing.T) {
+	tcs := []struct {
+		name     string
+		key      string
+		value    string
+		after    string
+		before   string
+		mutKey   string
+		resolved hlc.Time
+		time     hlc.Time
+		err      string
+	}{
+		{
+			name:   "upsert",
+			key:    `[1]`,
+			value:  `{"after":{"pk":1,"v":"hello"},"updated":"100.0000000002"}`,
+			after:  `{"pk":1,"v":"hello"}`,
+			mutKey: `[1]`,
+			time:   hlc.New(100, 2),
+		},
+		{
+			name:   "diff",
+			key:    `[1]`,
+			value:  `{"after":{"pk":1,"v":2},"before":{"pk":1,"v":1},"updated":"100.0000000000"}`,
+			after:  `{"pk":1,"v":2}`,
+			before: `{"pk":1,"v":1}`,
+			mutKey: `[1]`,
+			time:   hlc.New(100, 0),
+		},
+		{
+			name:   "delete",
+			key:    `[1]`,
+			value:  `{"after":null,"updated":"100.0000000000"}`,
+			after:  `null`,
+			mutKey: `[1]`,
+			time:   hlc.New(100, 0),
+		},
+		{
+			name:   "key_in_value",
+			value:  `{"after":{"pk":1},"key":[1],"updated":"100.0000000000"}`,
+			after:  `{"pk":1}`,
+			mutKey: `[1]`,
+			time:   hlc.New(100, 0),
+		},
+		{
+			name:     "resolved",
+			value:    `{"resolved":"200.0000000001"}`,
+			resolved: hlc.New(200, 1),
+		},
+		{
+			name:  "empty",
+			key:   `[1]`,
+			value: ``,
+			err:   "envelope=wrapped",
+		},
+		{
+			name:  "no_updated",
+			key:   `[1]`,
+			value: `{"after":{"pk":1}}`,
+			err:   "'WITH updated'",
+		},
+		{
+			name:  "no_key",
+			value: `{"after":{"pk":1},"updated":"100.0000000000"}`,
+			err:   "message has no key",
+		},
+		{
+			name:  "bad_json",
+			key:   `[1]`,
+			value: `{`,
+			err:   "unexpected EOF",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			r := require.New(t)
+			var key []byte
+			if tc.key != "" {
+				key = []byte(tc.key)
+			}
+			mut, resolved, err := parseMessage(key, []byte(tc.value))
+			if tc.err != "" {
+				r.ErrorContains(err, tc.err)
+				return
+			}
+			r.NoError(err)
+			r.Equal(tc.resolved, resolved)
+			if tc.resolved != hlc.Zero() {
+				return
+			}
+			r.Equal(tc.after, string(mut.Data))
+			r.Equal(tc.before, string(mut.Before))
+			r.Equal(tc.mutKey, string(mut.Key))
+			r.Equal(tc.time, mut.Time)
+		})
+	}
+}
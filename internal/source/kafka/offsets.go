@@ -0,0 +1,146 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+)
+
+// partitionKey identifies a partition within a topic.
+type partitionKey struct {
+	Topic     string
+	Partition int32
+}
+
+// partitionOffset records the progress made in consuming a single
+// partition of a topic.
+type partitionOffset struct {
+	Topic     string   `json:"topic"`
+	Partition int32    `json:"partition"`
+	Offset    int64    `json:"offset"`   // The next offset to consume.
+	Resolved  hlc.Time `json:"resolved"` // The last resolved timestamp seen.
+}
+
+// offsetStamp is the consistent point of the Kafka loop. It records
+// the next offset to read from each partition, along with the
+// resolved timestamps that have been observed. An offsetStamp must not
+// be modified once it has been constructed.
+type offsetStamp struct {
+	// Sorted by topic and then by partition.
+	Partitions []partitionOffset `json:"partitions,omitempty"`
+	// The minimum resolved timestamp across all partitions.
+	Resolved hlc.Time `json:"resolved"`
+}
+
+var _ logical.TimeStamp = (*offsetStamp)(nil)
+
+// newOffsetStamp constructs an offsetStamp from the given partition
+// states.
+func newOffsetStamp(partitions map[partitionKey]*partitionOffset) *offsetStamp {
+	ret := &offsetStamp{
+		Partitions: make([]partitionOffset, 0, len(partitions)),
+	}
+	for _, part := range partitions {
+		ret.Partitions = append(ret.Partitions, *part)
+	}
+	sort.Slice(ret.Partitions, func(i, j int) bool {
+		a, b := ret.Partitions[i], ret.Partitions[j]
+		if c := strings.Compare(a.Topic, b.Topic); c != 0 {
+			return c < 0
+		}
+		return a.Partition < b.Partition
+	})
+	ret.Resolved = minResolved(partitions)
+	return ret
+}
+
+// minResolved returns the minimum resolved timestamp across all
+// partitions. That is, the time for which every partition has emitted
+// all of its mutations.
+func minResolved(partitions map[partitionKey]*partitionOffset) hlc.Time {
+	if len(partitions) == 0 {
+		return hlc.Zero()
+	}
+	first := true
+	var ret hlc.Time
+	for _, part := range partitions {
+		if first || hlc.Compare(part.Resolved, ret) < 0 {
+			ret = part.Resolved
+			first = false
+		}
+	}
+	return ret
+}
+
+// AsTime implements logical.TimeStamp.
+func (s *offsetStamp) AsTime() time.Time {
+	return time.Unix(0, s.Resolved.Nanos())
+}
+
+// Less implements stamp.Stamp. Stamps are ordered by their resolved
+// timestamps and then by the total number of messages consumed.
+func (s *offsetStamp) Less(other stamp.Stamp) bool {
+	o := other.(*offsetStamp)
+	if c := hlc.Compare(s.Resolved, o.Resolved); c != 0 {
+		return c < 0
+	}
+	return s.total() < o.total()
+}
+
+// offset returns the progress recorded for the partition.
+func (s *offsetStamp) offset(key partitionKey) (partitionOffset, bool) {
+	idx := sort.Search(len(s.Partitions), func(i int) bool {
+		part := s.Partitions[i]
+		if c := strings.Compare(part.Topic, key.Topic); c != 0 {
+			return c > 0
+		}
+		return part.Partition >= key.Partition
+	})
+	if idx < len(s.Partitions) {
+		if found := s.Partitions[idx]; found.Topic == key.Topic && found.Partition == key.Partition {
+			return found, true
+		}
+	}
+	return partitionOffset{}, false
+}
+
+// String is for debugging use only.
+func (s *offsetStamp) String() string {
+	var sb strings.Builder
+	sb.WriteString(s.Resolved.String())
+	for _, part := range s.Partitions {
+		_, _ = fmt.Fprintf(&sb, " %s[%d]@%d", part.Topic, part.Partition, part.Offset)
+	}
+	return sb.String()
+}
+
+// total returns the sum of all offsets. Since offsets only increase,
+// this value will increase as messages are consumed.
+func (s *offsetStamp) total() int64 {
+	var ret int64
+	for _, part := range s.Partitions {
+		ret += part.Offset
+	}
+	return ret
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffsetStamp(t *testing.T) {
+	r := require.New(t)
+
+	parts := map[partitionKey]*partitionOffset{
+		{Topic: "b", Partition: 0}: {Topic: "b", Partition: 0, Offset: 10, Resolved: hlc.New(200, 0)},
+		{Topic: "a", Partition: 1}: {Topic: "a", Partition: 1, Offset: 5, Resolved: hlc.New(100, 1)},
+		{Topic: "a", Partition: 0}: {Topic: "a", Partition: 0, Offset: 7, Resolved: hlc.New(300, 0)},
+	}
+	s := newOffsetStamp(parts)
+
+	// Partitions are sorted and the minimum resolved time is used.
+	r.Len(s.Partitions, 3)
+	r.Equal("a", s.Partitions[0].Topic)
+	r.Equal(int32(0), s.Partitions[0].Partition)
+	r.Equal("a", s.Partitions[1].Topic)
+	r.Equal(int32(1), s.Partitions[1].Partition)
+	r.Equal("b", s.Partitions[2].Topic)
+	r.Equal(hlc.New(100, 1), s.Resolved)
+	r.Equal(time.Unix(0, 100), s.AsTime())
+
+	found, ok := s.offset(partitionKey{Topic: "a", Partition: 1})
+	r.True(ok)
+	r.Equal(int64(5), found.Offset)
+	_, ok = s.offset(partitionKey{Topic: "a", Partition: 2})
+	r.False(ok)
+	_, ok = s.offset(partitionKey{Topic: "c", Partition: 0})
+	r.False(ok)
+
+	// Check round-tripping through the memo table.
+	data, err := json.Marshal(s)
+	r.NoError(err)
+	var decoded offsetStamp
+	r.NoError(json.Unmarshal(data, &decoded))
+	r.Equal(s, &decoded)
+	r.Equal(0, stamp.Compare(s, &decoded))
+
+	// Consuming more messages advances the stamp.
+	parts[partitionKey{Topic: "b", Partition: 0}].Offset++
+	more := newOffsetStamp(parts)
+	r.Equal(-1, stamp.Compare(s, more))
+
+	// Advancing the resolved timestamp advances the stamp.
+	parts[partitionKey{Topic: "a", Partition: 1}].Resolved = hlc.New(150, 0)
+	resolved := newOffsetStamp(parts)
+	r.Equal(hlc.New(150, 0), resolved.Resolved)
+	r.Equal(-1, stamp.Compare(more, resolved))
+
+	// The zero value sorts first.
+	r.Equal(-1, stamp.Compare(&offsetStamp{}, s))
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+)
+
+// TableData contains the mutations for a single target table.
+type TableData struct {
+	Muts   []types.Mutation
+	Source ident.Ident // The name passed to [Batch.OnData].
+	Target ident.Table
+}
+
+// ApplyBatch writes the data to the target within a single Batch and
+// then advances the consistent point. It is intended for use by
+// Dialects whose messages each contain a complete source transaction.
+// If there is no data, only the consistent point is advanced.
+func ApplyBatch(ctx context.Context, events Events, cp stamp.Stamp, data []TableData) error {
+	if len(data) > 0 {
+		batch, err := events.OnBegin(ctx)
+		if err != nil {
+			return err
+		}
+		for _, tbl := range data {
+			if err := batch.OnData(ctx, tbl.Source, tbl.Target, tbl.Muts); err != nil {
+				_ = batch.OnRollback(ctx)
+				return err
+			}
+		}
+		select {
+		case err := <-batch.OnCommit(ctx):
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return events.SetConsistentPoint(ctx, cp)
+}
@@ -203,6 +203,13 @@ func (e *chaosBatch) OnRollback(ctx context.Context) error {
 	return e.delegate.OnRollback(ctx)
 }
 
+func (e *chaosBatch) OnTruncate(ctx context.Context, source ident.Ident, target ident.Table) error {
+	if rand.Float32() < e.prob {
+		return doChaos("OnTruncate")
+	}
+	return e.delegate.OnTruncate(ctx, source, target)
+}
+
 // doChaos is a convenient place to set a breakpoint.
 func doChaos(msg string) error {
 	return errors.WithMessage(ErrChaos, msg)
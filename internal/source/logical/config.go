@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/target/apply"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/pkg/errors"
@@ -52,6 +53,8 @@ type BaseConfig struct {
 	// The maximum length of time to wait for an incoming transaction
 	// to settle (i.e. to detect stalls in the target database).
 	ApplyTimeout time.Duration
+	// Bulk-loading configuration.
+	ApplyConfig apply.Config
 	// BackfillWindow enables the use of fan mode for backfilling data
 	// sources if the consistent point is older than the specified
 	// duration. A zero value disables the use of backfill mode.
@@ -107,6 +110,7 @@ func (c *BaseConfig) Base() *BaseConfig {
 
 // Bind adds flags to the set.
 func (c *BaseConfig) Bind(f *pflag.FlagSet) {
+	c.ApplyConfig.Bind(f)
 	c.DLQConfig.Bind(f)
 	c.ScriptConfig.Bind(f)
 
@@ -156,6 +160,9 @@ func (c *BaseConfig) Copy() *BaseConfig {
 // and returns an error if the Config is missing any fields for which a
 // default cannot be provided.
 func (c *BaseConfig) Preflight() error {
+	if err := c.ApplyConfig.Preflight(); err != nil {
+		return err
+	}
 	if err := c.DLQConfig.Preflight(); err != nil {
 		return err
 	}
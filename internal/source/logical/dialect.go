@@ -140,6 +140,11 @@ type Batch interface {
 	// message is encountered, to ensure that all internal state has
 	// been resynchronized.
 	OnRollback(ctx context.Context) error
+	// OnTruncate removes all rows from the target table. The truncation
+	// is ordered with respect to the calls to OnData that precede and
+	// follow it within the batch. The source is passed to the
+	// user-script, as with OnData.
+	OnTruncate(ctx context.Context, source ident.Ident, target ident.Table) error
 }
 
 // State provides information about a replication loop.
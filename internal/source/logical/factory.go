@@ -139,6 +139,11 @@ func (f *Factory) newLoop(ctx *stopper.Context, config *LoopConfig) (*Loop, func
 	}
 	loop.consistentPoint.Set(initialPoint)
 
+	loop.events.backfill = &fanEvents{
+		bulk: true,
+		loop: loop,
+	}
+
 	loop.events.fan = &fanEvents{
 		loop: loop,
 	}
@@ -150,6 +155,10 @@ func (f *Factory) newLoop(ctx *stopper.Context, config *LoopConfig) (*Loop, func
 	}
 
 	if f.baseConfig.ForeignKeysEnabled {
+		loop.events.backfill = &orderedEvents{
+			Events:  loop.events.backfill,
+			Watcher: watcher,
+		}
 		loop.events.fan = &orderedEvents{
 			Events:  loop.events.fan,
 			Watcher: watcher,
@@ -190,6 +199,10 @@ func (f *Factory) newLoop(ctx *stopper.Context, config *LoopConfig) (*Loop, func
 
 	// Apply logic and configurations defined by the user-script.
 	if userscript.Sources.Len() > 0 || userscript.Targets.Len() > 0 {
+		loop.events.backfill = &scriptEvents{
+			Events: loop.events.backfill,
+			Script: userscript,
+		}
 		loop.events.fan = &scriptEvents{
 			Events: loop.events.fan,
 			Script: userscript,
@@ -200,6 +213,7 @@ func (f *Factory) newLoop(ctx *stopper.Context, config *LoopConfig) (*Loop, func
 		}
 	}
 
+	loop.events.backfill = (&metricsEvents{Events: loop.events.backfill}).withLoopName(config.LoopName)
 	loop.events.fan = (&metricsEvents{Events: loop.events.fan}).withLoopName(config.LoopName)
 	loop.events.serial = (&metricsEvents{Events: loop.events.serial}).withLoopName(config.LoopName)
 
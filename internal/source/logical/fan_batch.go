@@ -29,10 +29,14 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// bulkBatchFactor increases the size of batches that are bulk-loaded.
+const bulkBatchFactor = 100
+
 // fanBatch is an implementation of the Batch interface that applies
 // mutations using a fixed pool of goroutines.
 type fanBatch struct {
 	batchSize    int
+	bulk         bool // Use Appliers.GetBulk.
 	pending      *latch.Counter
 	parent       *loop
 	state        notify.Var[*fanBatchState]
@@ -51,12 +55,18 @@ type fanBatchState struct {
 
 // newFanBatch constructs and starts a pool of workers to apply
 // mutations to destination tables.
-func newFanBatch(ctx context.Context, loop *loop) *fanBatch {
+func newFanBatch(ctx context.Context, loop *loop, bulk bool) *fanBatch {
 	ret := &fanBatch{
 		batchSize: batches.Size(),
+		bulk:      bulk,
 		parent:    loop,
 		pending:   latch.New(),
 	}
+	// Bulk loads amortize the cost of staging the data over many more
+	// rows than a multi-row statement.
+	if bulk {
+		ret.batchSize *= bulkBatchFactor
+	}
 	ret.state.Set(&fanBatchState{
 		data: &ident.TableMap[[]types.Mutation]{},
 	})
@@ -152,6 +162,29 @@ func (b *fanBatch) OnRollback(ctx context.Context) error {
 	}
 }
 
+// OnTruncate implements Batch. Any mutations for the table that have
+// not yet been dequeued are discarded, since they would be deleted
+// anyway. The batch is then flushed to ensure that there are no
+// in-flight writes to the table before its rows are deleted.
+func (b *fanBatch) OnTruncate(ctx context.Context, _ ident.Ident, table ident.Table) error {
+	if _, _, err := b.state.Update(func(state *fanBatchState) (*fanBatchState, error) {
+		if state.drain {
+			return nil, errors.New("OnTruncate() after OnCommit() / OnRollback()")
+		}
+		if discarded := state.data.GetZero(table); len(discarded) > 0 {
+			state.data.Delete(table)
+			b.pending.Apply(-len(discarded))
+		}
+		return state, nil
+	}); err != nil {
+		return err
+	}
+	if err := b.Flush(ctx); err != nil {
+		return err
+	}
+	return truncateTable(ctx, b.parent.factory.targetPool, table)
+}
+
 // chaos sometimes returns an error for testing.
 func (b *fanBatch) chaos() error {
 	if prob := b.parent.factory.baseConfig.ChaosProb; prob != 0 && rand.Float32() < prob {
@@ -179,7 +212,11 @@ func (b *fanBatch) loop(ctx context.Context) error {
 		if err := b.chaos(); err != nil {
 			return false, err
 		}
-		applier, err := appliers.Get(ctx, table)
+		getApplier := appliers.Get
+		if b.bulk {
+			getApplier = appliers.GetBulk
+		}
+		applier, err := getApplier(ctx, table)
 		if err != nil {
 			return false, errors.Wrapf(err, "table %s", table)
 		}
@@ -26,6 +26,7 @@ import (
 // fanEvents is a high-throughput implementation of Events which
 // does not preserve transaction boundaries.
 type fanEvents struct {
+	bulk bool  // Use bulk-loading appliers, e.g. for backfills.
 	loop *loop // The underlying loop.
 }
 
@@ -46,7 +47,7 @@ func (f *fanEvents) GetTargetDB() ident.Schema { return f.loop.GetTargetDB() }
 
 // OnBegin implements Events.
 func (f *fanEvents) OnBegin(ctx context.Context) (Batch, error) {
-	return newFanBatch(ctx, f.loop), nil
+	return newFanBatch(ctx, f.loop, f.bulk), nil
 }
 
 // SetConsistentPoint implements State.
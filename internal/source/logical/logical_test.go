@@ -467,3 +467,111 @@ api.configureTable("tgt", {
 
 	r.NoError(<-batch.OnCommit(ctx))
 }
+
+// TestTruncate verifies that truncations are ordered with respect to
+// other data in the batch and, in FK mode, with respect to the table
+// dependency order.
+func TestTruncate(t *testing.T) {
+	tcs := []struct {
+		name string
+		mode *logicalTestMode
+	}{
+		{"consistent", &logicalTestMode{}},
+		{"fk", &logicalTestMode{fk: true}},
+		{"immediate", &logicalTestMode{immediate: true}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			testTruncate(t, tc.mode)
+		})
+	}
+}
+
+func testTruncate(t *testing.T, mode *logicalTestMode) {
+	r := require.New(t)
+
+	// Create a basic test fixture.
+	fixture, cancel, err := base.NewFixture()
+	r.NoError(err)
+	defer cancel()
+
+	ctx := fixture.Context
+	targetSchema := fixture.TargetSchema.Schema()
+	pool := fixture.TargetPool
+
+	parent := ident.NewTable(targetSchema, ident.New("parent"))
+	child := ident.NewTable(targetSchema, ident.New("child"))
+	_, err = pool.ExecContext(ctx, fmt.Sprintf(
+		`CREATE TABLE %s (k INT PRIMARY KEY, v VARCHAR(2048), ref INT)`, parent))
+	r.NoError(err)
+	childSchema := fmt.Sprintf(
+		`CREATE TABLE %s (k INT PRIMARY KEY, v VARCHAR(2048), ref INT)`, child)
+	if mode.fk {
+		childSchema = fmt.Sprintf(`
+			CREATE TABLE %s (
+				k INT PRIMARY KEY, v VARCHAR(2048), ref INT NOT NULL,
+				FOREIGN KEY(ref) REFERENCES %s(k))`, child, parent)
+	}
+	_, err = pool.ExecContext(ctx, childSchema)
+	r.NoError(err)
+
+	cfg := &logical.BaseConfig{
+		ApplyTimeout:       2 * time.Minute, // Increase to make using the debugger easier.
+		ForeignKeysEnabled: mode.fk,
+		Immediate:          mode.immediate,
+		StagingConn:        fixture.StagingPool.ConnectionString,
+		StagingSchema:      fixture.StagingDB.Schema(),
+		StandbyTimeout:     5 * time.Millisecond,
+		TargetConn:         pool.ConnectionString,
+	}
+
+	factory, cancelFactory, err := logical.NewFactoryForTests(ctx, cfg)
+	r.NoError(err)
+	defer cancelFactory()
+
+	batcher, cancel, err := factory.Immediate(ctx, targetSchema)
+	r.NoError(err)
+	defer cancel()
+
+	mut := func(k int) []types.Mutation {
+		return []types.Mutation{{
+			Key:  []byte(fmt.Sprintf(`[%d]`, k)),
+			Data: []byte(fmt.Sprintf(`{"k":%[1]d,"v":"%[1]d","ref":%[1]d}`, k)),
+		}}
+	}
+
+	// Populate the tables.
+	batch, err := batcher.OnBegin(ctx)
+	r.NoError(err)
+	for k := 0; k < 10; k++ {
+		r.NoError(batch.OnData(ctx, parent.Table(), parent, mut(k)))
+		r.NoError(batch.OnData(ctx, child.Table(), child, mut(k)))
+	}
+	r.NoError(<-batch.OnCommit(ctx))
+
+	// Truncate the parent before the child, which is only possible in
+	// FK mode if the truncations are re-ordered. Data that is written
+	// after the truncation must be retained.
+	batch, err = batcher.OnBegin(ctx)
+	r.NoError(err)
+	if mode.fk {
+		r.NoError(batch.OnTruncate(ctx, parent.Table(), parent))
+		r.NoError(batch.OnTruncate(ctx, child.Table(), child))
+	} else {
+		r.NoError(batch.OnTruncate(ctx, child.Table(), child))
+		r.NoError(batch.OnTruncate(ctx, parent.Table(), parent))
+	}
+	r.NoError(batch.OnData(ctx, parent.Table(), parent, mut(100)))
+	r.NoError(batch.OnData(ctx, child.Table(), child, mut(100)))
+	r.NoError(<-batch.OnCommit(ctx))
+
+	for _, tbl := range []ident.Table{parent, child} {
+		var count int
+		r.NoError(pool.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT count(*) FROM %s WHERE k = 100", tbl)).Scan(&count))
+		r.Equal(1, count, tbl)
+		r.NoError(pool.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT count(*) FROM %s", tbl)).Scan(&count))
+		r.Equal(1, count, tbl)
+	}
+}
@@ -73,8 +73,9 @@ func (l *Loop) Stopped() <-chan struct{} {
 type loop struct {
 	// Various strategies for implementing the Events interface.
 	events struct {
-		fan    Events
-		serial Events
+		backfill Events // Like fan, but prefers to bulk-load data.
+		fan      Events
+		serial   Events
 	}
 	// The Factory that created the loop.
 	factory *Factory
@@ -411,7 +412,7 @@ func (l *loop) chooseFillStrategy() (choice fillFn, events Events, isBackfill bo
 		return
 	}
 	choice = back.BackfillInto
-	events = l.events.fan
+	events = l.events.backfill
 	isBackfill = true
 	log.WithFields(log.Fields{
 		"delta": delta,
@@ -440,7 +441,7 @@ func (l *loop) doBackfill(ctx context.Context, loopName string, backfiller Backf
 	return filler.loop.runOnceUsing(
 		stop,
 		backfiller.BackfillInto,
-		filler.loop.events.fan,
+		filler.loop.events.backfill,
 		true /* isBackfilling */)
 }
 
@@ -19,6 +19,7 @@ package logical
 import (
 	"context"
 	"reflect"
+	"sort"
 
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
@@ -32,6 +33,14 @@ type deferredData struct {
 	target ident.Table
 }
 
+// deferredTruncate saves calls to OnTruncate so that they may be
+// applied in dependency order.
+type deferredTruncate struct {
+	level  int
+	source ident.Ident
+	target ident.Table
+}
+
 // orderedEvents provides compatibility with target schemas that
 // have foreign keys enabled. It does this by accumulating and sorting
 // mutations to satisfy an (acyclic) FK dependency graph.
@@ -77,6 +86,9 @@ type orderedBatch struct {
 	// here; they're immediately passed through.
 	deferred [][]deferredData
 	parent   *orderedEvents
+	// Truncations that have yet to be applied. These are applied to
+	// the most-dependent tables first.
+	truncates []deferredTruncate
 }
 
 var _ Batch = (*orderedBatch)(nil)
@@ -85,6 +97,9 @@ var _ Batch = (*orderedBatch)(nil)
 func (e *orderedBatch) OnCommit(ctx context.Context) <-chan error {
 	defer func() { e.deferred = nil }()
 
+	if err := e.flushTruncates(ctx); err != nil {
+		return singletonChannel(err)
+	}
 	for _, defs := range e.deferred {
 		// Ensure that previous levels have been completely written out
 		// before we write the next level.
@@ -110,6 +125,9 @@ func (e *orderedBatch) OnData(
 	if !ok {
 		return errors.Errorf("unknown destination table %s", target)
 	}
+	if err := e.flushTruncates(ctx); err != nil {
+		return err
+	}
 	if destLevel == 0 {
 		return errors.Wrap(e.Batch.OnData(ctx, source, target, muts), "orderedEvents OnData")
 	}
@@ -120,5 +138,53 @@ func (e *orderedBatch) OnData(
 // OnRollback implements Events. It clears the internal state.
 func (e *orderedBatch) OnRollback(ctx context.Context) error {
 	e.deferred = nil
+	e.truncates = nil
 	return errors.Wrap(e.Batch.OnRollback(ctx), "orderedEvents OnRollback")
 }
+
+// OnTruncate implements Events. Any deferred updates to the table are
+// discarded, since they would be deleted anyway. The truncation itself
+// is deferred until the next call to OnData or OnCommit so that a
+// sequence of truncations (e.g. from a TRUNCATE ... CASCADE in the
+// source) can be applied to child tables before their parents.
+func (e *orderedBatch) OnTruncate(
+	_ context.Context, source ident.Ident, target ident.Table,
+) error {
+	destLevel, ok := e.parent.levels.Get(target)
+	if !ok {
+		return errors.Errorf("unknown destination table %s", target)
+	}
+	if destLevel > 0 {
+		// Slice-filter trick.
+		defs := e.deferred[destLevel-1]
+		idx := 0
+		for _, def := range defs {
+			if ident.Equal(def.target, target) {
+				continue
+			}
+			defs[idx] = def
+			idx++
+		}
+		e.deferred[destLevel-1] = defs[:idx]
+	}
+	e.truncates = append(e.truncates, deferredTruncate{destLevel, source, target})
+	return nil
+}
+
+// flushTruncates applies any deferred truncations, starting with the
+// tables that are deepest in the dependency tree.
+func (e *orderedBatch) flushTruncates(ctx context.Context) error {
+	if len(e.truncates) == 0 {
+		return nil
+	}
+	sort.SliceStable(e.truncates, func(i, j int) bool {
+		return e.truncates[i].level > e.truncates[j].level
+	})
+	for _, def := range e.truncates {
+		if err := e.Batch.OnTruncate(ctx, def.source, def.target); err != nil {
+			return errors.Wrap(err, "orderedEvents OnTruncate")
+		}
+	}
+	e.truncates = nil
+	return nil
+}
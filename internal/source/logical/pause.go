@@ -0,0 +1,42 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"context"
+)
+
+// Pause is called by Dialect.Process when replication cannot continue
+// without operator intervention. The messages from the source are
+// discarded, which allows the source connection to remain healthy, but
+// the consistent point will not advance. Pause returns when the
+// message channel has been closed, when the loop is stopping, or when
+// the context has been cancelled.
+func Pause(ctx context.Context, ch <-chan Message, state State) error {
+	for {
+		select {
+		case _, open := <-ch:
+			if !open {
+				return nil
+			}
+		case <-state.Stopping():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
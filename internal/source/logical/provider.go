@@ -22,6 +22,7 @@ import (
 
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/staging/version"
+	"github.com/cockroachdb/cdc-sink/internal/target/apply"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
@@ -37,6 +38,7 @@ import (
 // Set is used by Wire.
 var Set = wire.NewSet(
 	ProvideFactory,
+	ProvideApplyConfig,
 	ProvideBaseConfig,
 	ProvideDLQConfig,
 	ProvideStagingDB,
@@ -58,6 +60,11 @@ func ProvideBaseConfig(config Config, _ *script.Loader) (*BaseConfig, error) {
 	return config.Base(), nil
 }
 
+// ProvideApplyConfig is called by Wire.
+func ProvideApplyConfig(config *BaseConfig) *apply.Config {
+	return &config.ApplyConfig
+}
+
 // ProvideDLQConfig is called by Wire.
 func ProvideDLQConfig(config *BaseConfig) *dlq.Config {
 	return &config.DLQConfig
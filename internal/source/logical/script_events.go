@@ -162,3 +162,18 @@ func (e *scriptBatch) OnData(
 		return nil
 	})
 }
+
+// OnTruncate implements Batch. Truncations are routed in the same
+// manner as deletes, so the source configuration may override the
+// destination table.
+func (e *scriptBatch) OnTruncate(ctx context.Context, source ident.Ident, target ident.Table) error {
+	if cfg, ok := e.Script.Sources.Get(source); ok && cfg.Dispatch != nil && !cfg.DeletesTo.Empty() {
+		target = cfg.DeletesTo
+	}
+	if target.Empty() {
+		return errors.Errorf(
+			"cannot apply truncate from %s because there is no "+
+				"table configured for receiving the delete", source)
+	}
+	return e.Batch.OnTruncate(ctx, source, target)
+}
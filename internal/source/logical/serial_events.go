@@ -114,3 +114,12 @@ func (e *serialBatch) OnRollback(_ context.Context) error {
 	}
 	return nil
 }
+
+// OnTruncate implements Events. The rows are deleted within the
+// transaction, so the truncation is ordered with respect to OnData.
+func (e *serialBatch) OnTruncate(ctx context.Context, _ ident.Ident, target ident.Table) error {
+	if e.tx == nil {
+		return errors.New("OnTruncate called without matching OnBegin")
+	}
+	return truncateTable(ctx, e.tx, target)
+}
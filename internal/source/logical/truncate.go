@@ -0,0 +1,34 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logical
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+)
+
+// truncateTable removes all rows from the table. A DELETE statement is
+// used instead of TRUNCATE, since it is transactional in all of the
+// supported target products and will respect foreign-key constraints.
+func truncateTable(ctx context.Context, db types.TargetQuerier, table ident.Table) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", table))
+	return errors.Wrapf(err, "could not truncate %s", table)
+}
@@ -61,7 +61,8 @@ func NewFactoryForTests(ctx context.Context, config Config) (*Factory, func(), e
 		return nil, nil, err
 	}
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, cleanup5, err := apply.ProvideFactory(targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	applyConfig := ProvideApplyConfig(baseConfig)
+	appliers, cleanup5, err := apply.ProvideFactory(targetStatements, applyConfig, configs, diagnostics, dlQs, targetPool, watchers)
 	if err != nil {
 		cleanup4()
 		cleanup3()
@@ -0,0 +1,119 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mongological
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// Config contains the configuration necessary for creating a
+// replication connection to a MongoDB replica set or sharded cluster.
+type Config struct {
+	logical.BaseConfig
+	logical.LoopConfig
+
+	// The number of documents to load at once during a backfill operation.
+	BackfillBatchSize int
+	// Copies the document _id into the mutation using this property
+	// name.
+	DocumentIDProperty ident.Ident
+	// The fullDocument mode to request from the change stream.
+	FullDocument string
+	// A mongodb:// or mongodb+srv:// connection string.
+	SourceConn string
+	// The database that contains the source collections. If unset, the
+	// database named in SourceConn will be used.
+	SourceDatabase string
+}
+
+// Bind adds flags to the set.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	c.BaseConfig.Bind(f)
+	// Always opt into backfilling, since a new collection must be
+	// scanned before its change stream can be consumed. Values
+	// assigned in Preflight()
+	f.Lookup("backfillWindow").Hidden = true
+	f.Lookup("immediate").Hidden = true
+
+	c.LoopConfig.LoopName = "mongological"
+	c.LoopConfig.Bind(f)
+
+	f.IntVar(&c.BackfillBatchSize, "backfillBatchSize", 10_000,
+		"the number of documents to load when backfilling")
+	f.Var(ident.NewValue("id", &c.DocumentIDProperty), "docID",
+		"the column name (likely the primary key) to populate with the document _id")
+	f.StringVar(&c.FullDocument, "fullDocument", string(options.UpdateLookup),
+		"the change stream fullDocument mode; one of updateLookup, whenAvailable, or required")
+	f.StringVar(&c.SourceConn, "sourceConn", "",
+		"the source MongoDB connection string")
+	f.StringVar(&c.SourceDatabase, "sourceDatabase", "",
+		"the source database; defaults to the database in the connection string")
+}
+
+// Preflight updates the configuration with sane defaults or returns an
+// error if there are missing options for which a default cannot be
+// provided.
+func (c *Config) Preflight() error {
+	if err := c.BaseConfig.Preflight(); err != nil {
+		return err
+	}
+	if err := c.LoopConfig.Preflight(); err != nil {
+		return err
+	}
+
+	c.BackfillWindow = time.Minute
+
+	if c.BackfillBatchSize < 1 {
+		return errors.New("backfill batch size must be >= 1")
+	}
+
+	// Require a property to store the underlying doc id in.
+	if c.DocumentIDProperty.Empty() {
+		return errors.New("no document id property was configured")
+	}
+
+	// Update events only carry a delta unless the server is asked to
+	// provide the complete document.
+	switch options.FullDocument(c.FullDocument) {
+	case options.Required, options.UpdateLookup, options.WhenAvailable:
+	default:
+		return errors.Errorf("unsupported fullDocument mode %q", c.FullDocument)
+	}
+
+	if c.SourceConn == "" {
+		return errors.New("no source connection was configured")
+	}
+	if c.SourceDatabase == "" {
+		conn, err := connstring.ParseAndValidate(c.SourceConn)
+		if err != nil {
+			return errors.Wrap(err, "could not parse source connection string")
+		}
+		c.SourceDatabase = conn.Database
+	}
+	if c.SourceDatabase == "" {
+		return errors.New("no source database was configured")
+	}
+
+	return nil
+}
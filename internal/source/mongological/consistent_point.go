@@ -0,0 +1,75 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mongological
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// A consistentPoint has two flavors to support backfilling
+// and-or streaming modes. While a collection is being scanned, the
+// Time field is zero, which keeps the loop in backfill mode.
+type consistentPoint struct {
+	// A document of the form { _id: <value> } that identifies the last
+	// document read when backfilling.
+	BackfillID bson.Raw `json:"i,omitempty"`
+	// The number of documents that have been read when backfilling.
+	Count int64 `json:"n,omitempty"`
+	// A change stream resume token. When backfilling, this is the
+	// token that was current when the scan began.
+	Token bson.Raw `json:"r,omitempty"`
+	// A server-generated timestamp; only updated when a backfill has
+	// completed or when we receive new data in streaming mode.
+	Time time.Time `json:"t,omitempty"`
+}
+
+var _ stamp.Stamp = (*consistentPoint)(nil)
+
+// AsTime implements the optional logical.TimeStamp interface to aid in
+// metrics reporting and to switch between backfill and streaming
+// modes.
+func (t *consistentPoint) AsTime() time.Time {
+	return t.Time
+}
+
+// IsBackfilled returns true once the initial scan of the collection
+// has completed and the change stream may be consumed.
+func (t *consistentPoint) IsBackfilled() bool {
+	return t.Token != nil && !t.Time.IsZero()
+}
+
+// IsZero returns true if the consistentPoint represents a zero value.
+func (t *consistentPoint) IsZero() bool {
+	return t.BackfillID == nil && t.Token == nil && t.Time.IsZero()
+}
+
+// Less implements stamp.Stamp.
+func (t *consistentPoint) Less(other stamp.Stamp) bool {
+	o := other.(*consistentPoint)
+
+	if t.Time.Before(o.Time) {
+		return true
+	}
+	if t.Time.After(o.Time) {
+		return false
+	}
+
+	return t.Count < o.Count
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mongological
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// jsonDocument converts a BSON document into a map that can be
+// marshaled as JSON and presented to a user-script.
+func jsonDocument(doc bson.Raw) (map[string]any, error) {
+	elts, err := doc.Elements()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ret := make(map[string]any, len(elts))
+	for _, elt := range elts {
+		val, err := jsonValue(elt.Value())
+		if err != nil {
+			return nil, errors.Wrap(err, elt.Key())
+		}
+		ret[elt.Key()] = val
+	}
+	return ret, nil
+}
+
+// jsonValue converts a BSON value into a JSON-compatible value. Types
+// that have no natural JSON representation, such as regular
+// expressions or JavaScript code, are converted to their extended
+// JSON string.
+func jsonValue(val bson.RawValue) (any, error) {
+	switch val.Type {
+	case bson.TypeArray:
+		vals, err := val.Array().Values()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret := make([]any, len(vals))
+		for idx, elt := range vals {
+			ret[idx], err = jsonValue(elt)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+
+	case bson.TypeBinary:
+		// Byte slices are encoded as base64 strings.
+		_, data := val.Binary()
+		return data, nil
+
+	case bson.TypeBoolean:
+		return val.Boolean(), nil
+
+	case bson.TypeDateTime:
+		return time.UnixMilli(val.DateTime()).UTC(), nil
+
+	case bson.TypeDecimal128:
+		// NaN and infinite values aren't valid JSON numbers.
+		s := val.Decimal128().String()
+		if json.Valid([]byte(s)) {
+			return json.Number(s), nil
+		}
+		return s, nil
+
+	case bson.TypeDouble:
+		f := val.Double()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return strconv.FormatFloat(f, 'g', -1, 64), nil
+		}
+		return f, nil
+
+	case bson.TypeEmbeddedDocument:
+		return jsonDocument(val.Document())
+
+	case bson.TypeInt32:
+		return val.Int32(), nil
+
+	case bson.TypeInt64:
+		return val.Int64(), nil
+
+	case bson.TypeNull, bson.TypeUndefined:
+		return nil, nil
+
+	case bson.TypeObjectID:
+		return val.ObjectID().Hex(), nil
+
+	case bson.TypeString:
+		return val.StringValue(), nil
+
+	case bson.TypeSymbol:
+		return val.Symbol(), nil
+
+	case bson.TypeTimestamp:
+		t, _ := val.Timestamp()
+		return time.Unix(int64(t), 0).UTC(), nil
+
+	default:
+		return val.String(), nil
+	}
+}
+
+// nestedDocument is an element of an array of documents, found by
+// walking a document when a source is configured to recurse.
+type nestedDocument struct {
+	data map[string]any
+	path string // A dotted path, such as items.0.parts.1
+}
+
+// nestedDocuments returns all elements of arrays of documents within
+// the given data, in a stable order. Arrays of documents that are
+// themselves nested within an element are also returned.
+func nestedDocuments(data map[string]any, prefix string) []nestedDocument {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var ret []nestedDocument
+	for _, key := range keys {
+		arr, ok := data[key].([]any)
+		if !ok {
+			continue
+		}
+		for idx, elt := range arr {
+			doc, ok := elt.(map[string]any)
+			if !ok {
+				continue
+			}
+			path := prefix + key + "." + strconv.Itoa(idx)
+			ret = append(ret, nestedDocument{doc, path})
+			ret = append(ret, nestedDocuments(doc, path+".")...)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,182 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mongological
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestJSONDocument(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	oid, err := primitive.ObjectIDFromHex("65a1b2c3d4e5f60718293a4b")
+	r.NoError(err)
+	dec, err := primitive.ParseDecimal128("1234.5678")
+	r.NoError(err)
+	ts := time.Date(2023, 10, 11, 12, 13, 14, 15e6, time.UTC)
+
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: oid},
+		{Key: "array", Value: bson.A{int32(1), "two", bson.D{{Key: "three", Value: 3.0}}}},
+		{Key: "binary", Value: primitive.Binary{Data: []byte("hello")}},
+		{Key: "bool", Value: true},
+		{Key: "date", Value: primitive.NewDateTimeFromTime(ts)},
+		{Key: "decimal", Value: dec},
+		{Key: "double", Value: 1.5},
+		{Key: "inf", Value: math.Inf(1)},
+		{Key: "int32", Value: int32(32)},
+		{Key: "int64", Value: int64(64)},
+		{Key: "nested", Value: bson.D{{Key: "k", Value: "v"}}},
+		{Key: "null", Value: nil},
+		{Key: "regex", Value: primitive.Regex{Pattern: "^a", Options: "i"}},
+		{Key: "string", Value: "hello"},
+		{Key: "timestamp", Value: primitive.Timestamp{T: 1697026394, I: 1}},
+	})
+	r.NoError(err)
+
+	doc, err := jsonDocument(raw)
+	r.NoError(err)
+	data, err := json.Marshal(doc)
+	r.NoError(err)
+
+	a.JSONEq(`{
+  "_id": "65a1b2c3d4e5f60718293a4b",
+  "array": [1, "two", {"three": 3}],
+  "binary": "aGVsbG8=",
+  "bool": true,
+  "date": "2023-10-11T12:13:14.015Z",
+  "decimal": 1234.5678,
+  "double": 1.5,
+  "inf": "+Inf",
+  "int32": 32,
+  "int64": 64,
+  "nested": {"k": "v"},
+  "null": null,
+  "regex": "{\"$regularExpression\":{\"pattern\":\"^a\",\"options\":\"i\"}}",
+  "string": "hello",
+  "timestamp": "2023-10-11T12:13:14Z"
+}`, string(data))
+}
+
+func TestMarshalMutations(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	raw, err := bson.Marshal(bson.D{
+		{Key: "_id", Value: "order1"},
+		{Key: "items", Value: bson.A{
+			bson.D{
+				{Key: "sku", Value: "a"},
+				{Key: "parts", Value: bson.A{bson.D{{Key: "n", Value: int32(1)}}}},
+			},
+			bson.D{{Key: "sku", Value: "b"}},
+			"not a document",
+		}},
+		{Key: "tags", Value: bson.A{"x", "y"}},
+	})
+	r.NoError(err)
+	doc := batchDoc{
+		doc: raw,
+		id:  bson.Raw(raw).Lookup("_id"),
+		op:  "insert",
+		ts:  primitive.Timestamp{T: 1697026394, I: 2},
+	}
+
+	d := &Dialect{docIDProperty: "id", sourceName: ident.New("orders")}
+	muts, err := d.marshalMutations(doc)
+	r.NoError(err)
+	r.Len(muts, 1)
+	a.JSONEq(`{
+  "id": "order1",
+  "items": [{"sku": "a", "parts": [{"n": 1}]}, {"sku": "b"}, "not a document"],
+  "tags": ["x", "y"]
+}`, string(muts[0].Data))
+	a.Equal(`["order1"]`, string(muts[0].Key))
+	a.Equal(hlc.New(1697026394*int64(time.Second), 2), muts[0].Time)
+	a.Equal(map[string]any{
+		"clusterTime":   int64(1697026394000),
+		"collection":    "orders",
+		"id":            "order1",
+		"operationType": "insert",
+	}, muts[0].Meta)
+
+	// Elements of arrays of documents are dispatched separately.
+	d.recurse = true
+	muts, err = d.marshalMutations(doc)
+	r.NoError(err)
+	r.Len(muts, 4)
+	expected := []struct {
+		data string
+		path string
+	}{
+		{`{"sku": "a", "parts": [{"n": 1}]}`, "items.0"},
+		{`{"n": 1}`, "items.0.parts.0"},
+		{`{"sku": "b"}`, "items.1"},
+	}
+	for idx, exp := range expected {
+		mut := muts[idx+1]
+		a.JSONEq(exp.data, string(mut.Data))
+		a.Equal(`["order1","`+exp.path+`"]`, string(mut.Key))
+		a.Equal(exp.path, mut.Meta["path"])
+		a.Equal("order1", mut.Meta["id"])
+	}
+	a.NotContains(muts[0].Meta, "path")
+
+	// Deletes only have a key.
+	muts, err = d.marshalMutations(batchDoc{id: doc.id, op: "delete", ts: doc.ts})
+	r.NoError(err)
+	r.Len(muts, 1)
+	a.True(muts[0].IsDelete())
+	a.Equal(`["order1"]`, string(muts[0].Key))
+}
+
+func TestConsistentPoint(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	zero := &consistentPoint{}
+	a.True(zero.IsZero())
+	a.False(zero.IsBackfilled())
+
+	scanning := &consistentPoint{BackfillID: bson.Raw{5, 0, 0, 0, 0}, Count: 10, Token: bson.Raw{5, 0, 0, 0, 0}}
+	a.False(scanning.IsZero())
+	a.False(scanning.IsBackfilled())
+	a.True(zero.Less(scanning))
+
+	streaming := &consistentPoint{Token: bson.Raw{5, 0, 0, 0, 0}, Time: time.Unix(1, 0).UTC()}
+	a.True(streaming.IsBackfilled())
+	a.True(scanning.Less(streaming))
+	a.False(streaming.Less(scanning))
+
+	// Ensure that the stamp survives a round trip through the memo.
+	data, err := json.Marshal(streaming)
+	r.NoError(err)
+	var decoded consistentPoint
+	r.NoError(json.Unmarshal(data, &decoded))
+	a.Equal(streaming, &decoded)
+}
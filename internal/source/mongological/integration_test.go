@@ -0,0 +1,251 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mongological
+
+// To execute these tests, set the following environment variables:
+//   CDC_INTEGRATION=mongodb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The replica set is only reachable by its container-local address, so
+// we connect directly to the single member.
+const mongoConn = "mongodb://127.0.0.1:27017/?directConnection=true"
+
+func TestMain(m *testing.M) {
+	all.IntegrationMain(m, all.MongoDBName)
+}
+
+func TestSmoke(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	const docCount = 100
+
+	fixture, cancel, err := all.NewFixture()
+	r.NoError(err)
+	defer cancel()
+
+	ctx := fixture.Context
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoConn))
+	r.NoError(err)
+	defer func() { _ = client.Disconnect(context.Background()) }()
+	r.NoError(ensureReplicaSet(ctx, client))
+
+	// Mangle our DB ident into something that MongoDB will accept.
+	dbName := strings.NewReplacer(".", "_", `"`, "").Replace(fixture.TargetSchema.Schema().Raw())
+	db := client.Database(dbName)
+	defer func() { _ = db.Drop(context.Background()) }()
+
+	// Create the target schema. Elements of the items array are sent to
+	// a child table.
+	destTable, err := fixture.CreateTargetTable(ctx,
+		"CREATE TABLE %s (id STRING PRIMARY KEY, v STRING)")
+	r.NoError(err)
+	itemsTable, err := fixture.CreateTargetTable(ctx, fmt.Sprintf(
+		"CREATE TABLE %%s (order_id STRING REFERENCES %s ON DELETE CASCADE, "+
+			"path STRING, sku STRING, PRIMARY KEY (order_id, path))",
+		destTable))
+	r.NoError(err)
+
+	// Create source documents, which will be backfilled.
+	coll := db.Collection(destTable.Name().Table().Raw())
+	docs := make([]any, docCount)
+	for i := range docs {
+		docs[i] = bson.D{
+			{Key: "v", Value: fmt.Sprintf("value %d", i)},
+			{Key: "items", Value: bson.A{
+				bson.D{{Key: "sku", Value: fmt.Sprintf("a%d", i)}},
+				bson.D{{Key: "sku", Value: fmt.Sprintf("b%d", i)}},
+			}},
+		}
+	}
+	_, err = coll.InsertMany(ctx, docs)
+	r.NoError(err)
+
+	waitFor := func(tbl interface {
+		RowCount(context.Context) (int, error)
+	}, expected int) {
+		t.Helper()
+		for {
+			ct, err := tbl.RowCount(ctx)
+			r.NoError(err)
+			if ct == expected {
+				return
+			}
+			log.Infof("saw %d of %d rows", ct, expected)
+			select {
+			case <-ctx.Done():
+				r.NoError(ctx.Err())
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	waitAfterBackfill := make(chan struct{})
+
+	cfg := &Config{
+		BaseConfig: logical.BaseConfig{
+			ApplyTimeout:       2 * time.Minute, // Increase to make using the debugger easier.
+			ForeignKeysEnabled: true,
+			RetryDelay:         time.Nanosecond,
+			StandbyTimeout:     10 * time.Millisecond,
+			StagingConn:        fixture.StagingPool.ConnectionString,
+			StagingSchema:      fixture.StagingDB.Schema(),
+			TargetConn:         fixture.TargetPool.ConnectionString,
+
+			ScriptConfig: script.Config{
+				MainPath: "/main.ts",
+				FS: &fstest.MapFS{
+					"main.ts": &fstest.MapFile{
+						Data: []byte(fmt.Sprintf(`
+import * as api from "cdc-sink@v1";
+api.configureSource(%[1]s, {
+  deletesTo: %[1]s, // Use ON DELETE CASCADE
+  recurse: true,
+  dispatch: (doc, meta) => meta.path
+    ? { %[2]s: [ { order_id: meta.id, path: meta.path, sku: doc.sku } ] }
+    : { %[1]s: [ { id: doc.id, v: doc.v } ] },
+});
+`, destTable.Name().Table(), itemsTable.Name().Table())),
+					},
+				},
+			},
+		},
+		LoopConfig: logical.LoopConfig{
+			LoopName:          "mongologicaltest",
+			TargetSchema:      fixture.TargetSchema.Schema(),
+			WaitAfterBackfill: waitAfterBackfill,
+		},
+		BackfillBatchSize:  10,
+		DocumentIDProperty: ident.New("id"),
+		FullDocument:       string(options.UpdateLookup),
+		SourceConn:         mongoConn,
+		SourceDatabase:     dbName,
+	}
+
+	loops, cancelLoops, err := startLoopsFromFixture(fixture, cfg)
+	r.NoError(err)
+	defer cancelLoops()
+	r.Len(loops, 1)
+
+	log.Info("waiting for backfill")
+	waitFor(destTable, docCount)
+	waitFor(itemsTable, 2*docCount)
+
+	log.Info("backfill done, sending document updates")
+	close(waitAfterBackfill)
+
+	_, err = coll.UpdateMany(ctx, bson.D{},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "v", Value: "updated"}}}})
+	r.NoError(err)
+	for {
+		var ct int
+		r.NoError(fixture.TargetPool.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT count(*) FROM %s WHERE v = 'updated'", destTable.Name())).Scan(&ct))
+		if ct == docCount {
+			break
+		}
+		log.Infof("saw only %d updated documents", ct)
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	log.Info("saw updates, inserting a document")
+	_, err = coll.InsertOne(ctx, bson.D{
+		{Key: "v", Value: "inserted"},
+		{Key: "items", Value: bson.A{
+			bson.D{{Key: "sku", Value: "x"}},
+			bson.D{{Key: "sku", Value: "y"}},
+			bson.D{{Key: "sku", Value: "z"}},
+		}},
+	})
+	r.NoError(err)
+	waitFor(destTable, docCount+1)
+	waitFor(itemsTable, 2*docCount+3)
+
+	log.Info("saw insert, deleting documents")
+	_, err = coll.DeleteMany(ctx, bson.D{})
+	r.NoError(err)
+	waitFor(destTable, 0)
+	waitFor(itemsTable, 0)
+
+	// The resume token should have been persisted.
+	cp, _ := loops[0].GetConsistentPoint()
+	a.True(cp.(*consistentPoint).IsBackfilled())
+	a.Equal(int64(docCount), cp.(*consistentPoint).Count)
+
+	// Ensure diagnostics can be reported.
+	sinktest.CheckDiagnostics(ctx, t, fixture.Diagnostics)
+}
+
+// ensureReplicaSet initializes a single-member replica set, since
+// change streams are unavailable on a standalone server.
+func ensureReplicaSet(ctx context.Context, client *mongo.Client) error {
+	const notYetInitialized = 94
+	admin := client.Database("admin")
+
+	err := admin.RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Err()
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == notYetInitialized {
+		err = admin.RunCommand(ctx, bson.D{{Key: "replSetInitiate", Value: bson.D{
+			{Key: "_id", Value: "rs0"},
+			{Key: "members", Value: bson.A{
+				bson.D{{Key: "_id", Value: 0}, {Key: "host", Value: "127.0.0.1:27017"}},
+			}},
+		}}}).Err()
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Wait for the member to be elected.
+	for {
+		var hello struct {
+			IsWritablePrimary bool `bson:"isWritablePrimary"`
+		}
+		if err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+			return errors.WithStack(err)
+		}
+		if hello.IsWritablePrimary {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,604 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package mongological contains a logical-replication loop for
+// streaming document collections from MongoDB change streams.
+package mongological
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The server error code for a resume token that has fallen off of the
+// end of the oplog.
+const changeStreamHistoryLost = 286
+
+// Dialect reads data from a MongoDB collection.
+type Dialect struct {
+	backfillBatchSize int                  // Limit backfill query response size.
+	client            *mongo.Client        // Access to MongoDB.
+	coll              *mongo.Collection    // The collection to read from.
+	docIDProperty     string               // Added to mutation properties.
+	fullDocument      options.FullDocument // Change stream behavior for updates.
+	recurse           bool                 // Dispatch elements of nested arrays.
+	sourceName        ident.Ident          // Identifies the loop to the user-script.
+}
+
+var (
+	_ diag.Diagnostic    = (*Dialect)(nil)
+	_ logical.Backfiller = (*Dialect)(nil)
+	_ logical.Dialect    = (*Dialect)(nil)
+)
+
+// These are the Dialect message types.
+type (
+	batchStart struct{}
+	batchDoc   struct {
+		doc bson.Raw            // The document to write; nil for deletions.
+		id  bson.RawValue       // The document's _id.
+		op  string              // The change stream operation, if any.
+		ts  primitive.Timestamp // The server time of the change.
+	}
+	batchEnd struct {
+		cp *consistentPoint
+	}
+	// pointAdvance moves the consistent point without sending data.
+	pointAdvance struct {
+		cp *consistentPoint
+	}
+)
+
+// changeEvent contains the change stream event fields that we use.
+//
+// https://www.mongodb.com/docs/manual/reference/change-events/
+type changeEvent struct {
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+	DocumentKey   bson.Raw            `bson:"documentKey"`
+	FullDocument  bson.RawValue       `bson:"fullDocument"`
+	OperationType string              `bson:"operationType"`
+}
+
+// BackfillInto implements logical.Backfiller. It uses an ID-based
+// cursor approach to scan documents in their _id order. A change
+// stream resume token is recorded before the scan begins, so that
+// changes made while backfilling will be replayed once the loop
+// switches into streaming mode. If the scan has already completed, the
+// change stream is consumed until the loop has caught up.
+func (d *Dialect) BackfillInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	cp, cpUpdated := state.GetConsistentPoint()
+	if cp.(*consistentPoint).IsBackfilled() {
+		return d.ReadInto(ctx, ch, state)
+	}
+
+	token := cp.(*consistentPoint).Token
+	if token == nil {
+		var err error
+		if token, err = d.startToken(ctx); err != nil {
+			return err
+		}
+	}
+
+	for {
+		log.Tracef("backfilling %s after %d documents", d.sourceName, cp.(*consistentPoint).Count)
+
+		if err := d.backfillOneBatch(ctx, ch, cp.(*consistentPoint), token); err != nil {
+			return errors.Wrap(err, d.sourceName.Raw())
+		}
+
+		// Wait for that iteration of the loop to be processed
+		// (or not), and continue into the next loop.
+		select {
+		case <-cpUpdated:
+			cp, cpUpdated = state.GetConsistentPoint()
+			if cp.(*consistentPoint).IsBackfilled() {
+				// The loop will stop us to switch into streaming mode.
+				select {
+				case <-state.Stopping():
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		case <-state.Stopping():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// backfillOneBatch reads a single batch of documents that follow the
+// document identified by the consistent point.
+func (d *Dialect) backfillOneBatch(
+	ctx context.Context, ch chan<- logical.Message, cp *consistentPoint, token bson.Raw,
+) error {
+	filter := bson.D{}
+	if cp.BackfillID != nil {
+		filter = bson.D{{Key: "_id", Value: bson.D{{Key: "$gt", Value: cp.BackfillID.Lookup("_id")}}}}
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(int64(d.backfillBatchSize))
+
+	// A session allows us to retrieve the server time of the query.
+	sess, err := d.client.StartSession()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer sess.EndSession(ctx)
+	sctx := mongo.NewSessionContext(ctx, sess)
+
+	cur, err := d.coll.Find(sctx, filter, opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var docs []bson.Raw
+	if err := cur.All(sctx, &docs); err != nil {
+		return errors.WithStack(err)
+	}
+	log.Tracef("received %d documents from %s", len(docs), d.sourceName)
+
+	// Helper for interruptible send idiom.
+	send := func(msg logical.Message) error {
+		select {
+		case ch <- msg:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// If we have read through the end of the collection, the backfill
+	// is complete. The local time is used to allow the loop to switch
+	// into streaming mode, which will replay any changes that happened
+	// since the scan began.
+	if len(docs) == 0 {
+		return send(pointAdvance{&consistentPoint{
+			Count: cp.Count,
+			Token: token,
+			Time:  time.Now().UTC(),
+		}})
+	}
+
+	lastID, err := bson.Marshal(bson.D{{Key: "_id", Value: docs[len(docs)-1].Lookup("_id")}})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	ts := operationTime(sess)
+	if err := send(batchStart{}); err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		if err := send(batchDoc{doc: doc, id: doc.Lookup("_id"), ts: ts}); err != nil {
+			return err
+		}
+	}
+	return send(batchEnd{&consistentPoint{
+		BackfillID: lastID,
+		Count:      cp.Count + int64(len(docs)),
+		Token:      token,
+	}})
+}
+
+// ReadInto implements logical.Dialect and subscribes to the
+// collection's change stream, resuming from the token in the
+// consistent point.
+func (d *Dialect) ReadInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	// The calls to TryNext() below need to be made interruptable.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-state.Stopping():
+			// Cancel early to interrupt call to TryNext() below.
+			cancel()
+		case <-ctx.Done():
+			// Normal exit path when ReadInto exits.
+		}
+	}()
+
+	cp, _ := state.GetConsistentPoint()
+	lastToken := cp.(*consistentPoint).Token
+
+	opts := options.ChangeStream().SetFullDocument(d.fullDocument)
+	if lastToken == nil {
+		log.Warnf("no resume token for %s, starting change stream at current time", d.sourceName)
+	} else {
+		opts.SetResumeAfter(lastToken)
+	}
+
+	// A session allows us to retrieve the server time when the change
+	// stream is idle.
+	sess, err := d.client.StartSession()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer sess.EndSession(context.Background())
+	sctx := mongo.NewSessionContext(ctx, sess)
+
+	stream, err := d.coll.Watch(sctx, mongo.Pipeline{}, opts)
+	if err != nil {
+		return d.streamError(err)
+	}
+	defer func() { _ = stream.Close(context.Background()) }()
+
+	// Helper for interruptible send.
+	send := func(msg logical.Message) error {
+		select {
+		case ch <- msg:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for {
+		if !stream.TryNext(sctx) {
+			if err := stream.Err(); err != nil {
+				// Mask cancellations errors.
+				if ctx.Err() != nil {
+					return nil
+				}
+				return d.streamError(err)
+			}
+			if stream.ID() == 0 {
+				return errors.Errorf("change stream for %s was closed by the server", d.sourceName)
+			}
+			// An empty batch means that we have caught up. Advance to
+			// the post-batch resume token, so that an idle collection
+			// does not appear to have fallen behind.
+			if token := stream.ResumeToken(); token != nil && !bytes.Equal(token, lastToken) {
+				lastToken = append(bson.Raw(nil), token...)
+				if err := send(pointAdvance{&consistentPoint{
+					Token: lastToken,
+					Time:  timestampTime(operationTime(sess)),
+				}}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if err := send(batchStart{}); err != nil {
+			return err
+		}
+
+		var last primitive.Timestamp
+		for {
+			var ev changeEvent
+			if err := stream.Decode(&ev); err != nil {
+				return errors.WithStack(err)
+			}
+
+			switch ev.OperationType {
+			case "delete":
+				if err := send(batchDoc{
+					id: ev.DocumentKey.Lookup("_id"),
+					op: ev.OperationType,
+					ts: ev.ClusterTime,
+				}); err != nil {
+					return err
+				}
+
+			case "insert", "replace", "update":
+				// The full document may be unavailable if the document
+				// was deleted before the update could be looked up. A
+				// delete event will follow.
+				if ev.FullDocument.Type != bson.TypeEmbeddedDocument {
+					log.Tracef("no full document for %s event in %s", ev.OperationType, d.sourceName)
+					break
+				}
+				if err := send(batchDoc{
+					doc: ev.FullDocument.Document(),
+					id:  ev.DocumentKey.Lookup("_id"),
+					op:  ev.OperationType,
+					ts:  ev.ClusterTime,
+				}); err != nil {
+					return err
+				}
+
+			case "invalidate":
+				return errors.Errorf(
+					"change stream for %s was invalidated; the collection may have been dropped or renamed",
+					d.sourceName)
+
+			default:
+				// A drop or rename will be followed by an invalidate.
+				log.Debugf("ignoring %s event in %s", ev.OperationType, d.sourceName)
+			}
+			last = ev.ClusterTime
+
+			// Consume the remainder of the batch returned by the server.
+			if stream.RemainingBatchLength() == 0 || !stream.TryNext(sctx) {
+				break
+			}
+		}
+
+		lastToken = append(bson.Raw(nil), stream.ResumeToken()...)
+		if err := send(batchEnd{&consistentPoint{
+			Token: lastToken,
+			Time:  timestampTime(last),
+		}}); err != nil {
+			return err
+		}
+	}
+}
+
+// Diagnostic implements [diag.Diagnostic].
+func (d *Dialect) Diagnostic(_ context.Context) any {
+	type Payload struct {
+		BackfillBatchSize int
+		Collection        string
+		Database          string
+		DocIDProperty     string
+		FullDocument      options.FullDocument
+		Recurse           bool
+		SourceName        ident.Ident
+	}
+	return &Payload{
+		BackfillBatchSize: d.backfillBatchSize,
+		Collection:        d.coll.Name(),
+		Database:          d.coll.Database().Name(),
+		DocIDProperty:     d.docIDProperty,
+		FullDocument:      d.fullDocument,
+		Recurse:           d.recurse,
+		SourceName:        d.sourceName,
+	}
+}
+
+// Process implements logical.Dialect.
+func (d *Dialect) Process(
+	ctx context.Context, ch <-chan logical.Message, events logical.Events,
+) error {
+	var batch logical.Batch
+	defer func() {
+		if batch != nil {
+			_ = batch.OnRollback(ctx)
+		}
+	}()
+
+	for msg := range ch {
+		if logical.IsRollback(msg) {
+			if batch != nil {
+				if err := batch.OnRollback(ctx); err != nil {
+					return err
+				}
+				batch = nil
+			}
+			continue
+		}
+
+		switch t := msg.(type) {
+		case batchStart:
+			var err error
+			batch, err = events.OnBegin(ctx)
+			if err != nil {
+				return err
+			}
+
+		case batchDoc:
+			muts, err := d.marshalMutations(t)
+			if err != nil {
+				return err
+			}
+
+			// Pass an empty destination table, because we know that
+			// this is configured via a user-script.
+			if err := batch.OnData(ctx, d.sourceName, ident.Table{}, muts); err != nil {
+				return err
+			}
+
+		case batchEnd:
+			select {
+			case err := <-batch.OnCommit(ctx):
+				if err != nil {
+					return err
+				}
+				batch = nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			// Advance the consistent point.
+			if err := events.SetConsistentPoint(ctx, t.cp); err != nil {
+				return err
+			}
+
+		case pointAdvance:
+			if err := events.SetConsistentPoint(ctx, t.cp); err != nil {
+				return err
+			}
+
+		default:
+			panic(fmt.Sprintf("unimplemented type %T", msg))
+		}
+	}
+	return nil
+}
+
+// ZeroStamp implements logical.Dialect.
+func (d *Dialect) ZeroStamp() stamp.Stamp {
+	return &consistentPoint{}
+}
+
+// marshalMutations converts a document into a mutation. If the source
+// is configured to recurse, an additional mutation is created for each
+// element of a nested array of documents. These are distinguished by a
+// path property in the mutation metadata.
+func (d *Dialect) marshalMutations(doc batchDoc) ([]types.Mutation, error) {
+	id, err := jsonValue(doc.id)
+	if err != nil {
+		return nil, err
+	}
+	key, err := json.Marshal([]any{id})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ts := hlc.New(int64(doc.ts.T)*int64(time.Second), int(doc.ts.I))
+
+	if doc.doc == nil {
+		return []types.Mutation{{Key: key, Time: ts}}, nil
+	}
+
+	dataMap, err := jsonDocument(doc.doc)
+	if err != nil {
+		return nil, err
+	}
+	// Replace the _id property with the configured property name.
+	delete(dataMap, "_id")
+	dataMap[d.docIDProperty] = id
+
+	data, err := json.Marshal(dataMap)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// The timestamp is converted to a value that is easy to wrap a JS
+	// Date around in the user script.
+	// https://pkg.go.dev/github.com/dop251/goja#hdr-Handling_of_time_Time
+	meta := map[string]any{
+		"clusterTime": int64(doc.ts.T) * 1000,
+		"collection":  d.sourceName.Raw(),
+		"id":          id,
+	}
+	if doc.op != "" {
+		meta["operationType"] = doc.op
+	}
+
+	ret := []types.Mutation{{
+		Data: data,
+		Key:  key,
+		Meta: meta,
+		Time: ts,
+	}}
+	if !d.recurse {
+		return ret, nil
+	}
+
+	for _, nested := range nestedDocuments(dataMap, "") {
+		data, err := json.Marshal(nested.data)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		key, err := json.Marshal([]any{id, nested.path})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		nestedMeta := make(map[string]any, len(meta)+1)
+		for k, v := range meta {
+			nestedMeta[k] = v
+		}
+		nestedMeta["path"] = nested.path
+
+		ret = append(ret, types.Mutation{
+			Data: data,
+			Key:  key,
+			Meta: nestedMeta,
+			Time: ts,
+		})
+	}
+	return ret, nil
+}
+
+// startToken returns a resume token for the current position in the
+// collection's change stream.
+func (d *Dialect) startToken(ctx context.Context) (bson.Raw, error) {
+	stream, err := d.coll.Watch(ctx, mongo.Pipeline{})
+	if err != nil {
+		return nil, d.streamError(err)
+	}
+	defer func() { _ = stream.Close(ctx) }()
+
+	token := stream.ResumeToken()
+	if token == nil {
+		return nil, errors.Errorf(
+			"no resume token available for %s; MongoDB 4.0.7 or later is required", d.sourceName)
+	}
+	return append(bson.Raw(nil), token...), nil
+}
+
+// streamError decorates errors returned from a change stream.
+func (d *Dialect) streamError(err error) error {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == changeStreamHistoryLost {
+		return errors.Wrapf(err,
+			"the resume token for %s is no longer in the oplog; the collection must be backfilled",
+			d.sourceName)
+	}
+	return errors.WithStack(err)
+}
+
+// operationTime returns the server time of the last operation in the
+// session, or the local time if it is unavailable.
+func operationTime(sess mongo.Session) primitive.Timestamp {
+	if ts := sess.OperationTime(); ts != nil {
+		return *ts
+	}
+	return primitive.Timestamp{T: uint32(time.Now().Unix())}
+}
+
+// timestampTime converts a server timestamp into a wall time.
+func timestampTime(ts primitive.Timestamp) time.Time {
+	return time.Unix(int64(ts.T), 0).UTC()
+}
+
+// MongoLogical is the top-level injection type.
+type MongoLogical struct {
+	Diagnostics *diag.Diagnostics
+	Loops       []*logical.Loop
+}
+
+var (
+	_ stdlogical.HasDiagnostics = (*MongoLogical)(nil)
+	_ stdlogical.HasStoppable   = (*MongoLogical)(nil)
+)
+
+// GetDiagnostics implements stdlogical.HasDiagnostics.
+func (l *MongoLogical) GetDiagnostics() *diag.Diagnostics {
+	return l.Diagnostics
+}
+
+// GetStoppable implements stdlogical.HasStoppable.
+func (l *MongoLogical) GetStoppable() types.Stoppable {
+	ret := make(types.Stoppables, len(l.Loops))
+	for idx, loop := range l.Loops {
+		ret[idx] = loop
+	}
+	return ret
+}
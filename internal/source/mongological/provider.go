@@ -0,0 +1,113 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mongological
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ProvideLoops is called by Wire to start a logical loop for each
+// collection that is configured in the user-script.
+func ProvideLoops(
+	cfg *Config,
+	client *mongo.Client,
+	loops *logical.Factory,
+	userscript *script.UserScript,
+) ([]*logical.Loop, func(), error) {
+	if userscript.Sources.Len() == 0 {
+		return nil, nil, errors.New("the user-script must configure at least one source collection")
+	}
+
+	db := client.Database(cfg.SourceDatabase)
+	idx := 0
+	ret := make([]*logical.Loop, userscript.Sources.Len())
+
+	cancels := make([]func(), userscript.Sources.Len())
+	cancel := func() {
+		for _, fn := range cancels {
+			if fn != nil {
+				fn()
+			}
+		}
+	}
+
+	err := userscript.Sources.Range(func(sourceName ident.Ident, source *script.Source) error {
+		loopCfg := cfg.LoopConfig.Copy()
+		loopCfg.Dialect = &Dialect{
+			backfillBatchSize: cfg.BackfillBatchSize,
+			client:            client,
+			coll:              db.Collection(sourceName.Raw()),
+			docIDProperty:     cfg.DocumentIDProperty.Raw(),
+			fullDocument:      options.FullDocument(cfg.FullDocument),
+			recurse:           source.Recurse,
+			sourceName:        sourceName,
+		}
+		loopCfg.LoopName = sourceName.Raw()
+
+		var err error
+		ret[idx], cancels[idx], err = loops.Start(loopCfg)
+		if err != nil {
+			return err
+		}
+		idx++
+		log.Infof("started mongodb loop %s", sourceName)
+		return nil
+	})
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	return ret, cancel, nil
+}
+
+// ProvideMongoClient is called by Wire to connect to the source
+// cluster. There's a fake dependency on the user-script so that flags
+// can be evaluated first.
+func ProvideMongoClient(
+	ctx context.Context, cfg *Config, _ *script.UserScript,
+) (*mongo.Client, func(), error) {
+	if err := cfg.Preflight(); err != nil {
+		return nil, nil, err
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.SourceConn))
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	cancel := func() { _ = client.Disconnect(context.Background()) }
+
+	if err := client.Ping(ctx, nil); err != nil {
+		cancel()
+		return nil, nil, errors.Wrap(err, "could not connect to source")
+	}
+	return client, cancel, nil
+}
+
+// ProvideScriptTarget is called by Wire and returns the target schema
+// used by the user-script.
+func ProvideScriptTarget(cfg *Config) script.TargetSchema {
+	return script.TargetSchema(cfg.TargetSchema)
+}
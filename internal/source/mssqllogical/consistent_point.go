@@ -0,0 +1,136 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mssqllogical
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/pkg/errors"
+)
+
+// An lsn is a SQL Server log sequence number. The __$seqval column,
+// which orders changes within a transaction, has the same binary(10)
+// representation. Both are ordered by comparing their bytes.
+type lsn [10]byte
+
+// maxSeq sorts after every __$seqval. It is used to mark a commit LSN
+// as having been read in its entirety.
+var maxSeq = lsn{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// newLSN converts a binary(10) value returned by the source database.
+func newLSN(v any) (lsn, error) {
+	var ret lsn
+	b, ok := v.([]byte)
+	if !ok {
+		return ret, errors.Errorf("expecting LSN bytes, got %T", v)
+	}
+	if len(b) != len(ret) {
+		return ret, errors.Errorf("expecting %d LSN bytes, got %d", len(ret), len(b))
+	}
+	copy(ret[:], b)
+	return ret, nil
+}
+
+// Compare returns -1, 0, or 1.
+func (l lsn) Compare(other lsn) int {
+	return bytes.Compare(l[:], other[:])
+}
+
+// IsZero returns true if the LSN has not been set.
+func (l lsn) IsZero() bool {
+	return l == lsn{}
+}
+
+// String returns the LSN in the format used by SQL Server.
+func (l lsn) String() string {
+	return "0x" + strings.ToUpper(hex.EncodeToString(l[:]))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l lsn) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (l *lsn) UnmarshalText(data []byte) error {
+	text := strings.TrimPrefix(strings.ToLower(string(data)), "0x")
+	b, err := hex.DecodeString(text)
+	if err != nil {
+		return errors.Wrapf(err, "could not decode LSN %q", string(data))
+	}
+	parsed, err := newLSN(b)
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
+}
+
+// consistentPoint identifies the last change that has been applied.
+// Changes are read in whole transactions, so Seq is usually the last
+// __$seqval within the transaction committed at LSN.
+type consistentPoint struct {
+	LSN lsn       `json:"lsn"` // The commit LSN of a source transaction.
+	Seq lsn       `json:"seq"` // A __$seqval within LSN.
+	TS  time.Time `json:"ts"`  // The approximate commit time of LSN.
+}
+
+var (
+	_ logical.OffsetStamp = (*consistentPoint)(nil)
+	_ logical.TimeStamp   = (*consistentPoint)(nil)
+)
+
+// AsOffset implements logical.OffsetStamp. The value is the LSN's
+// virtual log file and log block, which is sufficient for reporting.
+func (c *consistentPoint) AsOffset() uint64 {
+	return binary.BigEndian.Uint64(c.LSN[:8])
+}
+
+// AsTime implements logical.TimeStamp.
+func (c *consistentPoint) AsTime() time.Time {
+	return c.TS
+}
+
+// Contains returns true if the change identified by the commit LSN and
+// __$seqval has already been applied.
+func (c *consistentPoint) Contains(commit, seq lsn) bool {
+	if cmp := commit.Compare(c.LSN); cmp != 0 {
+		return cmp < 0
+	}
+	return seq.Compare(c.Seq) <= 0
+}
+
+// Less implements stamp.Stamp.
+func (c *consistentPoint) Less(other stamp.Stamp) bool {
+	o := other.(*consistentPoint)
+	if cmp := c.LSN.Compare(o.LSN); cmp != 0 {
+		return cmp < 0
+	}
+	return c.Seq.Compare(o.Seq) < 0
+}
+
+// String is for debugging use only.
+func (c *consistentPoint) String() string {
+	return fmt.Sprintf("%s:%s", c.LSN, c.Seq)
+}
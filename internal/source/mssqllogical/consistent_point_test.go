@@ -0,0 +1,84 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mssqllogical
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLSN(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	l, err := newLSN([]byte{0, 0, 0, 0x2a, 0, 0, 0x01, 0x10, 0, 0x03})
+	r.NoError(err)
+	a.Equal("0x0000002A000001100003", l.String())
+
+	var decoded lsn
+	r.NoError(decoded.UnmarshalText([]byte("0x0000002a000001100003")))
+	a.Equal(l, decoded)
+
+	_, err = newLSN([]byte{1, 2, 3})
+	a.ErrorContains(err, "expecting 10 LSN bytes")
+	_, err = newLSN("0x00")
+	a.ErrorContains(err, "expecting LSN bytes")
+	a.Error(decoded.UnmarshalText([]byte("0xZZ")))
+}
+
+func TestConsistentPoint(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	mk := func(commit, seq byte) *consistentPoint {
+		return &consistentPoint{
+			LSN: lsn{0, 0, 0, 1, 0, 0, 0, commit},
+			Seq: lsn{0, 0, 0, 1, 0, 0, 0, seq},
+		}
+	}
+	zero := &consistentPoint{}
+	one := mk(1, 9)
+	two := mk(2, 1)
+	twoLater := mk(2, 2)
+
+	a.True(zero.Less(one))
+	a.False(one.Less(zero))
+	a.True(one.Less(two))
+	a.True(two.Less(twoLater))
+	a.False(twoLater.Less(two))
+	a.False(two.Less(two))
+
+	a.True(two.Contains(one.LSN, maxSeq))
+	a.True(two.Contains(two.LSN, two.Seq))
+	a.False(two.Contains(twoLater.LSN, twoLater.Seq))
+	a.False(zero.Contains(one.LSN, one.Seq))
+
+	a.Equal(uint64(1)<<32|1, one.AsOffset())
+	a.True(one.AsOffset() < two.AsOffset())
+
+	// Ensure that the stamp survives being persisted.
+	twoLater.TS = time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	data, err := json.Marshal(twoLater)
+	r.NoError(err)
+	decoded := &consistentPoint{}
+	r.NoError(json.Unmarshal(data, decoded))
+	a.Equal(twoLater, decoded)
+}
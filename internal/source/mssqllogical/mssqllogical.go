@@ -0,0 +1,506 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package mssqllogical contains a logical-replication loop for
+// Microsoft SQL Server. Changes are read from the change tables that
+// SQL Server's change data capture feature maintains for each capture
+// instance.
+package mssqllogical
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// MSSQLLogical is a SQL Server change data capture loop.
+type MSSQLLogical struct {
+	Diagnostics *diag.Diagnostics
+	Loop        *logical.Loop
+}
+
+var (
+	_ stdlogical.HasDiagnostics = (*MSSQLLogical)(nil)
+	_ stdlogical.HasStoppable   = (*MSSQLLogical)(nil)
+)
+
+// GetDiagnostics implements [stdlogical.HasDiagnostics].
+func (l *MSSQLLogical) GetDiagnostics() *diag.Diagnostics {
+	return l.Diagnostics
+}
+
+// GetStoppable implements [stdlogical.HasStoppable].
+func (l *MSSQLLogical) GetStoppable() types.Stoppable {
+	return l.Loop
+}
+
+// The __$operation values returned by fn_cdc_get_all_changes.
+const (
+	opDelete      = 1
+	opInsert      = 2
+	opUpdateAfter = 4
+)
+
+// Dialect reads SQL Server change tables.
+type Dialect struct {
+	batchSize    int                // Limit the number of transactions per poll.
+	filter       []string           // Optional capture instance names.
+	instances    []*captureInstance // Refreshed whenever ReadInto is called.
+	pollInterval time.Duration      // Delay once all changes are read.
+	source       *types.SourcePool  // Access to the source database.
+	targetSchema ident.Schema       // Tables are named after the source.
+	watchers     types.Watchers     // Target schema access.
+}
+
+var (
+	_ diag.Diagnostic = (*Dialect)(nil)
+	_ logical.Dialect = (*Dialect)(nil)
+)
+
+// A captureInstance is a change table for a single source table.
+type captureInstance struct {
+	// The names of the target table's primary key columns.
+	keys   []ident.Ident
+	name   string      // The name of the capture instance.
+	source ident.Table // The table in the source database.
+	target ident.Table // The table in the target database.
+}
+
+// A change is a single row returned from a change table.
+type change struct {
+	commit   lsn
+	instance *captureInstance
+	mut      types.Mutation
+	seq      lsn
+	ts       time.Time
+}
+
+// A txBatch is the sole message type sent by ReadInto. It contains the
+// changes made by a single source transaction and the consistent point
+// to record once they have been applied. A txBatch with no data is
+// used to advance past transactions that did not affect any captured
+// table.
+type txBatch struct {
+	cp   *consistentPoint
+	data []logical.TableData
+}
+
+// Diagnostic implements [diag.Diagnostic].
+func (d *Dialect) Diagnostic(_ context.Context) any {
+	type Payload struct {
+		BatchSize        int
+		CaptureInstances map[string]ident.Table
+		PollInterval     time.Duration
+	}
+	ret := &Payload{
+		BatchSize:        d.batchSize,
+		CaptureInstances: make(map[string]ident.Table, len(d.instances)),
+		PollInterval:     d.pollInterval,
+	}
+	for _, inst := range d.instances {
+		ret.CaptureInstances[inst.name] = inst.source
+	}
+	return ret
+}
+
+// ReadInto implements logical.Dialect. Each poll reads up to batchSize
+// committed transactions from every capture instance. The changes are
+// merged into commit order and sent as one message per transaction.
+// Once no new transactions are available, ReadInto waits for the
+// polling interval before trying again.
+func (d *Dialect) ReadInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	// Refresh the capture instances, in case they have changed.
+	if err := d.describe(ctx); err != nil {
+		return err
+	}
+
+	initial, _ := state.GetConsistentPoint()
+	cp := initial.(*consistentPoint)
+
+	for {
+		msgs, err := d.poll(ctx, cp)
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			select {
+			case ch <- msg:
+				cp = msg.cp
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if len(msgs) > 0 {
+			// Continue immediately, since there may be more changes.
+			select {
+			case <-state.Stopping():
+				return nil
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-time.After(d.pollInterval):
+		case <-state.Stopping():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Process implements logical.Dialect.
+func (d *Dialect) Process(
+	ctx context.Context, ch <-chan logical.Message, events logical.Events,
+) error {
+	for msg := range ch {
+		// Each message is a complete transaction, so there is no state
+		// to discard if ReadInto is restarted.
+		if logical.IsRollback(msg) {
+			continue
+		}
+		b, ok := msg.(*txBatch)
+		if !ok {
+			panic(fmt.Sprintf("unimplemented type %T", msg))
+		}
+		if err := logical.ApplyBatch(ctx, events, b.cp, b.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ZeroStamp implements logical.Dialect.
+func (d *Dialect) ZeroStamp() stamp.Stamp {
+	return &consistentPoint{}
+}
+
+// describe loads the capture instances from the source database and
+// determines the primary key of each target table. If a source table
+// has two capture instances, as may be the case while its schema is
+// being changed, the more recent one is used unless a filter has been
+// configured.
+func (d *Dialect) describe(ctx context.Context) error {
+	const q = `
+SELECT capture_instance, OBJECT_SCHEMA_NAME(source_object_id), OBJECT_NAME(source_object_id)
+FROM cdc.change_tables
+ORDER BY source_object_id, create_date DESC`
+	rows, err := d.source.QueryContext(ctx, q)
+	if err != nil {
+		return errors.Wrap(err, "could not query capture instances; is CDC enabled?")
+	}
+	defer rows.Close()
+
+	wanted := make(map[string]bool, len(d.filter))
+	for _, name := range d.filter {
+		wanted[name] = true
+	}
+	seen := &ident.Map[struct{}]{}
+	d.instances = d.instances[:0]
+	for rows.Next() {
+		var name, schemaName, tableName string
+		if err := rows.Scan(&name, &schemaName, &tableName); err != nil {
+			return errors.WithStack(err)
+		}
+		source := ident.NewTable(ident.MustSchema(ident.New(schemaName)), ident.New(tableName))
+		if len(wanted) > 0 {
+			if !wanted[name] {
+				continue
+			}
+			delete(wanted, name)
+		} else if _, dup := seen.Get(source.Table()); dup {
+			log.Debugf("ignoring older capture instance %s for %s", name, source)
+			continue
+		}
+		seen.Put(source.Table(), struct{}{})
+		d.instances = append(d.instances, &captureInstance{
+			name:   name,
+			source: source,
+			target: ident.NewTable(d.targetSchema, source.Table()),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(wanted) > 0 {
+		missing := make([]string, 0, len(wanted))
+		for name := range wanted {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return errors.Errorf("capture instances not found: %s", strings.Join(missing, ", "))
+	}
+	if len(d.instances) == 0 {
+		return errors.New("no capture instances found in source database")
+	}
+
+	watcher, err := d.watchers.Get(ctx, d.targetSchema)
+	if err != nil {
+		return err
+	}
+	for _, inst := range d.instances {
+		targetCols, ok := watcher.Get().Columns.Get(inst.target)
+		if !ok {
+			return errors.Errorf("target table %s not found", inst.target)
+		}
+		inst.keys = inst.keys[:0]
+		for _, col := range targetCols {
+			if col.Primary {
+				inst.keys = append(inst.keys, col.Name)
+			}
+		}
+		if len(inst.keys) == 0 {
+			return errors.Errorf("target table %s has no primary key", inst.target)
+		}
+	}
+	return nil
+}
+
+// poll returns one message for each source transaction that was
+// committed after the consistent point, up to the batch size. The
+// final message may contain no data, to advance the consistent point
+// past transactions that did not affect any captured table.
+func (d *Dialect) poll(ctx context.Context, cp *consistentPoint) ([]*txBatch, error) {
+	// Find the window of commit LSNs to read. The time mapping table
+	// contains an entry for every transaction that the capture process
+	// has scanned, as well as periodic entries that mark its progress
+	// while the database is idle.
+	const window = `
+SELECT MAX(start_lsn), MAX(tran_end_time) FROM (
+  SELECT TOP (@p1) start_lsn, tran_end_time
+  FROM cdc.lsn_time_mapping
+  WHERE start_lsn > @p2 AND start_lsn <= sys.fn_cdc_get_max_lsn()
+  ORDER BY start_lsn) AS w`
+	var upperBytes []byte
+	var upperTime sql.NullTime
+	if err := d.source.QueryRowContext(ctx, window, d.batchSize, cp.LSN[:]).
+		Scan(&upperBytes, &upperTime); err != nil {
+		return nil, errors.Wrap(err, window)
+	}
+	if upperBytes == nil {
+		return nil, nil
+	}
+	upper, err := newLSN(upperBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*change
+	for _, inst := range d.instances {
+		found, err := d.readInstance(ctx, inst, cp, upper)
+		if err != nil {
+			return nil, errors.Wrap(err, inst.name)
+		}
+		changes = append(changes, found...)
+	}
+	// Merge the changes from each capture instance into commit order.
+	sort.SliceStable(changes, func(i, j int) bool {
+		if cmp := changes[i].commit.Compare(changes[j].commit); cmp != 0 {
+			return cmp < 0
+		}
+		return changes[i].seq.Compare(changes[j].seq) < 0
+	})
+
+	var ret []*txBatch
+	var tx *txBatch
+	var current *captureInstance // The instance of the last TableData.
+	var keys map[string]bool     // The keys in the last TableData.
+	for _, chg := range changes {
+		if tx == nil || tx.cp.LSN != chg.commit {
+			tx = &txBatch{cp: &consistentPoint{LSN: chg.commit, TS: chg.ts}}
+			ret = append(ret, tx)
+			current = nil
+		}
+		tx.cp.Seq = chg.seq
+
+		// Mutations are grouped by table, preserving the order of the
+		// changes. A row that is changed more than once in the same
+		// transaction starts a new group.
+		if current != chg.instance || keys[string(chg.mut.Key)] {
+			tx.data = append(tx.data, logical.TableData{
+				Source: chg.instance.source.Table(),
+				Target: chg.instance.target,
+			})
+			current = chg.instance
+			keys = make(map[string]bool)
+		}
+		data := &tx.data[len(tx.data)-1]
+		data.Muts = append(data.Muts, chg.mut)
+		keys[string(chg.mut.Key)] = true
+	}
+
+	if len(ret) == 0 || ret[len(ret)-1].cp.LSN != upper {
+		next := &consistentPoint{LSN: upper, Seq: maxSeq, TS: upperTime.Time}
+		ret = append(ret, &txBatch{cp: next})
+	}
+	log.Tracef("read %d changes in %d transactions", len(changes), len(ret))
+	return ret, nil
+}
+
+// readInstance returns the changes from a capture instance that were
+// committed after the consistent point, up to and including the upper
+// LSN.
+func (d *Dialect) readInstance(
+	ctx context.Context, inst *captureInstance, cp *consistentPoint, upper lsn,
+) ([]*change, error) {
+	// The lower bound of the query must not precede the oldest change
+	// that has been retained for the capture instance.
+	var minBytes []byte
+	if err := d.source.QueryRowContext(ctx,
+		"SELECT sys.fn_cdc_get_min_lsn(@p1)", inst.name).Scan(&minBytes); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	from, err := newLSN(minBytes)
+	if err != nil {
+		return nil, err
+	}
+	if from.IsZero() {
+		return nil, errors.New("capture instance is not accessible")
+	}
+	if cp.LSN.Compare(from) >= 0 {
+		from = cp.LSN
+	} else if !cp.LSN.IsZero() {
+		log.Warnf("capture instance %s has discarded changes between %s and %s",
+			inst.name, cp.LSN, from)
+	}
+	if from.Compare(upper) > 0 {
+		return nil, nil
+	}
+
+	fn := ident.New("fn_cdc_get_all_changes_" + inst.name)
+	q := fmt.Sprintf(`
+SELECT sys.fn_cdc_map_lsn_to_time(__$start_lsn) AS __$commit_time, *
+FROM cdc.%s(@p1, @p2, N'all')
+ORDER BY __$start_lsn, __$seqval`, fn)
+	rows, err := d.source.QueryContext(ctx, q, from[:], upper[:])
+	if err != nil {
+		return nil, errors.Wrap(err, q)
+	}
+	defer rows.Close()
+
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	byName := &ident.Map[int]{}
+	for idx, col := range colTypes {
+		byName.Put(ident.New(col.Name()), idx)
+	}
+	lookup := func(name string) (int, error) {
+		idx, ok := byName.Get(ident.New(name))
+		if !ok {
+			return 0, errors.Errorf("change table did not return column %s", name)
+		}
+		return idx, nil
+	}
+	timeIdx, err := lookup("__$commit_time")
+	if err != nil {
+		return nil, err
+	}
+	commitIdx, err := lookup("__$start_lsn")
+	if err != nil {
+		return nil, err
+	}
+	seqIdx, err := lookup("__$seqval")
+	if err != nil {
+		return nil, err
+	}
+	opIdx, err := lookup("__$operation")
+	if err != nil {
+		return nil, err
+	}
+	keyIdx := make([]int, len(inst.keys))
+	for idx, key := range inst.keys {
+		if keyIdx[idx], err = lookup(key.Raw()); err != nil {
+			return nil, err
+		}
+	}
+
+	var ret []*change
+	for rows.Next() {
+		row := make([]any, len(colTypes))
+		ptrs := make([]any, len(colTypes))
+		for idx := range row {
+			ptrs[idx] = &row[idx]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		chg := &change{instance: inst}
+		if chg.commit, err = newLSN(row[commitIdx]); err != nil {
+			return nil, err
+		}
+		if chg.seq, err = newLSN(row[seqIdx]); err != nil {
+			return nil, err
+		}
+		if cp.Contains(chg.commit, chg.seq) {
+			continue
+		}
+		op, _ := row[opIdx].(int64)
+		if op != opDelete && op != opInsert && op != opUpdateAfter {
+			continue
+		}
+		chg.ts, _ = row[timeIdx].(time.Time)
+
+		data := make(map[string]any, len(row))
+		for idx, col := range colTypes {
+			if strings.HasPrefix(col.Name(), "__$") {
+				continue
+			}
+			if row[idx], err = jsonValue(col.DatabaseTypeName(), row[idx]); err != nil {
+				return nil, errors.Wrapf(err, "column %s", col.Name())
+			}
+			data[col.Name()] = row[idx]
+		}
+		key := make([]any, len(keyIdx))
+		for i, colIdx := range keyIdx {
+			key[i] = row[colIdx]
+		}
+
+		chg.mut.Time = hlc.New(chg.ts.UnixNano(), 0)
+		if chg.mut.Key, err = json.Marshal(key); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if op != opDelete {
+			if chg.mut.Data, err = json.Marshal(data); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		script.AddMeta("mssqllogical", inst.source, &chg.mut)
+		ret = append(ret, chg)
+	}
+	return ret, errors.WithStack(rows.Err())
+}
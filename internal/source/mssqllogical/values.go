@@ -0,0 +1,81 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mssqllogical
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// jsonValue converts a value returned by the SQL Server driver into
+// the representation used by a CockroachDB changefeed. This is the
+// representation that the target's ColData.Parse helpers expect.
+// The typeName is the column's DatabaseTypeName.
+func jsonValue(typeName string, value any) (any, error) {
+	switch t := value.(type) {
+	case nil:
+		return nil, nil
+
+	case []byte:
+		switch typeName {
+		case "DECIMAL", "MONEY", "SMALLMONEY":
+			// The driver returns exact decimal text.
+			return json.Number(t), nil
+		case "UNIQUEIDENTIFIER":
+			return uniqueIdentifier(t)
+		case "BINARY", "IMAGE", "VARBINARY":
+			return `\x` + hex.EncodeToString(t), nil
+		default:
+			return string(t), nil
+		}
+
+	case time.Time:
+		switch typeName {
+		case "DATE":
+			return t.Format("2006-01-02"), nil
+		case "TIME":
+			return t.Format("15:04:05.999999999"), nil
+		case "DATETIMEOFFSET":
+			return t.Format(time.RFC3339Nano), nil
+		default:
+			// DATETIME, DATETIME2, and SMALLDATETIME have no zone.
+			return t.Format("2006-01-02T15:04:05.999999999"), nil
+		}
+
+	default:
+		// Integers, floats, bools, and strings.
+		return value, nil
+	}
+}
+
+// uniqueIdentifier formats a UNIQUEIDENTIFIER, whose first three
+// groups are stored in little-endian order.
+func uniqueIdentifier(b []byte) (string, error) {
+	var u uuid.UUID
+	if len(b) != len(u) {
+		return "", errors.Errorf("expecting %d UNIQUEIDENTIFIER bytes, got %d", len(u), len(b))
+	}
+	copy(u[:], b)
+	u[0], u[1], u[2], u[3] = u[3], u[2], u[1], u[0]
+	u[4], u[5] = u[5], u[4]
+	u[6], u[7] = u[7], u[6]
+	return u.String(), nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mssqllogical
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONValue(t *testing.T) {
+	ts := time.Date(2023, 10, 1, 12, 34, 56, 789000000, time.FixedZone("", -5*60*60))
+	guid := []byte{
+		0x67, 0x45, 0x23, 0x01, 0xab, 0x89, 0xef, 0xcd,
+		0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef,
+	}
+
+	tcs := []struct {
+		typeName string
+		value    any
+		expected any
+		wantErr  string
+	}{
+		{typeName: "INT", value: nil, expected: nil},
+		{typeName: "BIGINT", value: int64(42), expected: int64(42)},
+		{typeName: "BIT", value: true, expected: true},
+		{typeName: "FLOAT", value: 1.5, expected: 1.5},
+		{typeName: "NVARCHAR", value: "hello", expected: "hello"},
+		{typeName: "DECIMAL", value: []byte("12.3400"), expected: json.Number("12.3400")},
+		{typeName: "MONEY", value: []byte("-1.2500"), expected: json.Number("-1.2500")},
+		{typeName: "VARBINARY", value: []byte{0xca, 0xfe}, expected: `\xcafe`},
+		{typeName: "BINARY", value: []byte{}, expected: `\x`},
+		{typeName: "XML", value: []byte("<a/>"), expected: "<a/>"},
+		{
+			typeName: "UNIQUEIDENTIFIER",
+			value:    guid,
+			expected: "01234567-89ab-cdef-0123-456789abcdef",
+		},
+		{typeName: "UNIQUEIDENTIFIER", value: []byte{1}, wantErr: "expecting 16"},
+		{typeName: "DATE", value: ts, expected: "2023-10-01"},
+		{typeName: "TIME", value: ts, expected: "12:34:56.789"},
+		{typeName: "DATETIME2", value: ts, expected: "2023-10-01T12:34:56.789"},
+		{typeName: "DATETIMEOFFSET", value: ts, expected: "2023-10-01T12:34:56.789-05:00"},
+	}
+	for _, tc := range tcs {
+		t.Run(fmt.Sprintf("%s %v", tc.typeName, tc.value), func(t *testing.T) {
+			a := assert.New(t)
+			actual, err := jsonValue(tc.typeName, tc.value)
+			if tc.wantErr != "" {
+				a.ErrorContains(err, tc.wantErr)
+				return
+			}
+			a.NoError(err)
+			a.Equal(tc.expected, actual)
+		})
+	}
+}
@@ -28,14 +28,29 @@ import (
 	"github.com/spf13/pflag"
 )
 
+const defaultSnapshotChunkSize = 10_000
+
 // Config contains the configuration necessary for creating a
 // replication connection. ServerID and SourceConn are mandatory.
 type Config struct {
 	logical.BaseConfig
 	logical.LoopConfig
 
-	SourceConn string // Connection string for the source db.
-	ProcessID  uint32 // A unique ID to identify this process to the master.
+	SourceConn   string // Connection string for the source db.
+	ProcessID    uint32 // A unique ID to identify this process to the master.
+	PropagateDDL bool   // Apply column changes in the source to the target.
+
+	// Replicate using a binlog file and position instead of GTIDs.
+	BinlogPosition bool
+	// The binlog file:pos to start from if no state is persisted.
+	DefaultBinlogPosition string
+
+	// Copy the contents of the source tables before streaming. Every
+	// copied table must have a primary key.
+	Snapshot bool
+	// The number of rows to read from a table at once when copying a
+	// snapshot.
+	SnapshotChunkSize int
 
 	// The fields below are extracted by Preflight.
 
@@ -58,8 +73,18 @@ func (c *Config) Bind(f *pflag.FlagSet) {
 	f.StringVar(&c.LoopConfig.DefaultConsistentPoint, "defaultGTIDSet", "",
 		"default GTIDSet. Used if no state is persisted")
 
+	f.BoolVar(&c.BinlogPosition, "binlogPosition", false,
+		"replicate using a binlog file and position instead of GTIDs")
+	f.StringVar(&c.DefaultBinlogPosition, "defaultBinlogPosition", "",
+		"default binlog position, as file:pos. Used with binlogPosition if no state is persisted")
+	f.BoolVar(&c.PropagateDDL, "propagateDDL", false,
+		"apply column additions, drops, and type widenings in the source to the target")
 	f.Uint32Var(&c.ProcessID, "replicationProcessID", 10,
 		"the replication process id to report to the source database")
+	f.BoolVar(&c.Snapshot, "snapshot", false,
+		"copy existing data using a consistent snapshot before streaming")
+	f.IntVar(&c.SnapshotChunkSize, "snapshotChunkSize", defaultSnapshotChunkSize,
+		"the number of rows to read from a table at once when copying a snapshot")
 	f.StringVar(&c.SourceConn, "sourceConn", "",
 		"the source database's connection string")
 }
@@ -124,6 +149,32 @@ func (c *Config) Preflight() error {
 		return errors.New("no SourceConn was configured")
 	}
 
+	if c.BinlogPosition {
+		// Preflight may be called more than once.
+		if c.DefaultBinlogPosition != "" {
+			if c.DefaultConsistentPoint != "" && c.DefaultConsistentPoint != c.DefaultBinlogPosition {
+				return errors.New("binlogPosition mode cannot be used with defaultGTIDSet")
+			}
+			c.DefaultConsistentPoint = c.DefaultBinlogPosition
+		}
+	} else if c.DefaultBinlogPosition != "" {
+		return errors.New("defaultBinlogPosition requires binlogPosition mode")
+	}
+
+	if c.Snapshot {
+		if c.BackfillWindow <= 0 {
+			return errors.New("snapshot mode requires a non-zero backfillWindow")
+		}
+		if c.DefaultConsistentPoint != "" {
+			return errors.New("snapshot mode cannot be used with defaultGTIDSet or defaultBinlogPosition")
+		}
+		if c.SnapshotChunkSize == 0 {
+			c.SnapshotChunkSize = defaultSnapshotChunkSize
+		} else if c.SnapshotChunkSize < 0 {
+			return errors.New("snapshotChunkSize must be >= 0")
+		}
+	}
+
 	u, err := url.Parse(c.SourceConn)
 	if err != nil {
 		return err
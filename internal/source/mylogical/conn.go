@@ -16,7 +16,8 @@
 
 // Package mylogical contains support for reading a mySQL logical
 // replication feed.
-// It uses Replication with Global Transaction Identifiers.
+// It uses Replication with Global Transaction Identifiers or, for
+// servers where GTIDs are not enabled, binlog file and position.
 // See  https://dev.mysql.com/doc/refman/8.0/en/replication-gtids.html
 package mylogical
 
@@ -32,6 +33,7 @@ import (
 
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/ddl"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
@@ -49,14 +51,28 @@ import (
 // responsible for receiving replication messages and replying with
 // status updates.
 type conn struct {
+	// Replicate using a binlog file and position instead of GTIDs.
+	binlogPosition bool
 	// Columns, as ordered by the source database.
 	columns *ident.TableMap[[]types.ColData]
+	// Applies schema changes to the target, if enabled.
+	ddl *ddl.Propagator
 	// Flavor is one of the mysql.MySQLFlavor or mysql.MariaDBFlavor constants
 	flavor string
+	// Records the progress of a snapshot.
+	memo types.Memo
 	// Map source ids to target tables.
 	relations map[uint64]ident.Table
+	// The number of rows to read from a table at once in snapshot mode.
+	snapshotChunkSize int
+	// The memo key used to record the progress of a snapshot.
+	snapshotKey string
+	// The most recently propagated definition of each source table.
+	sourceColumns *ident.TableMap[[]ddl.Column]
 	// The configuration for opening replication connections.
 	sourceConfig replication.BinlogSyncerConfig
+	// Used to access the memo table.
+	stagingPool *types.StagingPool
 }
 
 // mutationType is the type of mutation
@@ -79,9 +95,10 @@ var (
 // Diagnostics implements [diag.Diagnostic].
 func (c *conn) Diagnostic(_ context.Context) any {
 	return map[string]any{
-		"columns":   c.columns,
-		"flavor":    c.flavor,
-		"relations": c.relations,
+		"binlogPosition": c.binlogPosition,
+		"columns":        c.columns,
+		"flavor":         c.flavor,
+		"relations":      c.relations,
 	}
 }
 
@@ -91,15 +108,27 @@ func (c *conn) Process(
 	ctx context.Context, ch <-chan logical.Message, events logical.Events,
 ) error {
 	var batch logical.Batch
+	// Set once data has been added to the batch.
+	var batchHasData bool
 	defer func() {
 		if batch != nil {
 			_ = batch.OnRollback(ctx)
 		}
 	}()
-	// This is the expected consistent point (i.e. transaction id) that
-	// we expect to see, given all previous messages on the wire. It is
-	// set, and reset, any time the upstream producer (re-)starts a read
-	// from the transaction log.
+	// pause releases any locks held in the target before waiting for
+	// the operator to intervene.
+	pause := func(unsupported *ddl.UnsupportedError) error {
+		if batch != nil {
+			_ = batch.OnRollback(ctx)
+			batch = nil
+		}
+		c.ddl.Pause(unsupported)
+		return logical.Pause(ctx, ch, events)
+	}
+	// This is the expected consistent point (i.e. transaction id or
+	// binlog position) that we expect to see, given all previous
+	// messages on the wire. It is set, and reset, any time the upstream
+	// producer (re-)starts a read from the transaction log.
 	var streamCP *consistentPoint
 
 	for msg := range ch {
@@ -118,6 +147,19 @@ func (c *conn) Process(
 			streamCP = nextStamp
 			continue
 		}
+		// Messages from snapshotConn.BackfillInto.
+		switch msg := msg.(type) {
+		case *snapshotChunk:
+			if err := c.onSnapshotChunk(ctx, events, msg); err != nil {
+				return err
+			}
+			continue
+		case *snapshotComplete:
+			if err := c.onSnapshotComplete(ctx, events, msg); err != nil {
+				return err
+			}
+			continue
+		}
 		var ev, ok = msg.(replication.BinlogEvent)
 		if !ok {
 			return errors.Errorf("unexpected message %T", msg)
@@ -156,6 +198,13 @@ func (c *conn) Process(
 			case <-ctx.Done():
 				return ctx.Err()
 			}
+			// The header contains the position of the next event in
+			// the current binlog file, which is where we would resume.
+			if streamCP.pos != nil {
+				streamCP = streamCP.withPosition(
+					time.Unix(int64(ev.Header.Timestamp), 0),
+					mysql.Position{Name: streamCP.pos.Name, Pos: ev.Header.LogPos})
+			}
 
 			if err := events.SetConsistentPoint(ctx, streamCP); err != nil {
 				return err
@@ -165,6 +214,12 @@ func (c *conn) Process(
 			// A transaction is executed and committed on the source.
 			// This client transaction is assigned a GTID composed of the source's UUID
 			// and the smallest nonzero transaction sequence number not yet used on this server (GNO)
+			//
+			// Anonymous GTID events are also sent when GTIDs are
+			// disabled, so they are ignored in binlog position mode.
+			if streamCP.pos != nil {
+				continue
+			}
 			u, err := uuid.FromBytes(e.SID)
 			if err != nil {
 				return err
@@ -183,16 +238,28 @@ func (c *conn) Process(
 			if e.IsStandalone() {
 				continue
 			}
-			ts := time.Unix(int64(ev.Header.Timestamp), 0)
 			var err error
-			streamCP, err = streamCP.withMariaGTIDSet(ts, &e.GTID)
-			if err != nil {
-				return err
+			if streamCP.pos == nil {
+				ts := time.Unix(int64(ev.Header.Timestamp), 0)
+				streamCP, err = streamCP.withMariaGTIDSet(ts, &e.GTID)
+				if err != nil {
+					return err
+				}
 			}
 			batch, err = events.OnBegin(ctx)
 			if err != nil {
 				return err
 			}
+			batchHasData = false
+
+		case *replication.RotateEvent:
+			// Sent when the source switches to a new binlog file, and
+			// when a connection is established, to identify the file
+			// that is being read.
+			if streamCP.pos != nil {
+				streamCP = streamCP.withPosition(streamCP.ts,
+					mysql.Position{Name: string(e.NextLogName), Pos: uint32(e.Position)})
+			}
 
 		case *replication.QueryEvent:
 			// Only supporting BEGIN
@@ -204,9 +271,28 @@ func (c *conn) Process(
 				if err != nil {
 					return err
 				}
+				batchHasData = false
+			} else if c.ddl != nil {
+				err := c.checkQuery(ctx, events.GetTargetDB(), string(e.Schema), string(e.Query))
+				if unsupported, ok := ddl.IsUnsupported(err); ok {
+					return pause(unsupported)
+				}
+				if err != nil {
+					return err
+				}
 			}
 
 		case *replication.TableMapEvent:
+			if c.ddl != nil {
+				var err error
+				batch, err = c.propagateDDL(ctx, events, batch, batchHasData, e)
+				if unsupported, ok := ddl.IsUnsupported(err); ok {
+					return pause(unsupported)
+				}
+				if err != nil {
+					return err
+				}
+			}
 			if err := c.onRelation(e); err != nil {
 				return err
 			}
@@ -224,6 +310,7 @@ func (c *conn) Process(
 				return errors.Errorf("Operation not supported %s", ev.Header.EventType)
 			}
 			mutationCount.With(prometheus.Labels{"type": operation.String()}).Inc()
+			batchHasData = true
 			if err := c.onDataTuple(ctx, batch, events.GetTargetDB(), e, operation); err != nil {
 				return err
 			}
@@ -245,7 +332,19 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 	if cp == nil {
 		return errors.New("missing gtidset")
 	}
-	streamer, err := syncer.StartSyncGTID(cp.(*consistentPoint).AsGTIDSet())
+	var streamer *replication.BinlogStreamer
+	var err error
+	if c.binlogPosition {
+		pos := cp.(*consistentPoint).AsPosition()
+		// The first event in a binlog file follows a magic number.
+		if pos.Pos < 4 {
+			pos.Pos = 4
+		}
+		log.WithField("position", pos).Info("starting binlog position replication")
+		streamer, err = syncer.StartSync(pos)
+	} else {
+		streamer, err = syncer.StartSyncGTID(cp.(*consistentPoint).AsGTIDSet())
+	}
 	if err != nil {
 		dialFailureCount.Inc()
 		return err
@@ -284,6 +383,7 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 			*replication.TableMapEvent,
 			*replication.RowsEvent,
 			*replication.QueryEvent,
+			*replication.RotateEvent,
 			*replication.MariadbGTIDEvent,
 			*replication.MariadbAnnotateRowsEvent:
 			select {
@@ -292,7 +392,6 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 				return nil
 			}
 		case *replication.GenericEvent,
-			*replication.RotateEvent,
 			*replication.PreviousGTIDsEvent,
 			*replication.MariadbGTIDListEvent,
 			*replication.MariadbBinlogCheckPointEvent:
@@ -316,7 +415,7 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 
 // ZeroStamp implements logical.Dialect.
 func (c *conn) ZeroStamp() stamp.Stamp {
-	return newConsistentPoint(c.flavor)
+	return c.zeroPoint()
 }
 
 func (c *conn) onDataTuple(
@@ -395,33 +494,100 @@ func (c *conn) onDataTuple(
 // onRelation updates the source database namespace mappings.
 // Columns names are only available if
 // set global binlog_row_metadata = full;
+//
+// In binlog position mode, servers which predate binlog_row_metadata
+// are supported by reading the column names from the source's
+// information_schema the first time that a table id is seen.
 func (c *conn) onRelation(msg *replication.TableMapEvent) error {
 	tbl := ident.NewTable(
 		ident.MustSchema(ident.New(string(msg.Schema)), ident.Public),
 		ident.New(string(msg.Table)))
 	log.Tracef("Learned %+v", tbl)
-	c.relations[msg.TableID] = tbl
 	colData := make([]types.ColData, msg.ColumnCount)
 	primary := make(map[uint64]bool)
 	for _, p := range msg.PrimaryKey {
 		primary[p] = true
 	}
-	if len(msg.ColumnName) != len(msg.ColumnType) {
+	names := make([]string, len(msg.ColumnType))
+	switch {
+	case len(msg.ColumnName) == len(msg.ColumnType):
+		for idx, name := range msg.ColumnName {
+			names[idx] = string(name)
+		}
+
+	case c.binlogPosition:
+		// The server assigns a new table id when a table's definition
+		// changes, so the columns can be reused.
+		if prev, ok := c.relations[msg.TableID]; ok && ident.Equal(prev, tbl) {
+			if cols, ok := c.columns.Get(tbl); ok && len(cols) == len(msg.ColumnType) {
+				return nil
+			}
+		}
+		cols, err := c.catalogColumns(string(msg.Schema), string(msg.Table))
+		if err != nil {
+			return err
+		}
+		if len(cols) != len(msg.ColumnType) {
+			return errors.Errorf(
+				"%s has %d columns in the source catalog, but %d in the binlog; "+
+					"'set global binlog_row_metadata = full' if supported",
+				tbl, len(cols), len(msg.ColumnType))
+		}
+		for idx, col := range cols {
+			names[idx] = col.name
+			if col.primary {
+				primary[uint64(idx)] = true
+			}
+		}
+
+	default:
 		return errors.New("all columns names are required 'set global binlog_row_metadata = full'")
 	}
 	for idx, ctype := range msg.ColumnType {
 		_, found := primary[uint64(idx)]
 		colData[idx] = types.ColData{
 			Ignored: false,
-			Name:    ident.New(string(msg.ColumnName[idx])),
+			Name:    ident.New(names[idx]),
 			Primary: found,
 			Type:    fmt.Sprintf("%d", ctype),
 		}
 	}
+	c.relations[msg.TableID] = tbl
 	c.columns.Put(tbl, colData)
 	return nil
 }
 
+// catalogColumns reads a table's columns from the source database.
+func (c *conn) catalogColumns(database, table string) ([]snapshotColumn, error) {
+	source, err := c.openSource()
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+	return snapshotColumns(source, database, table)
+}
+
+// openSource opens a non-replication connection to the source database.
+func (c *conn) openSource() (*client.Conn, error) {
+	addr := fmt.Sprintf("%s:%d", c.sourceConfig.Host, c.sourceConfig.Port)
+	ret, err := client.Connect(addr, c.sourceConfig.User, c.sourceConfig.Password, "",
+		func(conn *client.Conn) {
+			conn.SetTLSConfig(c.sourceConfig.TLSConfig)
+		})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to source database")
+	}
+	return ret, nil
+}
+
+// zeroPoint returns an empty consistentPoint for the replication mode.
+func (c *conn) zeroPoint() *consistentPoint {
+	if c.binlogPosition {
+		return newPositionPoint()
+	}
+	return newConsistentPoint(c.flavor)
+}
+
 var (
 	// Required settings. { {"system variable", "expected value"}}
 	mySQLSystemSettings = [][]string{
@@ -434,12 +600,20 @@ var (
 		{"binlog_format", "ROW"},
 		{"binlog_row_metadata", "FULL"},
 	}
+	// Binlog position mode doesn't require GTIDs and supports servers
+	// which predate binlog_row_metadata.
+	positionSystemSettings = [][]string{
+		{"log_bin", "1"},
+		{"binlog_format", "ROW"},
+		{"binlog_row_image", "FULL"},
+	}
 )
 
 // getFlavor connects to the server and tries to determine the type of server by looking at the
 // @@version_comment system variable.
 // Based on the type of server it also verifies that the settings defined in the
 // mySQLSystemSettings and mariaDBSystemSettings slices are correctly configured for the replication to work.
+// The positionSystemSettings are checked instead in binlog position mode.
 // It returns mysql.MariaDBFlavor or mysql.MySQLFlavor upon success.
 func getFlavor(config *Config) (string, error) {
 	addr := fmt.Sprintf("%s:%d", config.host, config.port)
@@ -460,22 +634,22 @@ func getFlavor(config *Config) (string, error) {
 
 	version := string(res.Values[0][0].AsString())
 	log.Infof("Version info: %s", version)
+	flavor := mysql.MySQLFlavor
+	settings := mySQLSystemSettings
 	if strings.Contains(version, "mariadb") {
-		for _, v := range mariaDBSystemSettings {
-			err = checkSystemSetting(c, v[0], v[1])
-			if err != nil {
-				return "", err
-			}
-		}
-		return mysql.MariaDBFlavor, nil
+		flavor = mysql.MariaDBFlavor
+		settings = mariaDBSystemSettings
+	}
+	if config.BinlogPosition {
+		settings = positionSystemSettings
 	}
-	for _, v := range mySQLSystemSettings {
+	for _, v := range settings {
 		err = checkSystemSetting(c, v[0], v[1])
 		if err != nil {
 			return "", err
 		}
 	}
-	return mysql.MySQLFlavor, nil
+	return flavor, nil
 }
 
 func checkSystemSetting(c *client.Conn, variable string, expected string) error {
@@ -18,6 +18,9 @@ package mylogical
 
 import (
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
@@ -27,11 +30,13 @@ import (
 )
 
 // consistentPoint provides a uniform API around the various
-// flavors of GTIDSet used by the replication library.
+// flavors of GTIDSet used by the replication library, or a binlog file
+// and position when GTIDs are not available.
 type consistentPoint struct {
-	ma *mysql.MariadbGTIDSet
-	my *mysql.MysqlGTIDSet
-	ts time.Time // The approximate wall time of the consistent point.
+	ma  *mysql.MariadbGTIDSet
+	my  *mysql.MysqlGTIDSet
+	pos *mysql.Position
+	ts  time.Time // The approximate wall time of the consistent point.
 }
 
 var _ logical.TimeStamp = (*consistentPoint)(nil)
@@ -59,6 +64,12 @@ func newConsistentPoint(flavor string) *consistentPoint {
 	}
 }
 
+// newPositionPoint constructs an empty consistentPoint that records a
+// binlog file and position.
+func newPositionPoint() *consistentPoint {
+	return &consistentPoint{pos: &mysql.Position{}}
+}
+
 // AsGTIDSet returns the enclosed GTIDSet.
 func (c *consistentPoint) AsGTIDSet() mysql.GTIDSet {
 	switch {
@@ -71,18 +82,29 @@ func (c *consistentPoint) AsGTIDSet() mysql.GTIDSet {
 	}
 }
 
+// AsPosition returns the enclosed binlog file and position.
+func (c *consistentPoint) AsPosition() mysql.Position {
+	if c.pos == nil {
+		return mysql.Position{}
+	}
+	return *c.pos
+}
+
 // AsTime implements logical.TimeStamp.
 func (c *consistentPoint) AsTime() time.Time {
 	return c.ts
 }
 
-// IsZero returns true if the consistentPoint contains no GTID values.
+// IsZero returns true if the consistentPoint contains no GTID values
+// or binlog position.
 func (c *consistentPoint) IsZero() bool {
 	switch {
 	case c.ma != nil:
 		return len(c.ma.Sets) == 0
 	case c.my != nil:
 		return len(c.my.Sets) == 0
+	case c.pos != nil:
+		return c.pos.Name == ""
 	default:
 		return true
 	}
@@ -97,6 +119,12 @@ func (c *consistentPoint) Less(other stamp.Stamp) bool {
 	if c.IsZero() {
 		return true
 	}
+	if c.pos != nil && oPoint.pos != nil {
+		if c.pos.Name != oPoint.pos.Name {
+			return lessBinlogFile(c.pos.Name, oPoint.pos.Name)
+		}
+		return c.pos.Pos < oPoint.pos.Pos
+	}
 
 	cSet := c.AsGTIDSet()
 	oSet := oPoint.AsGTIDSet()
@@ -105,11 +133,14 @@ func (c *consistentPoint) Less(other stamp.Stamp) bool {
 
 // String is for debugging use only.
 func (c *consistentPoint) String() string {
+	if c.pos != nil {
+		return fmt.Sprintf("%s:%d", c.pos.Name, c.pos.Pos)
+	}
 	return c.AsGTIDSet().String()
 }
 
-// parseFrom decodes GTID Sets expressed as strings. This method returns
-// the receiver.
+// parseFrom decodes GTID Sets or binlog positions expressed as
+// strings. This method returns the receiver.
 //
 // Supports MySQL or MariaDB
 // See https://dev.mysql.com/doc/refman/8.0/en/replication-gtids-concepts.html
@@ -117,6 +148,7 @@ func (c *consistentPoint) String() string {
 // Examples:
 // MySQL: E11FA47-71CA-11E1-9E33-C80AA9429562:1-3:11:47-49
 // MariaDB: 0-1-1
+// Binlog position: mysql-bin.000003:154
 func (c *consistentPoint) parseFrom(text string) (*consistentPoint, error) {
 	switch {
 	case c.ma != nil:
@@ -133,6 +165,21 @@ func (c *consistentPoint) parseFrom(text string) (*consistentPoint, error) {
 		}
 		c.my = set.(*mysql.MysqlGTIDSet)
 
+	case c.pos != nil:
+		if text == "" {
+			c.pos = &mysql.Position{}
+			break
+		}
+		idx := strings.LastIndexByte(text, ':')
+		if idx <= 0 {
+			return nil, errors.Errorf("binlog position %q must be of the form file:pos", text)
+		}
+		pos, err := strconv.ParseUint(text[idx+1:], 10, 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid binlog position %q", text)
+		}
+		c.pos = &mysql.Position{Name: text[:idx], Pos: uint32(pos)}
+
 	default:
 		return nil, errors.New("no flavor configured")
 	}
@@ -155,9 +202,41 @@ func (c *consistentPoint) withMysqlGTIDSet(ts time.Time, set *mysql.UUIDSet) *co
 	return &consistentPoint{my: cloned, ts: ts}
 }
 
+// lessBinlogFile orders binlog file names by their numeric extension,
+// which is incremented each time the log is rotated. The extension is
+// zero-padded to six digits, but may grow beyond that width.
+func lessBinlogFile(a, b string) bool {
+	aBase, aSeq, aOK := splitBinlogFile(a)
+	bBase, bSeq, bOK := splitBinlogFile(b)
+	if aOK && bOK && aBase == bBase {
+		return aSeq < bSeq
+	}
+	return a < b
+}
+
+// splitBinlogFile returns the base name and sequence number of a
+// binlog file name such as mysql-bin.000042.
+func splitBinlogFile(name string) (base string, seq uint64, ok bool) {
+	idx := strings.LastIndexByte(name, '.')
+	if idx < 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseUint(name[idx+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return name[:idx], seq, true
+}
+
+func (c *consistentPoint) withPosition(ts time.Time, pos mysql.Position) *consistentPoint {
+	return &consistentPoint{pos: &pos, ts: ts}
+}
+
 type consistentPointPayload struct {
+	File   string    `json:"file,omitempty"`
 	Flavor string    `json:"flavor"`
 	GTID   string    `json:"gtid"`
+	Pos    uint32    `json:"pos,omitempty"`
 	TS     time.Time `json:"ts"`
 }
 
@@ -170,6 +249,9 @@ func (c *consistentPoint) MarshalJSON() ([]byte, error) {
 	case c.my != nil:
 		p.Flavor = mysql.MySQLFlavor
 		p.GTID = c.my.String()
+	case c.pos != nil:
+		p.File = c.pos.Name
+		p.Pos = c.pos.Pos
 	default:
 		return nil, errors.New("consistentPoint not initialized")
 	}
@@ -182,6 +264,10 @@ func (c *consistentPoint) UnmarshalJSON(data []byte) error {
 		return errors.WithStack(err)
 	}
 	c.ts = p.TS
+	if c.pos != nil {
+		c.pos = &mysql.Position{Name: p.File, Pos: p.Pos}
+		return nil
+	}
 	_, err := c.parseFrom(p.GTID)
 	return err
 }
@@ -65,8 +65,8 @@ func Test_mySqlStamp_Less(t *testing.T) {
 				return
 			}
 			a.Equalf(tt.want, this.Less(that), "%s failed", tt.name)
-			checkMarshal(a, mysql.MySQLFlavor, this)
-			checkMarshal(a, mysql.MySQLFlavor, that)
+			checkMarshal(a, newConsistentPoint(mysql.MySQLFlavor), this)
+			checkMarshal(a, newConsistentPoint(mysql.MySQLFlavor), that)
 		})
 	}
 }
@@ -100,18 +100,68 @@ func Test_mariadbStamp_Less(t *testing.T) {
 				return
 			}
 			a.Equalf(tt.want, this.Less(that), "%s failed", tt.name)
-			checkMarshal(a, mysql.MariaDBFlavor, this)
-			checkMarshal(a, mysql.MariaDBFlavor, that)
+			checkMarshal(a, newConsistentPoint(mysql.MariaDBFlavor), this)
+			checkMarshal(a, newConsistentPoint(mysql.MariaDBFlavor), that)
 		})
 	}
 }
 
-func checkMarshal(a *assert.Assertions, flavor string, cp *consistentPoint) {
+func Test_positionStamp_Less(t *testing.T) {
+	tests := []struct {
+		name string
+		this string
+		that string
+		want bool
+	}{
+		{"empty0", "", "", false},
+		{"empty1", "", "mysql-bin.000001:4", true},
+		{"empty2", "mysql-bin.000001:4", "", false},
+		{"single0", "mysql-bin.000001:154", "mysql-bin.000001:154", false},
+		{"single1", "mysql-bin.000001:154", "mysql-bin.000001:1024", true},
+		{"single2", "mysql-bin.000001:1024", "mysql-bin.000001:154", false},
+		{"rotate0", "mysql-bin.000001:1024", "mysql-bin.000002:4", true},
+		{"rotate1", "mysql-bin.000002:4", "mysql-bin.000001:1024", false},
+		{"rotate2", "mysql-bin.999999:1024", "mysql-bin.1000000:4", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := assert.New(t)
+			this, err := newPositionPoint().parseFrom(tt.this)
+			if !a.NoError(err) {
+				return
+			}
+			that, err := newPositionPoint().parseFrom(tt.that)
+			if !a.NoError(err) {
+				return
+			}
+			a.Equalf(tt.want, this.Less(that), "%s failed", tt.name)
+			checkMarshal(a, newPositionPoint(), this)
+			checkMarshal(a, newPositionPoint(), that)
+		})
+	}
+}
+
+func Test_positionStamp_Parse(t *testing.T) {
+	a := assert.New(t)
+
+	cp, err := newPositionPoint().parseFrom("mysql-bin.000003:154")
+	if a.NoError(err) {
+		a.Equal(mysql.Position{Name: "mysql-bin.000003", Pos: 154}, cp.AsPosition())
+		a.Equal("mysql-bin.000003:154", cp.String())
+		a.False(cp.IsZero())
+	}
+
+	for _, bad := range []string{"mysql-bin.000003", ":154", "mysql-bin.000003:", "mysql-bin.000003:-1"} {
+		_, err := newPositionPoint().parseFrom(bad)
+		a.Errorf(err, "%q", bad)
+	}
+}
+
+func checkMarshal(a *assert.Assertions, next *consistentPoint, cp *consistentPoint) {
 	data, err := cp.MarshalJSON()
 	if !a.NoError(err) {
 		return
 	}
-	next := newConsistentPoint(flavor)
 	if !a.NoError(next.UnmarshalJSON(data)) {
 		return
 	}
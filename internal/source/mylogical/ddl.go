@@ -0,0 +1,227 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mylogical
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/ddl"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/pkg/errors"
+)
+
+var (
+	// Matches ALTER TABLE ... RENAME statements. The second group may
+	// also be a keyword if a column or index is being renamed.
+	alterRenameRegex = regexp.MustCompile(
+		`(?is)^\s*ALTER\s+(?:ONLINE\s+|IGNORE\s+)*TABLE\s+([^\s(]+)\s.*\bRENAME\s+(?:TO\s+|AS\s+)?([^\s,;]+)`)
+	// Matches the first table in a RENAME TABLE statement.
+	renameTableRegex = regexp.MustCompile(
+		`(?is)^\s*RENAME\s+TABLES?\s+([^\s,]+)\s+TO\s+([^\s,;]+)`)
+)
+
+// checkQuery returns an UnsupportedError if the DDL statement renames a
+// table in the target schema. Column renames do not need to be detected
+// here, since they are rejected by [ddl.Diff]. The error is suppressed
+// once the operator has created the renamed table in the target, since
+// the statement will be replayed when replication is restarted.
+func (c *conn) checkQuery(
+	ctx context.Context, filter ident.Schema, defaultSchema, query string,
+) error {
+	from, to, ok := renamedTable(defaultSchema, query)
+	if !ok || (!filter.Contains(from) && !filter.Contains(to)) {
+		return nil
+	}
+	if filter.Contains(to) {
+		exists, err := c.ddl.Exists(ctx, to)
+		if err != nil || exists {
+			return err
+		}
+	}
+	return &ddl.UnsupportedError{
+		Table:  from,
+		Reason: fmt.Sprintf("table renamed to %s", to),
+	}
+}
+
+// renamedTable returns the old and new names of a table if the DDL
+// statement renames a table.
+func renamedTable(defaultSchema, query string) (from, to ident.Table, ok bool) {
+	match := alterRenameRegex.FindStringSubmatch(query)
+	if match != nil {
+		switch strings.ToUpper(match[2]) {
+		case "COLUMN", "INDEX", "KEY":
+			return ident.Table{}, ident.Table{}, false
+		}
+	} else if match = renameTableRegex.FindStringSubmatch(query); match == nil {
+		return ident.Table{}, ident.Table{}, false
+	}
+	return parseTableName(defaultSchema, match[1]), parseTableName(defaultSchema, match[2]), true
+}
+
+// parseTableName converts a possibly-qualified and possibly-quoted
+// table name into a Table.
+func parseTableName(defaultSchema, name string) ident.Table {
+	schema := defaultSchema
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		schema, name = name[:idx], name[idx+1:]
+	}
+	schema = strings.Trim(schema, "`")
+	name = strings.Trim(name, "`")
+	return ident.NewTable(
+		ident.MustSchema(ident.New(schema), ident.Public),
+		ident.New(name))
+}
+
+// propagateDDL compares the table definition to the target table's
+// columns and applies any differences to the target. The target is
+// modified outside of any open batch, since the batch may hold locks
+// on the table being altered. A batch that has not yet received any
+// data will be reopened and returned. Otherwise, an error is returned
+// so that the transaction will be replayed.
+func (c *conn) propagateDDL(
+	ctx context.Context,
+	events logical.Events,
+	batch logical.Batch,
+	batchHasData bool,
+	msg *replication.TableMapEvent,
+) (logical.Batch, error) {
+	tbl := ident.NewTable(
+		ident.MustSchema(ident.New(string(msg.Schema)), ident.Public),
+		ident.New(string(msg.Table)))
+	if !events.GetTargetDB().Contains(tbl) {
+		return batch, nil
+	}
+	if len(msg.ColumnName) != len(msg.ColumnType) {
+		return batch, errors.New("all columns names are required 'set global binlog_row_metadata = full'")
+	}
+
+	primary := make(map[uint64]bool)
+	for _, p := range msg.PrimaryKey {
+		primary[p] = true
+	}
+	unsigned := msg.UnsignedMap()
+	cols := make([]ddl.Column, len(msg.ColumnType))
+	for idx, ctype := range msg.ColumnType {
+		var meta uint16
+		if idx < len(msg.ColumnMeta) {
+			meta = msg.ColumnMeta[idx]
+		}
+		cols[idx] = ddl.Column{
+			Name:    ident.New(string(msg.ColumnName[idx])),
+			Primary: primary[uint64(idx)],
+			Type:    myType(ctype, meta, unsigned[idx]),
+		}
+	}
+
+	// The target's columns are the baseline, so that changes made
+	// while we weren't running are detected. The previously-observed
+	// definition, if any, is used to detect type changes and drops.
+	prev, _ := c.sourceColumns.Get(tbl)
+	changes, err := c.ddl.Plan(ctx, tbl, prev, cols)
+	if err != nil {
+		return batch, err
+	}
+	if len(changes) == 0 {
+		c.sourceColumns.Put(tbl, cols)
+		return batch, nil
+	}
+
+	if batch != nil {
+		if err := batch.OnRollback(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.ddl.Apply(ctx, changes); err != nil {
+		return nil, err
+	}
+	c.sourceColumns.Put(tbl, cols)
+
+	switch {
+	case batch == nil:
+		return nil, nil
+	case batchHasData:
+		return nil, errors.Errorf(
+			"schema of %s changed within a transaction; replaying transaction", tbl)
+	default:
+		return events.OnBegin(ctx)
+	}
+}
+
+// myType maps a MySQL column type and its binlog metadata to a
+// product-neutral type. Unsigned integers are mapped to the next-larger
+// signed type.
+func myType(ctype byte, meta uint16, unsigned bool) ddl.Type {
+	switch ctype {
+	case mysql.MYSQL_TYPE_TINY, mysql.MYSQL_TYPE_YEAR:
+		return ddl.Type{Kind: ddl.TypeInt16}
+	case mysql.MYSQL_TYPE_SHORT:
+		if unsigned {
+			return ddl.Type{Kind: ddl.TypeInt32}
+		}
+		return ddl.Type{Kind: ddl.TypeInt16}
+	case mysql.MYSQL_TYPE_INT24:
+		return ddl.Type{Kind: ddl.TypeInt32}
+	case mysql.MYSQL_TYPE_LONG:
+		if unsigned {
+			return ddl.Type{Kind: ddl.TypeInt64}
+		}
+		return ddl.Type{Kind: ddl.TypeInt32}
+	case mysql.MYSQL_TYPE_LONGLONG:
+		if unsigned {
+			return ddl.Type{Kind: ddl.TypeNumeric, Precision: 20}
+		}
+		return ddl.Type{Kind: ddl.TypeInt64}
+	case mysql.MYSQL_TYPE_FLOAT:
+		return ddl.Type{Kind: ddl.TypeFloat32}
+	case mysql.MYSQL_TYPE_DOUBLE:
+		return ddl.Type{Kind: ddl.TypeFloat64}
+	case mysql.MYSQL_TYPE_NEWDECIMAL:
+		// The metadata contains the precision and scale.
+		return ddl.Type{Kind: ddl.TypeNumeric, Precision: int(meta >> 8), Scale: int(meta & 0xff)}
+	case mysql.MYSQL_TYPE_DECIMAL:
+		return ddl.Type{Kind: ddl.TypeNumeric}
+	case mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_VAR_STRING:
+		// The metadata contains the maximum length in bytes, which is
+		// at least the length in characters.
+		return ddl.Type{Kind: ddl.TypeString, Length: int(meta)}
+	case mysql.MYSQL_TYPE_STRING, mysql.MYSQL_TYPE_ENUM, mysql.MYSQL_TYPE_SET,
+		mysql.MYSQL_TYPE_BIT, mysql.MYSQL_TYPE_TINY_BLOB, mysql.MYSQL_TYPE_MEDIUM_BLOB,
+		mysql.MYSQL_TYPE_LONG_BLOB, mysql.MYSQL_TYPE_BLOB:
+		// The binlog doesn't distinguish between binary and textual
+		// blobs. The values are decoded as strings by onDataTuple.
+		return ddl.Type{Kind: ddl.TypeString}
+	case mysql.MYSQL_TYPE_DATE, mysql.MYSQL_TYPE_NEWDATE:
+		return ddl.Type{Kind: ddl.TypeDate}
+	case mysql.MYSQL_TYPE_TIME, mysql.MYSQL_TYPE_TIME2:
+		return ddl.Type{Kind: ddl.TypeTime}
+	case mysql.MYSQL_TYPE_DATETIME, mysql.MYSQL_TYPE_DATETIME2:
+		return ddl.Type{Kind: ddl.TypeTimestamp}
+	case mysql.MYSQL_TYPE_TIMESTAMP, mysql.MYSQL_TYPE_TIMESTAMP2:
+		return ddl.Type{Kind: ddl.TypeTimestampTZ}
+	case mysql.MYSQL_TYPE_JSON:
+		return ddl.Type{Kind: ddl.TypeJSON}
+	default:
+		return ddl.Type{Kind: ddl.TypeUnknown}
+	}
+}
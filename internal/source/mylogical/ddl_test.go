@@ -0,0 +1,65 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mylogical
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/target/ddl"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenamedTable(t *testing.T) {
+	tbl := func(schema, name string) ident.Table {
+		return ident.NewTable(ident.MustSchema(ident.New(schema), ident.Public), ident.New(name))
+	}
+	tests := []struct {
+		query    string
+		from, to ident.Table
+	}{
+		{"ALTER TABLE tbl ADD COLUMN v INT", ident.Table{}, ident.Table{}},
+		{"ALTER TABLE tbl CHANGE v v BIGINT", ident.Table{}, ident.Table{}},
+		{"ALTER TABLE tbl RENAME COLUMN v TO w", ident.Table{}, ident.Table{}},
+		{"ALTER TABLE tbl RENAME INDEX idx TO idx2", ident.Table{}, ident.Table{}},
+		{"alter table `tbl` rename to `other`", tbl("db", "tbl"), tbl("db", "other")},
+		{"ALTER TABLE tbl ADD COLUMN v INT, RENAME AS other", tbl("db", "tbl"), tbl("db", "other")},
+		{"ALTER TABLE other_db.tbl RENAME other_db.other", tbl("other_db", "tbl"), tbl("other_db", "other")},
+		{"RENAME TABLE `db`.`tbl` TO `db2`.`other`", tbl("db", "tbl"), tbl("db2", "other")},
+		{"CREATE TABLE renamed (pk INT PRIMARY KEY)", ident.Table{}, ident.Table{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			a := assert.New(t)
+			from, to, ok := renamedTable("db", tt.query)
+			a.Equal(!tt.from.Empty(), ok)
+			a.True(ident.Equal(tt.from, from), "%s vs %s", tt.from, from)
+			a.True(ident.Equal(tt.to, to), "%s vs %s", tt.to, to)
+		})
+	}
+}
+
+func TestMyType(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(ddl.Type{Kind: ddl.TypeInt32}, myType(mysql.MYSQL_TYPE_LONG, 0, false))
+	a.Equal(ddl.Type{Kind: ddl.TypeInt64}, myType(mysql.MYSQL_TYPE_LONG, 0, true))
+	a.Equal(ddl.Type{Kind: ddl.TypeNumeric, Precision: 10, Scale: 2},
+		myType(mysql.MYSQL_TYPE_NEWDECIMAL, 10<<8|2, false))
+	a.Equal(ddl.Type{Kind: ddl.TypeString, Length: 64}, myType(mysql.MYSQL_TYPE_VARCHAR, 64, false))
+	a.Equal(ddl.Type{Kind: ddl.TypeUnknown}, myType(mysql.MYSQL_TYPE_GEOMETRY, 0, false))
+}
@@ -30,6 +30,10 @@ var (
 		Name: "mylogical_dial_success_total",
 		Help: "the number of times we successfully dialed a replication connection",
 	})
+	snapshotRowCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "mylogical_snapshot_rows_total",
+		Help: "the number of rows read from the source database's snapshot",
+	})
 	mutationCount = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "mutation_total",
@@ -19,6 +19,7 @@ package mylogical
 import (
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/ddl"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/go-mysql-org/go-mysql/replication"
@@ -34,7 +35,13 @@ var Set = wire.NewSet(
 // ProvideDialect is called by Wire to construct this package's
 // logical.Dialect implementation. There's a fake dependency on
 // the script loader so that flags can be evaluated first.
-func ProvideDialect(config *Config, _ *script.Loader) (logical.Dialect, error) {
+func ProvideDialect(
+	config *Config,
+	ddls *ddl.Propagator,
+	memo types.Memo,
+	stagingPool *types.StagingPool,
+	_ *script.Loader,
+) (logical.Dialect, error) {
 	if err := config.Preflight(); err != nil {
 		return nil, err
 	}
@@ -53,12 +60,28 @@ func ProvideDialect(config *Config, _ *script.Loader) (logical.Dialect, error) {
 		Password:  config.password,
 		TLSConfig: config.tlsConfig,
 	}
-	return &conn{
-		columns:      &ident.TableMap[[]types.ColData]{},
-		flavor:       flavor,
-		relations:    make(map[uint64]ident.Table),
-		sourceConfig: cfg,
-	}, nil
+	ret := &conn{
+		binlogPosition:    config.BinlogPosition,
+		columns:           &ident.TableMap[[]types.ColData]{},
+		flavor:            flavor,
+		memo:              memo,
+		relations:         make(map[uint64]ident.Table),
+		snapshotChunkSize: config.SnapshotChunkSize,
+		snapshotKey:       config.LoopName + "-snapshot",
+		sourceColumns:     &ident.TableMap[[]ddl.Column]{},
+		sourceConfig:      cfg,
+		stagingPool:       stagingPool,
+	}
+	if config.PropagateDDL {
+		ret.ddl = ddls
+	}
+	// Only advertise the Backfiller capability when requested, since
+	// the logical loop would otherwise choose to backfill when it
+	// starts from an empty GTID set or binlog position.
+	if config.Snapshot {
+		return &snapshotConn{ret}, nil
+	}
+	return ret, nil
 }
 
 // ProvideLoop is called by Wire to construct the sole logical loop used
@@ -0,0 +1,594 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package mylogical
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// errSnapshotStopping is returned from copyTable when the loop is
+// being stopped.
+var errSnapshotStopping = errors.New("snapshot stopping")
+
+// snapshotProgress is stored in the memo table to allow an interrupted
+// snapshot to be resumed.
+type snapshotProgress struct {
+	// The GTID set, or binlog file:pos, at which streaming begins once
+	// all tables have been copied.
+	GTIDSet string `json:"gtidSet"`
+	// Tables which have been completely copied.
+	Done []string `json:"done,omitempty"`
+	// The table which is currently being copied.
+	Table string `json:"table,omitempty"`
+	// The primary key of the last row copied from Table.
+	Key []any `json:"key,omitempty"`
+}
+
+// isDone returns true if the table has been completely copied.
+func (p *snapshotProgress) isDone(table string) bool {
+	for _, done := range p.Done {
+		if done == table {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotChunk is sent from BackfillInto to Process. It contains a
+// chunk of rows which were read from a single table and the progress
+// that should be recorded once the rows have been applied.
+type snapshotChunk struct {
+	muts     []types.Mutation
+	progress snapshotProgress
+	target   ident.Table
+}
+
+// snapshotComplete is sent from BackfillInto to Process once every
+// table has been copied.
+type snapshotComplete struct {
+	cp *consistentPoint
+}
+
+// snapshotColumn describes a column in a source table.
+type snapshotColumn struct {
+	bit     bool
+	name    string
+	primary bool
+	pkPos   int // The one-based position of the column in the primary key.
+}
+
+// snapshotConn adds an initial-load mode to conn. It is only used when
+// [Config.Snapshot] is set, so that the logical loop won't attempt to
+// backfill otherwise.
+type snapshotConn struct {
+	*conn
+}
+
+var _ logical.Backfiller = (*snapshotConn)(nil)
+
+// BackfillInto implements logical.Backfiller. If the loop has not yet
+// reached a consistent point, a transaction with a consistent snapshot
+// is opened and the executed GTID set is captured. Each table in the
+// source database is then read under that snapshot, in primary-key
+// order. Once all tables have been copied, the consistent point is
+// advanced to the captured GTID set so that the loop will hand off to
+// binlog streaming.
+//
+// If the snapshot is interrupted, it will resume from the last chunk
+// of rows that was applied to the target. The resumed copy is
+// performed using a newer snapshot, which is safe since streaming from
+// the original GTID set will replay any changes which are already
+// reflected in the newer snapshot.
+//
+// Tables without a primary key are rejected. Their rows are assigned a
+// random identity, so an interrupted copy could not be resumed without
+// duplicating the rows that had already been applied.
+func (c *snapshotConn) BackfillInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	// If the snapshot has been completed, we're simply behind in
+	// consuming the binlog.
+	cp, _ := state.GetConsistentPoint()
+	if x, ok := cp.(*consistentPoint); ok && !x.IsZero() {
+		return c.ReadInto(ctx, ch, state)
+	}
+
+	progress, err := c.loadSnapshotProgress(ctx)
+	if err != nil {
+		return err
+	}
+
+	source, err := c.openSource()
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	gtidSet, err := c.startSnapshot(source)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = source.Rollback() }()
+
+	if progress.GTIDSet == "" {
+		progress.GTIDSet = gtidSet
+		if err := c.storeSnapshotProgress(ctx, progress); err != nil {
+			return err
+		}
+		log.WithField("gtidSet", gtidSet).Info("starting snapshot")
+	} else {
+		log.WithField("gtidSet", progress.GTIDSet).Info("resuming snapshot")
+	}
+	next, err := c.zeroPoint().parseFrom(progress.GTIDSet)
+	if err != nil {
+		return err
+	}
+
+	// MySQL has a single-level namespace, so we copy the tables in the
+	// database whose name matches the target database.
+	targetDB := state.GetTargetDB()
+	database := targetDB.Idents(nil)[0].Raw()
+	tables, err := snapshotTables(source, database)
+	if err != nil {
+		return err
+	}
+	// Check every remaining table before copying any rows.
+	cols := make([][]snapshotColumn, len(tables))
+	for idx, table := range tables {
+		target := ident.NewTable(
+			ident.MustSchema(ident.New(database), ident.Public), ident.New(table))
+		if !targetDB.Contains(target) {
+			continue
+		}
+		if progress.isDone(quoteIdent(database) + "." + quoteIdent(table)) {
+			continue
+		}
+		cols[idx], err = snapshotColumns(source, database, table)
+		if err != nil {
+			return err
+		}
+	}
+	for idx, table := range tables {
+		target := ident.NewTable(
+			ident.MustSchema(ident.New(database), ident.Public), ident.New(table))
+		if !targetDB.Contains(target) {
+			continue
+		}
+		name := quoteIdent(database) + "." + quoteIdent(table)
+		if progress.isDone(name) {
+			log.WithField("table", name).Debug("table already copied")
+			continue
+		}
+		if err := c.copyTable(ctx, source, ch, state, database, table, target, cols[idx], progress); err != nil {
+			if errors.Is(err, errSnapshotStopping) {
+				return nil
+			}
+			return err
+		}
+		progress.Done = append(progress.Done, name)
+		progress.Key = nil
+		progress.Table = ""
+	}
+
+	select {
+	case ch <- &snapshotComplete{next}:
+	case <-state.Stopping():
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+
+	// Wait for the loop to switch to streaming mode once the consistent
+	// point has been advanced.
+	select {
+	case <-state.Stopping():
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+// startSnapshot opens a transaction with a consistent snapshot and
+// returns the GTID set, or binlog position, that corresponds to the
+// snapshot. The global read lock is held only long enough to ensure
+// that no transactions commit between starting the snapshot and
+// reading the GTID set.
+func (c *snapshotConn) startSnapshot(source *client.Conn) (string, error) {
+	if _, err := source.Execute("FLUSH TABLES WITH READ LOCK"); err != nil {
+		return "", errors.Wrap(err, "could not acquire global read lock")
+	}
+	// UNLOCK TABLES does not commit the transaction, since the lock
+	// was not acquired with LOCK TABLES.
+	defer func() { _, _ = source.Execute("UNLOCK TABLES") }()
+
+	if _, err := source.Execute(
+		"START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY",
+	); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	if c.binlogPosition {
+		res, err := source.Execute("SHOW MASTER STATUS")
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if len(res.Values) == 0 {
+			return "", errors.New("unable to retrieve binlog position; is log_bin enabled?")
+		}
+		file, err := res.GetString(0, 0)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		pos, err := res.GetString(0, 1)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		return file + ":" + pos, nil
+	}
+
+	var q string
+	switch c.flavor {
+	case mysql.MariaDBFlavor:
+		q = "SELECT @@GLOBAL.gtid_binlog_pos"
+	case mysql.MySQLFlavor:
+		q = "SELECT @@GLOBAL.gtid_executed"
+	default:
+		return "", errors.Errorf("unknown flavor %s", c.flavor)
+	}
+	res, err := source.Execute(q)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if len(res.Values) == 0 {
+		return "", errors.New("unable to retrieve executed GTID set")
+	}
+	// MySQL inserts newlines between the elements of large sets.
+	return strings.ReplaceAll(string(res.Values[0][0].AsString()), "\n", ""), nil
+}
+
+// copyTable reads the table in primary-key order, sending chunks of
+// rows to the channel.
+func (c *snapshotConn) copyTable(
+	ctx context.Context,
+	source *client.Conn,
+	ch chan<- logical.Message,
+	state logical.State,
+	database, table string,
+	target ident.Table,
+	cols []snapshotColumn,
+	progress *snapshotProgress,
+) error {
+	name := quoteIdent(database) + "." + quoteIdent(table)
+
+	var pks []snapshotColumn
+	selects := make([]string, len(cols))
+	for idx, col := range cols {
+		selects[idx] = quoteIdent(col.name)
+		if col.primary {
+			pks = append(pks, col)
+		}
+	}
+	// Page through the table in primary-key order, so that the index
+	// can be used.
+	sort.Slice(pks, func(i, j int) bool { return pks[i].pkPos < pks[j].pkPos })
+
+	// Pick up where we left off, if the table was partially copied.
+	var lastKey []any
+	if progress.Table == name && len(progress.Key) == len(pks) {
+		lastKey = progress.Key
+	}
+	log.WithFields(log.Fields{
+		"resume": lastKey,
+		"table":  name,
+	}).Info("copying table from snapshot")
+
+	// send passes a chunk of rows to Process. The key will be nil if
+	// the table has been completely copied.
+	send := func(muts []types.Mutation, key []any) error {
+		chunk := &snapshotChunk{
+			muts: muts,
+			progress: snapshotProgress{
+				GTIDSet: progress.GTIDSet,
+				Done:    append([]string(nil), progress.Done...),
+			},
+			target: target,
+		}
+		if key == nil {
+			chunk.progress.Done = append(chunk.progress.Done, name)
+		} else {
+			chunk.progress.Table = name
+			chunk.progress.Key = key
+		}
+		select {
+		case ch <- chunk:
+			snapshotRowCount.Add(float64(len(muts)))
+			return nil
+		case <-state.Stopping():
+			return errSnapshotStopping
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		}
+	}
+
+	for {
+		q, args := c.snapshotQuery(name, selects, pks, lastKey)
+		res, err := source.Execute(q, args...)
+		if err != nil {
+			return errors.Wrapf(err, "could not read from %s", name)
+		}
+
+		var key []any
+		var muts []types.Mutation
+		for _, row := range res.Values {
+			var mut types.Mutation
+			mut, key, err = snapshotMutation(target, cols, pks, row)
+			if err != nil {
+				return err
+			}
+			muts = append(muts, mut)
+		}
+
+		if len(res.Values) < c.snapshotChunkSize {
+			return send(muts, nil)
+		}
+		if err := send(muts, key); err != nil {
+			return err
+		}
+		lastKey = key
+	}
+}
+
+// snapshotQuery returns a query which reads the next chunk of rows from
+// a table.
+func (c *snapshotConn) snapshotQuery(
+	table string, selects []string, pks []snapshotColumn, lastKey []any,
+) (string, []any) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "SELECT %s FROM %s", strings.Join(selects, ", "), table)
+
+	names := make([]string, len(pks))
+	for idx, pk := range pks {
+		names[idx] = quoteIdent(pk.name)
+	}
+	var args []any
+	if lastKey != nil {
+		params := make([]string, len(pks))
+		for idx := range pks {
+			params[idx] = "?"
+			args = append(args, lastKey[idx])
+		}
+		fmt.Fprintf(&sb, " WHERE (%s) > (%s)",
+			strings.Join(names, ", "), strings.Join(params, ", "))
+	}
+	fmt.Fprintf(&sb, " ORDER BY %s LIMIT %d", strings.Join(names, ", "), c.snapshotChunkSize)
+	return sb.String(), args
+}
+
+// snapshotColumns returns the non-generated columns of the table in
+// their ordinal order. An error is returned if the table has no
+// primary key.
+func snapshotColumns(source *client.Conn, database, table string) ([]snapshotColumn, error) {
+	res, err := source.Execute(`
+SELECT c.column_name, c.data_type, COALESCE(k.ordinal_position, 0)
+  FROM information_schema.columns c
+  LEFT JOIN information_schema.key_column_usage k
+    ON k.table_schema = c.table_schema AND k.table_name = c.table_name
+   AND k.column_name = c.column_name AND k.constraint_name = 'PRIMARY'
+ WHERE c.table_schema = ? AND c.table_name = ?
+   AND c.extra NOT LIKE '%GENERATED%'
+ ORDER BY c.ordinal_position`, database, table)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ret := make([]snapshotColumn, len(res.Values))
+	for idx, row := range res.Values {
+		ret[idx] = snapshotColumn{
+			bit:     strings.EqualFold(string(row[1].AsString()), "bit"),
+			name:    string(row[0].AsString()),
+			primary: row[2].AsInt64() != 0,
+			pkPos:   int(row[2].AsInt64()),
+		}
+	}
+	if len(ret) == 0 {
+		return nil, errors.Errorf("no columns found for %s.%s", database, table)
+	}
+	for _, col := range ret {
+		if col.primary {
+			return ret, nil
+		}
+	}
+	return nil, errors.Errorf(
+		"%s.%s has no primary key and cannot be copied from a snapshot", database, table)
+}
+
+// snapshotTables returns the base tables in the database.
+func snapshotTables(source *client.Conn, database string) ([]string, error) {
+	res, err := source.Execute(
+		"SELECT table_name FROM information_schema.tables "+
+			"WHERE table_schema = ? AND table_type = 'BASE TABLE' ORDER BY table_name",
+		database)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ret := make([]string, len(res.Values))
+	for idx, row := range res.Values {
+		ret[idx] = string(row[0].AsString())
+	}
+	return ret, nil
+}
+
+// snapshotMutation converts a row into a mutation. The primary key of
+// the row is returned, in the order of the pks, to record the progress
+// of the copy.
+func snapshotMutation(
+	tbl ident.Table, cols, pks []snapshotColumn, row []mysql.FieldValue,
+) (types.Mutation, []any, error) {
+	var mut types.Mutation
+	var mutKey []any
+	enc := make(map[string]any, len(cols))
+	for idx, col := range cols {
+		var value any
+		switch field := row[idx]; field.Type {
+		case mysql.FieldValueTypeNull:
+		case mysql.FieldValueTypeSigned:
+			value = field.AsInt64()
+		case mysql.FieldValueTypeUnsigned:
+			value = field.AsUint64()
+		case mysql.FieldValueTypeFloat:
+			value = field.AsFloat64()
+		default:
+			data := field.AsString()
+			if col.bit {
+				// Use the same representation as onDataTuple.
+				var bits uint64
+				for _, b := range data {
+					bits = bits<<8 | uint64(b)
+				}
+				value = strconv.FormatUint(bits, 2)
+			} else {
+				value = string(bytes.Clone(data))
+			}
+		}
+		enc[col.name] = value
+		// As with onDataTuple, the key is in column order.
+		if col.primary {
+			mutKey = append(mutKey, value)
+		}
+	}
+	key := make([]any, len(pks))
+	for idx, pk := range pks {
+		key[idx] = enc[pk.name]
+	}
+
+	var err error
+	mut.Key, err = json.Marshal(mutKey)
+	if err != nil {
+		return mut, nil, errors.WithStack(err)
+	}
+	mut.Data, err = json.Marshal(enc)
+	if err != nil {
+		return mut, nil, errors.WithStack(err)
+	}
+	script.AddMeta("mylogical", tbl, &mut)
+	return mut, key, nil
+}
+
+// onSnapshotChunk applies the rows in the chunk and then records the
+// progress of the snapshot.
+func (c *conn) onSnapshotChunk(
+	ctx context.Context, events logical.Events, chunk *snapshotChunk,
+) error {
+	if len(chunk.muts) > 0 {
+		batch, err := events.OnBegin(ctx)
+		if err != nil {
+			return err
+		}
+		if err := batch.OnData(ctx,
+			script.SourceName(chunk.target), chunk.target, chunk.muts,
+		); err != nil {
+			_ = batch.OnRollback(ctx)
+			return err
+		}
+		select {
+		case err := <-batch.OnCommit(ctx):
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return c.storeSnapshotProgress(ctx, &chunk.progress)
+}
+
+// onSnapshotComplete advances the consistent point to the GTID set at
+// which the snapshot was taken.
+func (c *conn) onSnapshotComplete(
+	ctx context.Context, events logical.Events, msg *snapshotComplete,
+) error {
+	cp := msg.cp
+	cp.ts = time.Now()
+	if err := events.SetConsistentPoint(ctx, cp); err != nil {
+		return err
+	}
+	log.WithField("gtidSet", cp).Info("snapshot complete; switching to streaming")
+	// Clear the progress, in case the loop is ever reset.
+	return c.storeSnapshotProgress(ctx, &snapshotProgress{})
+}
+
+// loadSnapshotProgress returns the progress of the snapshot, which will
+// be zero-valued if no snapshot has been started.
+func (c *conn) loadSnapshotProgress(ctx context.Context) (*snapshotProgress, error) {
+	ret := &snapshotProgress{}
+	data, err := c.memo.Get(ctx, c.stagingPool, c.snapshotKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return ret, nil
+	}
+	// Preserve the precision of numeric key values.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(ret); err != nil {
+		return nil, errors.Wrapf(err, "could not decode snapshot progress %q", c.snapshotKey)
+	}
+	for idx, value := range ret.Key {
+		num, ok := value.(json.Number)
+		if !ok {
+			continue
+		}
+		if i, err := strconv.ParseInt(num.String(), 10, 64); err == nil {
+			ret.Key[idx] = i
+		} else if u, err := strconv.ParseUint(num.String(), 10, 64); err == nil {
+			ret.Key[idx] = u
+		} else if f, err := num.Float64(); err == nil {
+			ret.Key[idx] = f
+		}
+	}
+	return ret, nil
+}
+
+// storeSnapshotProgress records the progress of the snapshot.
+func (c *conn) storeSnapshotProgress(ctx context.Context, progress *snapshotProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.memo.Put(ctx, c.stagingPool, c.snapshotKey, data)
+}
+
+// quoteIdent returns a MySQL quoted identifier.
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/staging/memo"
 	"github.com/cockroachdb/cdc-sink/internal/staging/version"
 	"github.com/cockroachdb/cdc-sink/internal/target/apply"
+	"github.com/cockroachdb/cdc-sink/internal/target/ddl"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
 	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
@@ -35,11 +36,6 @@ func Start(ctx context.Context, config *Config) (*MYLogical, func(), error) {
 		cleanup()
 		return nil, nil, err
 	}
-	dialect, err := ProvideDialect(config, loader)
-	if err != nil {
-		cleanup()
-		return nil, nil, err
-	}
 	baseConfig, err := logical.ProvideBaseConfig(config, loader)
 	if err != nil {
 		cleanup()
@@ -71,8 +67,7 @@ func Start(ctx context.Context, config *Config) (*MYLogical, func(), error) {
 		cleanup()
 		return nil, nil, err
 	}
-	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, cleanup5, err := apply.ProvideFactory(targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	propagator, err := ddl.ProvidePropagator(targetPool, watchers, diagnostics)
 	if err != nil {
 		cleanup4()
 		cleanup3()
@@ -80,9 +75,8 @@ func Start(ctx context.Context, config *Config) (*MYLogical, func(), error) {
 		cleanup()
 		return nil, nil, err
 	}
-	stagingPool, cleanup6, err := logical.ProvideStagingPool(ctx, baseConfig, diagnostics)
+	stagingPool, cleanup5, err := logical.ProvideStagingPool(ctx, baseConfig, diagnostics)
 	if err != nil {
-		cleanup5()
 		cleanup4()
 		cleanup3()
 		cleanup2()
@@ -91,7 +85,6 @@ func Start(ctx context.Context, config *Config) (*MYLogical, func(), error) {
 	}
 	stagingSchema, err := logical.ProvideStagingDB(baseConfig)
 	if err != nil {
-		cleanup6()
 		cleanup5()
 		cleanup4()
 		cleanup3()
@@ -101,7 +94,26 @@ func Start(ctx context.Context, config *Config) (*MYLogical, func(), error) {
 	}
 	memoMemo, err := memo.ProvideMemo(ctx, stagingPool, stagingSchema)
 	if err != nil {
-		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	dialect, err := ProvideDialect(config, propagator, memoMemo, stagingPool, loader)
+	if err != nil {
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
+	applyConfig := logical.ProvideApplyConfig(baseConfig)
+	appliers, cleanup6, err := apply.ProvideFactory(targetStatements, applyConfig, configs, diagnostics, dlQs, targetPool, watchers)
+	if err != nil {
 		cleanup5()
 		cleanup4()
 		cleanup3()
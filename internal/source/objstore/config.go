@@ -0,0 +1,124 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package objstore
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// The actions that may be taken once a file has been processed.
+const (
+	AfterArchive = "archive"
+	AfterDelete  = "delete"
+	AfterKeep    = "keep"
+)
+
+const (
+	defaultListLimit    = 1000
+	defaultPollInterval = 10 * time.Second
+)
+
+// Config contains the configuration necessary for consuming a
+// CockroachDB changefeed that writes to an object store. BucketURL and
+// TargetSchema are mandatory.
+type Config struct {
+	// Files are processed in the same manner as the webhook-based
+	// changefeed server.
+	CDC cdc.Config
+
+	After        string        // What to do with processed files.
+	ArchiveURL   string        // Receives processed files when After is AfterArchive.
+	BucketURL    string        // The location of the changefeed's files.
+	ListLimit    int           // The number of file names to list at once.
+	PollInterval time.Duration // How long to wait when there are no new files.
+	TargetSchema ident.Schema  // The schema that contains the target tables.
+}
+
+var _ logical.Config = (*Config)(nil)
+
+// Base implements logical.Config.
+func (c *Config) Base() *logical.BaseConfig {
+	return c.CDC.Base()
+}
+
+// Bind adds flags to the set. It delegates to the embedded Config.Bind.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	c.CDC.Bind(f)
+
+	f.StringVar(&c.After, "afterProcessing", AfterKeep,
+		"what to do with files once they have been processed [ keep, delete, archive ]")
+	f.StringVar(&c.ArchiveURL, "archiveURL", "",
+		"a local directory or s3:// URL to move processed files into")
+	f.StringVar(&c.BucketURL, "bucketURL", "",
+		"a local directory or s3://[ACCESS_KEY:SECRET_KEY@]bucket[/prefix][?endpoint=URL&region=REGION] "+
+			"URL that the changefeed writes to")
+	f.IntVar(&c.ListLimit, "listLimit", defaultListLimit,
+		"the number of file names to retrieve in a single listing request")
+	f.DurationVar(&c.PollInterval, "pollInterval", defaultPollInterval,
+		"how often to check for new files once all existing files have been processed")
+	f.Var(ident.NewSchemaFlag(&c.TargetSchema), "targetSchema",
+		"the SQL database schema in the target cluster to update")
+}
+
+// Preflight updates the configuration with sane defaults or returns an
+// error if there are missing options for which a default cannot be
+// provided.
+func (c *Config) Preflight() error {
+	if err := c.CDC.Preflight(); err != nil {
+		return err
+	}
+
+	switch c.After {
+	case "":
+		c.After = AfterKeep
+	case AfterKeep, AfterDelete:
+	case AfterArchive:
+		if c.ArchiveURL == "" {
+			return errors.New("archiveURL must be set to archive processed files")
+		}
+	default:
+		return errors.Errorf("unknown afterProcessing value %q", c.After)
+	}
+	if c.After != AfterArchive && c.ArchiveURL != "" {
+		return errors.New("archiveURL requires --afterProcessing archive")
+	}
+	if c.BucketURL == "" {
+		return errors.New("bucketURL unset")
+	}
+	if c.ListLimit == 0 {
+		c.ListLimit = defaultListLimit
+	}
+	if c.ListLimit < 0 {
+		return errors.New("listLimit must be positive")
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = defaultPollInterval
+	}
+	if c.PollInterval < 0 {
+		return errors.New("pollInterval must be positive")
+	}
+	if c.TargetSchema.Empty() {
+		return errors.New("targetSchema unset")
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package objstore
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/s3fake"
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/objstore"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fixtureConfig struct {
+	after     string
+	immediate bool
+	s3        bool
+}
+
+func TestObjStore(t *testing.T) {
+	t.Run("consistent", func(t *testing.T) { testObjStore(t, &fixtureConfig{}) })
+	t.Run("immediate", func(t *testing.T) {
+		testObjStore(t, &fixtureConfig{immediate: true})
+	})
+	t.Run("s3-archive", func(t *testing.T) {
+		testObjStore(t, &fixtureConfig{after: AfterArchive, s3: true})
+	})
+	t.Run("s3-delete", func(t *testing.T) {
+		testObjStore(t, &fixtureConfig{after: AfterDelete, s3: true})
+	})
+}
+
+func testObjStore(t *testing.T, fc *fixtureConfig) {
+	r := require.New(t)
+
+	fixture, cancel, err := all.NewFixture()
+	r.NoError(err)
+	defer cancel()
+
+	ctx := fixture.Context
+
+	tbl, err := fixture.CreateTargetTable(ctx,
+		`CREATE TABLE %s (pk INT PRIMARY KEY, v VARCHAR(2048))`)
+	r.NoError(err)
+
+	var archiveURL, bucketURL string
+	if fc.s3 {
+		srv := s3fake.New()
+		defer srv.Close()
+		srv.CreateBucket("archive")
+		srv.CreateBucket("changefeed")
+		srv.SetPageSize(3) // Exercise pagination.
+		archiveURL = srv.URL("archive", "")
+		bucketURL = srv.URL("changefeed", "")
+	} else {
+		archiveURL = t.TempDir()
+		bucketURL = t.TempDir()
+	}
+	bucket, err := objstore.Open(bucketURL)
+	r.NoError(err)
+
+	// The changefeed encodes timestamps in file names as the UTC
+	// time, nanoseconds, and logical component.
+	encodeTime := func(ts hlc.Time) string {
+		return time.Unix(0, ts.Nanos()).UTC().Format("20060102150405") +
+			fmt.Sprintf("%09d%010d", ts.Nanos()%int64(time.Second), ts.Logical())
+	}
+	var written []string
+	put := func(name string, data []byte) {
+		t.Helper()
+		r.NoError(bucket.Put(ctx, name, bytes.NewReader(data)))
+		written = append(written, name)
+	}
+	// emit writes an ndjson file containing the given rows. An empty
+	// value represents a deletion.
+	emit := func(ts hlc.Time, values map[int]string) {
+		t.Helper()
+		var buf bytes.Buffer
+		for pk, value := range values {
+			after := "null"
+			if value != "" {
+				after = fmt.Sprintf(`{"pk":%d,"v":%q}`, pk, value)
+			}
+			_, _ = fmt.Fprintf(&buf, `{"after":%s,"key":[%d],"updated":%q}`+"\n",
+				after, pk, ts.String())
+		}
+		ets := encodeTime(ts)
+		put(fmt.Sprintf("%s/%s-56087568dba1e6b8-1-72-00000000-%s-1.ndjson",
+			ets[:4]+"-"+ets[4:6]+"-"+ets[6:8], ets, tbl.Name().Table().Raw()),
+			buf.Bytes())
+	}
+	resolve := func(ts hlc.Time) {
+		t.Helper()
+		ets := encodeTime(ts)
+		put(fmt.Sprintf("%s/%s.RESOLVED", ets[:4]+"-"+ets[4:6]+"-"+ets[6:8], ets),
+			[]byte(fmt.Sprintf(`{"resolved":%q}`, ts.String())))
+	}
+	waitFor := func(query string, expected int) {
+		t.Helper()
+		for {
+			var count int
+			r.NoError(fixture.TargetPool.QueryRowContext(ctx,
+				fmt.Sprintf(query, tbl.Name())).Scan(&count))
+			if count == expected {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				r.NoError(ctx.Err())
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	// Write some files before starting the consumer. Unrelated files
+	// are ignored.
+	const rowCount = 16
+	now := time.Now().UnixNano()
+	values := make(map[int]string, rowCount)
+	for i := 0; i < rowCount; i++ {
+		values[i] = fmt.Sprintf("v=%d", i)
+	}
+	emit(hlc.New(now, 0), values)
+	resolve(hlc.New(now+1, 0))
+	r.NoError(bucket.Put(ctx, "README.txt", strings.NewReader("hello")))
+
+	config := &Config{
+		CDC: cdc.Config{
+			BaseConfig: logical.BaseConfig{
+				ApplyTimeout:  2 * time.Minute, // Increase to make using the debugger easier.
+				Immediate:     fc.immediate,
+				RetryDelay:    time.Nanosecond,
+				StagingConn:   fixture.StagingPool.ConnectionString,
+				StagingSchema: fixture.StagingDB.Schema(),
+				TargetConn:    fixture.TargetPool.ConnectionString,
+			},
+			MetaTableName: ident.New("resolved_timestamps"),
+		},
+		After:        fc.after,
+		BucketURL:    bucketURL,
+		ListLimit:    2,
+		PollInterval: 10 * time.Millisecond,
+		TargetSchema: fixture.TargetSchema.Schema(),
+	}
+	if fc.after == AfterArchive {
+		config.ArchiveURL = archiveURL
+	}
+	r.NoError(config.Preflight())
+
+	source, cancelSource, err := Start(ctx, config)
+	r.NoError(err)
+	defer cancelSource()
+
+	waitFor("SELECT count(*) FROM %s", rowCount)
+
+	// Update all rows while the consumer is running.
+	for i := range values {
+		values[i] = "updated"
+	}
+	emit(hlc.New(now+2, 0), values)
+	resolve(hlc.New(now+3, 0))
+	waitFor("SELECT count(*) FROM %s WHERE v = 'updated'", rowCount)
+
+	// Delete some rows.
+	deletes := make(map[int]string, rowCount/2)
+	for i := 0; i < rowCount/2; i++ {
+		deletes[i] = ""
+	}
+	emit(hlc.New(now+4, 0), deletes)
+	resolve(hlc.New(now+5, 0))
+	waitFor("SELECT count(*) FROM %s", rowCount/2)
+
+	// Write a file whose name sorts before one that has already been
+	// processed. It must still be found.
+	processed := testutil.ToFloat64(fileCount)
+	emit(hlc.New(now+7, 0), map[int]string{rowCount: "early"})
+	for testutil.ToFloat64(fileCount) == processed {
+		select {
+		case <-ctx.Done():
+			r.NoError(ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	emit(hlc.New(now+6, 0), map[int]string{rowCount + 1: "late"})
+	resolve(hlc.New(now+8, 0))
+	waitFor("SELECT count(*) FROM %s WHERE v IN ('early', 'late')", 2)
+
+	// Wait for the checkpoint to reach the last file.
+	last := written[len(written)-1]
+	key := (&poller{name: sanitizeURL(bucketURL)}).memoKey()
+	for {
+		cp, err := fixture.Memo.Get(ctx, fixture.StagingPool, key)
+		r.NoError(err)
+		if string(cp) >= last {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			r.NoError(ctx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// Only the unrelated file should remain if the changefeed files
+	// are being cleaned up.
+	remaining, err := bucket.List(ctx, "", 0)
+	r.NoError(err)
+	switch fc.after {
+	case AfterArchive:
+		archive, err := objstore.Open(archiveURL)
+		r.NoError(err)
+		archived, err := archive.List(ctx, "", 0)
+		r.NoError(err)
+		sort.Strings(written)
+		r.Equal(written, archived)
+		r.Equal([]string{"README.txt"}, remaining)
+	case AfterDelete:
+		r.Equal([]string{"README.txt"}, remaining)
+	default:
+		r.Len(remaining, len(written)+1)
+	}
+
+	sinktest.CheckDiagnostics(ctx, t, source.Diagnostics)
+
+	cancelSource()
+	select {
+	case <-ctx.Done():
+		r.Fail("cancelSource timed out")
+	case <-source.Stopped():
+		// OK
+	}
+}
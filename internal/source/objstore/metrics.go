@@ -0,0 +1,37 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package objstore
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	fileCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "objstore_files_total",
+		Help: "the number of changefeed files that have been processed",
+	})
+	fileErrorCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "objstore_file_errors_total",
+		Help: "the number of changefeed files that could not be processed",
+	})
+	skippedCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "objstore_skipped_total",
+		Help: "the number of times a file not written by a changefeed was skipped",
+	})
+)
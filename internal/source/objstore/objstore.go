@@ -0,0 +1,217 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package objstore contains support for consuming a CockroachDB
+// changefeed that writes to a cloud-storage sink. Rather than
+// receiving files via HTTP, the files are listed and retrieved from a
+// local directory or an S3-compatible bucket.
+package objstore
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/objstore"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Source polls an object store for changefeed files.
+type Source struct {
+	Diagnostics *diag.Diagnostics
+
+	stopped chan struct{}
+}
+
+var (
+	_ stdlogical.HasDiagnostics = (*Source)(nil)
+	_ stdlogical.HasStoppable   = (*Source)(nil)
+	_ types.Stoppable           = (*Source)(nil)
+)
+
+// GetDiagnostics implements [stdlogical.HasDiagnostics].
+func (s *Source) GetDiagnostics() *diag.Diagnostics {
+	return s.Diagnostics
+}
+
+// GetStoppable implements [stdlogical.HasStoppable].
+func (s *Source) GetStoppable() types.Stoppable {
+	return s
+}
+
+// Stopped implements [types.Stoppable]. The channel will be closed
+// once the Source has stopped polling the object store.
+func (s *Source) Stopped() <-chan struct{} {
+	return s.stopped
+}
+
+// poller processes the files in a bucket in lexicographic order, which
+// is approximately the order in which they were written by the
+// changefeed. A file may be written after another file whose name
+// sorts after it, but not after a resolved-timestamp file whose name
+// sorts after it. The name of the last resolved-timestamp file to have
+// been processed is recorded in the memo table so that polling can
+// resume after a restart.
+type poller struct {
+	archive objstore.Bucket // Nil unless cfg.After is AfterArchive.
+	bucket  objstore.Bucket
+	cfg     *Config
+	handler *cdc.Handler
+	memo    types.Memo
+	name    string // Identifies the bucket in the memo and leases.
+	pool    *types.StagingPool
+}
+
+// run is called once the poller has acquired its lease.
+func (p *poller) run(ctx context.Context) error {
+	after, err := p.memo.Get(ctx, p.pool, p.memoKey())
+	if err != nil {
+		return err
+	}
+	checkpoint := string(after)
+	log.WithFields(log.Fields{
+		"bucket":     p.name,
+		"checkpoint": checkpoint,
+	}).Info("polling for changefeed files")
+
+	// Processed files are removed from the bucket if they are deleted
+	// or archived, so the bucket can be listed from the start to find
+	// any files that arrived late.
+	if p.cfg.After != AfterKeep {
+		checkpoint = ""
+	}
+
+	// Each polling cycle restarts from the checkpoint, so that files
+	// which arrive out of order are found. The names of files that have
+	// been processed or skipped since the checkpoint are retained, so
+	// that they are not retrieved again.
+	seen := make(map[string]struct{})
+	cursor := checkpoint
+	for {
+		names, err := p.bucket.List(ctx, cursor, p.cfg.ListLimit)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			select {
+			case <-time.After(p.cfg.PollInterval):
+				cursor = checkpoint
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		for _, name := range names {
+			cursor = name
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			processed, err := p.process(ctx, name)
+			if err != nil {
+				fileErrorCount.Inc()
+				return errors.Wrapf(err, "could not process %s", name)
+			}
+			if !processed || p.cfg.After == AfterKeep {
+				seen[name] = struct{}{}
+			}
+			if !processed || !strings.HasSuffix(name, resolvedSuffix) {
+				continue
+			}
+			if err := p.memo.Put(ctx, p.pool, p.memoKey(), []byte(name)); err != nil {
+				return err
+			}
+			if p.cfg.After != AfterKeep {
+				continue
+			}
+			// The changefeed will not write any more files whose names
+			// sort before a resolved-timestamp file.
+			checkpoint = name
+			for prev := range seen {
+				if prev <= checkpoint {
+					delete(seen, prev)
+				}
+			}
+		}
+	}
+}
+
+// process stages the contents of the named file and then deletes or
+// archives it. Files that were not written by a changefeed are
+// skipped, but are left in place. If the file is processed, but cannot
+// be archived, it will be processed again; this is safe, since staging
+// is idempotent.
+func (p *poller) process(ctx context.Context, name string) (processed bool, _ error) {
+	data, err := p.bucket.Get(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	err = p.handler.ServeFile(ctx, p.cfg.TargetSchema, name, data)
+	_ = data.Close()
+	if errors.Is(err, cdc.ErrUnknownFile) {
+		log.WithField("file", name).Debug("skipping file")
+		skippedCount.Inc()
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	fileCount.Inc()
+	log.WithField("file", name).Trace("processed file")
+
+	switch p.cfg.After {
+	case AfterArchive:
+		data, err := p.bucket.Get(ctx, name)
+		if err != nil {
+			return false, err
+		}
+		err = p.archive.Put(ctx, name, data)
+		_ = data.Close()
+		if err != nil {
+			return false, err
+		}
+		return true, p.bucket.Delete(ctx, name)
+	case AfterDelete:
+		return true, p.bucket.Delete(ctx, name)
+	default:
+		return true, nil
+	}
+}
+
+// resolvedSuffix identifies the resolved-timestamp files written by a
+// changefeed.
+const resolvedSuffix = ".RESOLVED"
+
+// memoKey returns the key used to record the last resolved-timestamp
+// file processed.
+func (p *poller) memoKey() string {
+	return "objstore.checkpoint." + p.name
+}
+
+// sanitizeURL removes any credentials from the bucket URL.
+func sanitizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
+}
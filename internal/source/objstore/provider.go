@@ -0,0 +1,88 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package objstore
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/staging/auth/trust"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/objstore"
+	"github.com/google/wire"
+)
+
+// Set is used by Wire.
+var Set = wire.NewSet(
+	ProvideAuthenticator,
+	ProvideSource,
+)
+
+// ProvideAuthenticator is called by Wire. Files are read from the
+// object store, rather than being sent to cdc-sink, so no access
+// checks are performed.
+func ProvideAuthenticator() types.Authenticator {
+	return trust.New()
+}
+
+// ProvideSource is called by Wire to start polling the object store.
+// There's a fake dependency on the script loader so that flags can be
+// evaluated first.
+func ProvideSource(
+	ctx context.Context,
+	cfg *Config,
+	diags *diag.Diagnostics,
+	handler *cdc.Handler,
+	leases types.Leases,
+	memo types.Memo,
+	pool *types.StagingPool,
+	_ *script.Loader,
+) (*Source, func(), error) {
+	if err := cfg.Preflight(); err != nil {
+		return nil, nil, err
+	}
+	bucket, err := objstore.Open(cfg.BucketURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &poller{
+		bucket:  bucket,
+		cfg:     cfg,
+		handler: handler,
+		memo:    memo,
+		name:    sanitizeURL(cfg.BucketURL),
+		pool:    pool,
+	}
+	if cfg.After == AfterArchive {
+		if p.archive, err = objstore.Open(cfg.ArchiveURL); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	ret := &Source{
+		Diagnostics: diags,
+		stopped:     make(chan struct{}),
+	}
+	go func() {
+		defer close(ret.stopped)
+		leases.Singleton(ctx, "objstore."+p.name, p.run)
+	}()
+	return ret, cancel, nil
+}
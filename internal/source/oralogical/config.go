@@ -0,0 +1,89 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package oralogical
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+const defaultPollInterval = time.Second
+
+// Config adds dialect-specific configuration to the core logical loop.
+type Config struct {
+	logical.BaseConfig
+	logical.LoopConfig
+
+	// The SCN to begin reading from if no consistent point has been
+	// stored. If zero, the database's current SCN will be used.
+	DefaultSCN uint64
+	// How long to wait once all changes have been read.
+	PollInterval time.Duration
+	// Connection string for the source db.
+	SourceConn string
+	// The owner of the tables to replicate.
+	SourceSchema string
+	// If present, only the named tables will be replicated.
+	Tables []string
+}
+
+// Bind adds flags to the set. It delegates to the embedded Config.Bind.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	c.BaseConfig.Bind(f)
+
+	c.LoopConfig.LoopName = "oralogical"
+	c.LoopConfig.Bind(f)
+
+	f.Uint64Var(&c.DefaultSCN, "defaultSCN", 0,
+		"the SCN to start replicating from if no consistent point has been stored "+
+			"(default: the current SCN)")
+	f.DurationVar(&c.PollInterval, "pollInterval", defaultPollInterval,
+		"how long to wait before polling again once all changes have been read")
+	f.StringVar(&c.SourceConn, "sourceConn", "",
+		"the source database's connection string")
+	f.StringVar(&c.SourceSchema, "sourceSchema", "",
+		"the owner of the tables to replicate")
+	f.StringSliceVar(&c.Tables, "table", nil,
+		"a source table to replicate; may be repeated (default: all tables in sourceSchema)")
+}
+
+// Preflight adds additional checks to the base logical.Config.
+func (c *Config) Preflight() error {
+	if err := c.BaseConfig.Preflight(); err != nil {
+		return err
+	}
+	if err := c.LoopConfig.Preflight(); err != nil {
+		return err
+	}
+
+	if c.PollInterval == 0 {
+		c.PollInterval = defaultPollInterval
+	}
+	if c.PollInterval < 0 {
+		return errors.New("pollInterval must be positive")
+	}
+	if c.SourceConn == "" {
+		return errors.New("sourceConn must be set")
+	}
+	if c.SourceSchema == "" {
+		return errors.New("sourceSchema must be set")
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package oralogical
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+)
+
+// consistentPoint identifies the last source transaction that has been
+// applied. Oracle does not order transactions that commit at the same
+// SCN, so the ids of the applied transactions with the commit SCN are
+// also recorded.
+type consistentPoint struct {
+	// The commit SCN of the last applied transaction.
+	Commit uint64 `json:"c"`
+	// The transactions committed at the Commit SCN that have been
+	// applied, as hex-encoded XID values.
+	XIDs []string `json:"x,omitempty"`
+	// The SCN at which LogMiner must start reading to see every change
+	// made by a transaction that was still open at the Commit SCN.
+	Restart uint64 `json:"r"`
+	// The commit time of the last applied transaction.
+	TS time.Time `json:"ts"`
+}
+
+var (
+	_ logical.OffsetStamp = (*consistentPoint)(nil)
+	_ logical.TimeStamp   = (*consistentPoint)(nil)
+)
+
+// AsOffset implements logical.OffsetStamp.
+func (c *consistentPoint) AsOffset() uint64 {
+	return c.Commit
+}
+
+// AsTime implements logical.TimeStamp.
+func (c *consistentPoint) AsTime() time.Time {
+	return c.TS
+}
+
+// Contains returns true if the transaction committed at the SCN has
+// already been applied.
+func (c *consistentPoint) Contains(commit uint64, xid string) bool {
+	if commit != c.Commit {
+		return commit < c.Commit
+	}
+	for _, applied := range c.XIDs {
+		if applied == xid {
+			return true
+		}
+	}
+	return false
+}
+
+// IsZero returns true if no SCN has been recorded.
+func (c *consistentPoint) IsZero() bool {
+	return c.Commit == 0 && c.Restart == 0
+}
+
+// Less implements stamp.Stamp.
+func (c *consistentPoint) Less(other stamp.Stamp) bool {
+	o := other.(*consistentPoint)
+	if c.Commit != o.Commit {
+		return c.Commit < o.Commit
+	}
+	if len(c.XIDs) != len(o.XIDs) {
+		return len(c.XIDs) < len(o.XIDs)
+	}
+	return c.Restart < o.Restart
+}
+
+// String is for debugging use only.
+func (c *consistentPoint) String() string {
+	return fmt.Sprintf("%d:%d:%d", c.Commit, len(c.XIDs), c.Restart)
+}
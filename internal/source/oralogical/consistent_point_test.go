@@ -0,0 +1,65 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package oralogical
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistentPoint(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	zero := &consistentPoint{}
+	one := &consistentPoint{Commit: 100, XIDs: []string{"0A"}, Restart: 90}
+	oneLater := &consistentPoint{Commit: 100, XIDs: []string{"0A", "0B"}, Restart: 90}
+	two := &consistentPoint{Commit: 200, XIDs: []string{"0C"}, Restart: 200}
+	twoRestart := &consistentPoint{Commit: 200, XIDs: []string{"0C"}, Restart: 250}
+
+	a.True(zero.IsZero())
+	a.False(one.IsZero())
+	a.True(zero.Less(one))
+	a.False(one.Less(zero))
+	a.True(one.Less(oneLater))
+	a.False(oneLater.Less(one))
+	a.True(oneLater.Less(two))
+	a.True(two.Less(twoRestart))
+	a.False(two.Less(two))
+
+	a.True(oneLater.Contains(99, "FF"))
+	a.True(oneLater.Contains(100, "0A"))
+	a.True(oneLater.Contains(100, "0B"))
+	a.False(oneLater.Contains(100, "0C"))
+	a.False(oneLater.Contains(101, "0A"))
+	a.False(zero.Contains(1, "0A"))
+
+	a.Equal(uint64(200), two.AsOffset())
+
+	// Ensure that the stamp survives being persisted.
+	oneLater.TS = time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	data, err := json.Marshal(oneLater)
+	r.NoError(err)
+	decoded := &consistentPoint{}
+	r.NoError(json.Unmarshal(data, decoded))
+	a.Equal(oneLater, decoded)
+	a.Equal(oneLater.TS, decoded.AsTime())
+}
@@ -0,0 +1,429 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package oralogical contains a logical-replication loop for Oracle
+// databases. Changes are read from the redo log using LogMiner.
+package oralogical
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// OraLogical is an Oracle LogMiner replication loop.
+type OraLogical struct {
+	Diagnostics *diag.Diagnostics
+	Loop        *logical.Loop
+}
+
+var (
+	_ stdlogical.HasDiagnostics = (*OraLogical)(nil)
+	_ stdlogical.HasStoppable   = (*OraLogical)(nil)
+)
+
+// GetDiagnostics implements [stdlogical.HasDiagnostics].
+func (l *OraLogical) GetDiagnostics() *diag.Diagnostics {
+	return l.Diagnostics
+}
+
+// GetStoppable implements [stdlogical.HasStoppable].
+func (l *OraLogical) GetStoppable() types.Stoppable {
+	return l.Loop
+}
+
+// Dialect reads the Oracle redo log.
+//
+// The row images are reconstructed from the SQL_REDO column, so the
+// source tables must have supplemental logging of all columns enabled.
+// Otherwise, updates would only contain the columns that were changed,
+// and the primary key columns may not be present at all. This is
+// checked before any changes to a table are replicated.
+type Dialect struct {
+	defaultSCN   uint64               // Used if there is no consistent point.
+	pollInterval time.Duration        // Delay once all changes are read.
+	source       *types.SourcePool    // Access to the source database.
+	sourceSchema ident.Schema         // The owner of the source tables.
+	tables       *ident.Map[struct{}] // Optional table names.
+	targetSchema ident.Schema         // Tables are named after the source.
+	watchers     types.Watchers       // Target schema access.
+}
+
+var (
+	_ diag.Diagnostic = (*Dialect)(nil)
+	_ logical.Dialect = (*Dialect)(nil)
+)
+
+// A txBatch is the sole message type sent by ReadInto. It contains the
+// changes made by a single source transaction and the consistent point
+// to record once they have been applied. A txBatch with no data is
+// used to advance past transactions that did not affect any replicated
+// table.
+type txBatch struct {
+	cp   *consistentPoint
+	data []logical.TableData
+}
+
+// Session settings that determine the format of values in SQL_REDO.
+var sessionSettings = []string{
+	"ALTER SESSION SET NLS_DATE_FORMAT = 'YYYY-MM-DD HH24:MI:SS'",
+	"ALTER SESSION SET NLS_NUMERIC_CHARACTERS = '.,'",
+	"ALTER SESSION SET NLS_TIMESTAMP_FORMAT = 'YYYY-MM-DD HH24:MI:SS.FF9'",
+	"ALTER SESSION SET NLS_TIMESTAMP_TZ_FORMAT = 'YYYY-MM-DD HH24:MI:SS.FF9 TZH:TZM'",
+}
+
+// Diagnostic implements [diag.Diagnostic].
+func (d *Dialect) Diagnostic(_ context.Context) any {
+	type Payload struct {
+		PollInterval time.Duration
+		SourceSchema ident.Schema
+		Tables       []string
+	}
+	ret := &Payload{
+		PollInterval: d.pollInterval,
+		SourceSchema: d.sourceSchema,
+	}
+	_ = d.tables.Range(func(tbl ident.Ident, _ struct{}) error {
+		ret.Tables = append(ret.Tables, tbl.Raw())
+		return nil
+	})
+	return ret
+}
+
+// ReadInto implements logical.Dialect. Each poll starts a LogMiner
+// session that reads from the consistent point's restart SCN up to the
+// database's current SCN. Transactions are sent as they are committed.
+// Once the session has been read, ReadInto waits for the polling
+// interval before starting another.
+func (d *Dialect) ReadInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	initial, _ := state.GetConsistentPoint()
+	cp := initial.(*consistentPoint)
+	if cp.IsZero() {
+		start := d.defaultSCN
+		if start == 0 {
+			var err error
+			if start, err = d.currentSCN(ctx); err != nil {
+				return err
+			}
+		}
+		cp = &consistentPoint{Commit: start, Restart: start}
+		log.Infof("starting replication at SCN %d", start)
+	}
+
+	reader := newTxReader(cp)
+	for {
+		if err := d.poll(ctx, ch, reader); err != nil {
+			return err
+		}
+
+		select {
+		case <-time.After(d.pollInterval):
+		case <-state.Stopping():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Process implements logical.Dialect.
+func (d *Dialect) Process(
+	ctx context.Context, ch <-chan logical.Message, events logical.Events,
+) error {
+	for msg := range ch {
+		// Each message is a complete transaction, so there is no state
+		// to discard if ReadInto is restarted.
+		if logical.IsRollback(msg) {
+			continue
+		}
+		b, ok := msg.(*txBatch)
+		if !ok {
+			panic(fmt.Sprintf("unimplemented type %T", msg))
+		}
+		if err := logical.ApplyBatch(ctx, events, b.cp, b.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ZeroStamp implements logical.Dialect.
+func (d *Dialect) ZeroStamp() stamp.Stamp {
+	return &consistentPoint{}
+}
+
+// currentSCN returns the database's current SCN.
+func (d *Dialect) currentSCN(ctx context.Context) (uint64, error) {
+	var ret int64
+	if err := d.source.QueryRowContext(ctx,
+		"SELECT CURRENT_SCN FROM V$DATABASE").Scan(&ret); err != nil {
+		return 0, errors.Wrap(err, "could not query current SCN")
+	}
+	return uint64(ret), nil
+}
+
+// lookupFn returns a function that finds the table to replicate for a
+// source table name, using a snapshot of the target schema. The
+// function returns nil if the table is not to be replicated.
+func (d *Dialect) lookupFn(ctx context.Context) (func(string) (*sourceTable, error), error) {
+	watcher, err := d.watchers.Get(ctx, d.targetSchema)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := watcher.Get()
+	var allLogged string
+	if err := d.source.QueryRowContext(ctx,
+		"SELECT SUPPLEMENTAL_LOG_DATA_ALL FROM V$DATABASE").Scan(&allLogged); err != nil {
+		return nil, errors.Wrap(err, "could not query supplemental logging")
+	}
+	found := make(map[string]*sourceTable)
+	return func(name string) (*sourceTable, error) {
+		if tbl, ok := found[name]; ok {
+			return tbl, nil
+		}
+		if d.tables.Len() > 0 {
+			if _, ok := d.tables.Get(ident.New(name)); !ok {
+				found[name] = nil
+				return nil, nil
+			}
+		}
+		tbl := &sourceTable{
+			source: ident.NewTable(d.sourceSchema, ident.New(name)),
+			target: ident.NewTable(d.targetSchema, ident.New(name)),
+		}
+		targetCols, ok := snapshot.Columns.Get(tbl.target)
+		if !ok {
+			return nil, errors.Errorf("target table %s not found", tbl.target)
+		}
+		for _, col := range targetCols {
+			if col.Primary {
+				tbl.keys = append(tbl.keys, col.Name)
+			}
+		}
+		if len(tbl.keys) == 0 {
+			return nil, errors.Errorf("target table %s has no primary key", tbl.target)
+		}
+		if allLogged != "YES" {
+			if err := d.checkLogging(ctx, name); err != nil {
+				return nil, err
+			}
+		}
+		found[name] = tbl
+		return tbl, nil
+	}, nil
+}
+
+// checkLogging returns an error if the source table does not have
+// supplemental logging of all columns.
+func (d *Dialect) checkLogging(ctx context.Context, table string) error {
+	var count int
+	if err := d.source.QueryRowContext(ctx, `
+SELECT COUNT(*) FROM ALL_LOG_GROUPS
+WHERE OWNER = :1 AND TABLE_NAME = :2 AND LOG_GROUP_TYPE = 'ALL COLUMN LOGGING'`,
+		d.sourceSchema.Raw(), table,
+	).Scan(&count); err != nil {
+		return errors.Wrap(err, "could not query supplemental logging")
+	}
+	if count == 0 {
+		source := ident.NewTable(d.sourceSchema, ident.New(table))
+		return errors.Errorf(
+			"source table %[1]s does not have supplemental logging of all columns; "+
+				"run ALTER TABLE %[1]s ADD SUPPLEMENTAL LOG DATA (ALL) COLUMNS", source)
+	}
+	return nil
+}
+
+// poll reads the redo log from the restart SCN of the reader's
+// consistent point up to the database's current SCN. A message is
+// sent for each transaction that commits within that range.
+func (d *Dialect) poll(ctx context.Context, ch chan<- logical.Message, r *txReader) error {
+	end, err := d.currentSCN(ctx)
+	if err != nil {
+		return err
+	}
+	start := r.cp.Restart
+	if end <= start {
+		return nil
+	}
+	lookup, err := d.lookupFn(ctx)
+	if err != nil {
+		return err
+	}
+	r.reset(lookup)
+
+	// A LogMiner session belongs to a single database session.
+	conn, err := d.source.Conn(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer conn.Close()
+	if err := startMiner(ctx, conn, start, end); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(),
+			"BEGIN DBMS_LOGMNR.END_LOGMNR; END;"); err != nil {
+			log.WithError(err).Warn("could not end LogMiner session")
+		}
+	}()
+
+	// Transaction control records are needed for every transaction,
+	// since a transaction may have changed rows in other schemas.
+	const q = `
+SELECT SCN, RAWTOHEX(XID), OPERATION_CODE, TIMESTAMP, TABLE_NAME, SQL_REDO, CSF
+FROM V$LOGMNR_CONTENTS
+WHERE OPERATION_CODE IN (6, 7, 36)
+   OR (OPERATION_CODE IN (1, 2, 3) AND SEG_OWNER = :1)`
+	rows, err := conn.QueryContext(ctx, q, d.sourceSchema.Raw())
+	if err != nil {
+		return errors.Wrap(err, q)
+	}
+	defer rows.Close()
+
+	send := func(b *txBatch) error {
+		select {
+		case ch <- b:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var count int
+	var redo strings.Builder
+	for rows.Next() {
+		var csf int
+		var scn int64
+		var redoPart, table sql.NullString
+		row := &logRow{}
+		if err := rows.Scan(&scn, &row.xid, &row.op, &row.ts, &table, &redoPart, &csf); err != nil {
+			return errors.WithStack(err)
+		}
+		// Long statements are split across rows, with the
+		// continuation flag set on all but the last.
+		redo.WriteString(redoPart.String)
+		if csf == 1 {
+			continue
+		}
+		row.redo = redo.String()
+		redo.Reset()
+		row.scn = uint64(scn)
+		row.table = table.String
+
+		b, err := r.add(row)
+		if err != nil {
+			return errors.Wrapf(err, "SCN %d", row.scn)
+		}
+		if b != nil {
+			count++
+			if err := send(b); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	if b := r.flush(end); b != nil {
+		if err := send(b); err != nil {
+			return err
+		}
+	}
+	log.Tracef("read %d transactions between SCN %d and %d", count, start, end)
+	return nil
+}
+
+// startMiner adds the redo log files that contain the start SCN and
+// any later changes to a LogMiner session and then starts it. The
+// online catalog is used as the dictionary, so the tables must not
+// have been altered since the start SCN.
+func startMiner(ctx context.Context, conn *sql.Conn, start, end uint64) error {
+	for _, stmt := range sessionSettings {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrap(err, stmt)
+		}
+	}
+
+	// Prefer the online logs, since archived copies of them may not
+	// have been written yet.
+	const files = `
+SELECT MIN(f.MEMBER), l.THREAD#, l.SEQUENCE#
+FROM V$LOG l JOIN V$LOGFILE f ON f.GROUP# = l.GROUP#
+WHERE l.STATUS <> 'UNUSED' AND l.NEXT_CHANGE# > :1
+GROUP BY l.THREAD#, l.SEQUENCE#
+UNION ALL
+SELECT MIN(a.NAME), a.THREAD#, a.SEQUENCE#
+FROM V$ARCHIVED_LOG a
+WHERE a.NAME IS NOT NULL AND a.DELETED = 'NO' AND a.STANDBY_DEST = 'NO'
+  AND a.NEXT_CHANGE# > :2
+  AND (a.THREAD#, a.SEQUENCE#) NOT IN (
+    SELECT THREAD#, SEQUENCE# FROM V$LOG WHERE STATUS <> 'UNUSED')
+GROUP BY a.THREAD#, a.SEQUENCE#
+ORDER BY 2, 3`
+	rows, err := conn.QueryContext(ctx, files, int64(start), int64(start))
+	if err != nil {
+		return errors.Wrap(err, files)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		var thread, seq int64
+		if err := rows.Scan(&name, &thread, &seq); err != nil {
+			return errors.WithStack(err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(names) == 0 {
+		return errors.Errorf("no redo log files contain SCN %d", start)
+	}
+
+	for idx, name := range names {
+		opt := "DBMS_LOGMNR.ADDFILE"
+		if idx == 0 {
+			opt = "DBMS_LOGMNR.NEW"
+		}
+		q := fmt.Sprintf("BEGIN DBMS_LOGMNR.ADD_LOGFILE(LOGFILENAME => :1, OPTIONS => %s); END;", opt)
+		if _, err := conn.ExecContext(ctx, q, name); err != nil {
+			return errors.Wrapf(err, "could not add log file %s", name)
+		}
+	}
+
+	const q = `
+BEGIN DBMS_LOGMNR.START_LOGMNR(STARTSCN => :1, ENDSCN => :2,
+  OPTIONS => DBMS_LOGMNR.DICT_FROM_ONLINE_CATALOG + DBMS_LOGMNR.NO_ROWID_IN_STMT);
+END;`
+	if _, err := conn.ExecContext(ctx, q, int64(start), int64(end)); err != nil {
+		return errors.Wrap(err, "could not start LogMiner; is the LOGMINING privilege granted?")
+	}
+	return nil
+}
@@ -0,0 +1,609 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package oralogical
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// A redoStatement is the parsed SQL_REDO value of a DML operation.
+type redoStatement struct {
+	// Values from the column list of an insert or the SET clause of an
+	// update.
+	after map[string]any
+	// Values from the WHERE clause of an update or delete. These are
+	// the row's key columns and any other columns that were included by
+	// supplemental logging.
+	before map[string]any
+	// One of opInsert, opUpdate, or opDelete.
+	op int
+	// The unquoted name of the table.
+	table string
+}
+
+// parseRedo parses an INSERT, UPDATE, or DELETE statement generated by
+// LogMiner. Values are returned as strings, except for numeric values,
+// which are returned as a json.Number, and NULL. The statements are
+// expected to have been generated in a session that uses ISO-style
+// date and timestamp formats.
+//
+// The before image of an update or delete contains only the columns
+// that were written to the redo log. It will only contain every column
+// if supplemental logging of all columns has been enabled for the
+// table. LOB values are not written to the redo in a form that can be
+// parsed, so they appear as EMPTY_CLOB() or EMPTY_BLOB().
+func parseRedo(sql string) (*redoStatement, error) {
+	p := &redoParser{lex: redoLexer{src: sql}}
+	ret, err := p.statement()
+	return ret, errors.Wrapf(err, "could not parse redo %q", sql)
+}
+
+type redoParser struct {
+	lex  redoLexer
+	peek *redoToken
+}
+
+func (p *redoParser) statement() (*redoStatement, error) {
+	kw, err := p.word()
+	if err != nil {
+		return nil, err
+	}
+	ret := &redoStatement{}
+	switch kw {
+	case "INSERT":
+		ret.op = opInsert
+		if err := p.expectWord("INTO"); err != nil {
+			return nil, err
+		}
+		if ret.table, err = p.table(); err != nil {
+			return nil, err
+		}
+		if ret.after, err = p.insertValues(); err != nil {
+			return nil, err
+		}
+
+	case "UPDATE":
+		ret.op = opUpdate
+		if ret.table, err = p.table(); err != nil {
+			return nil, err
+		}
+		if err := p.expectWord("SET"); err != nil {
+			return nil, err
+		}
+		if ret.after, err = p.assignments(); err != nil {
+			return nil, err
+		}
+		if ret.before, err = p.where(); err != nil {
+			return nil, err
+		}
+
+	case "DELETE":
+		ret.op = opDelete
+		if err := p.expectWord("FROM"); err != nil {
+			return nil, err
+		}
+		if ret.table, err = p.table(); err != nil {
+			return nil, err
+		}
+		if ret.before, err = p.where(); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, errors.Errorf("unsupported statement %s", kw)
+	}
+
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if tok.isPunct(";") {
+		if tok, err = p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if tok.kind != tokEOF {
+		return nil, errors.Errorf("unexpected %q at end of statement", tok.text)
+	}
+	return ret, nil
+}
+
+// assignments parses the SET clause of an UPDATE statement, up to and
+// including the WHERE keyword, if present.
+func (p *redoParser) assignments() (map[string]any, error) {
+	ret := make(map[string]any)
+	for {
+		col, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct("="); err != nil {
+			return nil, err
+		}
+		if ret[col], err = p.value(); err != nil {
+			return nil, err
+		}
+		tok, err := p.peekToken()
+		if err != nil {
+			return nil, err
+		}
+		if !tok.isPunct(",") {
+			return ret, nil
+		}
+		p.peek = nil
+	}
+}
+
+// ident returns the next token, which must be a quoted identifier.
+func (p *redoParser) ident() (string, error) {
+	tok, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if tok.kind != tokIdent {
+		return "", errors.Errorf("expecting identifier, got %q", tok.text)
+	}
+	return tok.text, nil
+}
+
+// insertValues parses the column and value lists of an INSERT.
+func (p *redoParser) insertValues() (map[string]any, error) {
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	var cols []string
+	for {
+		col, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, col)
+		tok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.isPunct(")") {
+			break
+		}
+		if !tok.isPunct(",") {
+			return nil, errors.Errorf("expecting , or ), got %q", tok.text)
+		}
+	}
+	if err := p.expectWord("VALUES"); err != nil {
+		return nil, err
+	}
+	vals, err := p.arguments()
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) != len(cols) {
+		return nil, errors.Errorf("statement has %d columns, but %d values", len(cols), len(vals))
+	}
+	ret := make(map[string]any, len(cols))
+	for idx, col := range cols {
+		ret[col] = vals[idx]
+	}
+	return ret, nil
+}
+
+// arguments parses a parenthesized list of values.
+func (p *redoParser) arguments() ([]any, error) {
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+	tok, err := p.peekToken()
+	if err != nil {
+		return nil, err
+	}
+	if tok.isPunct(")") {
+		p.peek = nil
+		return nil, nil
+	}
+	var ret []any
+	for {
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, val)
+		tok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.isPunct(")") {
+			return ret, nil
+		}
+		if !tok.isPunct(",") {
+			return nil, errors.Errorf("expecting , or ), got %q", tok.text)
+		}
+	}
+}
+
+// table parses a possibly-qualified table name, returning the
+// unqualified name.
+func (p *redoParser) table() (string, error) {
+	name, err := p.ident()
+	if err != nil {
+		return "", err
+	}
+	tok, err := p.peekToken()
+	if err != nil {
+		return "", err
+	}
+	if tok.isPunct(".") {
+		p.peek = nil
+		return p.ident()
+	}
+	return name, nil
+}
+
+// value parses a literal or a call to one of the conversion functions
+// that LogMiner uses to represent typed values.
+func (p *redoParser) value() (any, error) {
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch tok.kind {
+	case tokNumber:
+		return json.Number(tok.text), nil
+	case tokString:
+		return tok.text, nil
+	case tokWord:
+		if tok.text == "NULL" {
+			return nil, nil
+		}
+		args, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		return redoFunction(tok.text, args)
+	default:
+		return nil, errors.Errorf("expecting value, got %q", tok.text)
+	}
+}
+
+// where parses the conditions of a WHERE clause. Comparisons with the
+// ROWID pseudo-column are ignored.
+func (p *redoParser) where() (map[string]any, error) {
+	ret := make(map[string]any)
+	tok, err := p.peekToken()
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind != tokWord || tok.text != "WHERE" {
+		return ret, nil
+	}
+	p.peek = nil
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case tok.kind == tokWord && tok.text == "ROWID":
+			if err := p.expectPunct("="); err != nil {
+				return nil, err
+			}
+			if _, err := p.value(); err != nil {
+				return nil, err
+			}
+
+		case tok.kind == tokIdent:
+			col := tok.text
+			next, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if next.isPunct("=") {
+				if ret[col], err = p.value(); err != nil {
+					return nil, err
+				}
+			} else if next.kind == tokWord && next.text == "IS" {
+				if err := p.expectWord("NULL"); err != nil {
+					return nil, err
+				}
+				ret[col] = nil
+			} else {
+				return nil, errors.Errorf("unsupported condition on %s: %q", col, next.text)
+			}
+
+		default:
+			return nil, errors.Errorf("expecting condition, got %q", tok.text)
+		}
+
+		next, err := p.peekToken()
+		if err != nil {
+			return nil, err
+		}
+		if next.kind != tokWord || next.text != "AND" {
+			return ret, nil
+		}
+		p.peek = nil
+	}
+}
+
+// word returns the next token, which must be a keyword.
+func (p *redoParser) word() (string, error) {
+	tok, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if tok.kind != tokWord {
+		return "", errors.Errorf("expecting keyword, got %q", tok.text)
+	}
+	return tok.text, nil
+}
+
+func (p *redoParser) expectPunct(punct string) error {
+	tok, err := p.next()
+	if err != nil {
+		return err
+	}
+	if !tok.isPunct(punct) {
+		return errors.Errorf("expecting %s, got %q", punct, tok.text)
+	}
+	return nil
+}
+
+func (p *redoParser) expectWord(kw string) error {
+	found, err := p.word()
+	if err != nil {
+		return err
+	}
+	if found != kw {
+		return errors.Errorf("expecting %s, got %s", kw, found)
+	}
+	return nil
+}
+
+func (p *redoParser) next() (*redoToken, error) {
+	if tok := p.peek; tok != nil {
+		p.peek = nil
+		return tok, nil
+	}
+	return p.lex.next()
+}
+
+func (p *redoParser) peekToken() (*redoToken, error) {
+	if p.peek == nil {
+		tok, err := p.lex.next()
+		if err != nil {
+			return nil, err
+		}
+		p.peek = tok
+	}
+	return p.peek, nil
+}
+
+// redoFunction evaluates the functions that LogMiner uses to represent
+// values that do not have a literal syntax. Date, time, and interval
+// values are returned in the string form that was used to construct
+// them.
+func redoFunction(name string, args []any) (any, error) {
+	switch name {
+	case "EMPTY_BLOB", "EMPTY_CLOB":
+		return "", nil
+	case "HEXTORAW":
+		s, err := stringArg(name, args)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := hex.DecodeString(s); err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		return `\x` + strings.ToLower(s), nil
+	case "TO_BINARY_DOUBLE", "TO_BINARY_FLOAT", "TO_NUMBER":
+		s, err := stringArg(name, args)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			// Special values, such as Inf or Nan.
+			return s, nil
+		}
+		return json.Number(s), nil
+	case "TO_DATE", "TO_DSINTERVAL", "TO_TIMESTAMP", "TO_TIMESTAMP_TZ", "TO_YMINTERVAL":
+		return stringArg(name, args)
+	case "UNISTR":
+		s, err := stringArg(name, args)
+		if err != nil {
+			return nil, err
+		}
+		return unistr(s)
+	default:
+		return nil, errors.Errorf("unsupported function %s", name)
+	}
+}
+
+// stringArg returns the first argument to a function, which is
+// expected to be a string literal.
+func stringArg(name string, args []any) (string, error) {
+	if len(args) == 0 {
+		return "", errors.Errorf("%s requires an argument", name)
+	}
+	switch t := args[0].(type) {
+	case string:
+		return t, nil
+	case json.Number:
+		return t.String(), nil
+	default:
+		return "", errors.Errorf("%s: unexpected argument %v", name, args[0])
+	}
+}
+
+// unistr decodes the \XXXX escapes used by the UNISTR function. The
+// escapes are UTF-16 code units, so surrogate pairs must be combined.
+func unistr(s string) (string, error) {
+	var units []uint16
+	var sb strings.Builder
+	flush := func() {
+		sb.WriteString(string(utf16.Decode(units)))
+		units = units[:0]
+	}
+	for idx := 0; idx < len(s); idx++ {
+		if s[idx] != '\\' {
+			flush()
+			sb.WriteByte(s[idx])
+			continue
+		}
+		if idx+1 < len(s) && s[idx+1] == '\\' {
+			flush()
+			sb.WriteByte('\\')
+			idx++
+			continue
+		}
+		if idx+5 > len(s) {
+			return "", errors.Errorf("truncated UNISTR escape in %q", s)
+		}
+		unit, err := strconv.ParseUint(s[idx+1:idx+5], 16, 16)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid UNISTR escape in %q", s)
+		}
+		units = append(units, uint16(unit))
+		idx += 4
+	}
+	flush()
+	return sb.String(), nil
+}
+
+type redoTokenKind int
+
+const (
+	tokEOF    redoTokenKind = iota
+	tokIdent                // A double-quoted identifier.
+	tokNumber               // An unquoted numeric literal.
+	tokPunct                // One of ( ) , . = or ;.
+	tokString               // A single-quoted string literal.
+	tokWord                 // An unquoted keyword or function name.
+)
+
+type redoToken struct {
+	kind redoTokenKind
+	text string // Unquoted text; keywords are upper-cased.
+}
+
+func (t *redoToken) isPunct(punct string) bool {
+	return t.kind == tokPunct && t.text == punct
+}
+
+type redoLexer struct {
+	pos int
+	src string
+}
+
+func (l *redoLexer) next() (*redoToken, error) {
+	for l.pos < len(l.src) && isRedoSpace(l.src[l.pos]) {
+		l.pos++
+	}
+	if l.pos >= len(l.src) {
+		return &redoToken{kind: tokEOF}, nil
+	}
+
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case c == '"' || c == '\'':
+		text, err := l.quoted(c)
+		if err != nil {
+			return nil, err
+		}
+		kind := tokString
+		if c == '"' {
+			kind = tokIdent
+		}
+		return &redoToken{kind: kind, text: text}, nil
+
+	case c == '-' || c == '+' || isRedoDigit(c) ||
+		(c == '.' && l.pos+1 < len(l.src) && isRedoDigit(l.src[l.pos+1])):
+		l.pos++
+		for l.pos < len(l.src) {
+			c := l.src[l.pos]
+			if isRedoDigit(c) || c == '.' || c == 'e' || c == 'E' ||
+				((c == '-' || c == '+') && (l.src[l.pos-1] == 'e' || l.src[l.pos-1] == 'E')) {
+				l.pos++
+				continue
+			}
+			break
+		}
+		text := strings.TrimPrefix(l.src[start:l.pos], "+")
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return nil, errors.Errorf("invalid number %q at offset %d", l.src[start:l.pos], start)
+		}
+		// Oracle omits the leading zero of fractional values.
+		if strings.HasPrefix(text, ".") {
+			text = "0" + text
+		} else if strings.HasPrefix(text, "-.") {
+			text = "-0" + text[1:]
+		}
+		return &redoToken{kind: tokNumber, text: text}, nil
+
+	case strings.IndexByte("(),.=;", c) >= 0:
+		l.pos++
+		return &redoToken{kind: tokPunct, text: string(c)}, nil
+
+	case isRedoWord(c):
+		for l.pos < len(l.src) && (isRedoWord(l.src[l.pos]) || isRedoDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return &redoToken{kind: tokWord, text: strings.ToUpper(l.src[start:l.pos])}, nil
+
+	default:
+		return nil, errors.Errorf("unexpected character %q at offset %d", c, start)
+	}
+}
+
+// quoted reads a quoted string, in which the quote character is
+// escaped by doubling it.
+func (l *redoLexer) quoted(quote byte) (string, error) {
+	start := l.pos
+	l.pos++
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		l.pos++
+		if c != quote {
+			sb.WriteByte(c)
+			continue
+		}
+		if l.pos < len(l.src) && l.src[l.pos] == quote {
+			sb.WriteByte(quote)
+			l.pos++
+			continue
+		}
+		return sb.String(), nil
+	}
+	return "", errors.Errorf("unterminated quote at offset %d", start)
+}
+
+func isRedoDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isRedoSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isRedoWord(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c == '_' || c == '$' || c == '#'
+}
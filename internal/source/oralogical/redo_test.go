@@ -0,0 +1,123 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package oralogical
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRedo(t *testing.T) {
+	tcs := []struct {
+		redo     string
+		expected *redoStatement
+		err      string
+	}{
+		{
+			redo: `insert into "APP"."ORDERS"("ID","NAME","QTY","PRICE","NOTE") ` +
+				`values ('1','it''s',-5,.25,NULL);`,
+			expected: &redoStatement{
+				after: map[string]any{
+					"ID":    "1",
+					"NAME":  "it's",
+					"QTY":   json.Number("-5"),
+					"PRICE": json.Number("0.25"),
+					"NOTE":  nil,
+				},
+				op:    opInsert,
+				table: "ORDERS",
+			},
+		},
+		{
+			redo: `insert into "APP"."Mixed Case"("When","Raw","Text","Blob","Dbl") values ` +
+				`(TO_TIMESTAMP('2023-10-01 12:00:00.500000000'),HEXTORAW('C0FFEE'),` +
+				`UNISTR('caf\00e9 \d83d\de00'),EMPTY_BLOB(),TO_BINARY_DOUBLE('1.5E+000'));`,
+			expected: &redoStatement{
+				after: map[string]any{
+					"When": "2023-10-01 12:00:00.500000000",
+					"Raw":  `\xc0ffee`,
+					"Text": "café 😀",
+					"Blob": "",
+					"Dbl":  json.Number("1.5E+000"),
+				},
+				op:    opInsert,
+				table: "Mixed Case",
+			},
+		},
+		{
+			redo: `update "APP"."ORDERS" set "NAME" = 'new', "QTY" = NULL ` +
+				`where "ID" = '1' and "NAME" = 'old' and "QTY" = 5 and "NOTE" IS NULL;`,
+			expected: &redoStatement{
+				after: map[string]any{"NAME": "new", "QTY": nil},
+				before: map[string]any{
+					"ID":   "1",
+					"NAME": "old",
+					"QTY":  json.Number("5"),
+					"NOTE": nil,
+				},
+				op:    opUpdate,
+				table: "ORDERS",
+			},
+		},
+		{
+			redo: `delete from "APP"."ORDERS" where "ID" = '1' and ` +
+				`"DUE" = TO_DATE('2023-10-01 00:00:00', 'YYYY-MM-DD HH24:MI:SS') and ROWID = 'AAAR3sAABAAAVRBAAA';`,
+			expected: &redoStatement{
+				before: map[string]any{"ID": "1", "DUE": "2023-10-01 00:00:00"},
+				op:     opDelete,
+				table:  "ORDERS",
+			},
+		},
+		{
+			redo: `delete from "APP"."ORDERS" where "ID" = '1' and "NAME" LIKE 'x';`,
+			err:  "unsupported condition",
+		},
+		{
+			redo: `insert into "APP"."ORDERS"("ID") values (SYSDATE());`,
+			err:  "unsupported function SYSDATE",
+		},
+		{
+			redo: `insert into "APP"."ORDERS"("ID","NAME") values ('1');`,
+			err:  "2 columns, but 1 values",
+		},
+		{
+			redo: `insert into "APP"."ORDERS"("ID") values ('1`,
+			err:  "unterminated quote",
+		},
+		{
+			redo: `Unsupported`,
+			err:  "unsupported statement",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.redo, func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
+			stmt, err := parseRedo(tc.redo)
+			if tc.err != "" {
+				a.ErrorContains(err, tc.err)
+				return
+			}
+			r.NoError(err)
+			a.Equal(tc.expected, stmt)
+		})
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package oralogical
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+)
+
+// The OPERATION_CODE values in V$LOGMNR_CONTENTS.
+const (
+	opInsert   = 1
+	opDelete   = 2
+	opUpdate   = 3
+	opStart    = 6
+	opCommit   = 7
+	opRollback = 36
+)
+
+// A logRow is a row read from V$LOGMNR_CONTENTS. Statements that span
+// multiple rows have already been reassembled.
+type logRow struct {
+	op    int
+	redo  string
+	scn   uint64
+	table string
+	ts    time.Time
+	xid   string
+}
+
+// A sourceTable is a table whose changes are being replicated.
+type sourceTable struct {
+	// The names of the target table's primary key columns.
+	keys   []ident.Ident
+	source ident.Table // The table in the source database.
+	target ident.Table // The table in the target database.
+}
+
+// A change is a mutation made by an open transaction.
+type change struct {
+	mut   types.Mutation
+	table *sourceTable
+}
+
+// A pendingTx accumulates the changes made by an open transaction.
+type pendingTx struct {
+	changes []change
+	start   uint64 // The SCN of the first row seen for the transaction.
+}
+
+// A txReader groups the rows read from LogMiner into transactions.
+// LogMiner returns the changes made by concurrent transactions in the
+// order in which they were written to the redo log, so changes are
+// buffered until their transaction commits.
+type txReader struct {
+	// The point to be recorded once the batches that have been returned
+	// are applied.
+	cp *consistentPoint
+	// Set when cp has been advanced by a transaction that had no
+	// changes to replicate.
+	dirty bool
+	// The time assigned to the mutations in the most recent batch.
+	lastTime hlc.Time
+	// Returns the table to replicate, or nil if it should be ignored.
+	lookup func(table string) (*sourceTable, error)
+	// Transactions that have not yet committed or rolled back.
+	open map[string]*pendingTx
+}
+
+// newTxReader constructs a txReader that will ignore transactions that
+// have been applied at the consistent point.
+func newTxReader(cp *consistentPoint) *txReader {
+	return &txReader{cp: cp, open: make(map[string]*pendingTx)}
+}
+
+// add processes a row. It returns a batch when the row commits a
+// transaction that made changes to be replicated and which has not
+// already been applied.
+func (r *txReader) add(row *logRow) (*txBatch, error) {
+	switch row.op {
+	case opStart:
+		r.pending(row)
+		return nil, nil
+
+	case opRollback:
+		delete(r.open, row.xid)
+		return nil, nil
+
+	case opCommit:
+		return r.commit(row)
+
+	case opInsert, opUpdate, opDelete:
+		tx := r.pending(row)
+		tbl, err := r.lookup(row.table)
+		if err != nil || tbl == nil {
+			return nil, err
+		}
+		stmt, err := parseRedo(row.redo)
+		if err != nil {
+			return nil, err
+		}
+		muts, err := tbl.mutations(stmt)
+		if err != nil {
+			return nil, err
+		}
+		for _, mut := range muts {
+			tx.changes = append(tx.changes, change{mut: mut, table: tbl})
+		}
+		return nil, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// flush is called once all rows up to the end SCN have been read. If
+// the consistent point can be advanced without applying any changes,
+// a batch without data is returned.
+func (r *txReader) flush(end uint64) *txBatch {
+	restart := r.restart(end)
+	if restart > r.cp.Restart {
+		next := *r.cp
+		next.Restart = restart
+		r.cp = &next
+		r.dirty = true
+	}
+	if !r.dirty {
+		return nil
+	}
+	r.dirty = false
+	return &txBatch{cp: r.cp}
+}
+
+// reset discards any open transactions. It is called before LogMiner
+// rereads the redo log from the consistent point's restart SCN.
+func (r *txReader) reset(lookup func(string) (*sourceTable, error)) {
+	r.lookup = lookup
+	r.open = make(map[string]*pendingTx)
+}
+
+// commit completes a transaction.
+func (r *txReader) commit(row *logRow) (*txBatch, error) {
+	tx := r.open[row.xid]
+	delete(r.open, row.xid)
+	if r.cp.Contains(row.scn, row.xid) {
+		return nil, nil
+	}
+
+	next := &consistentPoint{
+		Commit:  row.scn,
+		Restart: r.restart(row.scn),
+		TS:      row.ts,
+	}
+	if row.scn == r.cp.Commit {
+		next.XIDs = append(next.XIDs, r.cp.XIDs...)
+	}
+	next.XIDs = append(next.XIDs, row.xid)
+	if next.Restart < r.cp.Restart {
+		next.Restart = r.cp.Restart
+	}
+	r.cp = next
+
+	if tx == nil || len(tx.changes) == 0 {
+		r.dirty = true
+		return nil, nil
+	}
+	r.dirty = false
+
+	// Commit timestamps have a resolution of one second, so the
+	// logical component is used to order transactions that commit
+	// within the same second.
+	tm := hlc.New(row.ts.UnixNano(), 0)
+	if hlc.Compare(tm, r.lastTime) <= 0 {
+		tm = hlc.New(r.lastTime.Nanos(), r.lastTime.Logical()+1)
+	}
+	r.lastTime = tm
+
+	ret := &txBatch{cp: next}
+	var current *sourceTable // The table of the last TableData.
+	var keys map[string]bool // The keys in the last TableData.
+	for _, chg := range tx.changes {
+		chg.mut.Time = tm
+
+		// Mutations are grouped by table, preserving the order of the
+		// changes. A row that is changed more than once in the same
+		// transaction starts a new group.
+		if current != chg.table || keys[string(chg.mut.Key)] {
+			ret.data = append(ret.data, logical.TableData{
+				Source: chg.table.source.Table(),
+				Target: chg.table.target,
+			})
+			current = chg.table
+			keys = make(map[string]bool)
+		}
+		data := &ret.data[len(ret.data)-1]
+		data.Muts = append(data.Muts, chg.mut)
+		keys[string(chg.mut.Key)] = true
+	}
+	return ret, nil
+}
+
+// pending returns the open transaction with the row's XID.
+func (r *txReader) pending(row *logRow) *pendingTx {
+	tx, ok := r.open[row.xid]
+	if !ok {
+		tx = &pendingTx{start: row.scn}
+		r.open[row.xid] = tx
+	}
+	return tx
+}
+
+// restart returns the SCN from which the redo log must be reread to
+// see every change made by the open transactions.
+func (r *txReader) restart(limit uint64) uint64 {
+	for _, tx := range r.open {
+		if tx.start < limit {
+			limit = tx.start
+		}
+	}
+	return limit
+}
+
+// mutations converts a parsed statement into mutations. An update that
+// changes a row's primary key is represented as a deletion of the old
+// key and an upsert of the new key.
+func (t *sourceTable) mutations(stmt *redoStatement) ([]types.Mutation, error) {
+	var ret []types.Mutation
+	switch stmt.op {
+	case opInsert:
+		key, err := t.key(stmt.after)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(stmt.after)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret = append(ret, types.Mutation{Data: data, Key: key})
+
+	case opUpdate:
+		image := make(map[string]any, len(stmt.before)+len(stmt.after))
+		for col, val := range stmt.before {
+			image[col] = val
+		}
+		for col, val := range stmt.after {
+			image[col] = val
+		}
+		oldKey, err := t.key(stmt.before)
+		if err != nil {
+			return nil, err
+		}
+		key, err := t.key(image)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(image)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !bytes.Equal(oldKey, key) {
+			ret = append(ret, types.Mutation{Key: oldKey})
+		}
+		ret = append(ret, types.Mutation{Data: data, Key: key})
+
+	case opDelete:
+		key, err := t.key(stmt.before)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, types.Mutation{Key: key})
+
+	default:
+		return nil, errors.Errorf("unexpected operation %d", stmt.op)
+	}
+
+	for idx := range ret {
+		script.AddMeta("oralogical", t.source, &ret[idx])
+	}
+	return ret, nil
+}
+
+// key returns the JSON array of the primary key values in the image.
+func (t *sourceTable) key(image map[string]any) (json.RawMessage, error) {
+	cols := &ident.Map[any]{}
+	for col, val := range image {
+		cols.Put(ident.New(col), val)
+	}
+	key := make([]any, len(t.keys))
+	for idx, col := range t.keys {
+		val, ok := cols.Get(col)
+		if !ok {
+			return nil, errors.Errorf(
+				"redo for %s does not contain primary key column %s; "+
+					"is supplemental logging enabled?", t.source, col)
+		}
+		key[idx] = val
+	}
+	ret, err := json.Marshal(key)
+	return ret, errors.WithStack(err)
+}
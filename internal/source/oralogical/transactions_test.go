@@ -0,0 +1,157 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package oralogical
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxReader(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	sourceSchema := ident.MustSchema(ident.New("APP"))
+	targetSchema := ident.MustSchema(ident.New("public"))
+	orders := &sourceTable{
+		keys:   []ident.Ident{ident.New("id")},
+		source: ident.NewTable(sourceSchema, ident.New("ORDERS")),
+		target: ident.NewTable(targetSchema, ident.New("ORDERS")),
+	}
+	lookup := func(name string) (*sourceTable, error) {
+		if name == "ORDERS" {
+			return orders, nil
+		}
+		return nil, nil
+	}
+	ts := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	insert := func(scn uint64, xid string, id int) *logRow {
+		return &logRow{
+			op:    opInsert,
+			redo:  fmt.Sprintf(`insert into "APP"."ORDERS"("ID","VAL") values ('%d','v');`, id),
+			scn:   scn,
+			table: "ORDERS",
+			ts:    ts,
+			xid:   xid,
+		}
+	}
+	row := func(op int, scn uint64, xid string) *logRow {
+		return &logRow{op: op, scn: scn, ts: ts, xid: xid}
+	}
+
+	reader := newTxReader(&consistentPoint{Commit: 100, XIDs: []string{"OLD"}, Restart: 100})
+	reader.reset(lookup)
+
+	// Transaction A is interleaved with B, which commits first.
+	for _, rw := range []*logRow{
+		row(opStart, 101, "A"),
+		insert(101, "A", 1),
+		row(opStart, 102, "B"),
+		insert(102, "B", 2),
+		insert(103, "B", 3),
+		// A transaction that changes an ignored table.
+		row(opStart, 104, "C"),
+		{op: opInsert, scn: 104, table: "OTHER", xid: "C", redo: "unparsed"},
+		// A transaction that rolls back.
+		row(opStart, 105, "D"),
+		insert(105, "D", 4),
+		row(opRollback, 106, "D"),
+	} {
+		b, err := reader.add(rw)
+		r.NoError(err)
+		a.Nil(b)
+	}
+
+	// B's changes are emitted, but A's first change must be reread.
+	b, err := reader.add(row(opCommit, 110, "B"))
+	r.NoError(err)
+	r.NotNil(b)
+	a.Equal(&consistentPoint{Commit: 110, XIDs: []string{"B"}, Restart: 101, TS: ts}, b.cp)
+	r.Len(b.data, 1)
+	a.Equal(orders.target, b.data[0].Target)
+	r.Len(b.data[0].Muts, 2)
+	a.Equal(`["2"]`, string(b.data[0].Muts[0].Key))
+	a.Equal(hlc.New(ts.UnixNano(), 0), b.data[0].Muts[0].Time)
+
+	// C commits at the same SCN, but has no changes to apply.
+	b, err = reader.add(row(opCommit, 110, "C"))
+	r.NoError(err)
+	a.Nil(b)
+
+	// A commits in the same second, so the logical time is advanced.
+	b, err = reader.add(row(opCommit, 111, "A"))
+	r.NoError(err)
+	r.NotNil(b)
+	a.Equal(uint64(111), b.cp.Restart)
+	a.Equal(hlc.New(ts.UnixNano(), 1), b.data[0].Muts[0].Time)
+
+	// Nothing else is open, so the restart point moves to the end.
+	b = reader.flush(120)
+	r.NotNil(b)
+	a.Empty(b.data)
+	a.Equal(&consistentPoint{Commit: 111, XIDs: []string{"A"}, Restart: 120, TS: ts}, b.cp)
+	a.Nil(reader.flush(120))
+
+	// Rereading from an earlier point skips applied transactions.
+	reader = newTxReader(&consistentPoint{Commit: 110, XIDs: []string{"B", "C"}, Restart: 101})
+	reader.reset(lookup)
+	for _, rw := range []*logRow{
+		row(opStart, 101, "A"), insert(101, "A", 1),
+		row(opStart, 102, "B"), insert(102, "B", 2),
+	} {
+		_, err := reader.add(rw)
+		r.NoError(err)
+	}
+	b, err = reader.add(row(opCommit, 110, "B"))
+	r.NoError(err)
+	a.Nil(b)
+	b, err = reader.add(row(opCommit, 111, "A"))
+	r.NoError(err)
+	r.NotNil(b)
+	a.Equal([]string{"A"}, b.cp.XIDs)
+}
+
+func TestSourceTableMutations(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	tbl := &sourceTable{
+		keys:   []ident.Ident{ident.New("id")},
+		source: ident.NewTable(ident.MustSchema(ident.New("APP")), ident.New("T")),
+	}
+
+	stmt, err := parseRedo(`update "APP"."T" set "ID" = 2 where "ID" = 1 and "VAL" = 'x';`)
+	r.NoError(err)
+	muts, err := tbl.mutations(stmt)
+	r.NoError(err)
+	r.Len(muts, 2)
+	a.Equal(`[1]`, string(muts[0].Key))
+	a.True(muts[0].IsDelete())
+	a.Equal(`[2]`, string(muts[1].Key))
+	a.JSONEq(`{"ID":2,"VAL":"x"}`, string(muts[1].Data))
+
+	stmt, err = parseRedo(`update "APP"."T" set "VAL" = 'y' where "VAL" = 'x';`)
+	r.NoError(err)
+	_, err = tbl.mutations(stmt)
+	a.ErrorContains(err, "supplemental logging")
+}
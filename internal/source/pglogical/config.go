@@ -22,6 +22,18 @@ import (
 	"github.com/spf13/pflag"
 )
 
+// These values are accepted by [Config.TruncateMode].
+const (
+	TruncateApply  = "apply"  // Delete all rows from the target table.
+	TruncateDLQ    = "dlq"    // Record the operation in a dead-letter queue.
+	TruncateIgnore = "ignore" // Discard the operation.
+)
+
+const (
+	defaultSnapshotChunkSize = 10_000
+	defaultTruncateDLQ       = "truncate"
+)
+
 // Config contains the configuration necessary for creating a
 // replication connection. All field, other than TestControls, are
 // mandatory.
@@ -29,12 +41,31 @@ type Config struct {
 	logical.BaseConfig
 	logical.LoopConfig
 
+	// Apply column additions, drops, and type widenings in the source
+	// to the target.
+	PropagateDDL bool
 	// The name of the publication to attach to.
 	Publication string
 	// The replication slot to attach to.
 	Slot string
+	// Create the replication slot and copy the contents of the
+	// published tables before streaming. Every published table must
+	// have a primary key.
+	Snapshot bool
+	// The number of rows to read from a table at once when copying a
+	// snapshot.
+	SnapshotChunkSize int
 	// Connection string for the source db.
 	SourceConn string
+	// Use version 2 of the pgoutput protocol to receive the changes
+	// made by large transactions before they have committed. Requires
+	// PostgreSQL 14 or later.
+	StreamTransactions bool
+	// The name of the dead-letter queue to use when TruncateMode is
+	// TruncateDLQ.
+	TruncateDLQ string
+	// Controls how TRUNCATE operations in the source are handled.
+	TruncateMode string
 }
 
 // Bind adds flags to the set.
@@ -45,9 +76,22 @@ func (c *Config) Bind(f *pflag.FlagSet) {
 	c.LoopConfig.Bind(f)
 
 	f.StringVar(&c.Slot, "slotName", "cdc_sink", "the replication slot in the source database")
+	f.BoolVar(&c.Snapshot, "snapshot", false,
+		"create the replication slot and copy existing data before streaming")
+	f.IntVar(&c.SnapshotChunkSize, "snapshotChunkSize", defaultSnapshotChunkSize,
+		"the number of rows to read from a table at once when copying a snapshot")
 	f.StringVar(&c.SourceConn, "sourceConn", "", "the source database's connection string")
+	f.BoolVar(&c.StreamTransactions, "streamTransactions", false,
+		"receive large transactions before they commit, spilling them to the staging "+
+			"database once bytesInFlight is reached; requires PostgreSQL 14 or later")
+	f.BoolVar(&c.PropagateDDL, "propagateDDL", false,
+		"apply column additions, drops, and type widenings in the source to the target")
 	f.StringVar(&c.Publication, "publicationName", "",
 		"the publication within the source database to replicate")
+	f.StringVar(&c.TruncateDLQ, "truncateDLQ", defaultTruncateDLQ,
+		"the name of the dead-letter queue to use with --truncate=dlq")
+	f.StringVar(&c.TruncateMode, "truncate", TruncateApply,
+		"how to handle TRUNCATE operations in the source: apply, dlq, or ignore")
 }
 
 // Preflight updates the configuration with sane defaults or returns an
@@ -69,5 +113,26 @@ func (c *Config) Preflight() error {
 	if c.SourceConn == "" {
 		return errors.New("no source connection was configured")
 	}
+	if c.Snapshot {
+		if c.BackfillWindow <= 0 {
+			return errors.New("snapshot mode requires a non-zero backfillWindow")
+		}
+		if c.SnapshotChunkSize == 0 {
+			c.SnapshotChunkSize = defaultSnapshotChunkSize
+		} else if c.SnapshotChunkSize < 0 {
+			return errors.New("snapshotChunkSize must be >= 0")
+		}
+	}
+	switch c.TruncateMode {
+	case "":
+		c.TruncateMode = TruncateApply
+	case TruncateApply, TruncateIgnore:
+	case TruncateDLQ:
+		if c.TruncateDLQ == "" {
+			c.TruncateDLQ = defaultTruncateDLQ
+		}
+	default:
+		return errors.Errorf("unknown truncate mode %q", c.TruncateMode)
+	}
 	return nil
 }
@@ -26,7 +26,9 @@ import (
 
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/ddl"
 	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
 	"github.com/google/uuid"
@@ -59,14 +61,39 @@ func (s *lsnStamp) Less(other stamp.Stamp) bool { return s.LSN < other.(*lsnStam
 type conn struct {
 	// Columns, as ordered by the source database.
 	columns *ident.TableMap[[]types.ColData]
+	// Applies schema changes to the target, if enabled.
+	ddl *ddl.Propagator
+	// Access to dead-letter queues for TRUNCATE operations.
+	dlqs types.DLQs
+	// Records the progress of a snapshot.
+	memo types.Memo
 	// The pg publication name to subscribe to.
 	publicationName string
 	// Map source ids to target tables.
 	relations map[uint32]ident.Table
 	// The name of the slot within the publication.
 	slotName string
+	// The number of rows to read from a table at once in snapshot mode.
+	snapshotChunkSize int
+	// The memo key used to record the progress of a snapshot.
+	snapshotKey string
+	// Connection string for reading snapshots from the source.
+	sourceConn string
+	// The most recently propagated definition of each source table.
+	sourceColumns *ident.TableMap[[]ddl.Column]
 	// The configuration for opening replication connections.
 	sourceConfig *pgconn.Config
+	// Used to access the memo table.
+	stagingPool *types.StagingPool
+	// Holds the changes made by streamed transactions. If nil, the
+	// version 1 protocol is used and transactions are not streamed.
+	streams *streamBuffer
+	// Used to write to dead-letter queues.
+	targetPool *types.TargetPool
+	// The dead-letter queue to use for TRUNCATE operations.
+	truncateDLQ string
+	// Controls the handling of TRUNCATE operations.
+	truncateMode string
 }
 
 var _ logical.Dialect = (*conn)(nil)
@@ -77,6 +104,8 @@ func (c *conn) Process(
 	ctx context.Context, ch <-chan logical.Message, events logical.Events,
 ) error {
 	var batch logical.Batch
+	// Set once data has been added to the batch.
+	var batchHasData bool
 	defer func() {
 		if batch != nil {
 			_ = batch.OnRollback(ctx)
@@ -90,6 +119,17 @@ func (c *conn) Process(
 	ignoreBefore, _ := events.GetConsistentPoint()
 	ignoreLSN := ignoreBefore.(*lsnStamp).AsLSN()
 
+	// Tables to record in a dead-letter queue once the current
+	// transaction has been committed.
+	var truncated []ident.Table
+
+	// Any transactions that were being streamed will be resent.
+	if c.streams != nil {
+		if err := c.streams.reset(ctx); err != nil {
+			return err
+		}
+	}
+
 	for msg := range ch {
 		// Ensure that we resynchronize.
 		if logical.IsRollback(msg) {
@@ -99,6 +139,12 @@ func (c *conn) Process(
 				}
 				batch = nil
 			}
+			truncated = nil
+			if c.streams != nil {
+				if err := c.streams.reset(ctx); err != nil {
+					return err
+				}
+			}
 			continue
 		}
 
@@ -109,7 +155,12 @@ func (c *conn) Process(
 			// The replication protocol says that we'll see these
 			// descriptors before any use of the relation id in the
 			// stream. We'll map the int value to our table identifiers.
-			c.onRelation(msg, events.GetTargetDB())
+			if c.ddl != nil {
+				batch, err = c.propagateDDL(ctx, events, batch, batchHasData, msg)
+			}
+			if err == nil {
+				c.onRelation(msg, events.GetTargetDB())
+			}
 
 		case *pglogrepl.BeginMessage:
 			if msg.FinalLSN <= ignoreLSN {
@@ -118,6 +169,7 @@ func (c *conn) Process(
 				continue
 			}
 			batch, err = events.OnBegin(ctx)
+			batchHasData = false
 
 		case *pglogrepl.CommitMessage:
 			if msg.CommitLSN <= ignoreLSN {
@@ -135,6 +187,13 @@ func (c *conn) Process(
 				return ctx.Err()
 			}
 
+			if len(truncated) > 0 {
+				if err := c.enqueueTruncates(ctx, truncated, msg.CommitLSN, msg.CommitTime); err != nil {
+					return err
+				}
+				truncated = nil
+			}
+
 			// The COMMIT records are written in order, so they're a
 			// better marker to record.
 			if err := events.SetConsistentPoint(ctx, &lsnStamp{msg.CommitLSN, msg.CommitTime}); err != nil {
@@ -143,20 +202,69 @@ func (c *conn) Process(
 			ignoreLSN = msg.CommitLSN
 
 		case *pglogrepl.DeleteMessage:
+			batchHasData = true
 			err = c.onDataTuple(ctx, batch, msg.RelationID, msg.OldTuple, true /* isDelete */)
 
 		case *pglogrepl.InsertMessage:
+			batchHasData = true
 			err = c.onDataTuple(ctx, batch, msg.RelationID, msg.Tuple, false /* isDelete */)
 
 		case *pglogrepl.UpdateMessage:
+			batchHasData = true
 			err = c.onDataTuple(ctx, batch, msg.RelationID, msg.NewTuple, false /* isDelete */)
 
+		case *streamStart:
+			c.streams.start(msg.xid)
+
+		case *streamData:
+			err = c.onStreamData(ctx, msg)
+
+		case *streamStop:
+			c.streams.stop()
+
+		case *streamCommit:
+			if msg.commitLSN <= ignoreLSN {
+				log.Tracef("ignoring streamCommit at %s before %s",
+					msg.commitLSN, ignoreLSN)
+				err = c.streams.remove(ctx, msg.xid)
+				break
+			}
+			err = c.onStreamCommit(ctx, events, msg)
+			if err == nil {
+				ignoreLSN = msg.commitLSN
+			}
+
+		case *streamAbort:
+			err = c.streams.abort(ctx, msg)
+
+		case *snapshotChunk:
+			err = c.onSnapshotChunk(ctx, events, msg)
+
+		case *snapshotComplete:
+			err = c.onSnapshotComplete(ctx, events, msg)
+			if err == nil {
+				ignoreLSN = msg.lsn
+			}
+
 		case *pglogrepl.TruncateMessage:
-			err = errors.Errorf("the TRUNCATE operation cannot be supported on table %d", msg.RelationNum)
+			batchHasData = true
+			var tbls []ident.Table
+			tbls, err = c.onTruncate(ctx, batch, msg)
+			truncated = append(truncated, tbls...)
 
 		default:
 			err = errors.Errorf("unimplemented logical replication message %T", msg)
 		}
+		if unsupported, ok := ddl.IsUnsupported(err); ok {
+			// Release any locks held in the target before waiting for
+			// the operator to intervene.
+			if batch != nil {
+				_ = batch.OnRollback(ctx)
+				batch = nil
+			}
+			c.ddl.Pause(unsupported)
+			return logical.Pause(ctx, ch, events)
+		}
 		if err != nil {
 			return err
 		}
@@ -179,13 +287,18 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 	if x, ok := cp.(*lsnStamp); ok {
 		startLogPos = x.AsLSN()
 	}
+	pluginArgs := []string{
+		"proto_version '1'",
+		fmt.Sprintf("publication_names '%s'", c.publicationName)}
+	if c.streams != nil {
+		pluginArgs = []string{
+			"proto_version '2'",
+			fmt.Sprintf("publication_names '%s'", c.publicationName),
+			"streaming 'on'"}
+	}
 	if err := pglogrepl.StartReplication(ctx,
 		replConn, c.slotName, startLogPos,
-		pglogrepl.StartReplicationOptions{
-			PluginArgs: []string{
-				"proto_version '1'",
-				fmt.Sprintf("publication_names '%s'", c.publicationName)},
-		},
+		pglogrepl.StartReplicationOptions{PluginArgs: pluginArgs},
 	); err != nil {
 		dialFailureCount.Inc()
 		return errors.WithStack(err)
@@ -195,6 +308,9 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 	const standbyTimeout = time.Second * 10
 	standbyDeadline := time.Now().Add(standbyTimeout)
 
+	// Tracks whether a block of streamed changes is being received.
+	var decoder streamDecoder
+
 	for {
 		select {
 		case <-state.Stopping():
@@ -269,9 +385,9 @@ func (c *conn) ReadInto(ctx context.Context, ch chan<- logical.Message, state lo
 					"WALStart":     xld.WALStart,
 				}).Debug("xlog data")
 
-				logicalMsg, err := pglogrepl.Parse(xld.WALData)
+				logicalMsg, err := decoder.decode(xld.WALData)
 				if err != nil {
-					return errors.WithStack(err)
+					return err
 				}
 				select {
 				case ch <- logicalMsg:
@@ -380,6 +496,105 @@ func (c *conn) onDataTuple(
 	return batch.OnData(ctx, script.SourceName(tbl), tbl, []types.Mutation{mut})
 }
 
+// onTruncate handles a TRUNCATE operation in the source database
+// according to the configured mode. Any tables which should be recorded
+// in a dead-letter queue are returned, since the queue should only be
+// written to once the enclosing transaction has been committed.
+func (c *conn) onTruncate(
+	ctx context.Context, batch logical.Batch, msg *pglogrepl.TruncateMessage,
+) ([]ident.Table, error) {
+	// Will be nil if we're ignoring replayed messages.
+	if batch == nil {
+		return nil, nil
+	}
+	tbls, err := c.truncatedTables(msg)
+	if err != nil {
+		return nil, err
+	}
+	return c.applyTruncate(ctx, batch, tbls)
+}
+
+// applyTruncate handles the truncation of the tables according to the
+// configured mode. It returns the tables to record in a dead-letter
+// queue.
+func (c *conn) applyTruncate(
+	ctx context.Context, batch logical.Batch, tbls []ident.Table,
+) ([]ident.Table, error) {
+	switch c.truncateMode {
+	case TruncateApply:
+		// The batch is responsible for ordering the truncations with
+		// respect to any foreign-key relationships.
+		for _, tbl := range tbls {
+			if err := batch.OnTruncate(ctx, script.SourceName(tbl), tbl); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+
+	case TruncateDLQ:
+		return tbls, nil
+
+	case TruncateIgnore:
+		log.WithField("tables", tbls).Warn("ignoring TRUNCATE operation")
+		truncateIgnoredCount.Add(float64(len(tbls)))
+		return nil, nil
+
+	default:
+		return nil, errors.Errorf("unknown truncate mode %q", c.truncateMode)
+	}
+}
+
+// truncatedTables returns the tables named by a TRUNCATE message.
+func (c *conn) truncatedTables(msg *pglogrepl.TruncateMessage) ([]ident.Table, error) {
+	tbls := make([]ident.Table, len(msg.RelationIDs))
+	for idx, relation := range msg.RelationIDs {
+		tbl, ok := c.relations[relation]
+		if !ok {
+			return nil, errors.Errorf("unknown relation id %d", relation)
+		}
+		tbls[idx] = tbl
+	}
+	return tbls, nil
+}
+
+// enqueueTruncates records TRUNCATE operations in the configured
+// dead-letter queue. The name of the truncated table and the LSN of
+// the enclosing transaction are recorded in the mutation's data. The
+// queue is written to after the transaction has been committed, so
+// the entries are recorded idempotently in case the transaction is
+// replayed before the consistent point has been saved.
+func (c *conn) enqueueTruncates(
+	ctx context.Context, tbls []ident.Table, commitLSN pglogrepl.LSN, commitTime time.Time,
+) error {
+	for _, tbl := range tbls {
+		q, err := c.dlqs.Get(ctx, tbl.Schema(), c.truncateDLQ)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(map[string]string{
+			"lsn":       commitLSN.String(),
+			"operation": "truncate",
+			"table":     tbl.Raw(),
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		key, err := json.Marshal([]string{tbl.Raw(), commitLSN.String()})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := q.EnqueueOnce(ctx, c.targetPool, types.Mutation{
+			Data: data,
+			Key:  key,
+			Time: hlc.New(commitTime.UnixNano(), 0),
+		}); err != nil {
+			return err
+		}
+		truncateDLQCount.Inc()
+	}
+	return nil
+}
+
 // learn updates the source database namespace mappings.
 func (c *conn) onRelation(msg *pglogrepl.RelationMessage, targetDB ident.Schema) {
 	// The replication protocol says that we'll see these
@@ -0,0 +1,135 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/ddl"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/jackc/pglogrepl"
+	"github.com/pkg/errors"
+)
+
+// propagateDDL compares the relation to the target table's
+// columns and applies any differences to the target. The target is
+// modified outside of any open batch, since the batch may hold locks
+// on the table being altered. A batch that has not yet received any
+// data will be reopened and returned. Otherwise, an error is returned
+// so that the transaction will be replayed.
+func (c *conn) propagateDDL(
+	ctx context.Context,
+	events logical.Events,
+	batch logical.Batch,
+	batchHasData bool,
+	msg *pglogrepl.RelationMessage,
+) (logical.Batch, error) {
+	tbl := ident.NewTable(events.GetTargetDB(), ident.New(msg.RelationName))
+	cols := make([]ddl.Column, len(msg.Columns))
+	for idx, col := range msg.Columns {
+		cols[idx] = ddl.Column{
+			Name:    ident.New(col.Name),
+			Primary: col.Flags == 1,
+			Type:    pgType(col.DataType, col.TypeModifier),
+		}
+	}
+
+	// The target's columns are the baseline, so that changes made
+	// while we weren't running are detected. The previously-observed
+	// definition, if any, is used to detect type changes and drops.
+	prev, _ := c.sourceColumns.Get(tbl)
+	changes, err := c.ddl.Plan(ctx, tbl, prev, cols)
+	if err != nil {
+		return batch, err
+	}
+	if len(changes) == 0 {
+		c.sourceColumns.Put(tbl, cols)
+		return batch, nil
+	}
+
+	if batch != nil {
+		if err := batch.OnRollback(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.ddl.Apply(ctx, changes); err != nil {
+		return nil, err
+	}
+	c.sourceColumns.Put(tbl, cols)
+
+	switch {
+	case batch == nil:
+		return nil, nil
+	case batchHasData:
+		return nil, errors.Errorf(
+			"schema of %s changed within a transaction; replaying transaction", tbl)
+	default:
+		return events.OnBegin(ctx)
+	}
+}
+
+// pgType maps a PostgreSQL type OID and type modifier to a
+// product-neutral type.
+func pgType(oid uint32, typmod int32) ddl.Type {
+	switch oid {
+	case 16: // bool
+		return ddl.Type{Kind: ddl.TypeBool}
+	case 17: // bytea
+		return ddl.Type{Kind: ddl.TypeBytes}
+	case 20: // int8
+		return ddl.Type{Kind: ddl.TypeInt64}
+	case 21: // int2
+		return ddl.Type{Kind: ddl.TypeInt16}
+	case 23: // int4
+		return ddl.Type{Kind: ddl.TypeInt32}
+	case 25: // text
+		return ddl.Type{Kind: ddl.TypeString}
+	case 114, 3802: // json, jsonb
+		return ddl.Type{Kind: ddl.TypeJSON}
+	case 700: // float4
+		return ddl.Type{Kind: ddl.TypeFloat32}
+	case 701: // float8
+		return ddl.Type{Kind: ddl.TypeFloat64}
+	case 1042, 1043: // bpchar, varchar
+		ret := ddl.Type{Kind: ddl.TypeString}
+		// The type modifier includes a four-byte header.
+		if typmod >= 4 {
+			ret.Length = int(typmod - 4)
+		}
+		return ret
+	case 1082: // date
+		return ddl.Type{Kind: ddl.TypeDate}
+	case 1083: // time
+		return ddl.Type{Kind: ddl.TypeTime}
+	case 1114: // timestamp
+		return ddl.Type{Kind: ddl.TypeTimestamp}
+	case 1184: // timestamptz
+		return ddl.Type{Kind: ddl.TypeTimestampTZ}
+	case 1700: // numeric
+		ret := ddl.Type{Kind: ddl.TypeNumeric}
+		if typmod >= 4 {
+			ret.Precision = int(((typmod - 4) >> 16) & 0xffff)
+			ret.Scale = int((typmod - 4) & 0xffff)
+		}
+		return ret
+	case 2950: // uuid
+		return ddl.Type{Kind: ddl.TypeUUID}
+	default:
+		return ddl.Type{Kind: ddl.TypeUnknown}
+	}
+}
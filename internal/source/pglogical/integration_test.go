@@ -58,6 +58,7 @@ type fixtureConfig struct {
 	chaosProb float32
 	immediate bool
 	script    bool
+	stream    bool
 }
 
 // This is a general smoke-test of the logical replication feed.
@@ -78,6 +79,9 @@ func TestPGLogical(t *testing.T) {
 	t.Run("consistent-script", func(t *testing.T) {
 		testPGLogical(t, &fixtureConfig{script: true})
 	})
+	t.Run("consistent-stream", func(t *testing.T) {
+		testPGLogical(t, &fixtureConfig{stream: true})
+	})
 	t.Run("immediate", func(t *testing.T) {
 		testPGLogical(t, &fixtureConfig{immediate: true})
 	})
@@ -87,6 +91,9 @@ func TestPGLogical(t *testing.T) {
 	t.Run("immediate-script", func(t *testing.T) {
 		testPGLogical(t, &fixtureConfig{immediate: true, script: true})
 	})
+	t.Run("immediate-stream", func(t *testing.T) {
+		testPGLogical(t, &fixtureConfig{immediate: true, stream: true})
+	})
 }
 
 func testPGLogical(t *testing.T, fc *fixtureConfig) {
@@ -110,6 +117,16 @@ func testPGLogical(t *testing.T, fc *fixtureConfig) {
 	}
 	defer cancel()
 
+	if fc.stream {
+		var version int
+		if err := pgPool.QueryRow(ctx, "SHOW server_version_num").Scan(&version); !a.NoError(err) {
+			return
+		}
+		if version < 140000 {
+			t.Skipf("streaming requires PostgreSQL 14 or later, have %d", version)
+		}
+	}
+
 	// Create the schema in both locations.
 	var tgts []ident.Table
 	if fc.script {
@@ -220,6 +237,14 @@ func testPGLogical(t *testing.T, fc *fixtureConfig) {
 			MainPath: "/testdata/logical_test.ts",
 		}
 	}
+	if fc.stream {
+		// Use the smallest decoding buffer, so that the large update,
+		// delete, and truncate transactions below will be streamed
+		// before they commit. Spill them once a few rows are buffered.
+		cfg.BytesInFlight = 1024
+		cfg.SourceConn += "?logical_decoding_work_mem=64kB"
+		cfg.StreamTransactions = true
+	}
 	repl, cancelLoop, err := Start(ctx, cfg)
 	if !a.NoError(err) {
 		return
@@ -301,6 +326,45 @@ func testPGLogical(t *testing.T, fc *fixtureConfig) {
 		}
 	}
 
+	// Truncate the tables and insert a new row in the same
+	// transaction, to verify that the operations are ordered.
+	tx, err = pgPool.Begin(ctx)
+	if !a.NoError(err) {
+		return
+	}
+	for _, tgt := range tgts {
+		if _, err := tx.Exec(ctx, fmt.Sprintf("TRUNCATE TABLE %s", tgt)); !a.NoError(err) {
+			return
+		}
+		if _, err := tx.Exec(ctx,
+			fmt.Sprintf("INSERT INTO %s VALUES (1, 'truncated')", tgt)); !a.NoError(err) {
+			return
+		}
+	}
+	if !a.NoError(tx.Commit(ctx)) {
+		return
+	}
+
+	// Wait for the truncation to propagate.
+	for _, tgt := range tgts {
+		for {
+			var count, truncated int
+			if err := crdbPool.QueryRowContext(ctx,
+				fmt.Sprintf("SELECT count(*) FROM %s", tgt)).Scan(&count); !a.NoError(err) {
+				return
+			}
+			if err := crdbPool.QueryRowContext(ctx,
+				fmt.Sprintf("SELECT count(*) FROM %s WHERE %s = 'truncated'", tgt, crdbCol)).Scan(&truncated); !a.NoError(err) {
+				return
+			}
+			log.Trace("truncate count", count)
+			if count == 1 && truncated == 1 {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
 	sinktest.CheckDiagnostics(ctx, t, repl.Diagnostics)
 
 	cancelLoop()
@@ -478,6 +542,232 @@ func TestDataTypes(t *testing.T) {
 	}
 }
 
+// TestPropagateDDL verifies that a column added to a source table will
+// be added to the target table.
+func TestPropagateDDL(t *testing.T) {
+	a := assert.New(t)
+
+	fixture, cancel, err := base.NewFixture()
+	if !a.NoError(err) {
+		return
+	}
+	defer cancel()
+
+	ctx := fixture.Context
+	dbSchema := fixture.TargetSchema.Schema()
+	dbName := dbSchema.Idents(nil)[0] // Extract first name part.
+	crdbPool := fixture.TargetPool
+
+	pgPool, cancel, err := setupPGPool(dbName)
+	if !a.NoError(err) {
+		return
+	}
+	defer cancel()
+
+	tgt := ident.NewTable(dbSchema, ident.New("ddl_tbl"))
+	schema := fmt.Sprintf(`CREATE TABLE %s (pk INT PRIMARY KEY, v TEXT)`, tgt)
+	if _, err := pgPool.Exec(ctx, schema); !a.NoError(err) {
+		return
+	}
+	if _, err := crdbPool.ExecContext(ctx, schema); !a.NoError(err) {
+		return
+	}
+	if _, err := pgPool.Exec(ctx,
+		fmt.Sprintf("INSERT INTO %s VALUES (1, 'one')", tgt)); !a.NoError(err) {
+		return
+	}
+
+	pubNameRaw := publicationName(dbName).Raw()
+	repl, cancelLoop, err := Start(ctx, &Config{
+		BaseConfig: logical.BaseConfig{
+			RetryDelay:    time.Nanosecond,
+			StagingSchema: fixture.StagingDB.Schema(),
+			TargetConn:    crdbPool.ConnectionString,
+		},
+		LoopConfig: logical.LoopConfig{
+			LoopName:     "pglogicaltest",
+			TargetSchema: dbSchema,
+		},
+		PropagateDDL: true,
+		Publication:  pubNameRaw,
+		Slot:         pubNameRaw,
+		SourceConn:   *pgConnString + dbName.Raw(),
+	})
+	if !a.NoError(err) {
+		return
+	}
+
+	// Wait for the initial row, so that the original table definition
+	// has been observed.
+	for {
+		count, err := base.GetRowCount(ctx, crdbPool, tgt)
+		if !a.NoError(err) {
+			return
+		}
+		if count == 1 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Add a column in the source and use it.
+	if _, err := pgPool.Exec(ctx,
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN extra VARCHAR(16)", tgt)); !a.NoError(err) {
+		return
+	}
+	if _, err := pgPool.Exec(ctx,
+		fmt.Sprintf("INSERT INTO %s VALUES (2, 'two', 'extra')", tgt)); !a.NoError(err) {
+		return
+	}
+
+	// The query will fail until the column has been added.
+	for {
+		var count int
+		err := crdbPool.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT count(*) FROM %s WHERE extra = 'extra'", tgt)).Scan(&count)
+		if err == nil && count == 1 {
+			break
+		}
+		if !a.NoError(ctx.Err()) {
+			return
+		}
+		log.WithError(err).Trace("waiting for added column")
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	sinktest.CheckDiagnostics(ctx, t, repl.Diagnostics)
+
+	cancelLoop()
+	select {
+	case <-repl.Loop.Stopped():
+	case <-ctx.Done():
+	}
+}
+
+// TestSnapshot verifies that the contents of the source tables are
+// copied before switching to streaming from the replication slot.
+func TestSnapshot(t *testing.T) {
+	a := assert.New(t)
+
+	fixture, cancel, err := base.NewFixture()
+	if !a.NoError(err) {
+		return
+	}
+	defer cancel()
+
+	ctx := fixture.Context
+	dbSchema := fixture.TargetSchema.Schema()
+	dbName := dbSchema.Idents(nil)[0] // Extract first name part.
+	crdbPool := fixture.TargetPool
+
+	pgPool, cancel, err := setupPGPool(dbName)
+	if !a.NoError(err) {
+		return
+	}
+	defer cancel()
+
+	// The slot will be created by the snapshot process.
+	pubNameRaw := publicationName(dbName).Raw()
+	if _, err := pgPool.Exec(ctx, "SELECT pg_drop_replication_slot($1)", pubNameRaw); !a.NoError(err) {
+		return
+	}
+
+	// Use a composite primary key to exercise the chunking query. The
+	// key columns are declared out of column order, to verify that the
+	// table is paged through in index order.
+	tgt := ident.NewTable(dbSchema, ident.New("snapshot_tbl"))
+	schema := fmt.Sprintf(`CREATE TABLE %s (a INT, b TEXT, v TEXT, PRIMARY KEY (b, a))`, tgt)
+	if _, err := pgPool.Exec(ctx, schema); !a.NoError(err) {
+		return
+	}
+	if _, err := crdbPool.ExecContext(ctx, schema); !a.NoError(err) {
+		return
+	}
+
+	const rowCount = 1024
+	if _, err := pgPool.Exec(ctx, fmt.Sprintf(
+		`INSERT INTO %s SELECT i %% 10, i::TEXT, 'snapshot' FROM generate_series(1, $1) i`, tgt),
+		rowCount,
+	); !a.NoError(err) {
+		return
+	}
+
+	cfg := &Config{
+		BaseConfig: logical.BaseConfig{
+			ApplyTimeout:   2 * time.Minute, // Increase to make using the debugger easier.
+			BackfillWindow: time.Minute,
+			RetryDelay:     time.Nanosecond,
+			StagingSchema:  fixture.StagingDB.Schema(),
+			TargetConn:     crdbPool.ConnectionString,
+		},
+		LoopConfig: logical.LoopConfig{
+			LoopName:     "pglogicaltest",
+			TargetSchema: dbSchema,
+		},
+		Publication:       pubNameRaw,
+		Slot:              pubNameRaw,
+		Snapshot:          true,
+		SnapshotChunkSize: 100,
+		SourceConn:        *pgConnString + dbName.Raw(),
+	}
+	repl, cancelLoop, err := Start(ctx, cfg)
+	if !a.NoError(err) {
+		return
+	}
+
+	// Wait for the snapshot to be copied.
+	for {
+		var count int
+		if err := crdbPool.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT count(*) FROM %s WHERE v = 'snapshot'", tgt)).Scan(&count); !a.NoError(err) {
+			return
+		}
+		log.Trace("snapshot count", count)
+		if count == rowCount {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Changes made after the snapshot should be streamed.
+	if _, err := pgPool.Exec(ctx, fmt.Sprintf(
+		`INSERT INTO %s VALUES (-1, 'streamed', 'streamed')`, tgt),
+	); !a.NoError(err) {
+		return
+	}
+	if _, err := pgPool.Exec(ctx, fmt.Sprintf(
+		`UPDATE %s SET v = 'updated' WHERE a = 0`, tgt),
+	); !a.NoError(err) {
+		return
+	}
+	for {
+		var streamed, updated int
+		if err := crdbPool.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT count(*) FROM %s WHERE v = 'streamed'", tgt)).Scan(&streamed); !a.NoError(err) {
+			return
+		}
+		if err := crdbPool.QueryRowContext(ctx,
+			fmt.Sprintf("SELECT count(*) FROM %s WHERE v = 'updated'", tgt)).Scan(&updated); !a.NoError(err) {
+			return
+		}
+		log.Trace("streamed count", streamed, updated)
+		if streamed == 1 && updated == rowCount/10 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	sinktest.CheckDiagnostics(ctx, t, repl.Diagnostics)
+
+	cancelLoop()
+	select {
+	case <-ctx.Done():
+		a.Fail("cancelConn timed out")
+	case <-repl.Loop.Stopped():
+		// OK
+	}
+}
+
 // Allowable publication slot names are a subset of allowable
 // database names, so we need to replace the must-quote dashes in
 // the database name.
@@ -30,4 +30,16 @@ var (
 		Name: "pglogical_dial_success_total",
 		Help: "the number of times we successfully dialed a replication connection",
 	})
+	snapshotRowCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglogical_snapshot_rows_total",
+		Help: "the number of rows read from the source database's snapshot",
+	})
+	truncateDLQCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglogical_truncate_dlq_total",
+		Help: "the number of TRUNCATE operations that were written to a dead-letter queue",
+	})
+	truncateIgnoredCount = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pglogical_truncate_ignored_total",
+		Help: "the number of TRUNCATE operations that were ignored",
+	})
 )
@@ -21,6 +21,7 @@ import (
 
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/target/ddl"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
 	"github.com/cockroachdb/cdc-sink/internal/util/stdpool"
@@ -41,15 +42,24 @@ var Set = wire.NewSet(
 // has been configured. There's a fake dependency on the script loader
 // so that flags can be evaluated first.
 func ProvideDialect(
-	ctx context.Context, config *Config, _ *script.Loader,
+	ctx context.Context,
+	config *Config,
+	ddls *ddl.Propagator,
+	dlqs types.DLQs,
+	memo types.Memo,
+	stagingPool *types.StagingPool,
+	stagingSchema ident.StagingSchema,
+	targetPool *types.TargetPool,
+	_ *script.Loader,
 ) (logical.Dialect, error) {
 	if err := config.Preflight(); err != nil {
 		return nil, err
 	}
 	// Verify that the publication and replication slots were configured
-	// by the user. We could create the replication slot ourselves, but
-	// we want to coordinate the timing of the backup, restore, and
-	// streaming operations.
+	// by the user. Unless a snapshot has been requested, we don't
+	// create the replication slot ourselves, since we want to
+	// coordinate the timing of the backup, restore, and streaming
+	// operations.
 	source, cleanup, err := stdpool.OpenPgxAsConn(ctx, config.SourceConn)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not connect to source database")
@@ -72,32 +82,62 @@ func ProvideDialect(
 	}
 	log.Tracef("validated that publication %q exists", config.Publication)
 
-	// Verify that the consumer slot exists.
-	if err := source.QueryRow(ctx,
-		"SELECT count(*) FROM pg_replication_slots WHERE slot_name = $1",
-		config.Slot,
-	).Scan(&count); err != nil {
-		return nil, errors.WithStack(err)
-	}
-	if count != 1 {
-		return nil, errors.Errorf(
-			"run SELECT pg_create_logical_replication_slot('%s', 'pgoutput'); in source database, "+
-				"then perform bulk data copy",
-			config.Slot)
+	// Verify that the consumer slot exists. In snapshot mode, the slot
+	// will be created when the snapshot is taken.
+	if !config.Snapshot {
+		if err := source.QueryRow(ctx,
+			"SELECT count(*) FROM pg_replication_slots WHERE slot_name = $1",
+			config.Slot,
+		).Scan(&count); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if count != 1 {
+			return nil, errors.Errorf(
+				"run SELECT pg_create_logical_replication_slot('%s', 'pgoutput'); in source database, "+
+					"then perform bulk data copy, or use --snapshot",
+				config.Slot)
+		}
+		log.Tracef("validated that replication slot %q exists", config.Slot)
 	}
-	log.Tracef("validated that replication slot %q exists", config.Slot)
 
 	// Copy the configuration and tweak it for replication behavior.
 	sourceConfig := source.Config().Config.Copy()
 	sourceConfig.RuntimeParams["replication"] = "database"
 
-	return &conn{
-		columns:         &ident.TableMap[[]types.ColData]{},
-		publicationName: config.Publication,
-		relations:       make(map[uint32]ident.Table),
-		slotName:        config.Slot,
-		sourceConfig:    sourceConfig,
-	}, nil
+	ret := &conn{
+		columns:           &ident.TableMap[[]types.ColData]{},
+		dlqs:              dlqs,
+		memo:              memo,
+		publicationName:   config.Publication,
+		relations:         make(map[uint32]ident.Table),
+		slotName:          config.Slot,
+		snapshotChunkSize: config.SnapshotChunkSize,
+		snapshotKey:       config.LoopName + "-snapshot",
+		sourceColumns:     &ident.TableMap[[]ddl.Column]{},
+		sourceConfig:      sourceConfig,
+		sourceConn:        config.SourceConn,
+		stagingPool:       stagingPool,
+		targetPool:        targetPool,
+		truncateDLQ:       config.TruncateDLQ,
+		truncateMode:      config.TruncateMode,
+	}
+	if config.PropagateDDL {
+		ret.ddl = ddls
+	}
+	if config.StreamTransactions {
+		ret.streams, err = newStreamBuffer(
+			ctx, stagingPool, stagingSchema, config.Slot, config.BytesInFlight)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Only advertise the Backfiller capability when requested, since
+	// the logical loop would otherwise choose to backfill when it
+	// starts from a zero-valued consistent point.
+	if config.Snapshot {
+		return &snapshotConn{ret}, nil
+	}
+	return ret, nil
 }
 
 // ProvideLoop is called by Wire to construct the sole logical loop used
@@ -0,0 +1,581 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdpool"
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// errSnapshotStopping is returned from copyTable when the loop is
+// being stopped.
+var errSnapshotStopping = errors.New("snapshot stopping")
+
+// snapshotProgress is stored in the memo table to allow an interrupted
+// snapshot to be resumed.
+type snapshotProgress struct {
+	// The position in the replication slot at which streaming begins
+	// once all tables have been copied.
+	LSN pglogrepl.LSN `json:"lsn"`
+	// Tables which have been completely copied.
+	Done []string `json:"done,omitempty"`
+	// The table which is currently being copied.
+	Table string `json:"table,omitempty"`
+	// The primary key of the last row copied from Table.
+	Key []string `json:"key,omitempty"`
+}
+
+// isDone returns true if the table has been completely copied.
+func (p *snapshotProgress) isDone(table string) bool {
+	for _, done := range p.Done {
+		if done == table {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotChunk is sent from BackfillInto to Process. It contains a
+// chunk of rows which were read from a single table and the progress
+// that should be recorded once the rows have been applied.
+type snapshotChunk struct {
+	muts     []types.Mutation
+	progress snapshotProgress
+	target   ident.Table
+}
+
+// snapshotComplete is sent from BackfillInto to Process once every
+// table has been copied.
+type snapshotComplete struct {
+	lsn pglogrepl.LSN
+}
+
+// snapshotColumn describes a column in a source table.
+type snapshotColumn struct {
+	name    string
+	primary bool
+	pkPos   int // The one-based position of the column in the primary key.
+	typ     string
+}
+
+// snapshotConn adds an initial-load mode to conn. It is only used when
+// [Config.Snapshot] is set, so that the logical loop won't attempt to
+// backfill otherwise.
+type snapshotConn struct {
+	*conn
+}
+
+var _ logical.Backfiller = (*snapshotConn)(nil)
+
+// BackfillInto implements logical.Backfiller. If the loop has not yet
+// reached a consistent point, the replication slot will be created
+// with an exported snapshot. Each table in the publication is then read
+// under that snapshot, in primary-key order. Once all tables have been
+// copied, the consistent point is advanced to the slot's starting
+// position so that the loop will hand off to streaming.
+//
+// If the snapshot is interrupted, it will resume from the last chunk
+// of rows that was applied to the target. The exported snapshot does
+// not outlive the connection that created it, so the resumed copy is
+// performed using a newer snapshot. This is safe, since streaming
+// from the slot's starting position will replay any changes which
+// are already reflected in the newer snapshot.
+//
+// Tables without a primary key are rejected. Their rows are assigned a
+// random identity, so an interrupted copy could not be resumed without
+// duplicating the rows that had already been applied.
+func (c *snapshotConn) BackfillInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	// If the snapshot has been completed, we're simply behind in
+	// consuming the replication slot.
+	cp, _ := state.GetConsistentPoint()
+	if x, ok := cp.(*lsnStamp); ok && x.AsLSN() != 0 {
+		return c.ReadInto(ctx, ch, state)
+	}
+
+	source, cleanup, err := stdpool.OpenPgxAsConn(ctx, c.sourceConn)
+	if err != nil {
+		return errors.Wrap(err, "could not connect to source database")
+	}
+	defer cleanup()
+
+	progress, err := c.loadSnapshotProgress(ctx)
+	if err != nil {
+		return err
+	}
+
+	var slotLSN *string
+	err = source.QueryRow(ctx,
+		"SELECT confirmed_flush_lsn::TEXT FROM pg_replication_slots WHERE slot_name = $1",
+		c.slotName,
+	).Scan(&slotLSN)
+	slotExists := true
+	if errors.Is(err, pgx.ErrNoRows) {
+		slotExists = false
+	} else if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var snapshotName string
+	if slotExists {
+		// The slot was created by a previous attempt or by the user.
+		if progress.LSN == 0 {
+			if slotLSN == nil {
+				return errors.Errorf("replication slot %q has no confirmed position", c.slotName)
+			}
+			progress.LSN, err = pglogrepl.ParseLSN(*slotLSN)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if err := c.storeSnapshotProgress(ctx, progress); err != nil {
+				return err
+			}
+		}
+		log.WithFields(log.Fields{
+			"lsn":  progress.LSN,
+			"slot": c.slotName,
+		}).Info("resuming snapshot using existing replication slot")
+	} else {
+		if progress.LSN != 0 {
+			log.WithField("slot", c.slotName).Warn(
+				"replication slot was dropped during snapshot; restarting")
+			progress = &snapshotProgress{}
+		}
+		// The exported snapshot is only valid while the replication
+		// connection remains open and idle.
+		replConn, err := pgconn.ConnectConfig(ctx, c.sourceConfig)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer replConn.Close(context.Background())
+
+		res, err := pglogrepl.CreateReplicationSlot(ctx, replConn, c.slotName, "pgoutput",
+			pglogrepl.CreateReplicationSlotOptions{
+				Mode:           pglogrepl.LogicalReplication,
+				SnapshotAction: "EXPORT_SNAPSHOT",
+			})
+		if err != nil {
+			return errors.Wrapf(err, "could not create replication slot %q", c.slotName)
+		}
+		progress.LSN, err = pglogrepl.ParseLSN(res.ConsistentPoint)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		snapshotName = res.SnapshotName
+		if err := c.storeSnapshotProgress(ctx, progress); err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"lsn":      progress.LSN,
+			"slot":     c.slotName,
+			"snapshot": snapshotName,
+		}).Info("created replication slot with exported snapshot")
+	}
+
+	tx, err := source.BeginTx(ctx, pgx.TxOptions{
+		AccessMode: pgx.ReadOnly,
+		IsoLevel:   pgx.RepeatableRead,
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = tx.Rollback(context.Background()) }()
+	if snapshotName != "" {
+		// The snapshot name is generated by the server and can't be
+		// passed as a parameter.
+		if _, err := tx.Exec(ctx,
+			fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", snapshotName),
+		); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	tables, err := c.snapshotTables(ctx, tx)
+	if err != nil {
+		return err
+	}
+	// Check every remaining table before copying any rows.
+	cols := make([][]snapshotColumn, len(tables))
+	for idx, tbl := range tables {
+		if progress.isDone(tbl.Sanitize()) {
+			continue
+		}
+		cols[idx], err = c.snapshotColumns(ctx, tx, tbl)
+		if err != nil {
+			return err
+		}
+	}
+	for idx, tbl := range tables {
+		if progress.isDone(tbl.Sanitize()) {
+			log.WithField("table", tbl.Sanitize()).Debug("table already copied")
+			continue
+		}
+		if err := c.copyTable(ctx, tx, ch, state, tbl, cols[idx], progress); err != nil {
+			if errors.Is(err, errSnapshotStopping) {
+				return nil
+			}
+			return err
+		}
+		progress.Done = append(progress.Done, tbl.Sanitize())
+		progress.Key = nil
+		progress.Table = ""
+	}
+
+	select {
+	case ch <- &snapshotComplete{progress.LSN}:
+	case <-state.Stopping():
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+
+	// Wait for the loop to switch to streaming mode once the consistent
+	// point has been advanced.
+	select {
+	case <-state.Stopping():
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+// copyTable reads the table in primary-key order, sending chunks of
+// rows to the channel.
+func (c *snapshotConn) copyTable(
+	ctx context.Context,
+	tx pgx.Tx,
+	ch chan<- logical.Message,
+	state logical.State,
+	tbl pgx.Identifier,
+	cols []snapshotColumn,
+	progress *snapshotProgress,
+) error {
+	name := tbl.Sanitize()
+	target := ident.NewTable(state.GetTargetDB(), ident.New(tbl[1]))
+
+	var pks []snapshotColumn
+	selects := make([]string, len(cols))
+	for idx, col := range cols {
+		selects[idx] = fmt.Sprintf("%s::TEXT", pgx.Identifier{col.name}.Sanitize())
+		if col.primary {
+			pks = append(pks, col)
+		}
+	}
+	// Page through the table in primary-key order, so that the index
+	// can be used.
+	sort.Slice(pks, func(i, j int) bool { return pks[i].pkPos < pks[j].pkPos })
+
+	// Pick up where we left off, if the table was partially copied.
+	var lastKey []string
+	if progress.Table == name && len(progress.Key) == len(pks) {
+		lastKey = progress.Key
+	}
+	log.WithFields(log.Fields{
+		"resume": lastKey,
+		"table":  name,
+	}).Info("copying table from snapshot")
+
+	// send passes a chunk of rows to Process. The key will be nil if
+	// the table has been completely copied.
+	send := func(muts []types.Mutation, key []string) error {
+		chunk := &snapshotChunk{
+			muts: muts,
+			progress: snapshotProgress{
+				LSN:  progress.LSN,
+				Done: append([]string(nil), progress.Done...),
+			},
+			target: target,
+		}
+		if key == nil {
+			chunk.progress.Done = append(chunk.progress.Done, name)
+		} else {
+			chunk.progress.Table = name
+			chunk.progress.Key = key
+		}
+		select {
+		case ch <- chunk:
+			snapshotRowCount.Add(float64(len(muts)))
+			return nil
+		case <-state.Stopping():
+			return errSnapshotStopping
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		}
+	}
+
+	for {
+		q, args := c.snapshotQuery(name, selects, pks, lastKey)
+		rows, err := tx.Query(ctx, q, args...)
+		if err != nil {
+			return errors.Wrapf(err, "could not read from %s", name)
+		}
+
+		var key []string
+		var muts []types.Mutation
+		count := 0
+		for rows.Next() {
+			vals := make([]*string, len(cols))
+			dest := make([]any, len(cols))
+			for idx := range vals {
+				dest[idx] = &vals[idx]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return errors.WithStack(err)
+			}
+			var mut types.Mutation
+			mut, key, err = snapshotMutation(target, cols, pks, vals)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			muts = append(muts, mut)
+			count++
+		}
+		if err := rows.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+
+		if count < c.snapshotChunkSize {
+			return send(muts, nil)
+		}
+		if err := send(muts, key); err != nil {
+			return err
+		}
+		lastKey = key
+	}
+}
+
+// snapshotQuery returns a query which reads the next chunk of rows from
+// a table.
+func (c *snapshotConn) snapshotQuery(
+	table string, selects []string, pks []snapshotColumn, lastKey []string,
+) (string, []any) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "SELECT %s FROM %s", strings.Join(selects, ", "), table)
+
+	names := make([]string, len(pks))
+	for idx, pk := range pks {
+		names[idx] = pgx.Identifier{pk.name}.Sanitize()
+	}
+	var args []any
+	if lastKey != nil {
+		params := make([]string, len(pks))
+		for idx, pk := range pks {
+			params[idx] = fmt.Sprintf("$%d::TEXT::%s", idx+1, pk.typ)
+			args = append(args, lastKey[idx])
+		}
+		fmt.Fprintf(&sb, " WHERE (%s) > (%s)",
+			strings.Join(names, ", "), strings.Join(params, ", "))
+	}
+	fmt.Fprintf(&sb, " ORDER BY %s LIMIT %d", strings.Join(names, ", "), c.snapshotChunkSize)
+	return sb.String(), args
+}
+
+// snapshotColumns returns the replicated columns of the table in their
+// ordinal order. An error is returned if the table has no primary key.
+func (c *snapshotConn) snapshotColumns(
+	ctx context.Context, tx pgx.Tx, tbl pgx.Identifier,
+) ([]snapshotColumn, error) {
+	// Generated columns aren't sent by pgoutput. The is_generated
+	// column is always present in information_schema, unlike
+	// pg_attribute.attgenerated.
+	rows, err := tx.Query(ctx, `
+SELECT a.attname, format_type(a.atttypid, a.atttypmod),
+       COALESCE((SELECT array_position(i.indkey, a.attnum) FROM pg_index i
+                  WHERE i.indrelid = a.attrelid AND i.indisprimary), 0)
+  FROM pg_attribute a
+  JOIN information_schema.columns c
+    ON c.table_schema = $1 AND c.table_name = $2 AND c.column_name = a.attname
+ WHERE a.attrelid = $3::REGCLASS AND a.attnum > 0 AND NOT a.attisdropped
+   AND c.is_generated <> 'ALWAYS'
+ ORDER BY a.attnum`,
+		tbl[0], tbl[1], tbl.Sanitize())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	var ret []snapshotColumn
+	for rows.Next() {
+		var col snapshotColumn
+		if err := rows.Scan(&col.name, &col.typ, &col.pkPos); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		col.primary = col.pkPos > 0
+		ret = append(ret, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(ret) == 0 {
+		return nil, errors.Errorf("no columns found for %s", tbl.Sanitize())
+	}
+	for _, col := range ret {
+		if col.primary {
+			return ret, nil
+		}
+	}
+	return nil, errors.Errorf(
+		"%s has no primary key and cannot be copied from a snapshot", tbl.Sanitize())
+}
+
+// snapshotTables returns the tables that are included in the
+// publication.
+func (c *snapshotConn) snapshotTables(ctx context.Context, tx pgx.Tx) ([]pgx.Identifier, error) {
+	rows, err := tx.Query(ctx,
+		"SELECT schemaname, tablename FROM pg_publication_tables "+
+			"WHERE pubname = $1 ORDER BY schemaname, tablename",
+		c.publicationName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	var ret []pgx.Identifier
+	for rows.Next() {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret = append(ret, pgx.Identifier{schema, table})
+	}
+	return ret, errors.WithStack(rows.Err())
+}
+
+// snapshotMutation converts a row into a mutation. The primary key of
+// the row is returned, in the order of the pks, to record the progress
+// of the copy.
+func snapshotMutation(
+	tbl ident.Table, cols, pks []snapshotColumn, vals []*string,
+) (types.Mutation, []string, error) {
+	var mut types.Mutation
+	var mutKey []string
+	enc := make(map[string]any, len(cols))
+	for idx, col := range cols {
+		if vals[idx] == nil {
+			enc[col.name] = nil
+			continue
+		}
+		enc[col.name] = *vals[idx]
+		// As with decodeMutation, the key is in column order.
+		if col.primary {
+			mutKey = append(mutKey, *vals[idx])
+		}
+	}
+	var key []string
+	for _, pk := range pks {
+		if val, ok := enc[pk.name].(string); ok {
+			key = append(key, val)
+		}
+	}
+
+	var err error
+	mut.Key, err = json.Marshal(mutKey)
+	if err != nil {
+		return mut, nil, errors.WithStack(err)
+	}
+	mut.Data, err = json.Marshal(enc)
+	if err != nil {
+		return mut, nil, errors.WithStack(err)
+	}
+	script.AddMeta("pglogical", tbl, &mut)
+	return mut, key, nil
+}
+
+// onSnapshotChunk applies the rows in the chunk and then records the
+// progress of the snapshot.
+func (c *conn) onSnapshotChunk(
+	ctx context.Context, events logical.Events, chunk *snapshotChunk,
+) error {
+	if len(chunk.muts) > 0 {
+		batch, err := events.OnBegin(ctx)
+		if err != nil {
+			return err
+		}
+		if err := batch.OnData(ctx,
+			script.SourceName(chunk.target), chunk.target, chunk.muts,
+		); err != nil {
+			_ = batch.OnRollback(ctx)
+			return err
+		}
+		select {
+		case err := <-batch.OnCommit(ctx):
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return c.storeSnapshotProgress(ctx, &chunk.progress)
+}
+
+// onSnapshotComplete advances the consistent point to the position in
+// the replication slot at which the snapshot was taken.
+func (c *conn) onSnapshotComplete(
+	ctx context.Context, events logical.Events, msg *snapshotComplete,
+) error {
+	if err := events.SetConsistentPoint(ctx, &lsnStamp{msg.lsn, time.Now()}); err != nil {
+		return err
+	}
+	log.WithField("lsn", msg.lsn).Info("snapshot complete; switching to streaming")
+	// Clear the progress, in case the loop is ever reset.
+	return c.storeSnapshotProgress(ctx, &snapshotProgress{})
+}
+
+// loadSnapshotProgress returns the progress of the snapshot, which will
+// be zero-valued if no snapshot has been started.
+func (c *conn) loadSnapshotProgress(ctx context.Context) (*snapshotProgress, error) {
+	ret := &snapshotProgress{}
+	data, err := c.memo.Get(ctx, c.stagingPool, c.snapshotKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return ret, nil
+	}
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, errors.Wrapf(err, "could not decode snapshot progress %q", c.snapshotKey)
+	}
+	return ret, nil
+}
+
+// storeSnapshotProgress records the progress of the snapshot.
+func (c *conn) storeSnapshotProgress(ctx context.Context, progress *snapshotProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return c.memo.Put(ctx, c.stagingPool, c.snapshotKey, data)
+}
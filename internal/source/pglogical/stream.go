@@ -0,0 +1,549 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pglogical
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/batches"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/retry"
+	"github.com/jackc/pglogrepl"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// streamStart begins a block of changes made by a transaction that has
+// not yet committed.
+type streamStart struct {
+	firstSegment bool   // True for the first block of the transaction.
+	xid          uint32 // The top-level transaction id.
+}
+
+// streamStop ends a block of changes started by a streamStart.
+type streamStop struct{}
+
+// streamCommit indicates that a streamed transaction has committed.
+type streamCommit struct {
+	commitLSN  pglogrepl.LSN
+	commitTime time.Time
+	endLSN     pglogrepl.LSN
+	xid        uint32
+}
+
+// streamAbort indicates that a streamed transaction, or one of its
+// subtransactions, has rolled back.
+type streamAbort struct {
+	subXID uint32 // Equal to xid if the whole transaction was aborted.
+	xid    uint32
+}
+
+// streamData contains a change made by a streamed transaction.
+type streamData struct {
+	msg    pglogrepl.Message
+	subXID uint32 // The (sub)transaction that made the change.
+}
+
+// The message types that are only sent by version 2 of the protocol.
+const (
+	streamAbortByte  = 'A'
+	streamCommitByte = 'c'
+	streamStartByte  = 'S'
+	streamStopByte   = 'E'
+)
+
+// pgEpoch is the origin of PostgreSQL timestamps.
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// A streamDecoder parses the messages sent by version 2 of the
+// pgoutput protocol. Within a block of streamed changes, each message
+// includes the id of the transaction that sent it. The messages are
+// otherwise identical to their version 1 counterparts, so they are
+// parsed by pglogrepl once the transaction id has been removed.
+type streamDecoder struct {
+	inStream bool
+}
+
+// decode parses a logical replication message.
+func (d *streamDecoder) decode(data []byte) (logical.Message, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty logical replication message")
+	}
+	body := data[1:]
+	short := func(want int) error {
+		return errors.Errorf("message %q has %d bytes, expecting %d",
+			string(data[0]), len(body), want)
+	}
+
+	switch data[0] {
+	case streamStartByte:
+		if len(body) < 5 {
+			return nil, short(5)
+		}
+		d.inStream = true
+		return &streamStart{
+			firstSegment: body[4] == 1,
+			xid:          binary.BigEndian.Uint32(body),
+		}, nil
+
+	case streamStopByte:
+		d.inStream = false
+		return &streamStop{}, nil
+
+	case streamCommitByte:
+		// Transaction id, flags, commit LSN, end LSN, commit time.
+		if len(body) < 29 {
+			return nil, short(29)
+		}
+		micros := int64(binary.BigEndian.Uint64(body[21:]))
+		return &streamCommit{
+			commitLSN:  pglogrepl.LSN(binary.BigEndian.Uint64(body[5:])),
+			commitTime: pgEpoch.Add(time.Duration(micros) * time.Microsecond),
+			endLSN:     pglogrepl.LSN(binary.BigEndian.Uint64(body[13:])),
+			xid:        binary.BigEndian.Uint32(body),
+		}, nil
+
+	case streamAbortByte:
+		if len(body) < 8 {
+			return nil, short(8)
+		}
+		return &streamAbort{
+			subXID: binary.BigEndian.Uint32(body[4:]),
+			xid:    binary.BigEndian.Uint32(body),
+		}, nil
+	}
+
+	if !d.inStream {
+		msg, err := pglogrepl.Parse(data)
+		return msg, errors.WithStack(err)
+	}
+	if len(body) < 4 {
+		return nil, short(4)
+	}
+	subXID := binary.BigEndian.Uint32(body)
+	stripped := make([]byte, 0, len(data)-4)
+	stripped = append(stripped, data[0])
+	stripped = append(stripped, body[4:]...)
+	msg, err := pglogrepl.Parse(stripped)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch msg.(type) {
+	case *pglogrepl.DeleteMessage, *pglogrepl.InsertMessage,
+		*pglogrepl.TruncateMessage, *pglogrepl.UpdateMessage:
+		return &streamData{msg: msg, subXID: subXID}, nil
+	default:
+		// Relation and type messages describe the schema and are
+		// processed immediately.
+		return msg, nil
+	}
+}
+
+// onStreamCommit applies the changes made by a streamed transaction in
+// a single batch and then advances the consistent point. The mutations
+// in each page of replayed changes are passed to the batch together,
+// grouped by table.
+func (c *conn) onStreamCommit(
+	ctx context.Context, events logical.Events, msg *streamCommit,
+) error {
+	batch, err := events.OnBegin(ctx)
+	if err != nil {
+		return err
+	}
+	var truncated []ident.Table
+	err = c.streams.replay(ctx, msg.xid, func(page []*streamChange) error {
+		var order []ident.Table
+		pending := &ident.TableMap[[]types.Mutation]{}
+		flush := func() error {
+			for _, tbl := range order {
+				muts, _ := pending.Get(tbl)
+				if err := batch.OnData(ctx, script.SourceName(tbl), tbl, muts); err != nil {
+					return err
+				}
+			}
+			order = nil
+			pending = &ident.TableMap[[]types.Mutation]{}
+			return nil
+		}
+
+		for _, chg := range page {
+			if chg.truncate {
+				// Apply any preceding changes before the truncation.
+				if err := flush(); err != nil {
+					return err
+				}
+				tbls, err := c.applyTruncate(ctx, batch, []ident.Table{chg.table})
+				truncated = append(truncated, tbls...)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			mut := chg.mut
+			script.AddMeta("pglogical", chg.table, &mut)
+			muts, ok := pending.Get(chg.table)
+			if !ok {
+				order = append(order, chg.table)
+			}
+			pending.Put(chg.table, append(muts, mut))
+		}
+		return flush()
+	})
+	if err != nil {
+		_ = batch.OnRollback(ctx)
+		return err
+	}
+	select {
+	case err := <-batch.OnCommit(ctx):
+		if err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if len(truncated) > 0 {
+		if err := c.enqueueTruncates(ctx, truncated, msg.commitLSN, msg.commitTime); err != nil {
+			return err
+		}
+	}
+	if err := events.SetConsistentPoint(ctx, &lsnStamp{msg.commitLSN, msg.commitTime}); err != nil {
+		return err
+	}
+	return c.streams.remove(ctx, msg.xid)
+}
+
+// onStreamData decodes a change made by a streamed transaction and
+// adds it to the stream buffer.
+func (c *conn) onStreamData(ctx context.Context, msg *streamData) error {
+	var relation uint32
+	var tuple *pglogrepl.TupleData
+	var isDelete bool
+	switch t := msg.msg.(type) {
+	case *pglogrepl.DeleteMessage:
+		relation, tuple, isDelete = t.RelationID, t.OldTuple, true
+	case *pglogrepl.InsertMessage:
+		relation, tuple = t.RelationID, t.Tuple
+	case *pglogrepl.UpdateMessage:
+		relation, tuple = t.RelationID, t.NewTuple
+	case *pglogrepl.TruncateMessage:
+		tbls, err := c.truncatedTables(t)
+		if err != nil {
+			return err
+		}
+		for _, tbl := range tbls {
+			if err := c.streams.add(ctx, &streamChange{
+				subXID:   msg.subXID,
+				table:    tbl,
+				truncate: true,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return errors.Errorf("unimplemented streamed message %T", msg.msg)
+	}
+
+	traceTuple(tuple)
+	tbl, ok := c.relations[relation]
+	if !ok {
+		return errors.Errorf("unknown relation id %d", relation)
+	}
+	mut, err := c.decodeMutation(tbl, tuple, isDelete)
+	if err != nil {
+		return err
+	}
+	return c.streams.add(ctx, &streamChange{mut: mut, subXID: msg.subXID, table: tbl})
+}
+
+// A streamChange is a change made by a streamed transaction.
+type streamChange struct {
+	mut      types.Mutation // Unused for a TRUNCATE.
+	seq      int64          // Orders the changes within a transaction.
+	subXID   uint32         // The (sub)transaction that made the change.
+	table    ident.Table
+	truncate bool
+}
+
+func (c *streamChange) size() int {
+	return len(c.mut.Key) + len(c.mut.Data)
+}
+
+// A streamTx holds the changes made by a streamed transaction.
+type streamTx struct {
+	buffered []*streamChange // Changes that have not been spilled.
+	nextSeq  int64
+	spilled  bool // Set once changes have been written to the spill table.
+}
+
+// A streamBuffer holds the changes made by streamed transactions until
+// they commit or abort. Once the buffered changes reach the in-flight
+// limit, they are spilled to a table in the staging database.
+type streamBuffer struct {
+	bytes   int       // The size of all buffered changes.
+	current *streamTx // The transaction in the current block, if any.
+	limit   int       // The maximum number of bytes to buffer.
+	pool    *types.StagingPool
+	slot    string // Isolates the rows of other replication slots.
+	sql     struct {
+		deleteSlot string
+		deleteSub  string
+		deleteTx   string
+		insert     string
+		read       string
+	}
+	txs map[uint32]*streamTx
+}
+
+const (
+	streamSchema = `
+CREATE TABLE IF NOT EXISTS %[1]s (
+  slot     STRING NOT NULL,
+  xid      INT8   NOT NULL,
+  seq      INT8   NOT NULL,
+  sub_xid  INT8   NOT NULL,
+  target   STRING NOT NULL,
+  key      BYTES  NOT NULL,
+  data     BYTES  NOT NULL,
+  truncate BOOL   NOT NULL,
+  PRIMARY KEY (slot, xid, seq)
+)`
+	streamDeleteSlotTemplate = `DELETE FROM %[1]s WHERE slot = $1`
+	streamDeleteSubTemplate  = `DELETE FROM %[1]s WHERE slot = $1 AND xid = $2 AND sub_xid = $3`
+	streamDeleteTxTemplate   = `DELETE FROM %[1]s WHERE slot = $1 AND xid = $2`
+	streamInsertTemplate     = `
+UPSERT INTO %[1]s (slot, xid, seq, sub_xid, target, key, data, truncate)
+SELECT $1, $2, unnest($3::INT8[]), unnest($4::INT8[]), unnest($5::STRING[]),
+       unnest($6::BYTES[]), unnest($7::BYTES[]), unnest($8::BOOL[])`
+	streamReadTemplate = `
+SELECT seq, sub_xid, target, key, data, truncate FROM %[1]s
+WHERE slot = $1 AND xid = $2 AND seq > $3
+ORDER BY seq
+LIMIT $4`
+)
+
+// newStreamBuffer creates the spill table, if necessary.
+func newStreamBuffer(
+	ctx context.Context,
+	pool *types.StagingPool,
+	staging ident.StagingSchema,
+	slot string,
+	limit int,
+) (*streamBuffer, error) {
+	tbl := ident.NewTable(staging.Schema(), ident.New("pglogical_streams"))
+	if err := retry.Execute(ctx, pool, fmt.Sprintf(streamSchema, tbl)); err != nil {
+		return nil, err
+	}
+	ret := &streamBuffer{
+		limit: limit,
+		pool:  pool,
+		slot:  slot,
+		txs:   make(map[uint32]*streamTx),
+	}
+	ret.sql.deleteSlot = fmt.Sprintf(streamDeleteSlotTemplate, tbl)
+	ret.sql.deleteSub = fmt.Sprintf(streamDeleteSubTemplate, tbl)
+	ret.sql.deleteTx = fmt.Sprintf(streamDeleteTxTemplate, tbl)
+	ret.sql.insert = fmt.Sprintf(streamInsertTemplate, tbl)
+	ret.sql.read = fmt.Sprintf(streamReadTemplate, tbl)
+	return ret, nil
+}
+
+// abort discards the changes made by a transaction or one of its
+// subtransactions.
+func (b *streamBuffer) abort(ctx context.Context, msg *streamAbort) error {
+	if msg.subXID == msg.xid {
+		return b.remove(ctx, msg.xid)
+	}
+	tx, ok := b.txs[msg.xid]
+	if !ok {
+		return nil
+	}
+	kept := tx.buffered[:0]
+	for _, chg := range tx.buffered {
+		if chg.subXID == msg.subXID {
+			b.bytes -= chg.size()
+		} else {
+			kept = append(kept, chg)
+		}
+	}
+	tx.buffered = kept
+	if !tx.spilled {
+		return nil
+	}
+	return retry.Execute(ctx, b.pool, b.sql.deleteSub, b.slot, int64(msg.xid), int64(msg.subXID))
+}
+
+// add appends a change to the transaction in the current block.
+func (b *streamBuffer) add(ctx context.Context, chg *streamChange) error {
+	tx := b.current
+	if tx == nil {
+		return errors.New("received streamed change outside of a stream block")
+	}
+	chg.seq = tx.nextSeq
+	tx.nextSeq++
+	tx.buffered = append(tx.buffered, chg)
+	b.bytes += chg.size()
+	if b.bytes < b.limit {
+		return nil
+	}
+	return b.spill(ctx)
+}
+
+// remove discards a transaction and any changes that it has spilled.
+func (b *streamBuffer) remove(ctx context.Context, xid uint32) error {
+	tx, ok := b.txs[xid]
+	if !ok {
+		return nil
+	}
+	delete(b.txs, xid)
+	if b.current == tx {
+		b.current = nil
+	}
+	for _, chg := range tx.buffered {
+		b.bytes -= chg.size()
+	}
+	if !tx.spilled {
+		return nil
+	}
+	return retry.Execute(ctx, b.pool, b.sql.deleteTx, b.slot, int64(xid))
+}
+
+// replay calls the function with pages of the changes made by the
+// transaction, in the order in which they were made.
+func (b *streamBuffer) replay(
+	ctx context.Context, xid uint32, fn func([]*streamChange) error,
+) error {
+	tx, ok := b.txs[xid]
+	if !ok {
+		return nil
+	}
+
+	if tx.spilled {
+		after := int64(-1)
+		for {
+			var page []*streamChange
+			err := retry.Retry(ctx, func(ctx context.Context) error {
+				page = page[:0]
+				rows, err := b.pool.Query(ctx, b.sql.read, b.slot, int64(xid), after, batches.Size())
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				defer rows.Close()
+				for rows.Next() {
+					var subXID int64
+					var target string
+					chg := &streamChange{}
+					if err := rows.Scan(&chg.seq, &subXID, &target,
+						&chg.mut.Key, &chg.mut.Data, &chg.truncate); err != nil {
+						return errors.WithStack(err)
+					}
+					chg.subXID = uint32(subXID)
+					if chg.table, err = ident.ParseTable(target); err != nil {
+						return err
+					}
+					page = append(page, chg)
+				}
+				return errors.WithStack(rows.Err())
+			})
+			if err != nil {
+				return err
+			}
+			if len(page) == 0 {
+				break
+			}
+			if err := fn(page); err != nil {
+				return err
+			}
+			after = page[len(page)-1].seq
+		}
+	}
+
+	return batches.Batch(len(tx.buffered), func(begin, end int) error {
+		return fn(tx.buffered[begin:end])
+	})
+}
+
+// reset discards all streamed transactions. It is called when the
+// replication connection is restarted, since the source database will
+// resend the transactions from the beginning.
+func (b *streamBuffer) reset(ctx context.Context) error {
+	b.bytes = 0
+	b.current = nil
+	b.txs = make(map[uint32]*streamTx)
+	return retry.Execute(ctx, b.pool, b.sql.deleteSlot, b.slot)
+}
+
+// spill writes all buffered changes to the staging database.
+func (b *streamBuffer) spill(ctx context.Context) error {
+	for xid, tx := range b.txs {
+		if len(tx.buffered) == 0 {
+			continue
+		}
+		err := batches.Batch(len(tx.buffered), func(begin, end int) error {
+			chunk := tx.buffered[begin:end]
+			seqs := make([]int64, len(chunk))
+			subXIDs := make([]int64, len(chunk))
+			targets := make([]string, len(chunk))
+			keys := make([][]byte, len(chunk))
+			datas := make([][]byte, len(chunk))
+			truncates := make([]bool, len(chunk))
+			for idx, chg := range chunk {
+				seqs[idx] = chg.seq
+				subXIDs[idx] = int64(chg.subXID)
+				targets[idx] = chg.table.String()
+				keys[idx] = append([]byte{}, chg.mut.Key...)
+				datas[idx] = append([]byte{}, chg.mut.Data...)
+				truncates[idx] = chg.truncate
+			}
+			return retry.Execute(ctx, b.pool, b.sql.insert, b.slot, int64(xid),
+				seqs, subXIDs, targets, keys, datas, truncates)
+		})
+		if err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"count": len(tx.buffered),
+			"xid":   xid,
+		}).Debug("spilled streamed changes")
+		tx.buffered = nil
+		tx.spilled = true
+	}
+	b.bytes = 0
+	return nil
+}
+
+// start begins a block of changes for the transaction.
+func (b *streamBuffer) start(xid uint32) {
+	tx, ok := b.txs[xid]
+	if !ok {
+		tx = &streamTx{}
+		b.txs[xid] = tx
+	}
+	b.current = tx
+}
+
+// stop ends the current block of changes.
+func (b *streamBuffer) stop() {
+	b.current = nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pollinglogical
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultBatchSize    = 10_000
+	defaultPollInterval = time.Second
+)
+
+// Config adds dialect-specific configuration to the core logical loop.
+type Config struct {
+	logical.BaseConfig
+	logical.LoopConfig
+
+	// The maximum number of rows to read from a table at once.
+	BatchSize int
+	// How long to wait once all tables have been read.
+	PollInterval time.Duration
+	// Connection string for the source db.
+	SourceConn string
+	// The names of the tables to poll in the source database.
+	Tables []string
+	// The name of a table in the source database that contains
+	// tombstones for deleted rows.
+	TombstoneTable string
+	// The name of the column within TombstoneTable that stores a JSON
+	// array of the deleted row's primary key.
+	TombstoneKeyColumn ident.Ident
+	// The name of the column within TombstoneTable that stores the
+	// name of the table from which the row was deleted.
+	TombstoneTableColumn ident.Ident
+	// By default, tombstones that cannot be mapped onto a polled table
+	// will be rejected. Setting this property to true will ignore
+	// unmapped tombstones.
+	TombstoneIgnoreUnmapped bool
+	// The name of a column used for high-water marks. It must be
+	// present in all polled tables and in the tombstone table.
+	UpdatedAtColumn ident.Ident
+
+	// The fields below are extracted by Preflight.
+
+	tables    []ident.Table
+	tombstone ident.Table
+}
+
+// Bind adds flags to the set. It delegates to the embedded Config.Bind.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	c.BaseConfig.Bind(f)
+
+	c.LoopConfig.LoopName = "pollinglogical"
+	c.LoopConfig.Bind(f)
+
+	f.IntVar(&c.BatchSize, "batchSize", defaultBatchSize,
+		"the maximum number of rows to read from a table at once")
+	f.DurationVar(&c.PollInterval, "pollInterval", defaultPollInterval,
+		"how long to wait before polling again once all tables have been read")
+	f.StringVar(&c.SourceConn, "sourceConn", "",
+		"the source database's connection string")
+	f.StringSliceVar(&c.Tables, "table", nil,
+		"a table in the source database to poll; may be repeated")
+	f.StringVar(&c.TombstoneTable, "tombstoneTable", "",
+		"the name of a table in the source database that contains tombstones for deleted rows")
+	// NB: Keep default value in sync with doc on tombstones.
+	f.Var(ident.NewValue("row_key", &c.TombstoneKeyColumn), "tombstoneKeyColumn",
+		"the column in a tombstone that contains a JSON array of the deleted row's primary key")
+	// NB: Keep default value in sync with doc on tombstones.
+	f.Var(ident.NewValue("table_name", &c.TombstoneTableColumn), "tombstoneTableColumn",
+		"the column in a tombstone that contains the name of the table the row was deleted from")
+	f.BoolVar(&c.TombstoneIgnoreUnmapped, "tombstoneIgnoreUnmapped", false,
+		"skip, rather than reject, any tombstones that do not map to a polled table")
+	// NB: Keep default value in sync with doc on tombstones.
+	f.Var(ident.NewValue("updated_at", &c.UpdatedAtColumn), "updatedAt",
+		"the name of a column used for high-water marks")
+}
+
+// Preflight adds additional checks to the base logical.Config.
+func (c *Config) Preflight() error {
+	if err := c.BaseConfig.Preflight(); err != nil {
+		return err
+	}
+	if err := c.LoopConfig.Preflight(); err != nil {
+		return err
+	}
+
+	if c.BatchSize == 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.BatchSize < 0 {
+		return errors.New("batch size must be >= 1")
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = defaultPollInterval
+	}
+	if c.PollInterval < 0 {
+		return errors.New("pollInterval must be positive")
+	}
+	if c.SourceConn == "" {
+		return errors.New("sourceConn must be set")
+	}
+	if len(c.Tables) == 0 {
+		return errors.New("at least one table must be specified")
+	}
+
+	c.tables = make([]ident.Table, len(c.Tables))
+	seen := &ident.Map[struct{}]{}
+	for idx, name := range c.Tables {
+		tbl, err := ident.ParseTable(name)
+		if err != nil {
+			return err
+		}
+		// Target tables are named after the source tables.
+		if _, dup := seen.Get(tbl.Table()); dup {
+			return errors.Errorf("duplicate table name %s", tbl.Table())
+		}
+		seen.Put(tbl.Table(), struct{}{})
+		c.tables[idx] = tbl
+	}
+
+	c.tombstone = ident.Table{}
+	if c.TombstoneTable != "" {
+		tbl, err := ident.ParseTable(c.TombstoneTable)
+		if err != nil {
+			return err
+		}
+		c.tombstone = tbl
+		if c.TombstoneKeyColumn.Empty() {
+			return errors.New("if tombstones are enabled, a key column name must be set")
+		}
+		if c.TombstoneTableColumn.Empty() {
+			return errors.New("if tombstones are enabled, a table column name must be set")
+		}
+	}
+
+	if c.UpdatedAtColumn.Empty() {
+		return errors.New("no updated_at column name given")
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pollinglogical
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/pkg/errors"
+)
+
+// A watermark records the greatest value of the updated-at column that
+// has been processed for a table. Exactly one field will be set,
+// depending on the type of value returned by the source database.
+type watermark struct {
+	Int  *int64     `json:"i,omitempty"` // Row versions or epoch counters.
+	Text string     `json:"s,omitempty"` // Textual timestamps, e.g. MySQL DATETIME.
+	Time *time.Time `json:"t,omitempty"` // Native timestamps.
+}
+
+// textLayouts are used to interpret textual watermarks as times.
+var textLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// newWatermark converts a value from the updated-at column.
+func newWatermark(value any) (*watermark, error) {
+	var i int64
+	switch t := value.(type) {
+	case nil:
+		return nil, errors.New("updated-at column is NULL")
+	case time.Time:
+		return &watermark{Time: &t}, nil
+	case int:
+		i = int64(t)
+	case int32:
+		i = int64(t)
+	case int64:
+		i = t
+	case uint32:
+		i = int64(t)
+	case uint64:
+		if t > math.MaxInt64 {
+			return nil, errors.Errorf("updated-at value %d out of range", t)
+		}
+		i = int64(t)
+	case float64:
+		if t != math.Trunc(t) || t < math.MinInt64 || t >= math.MaxInt64 {
+			return nil, errors.Errorf("updated-at value %g is not an integer", t)
+		}
+		i = int64(t)
+	case []byte:
+		return newWatermark(string(t))
+	case string:
+		if parsed, err := strconv.ParseInt(t, 10, 64); err == nil {
+			i = parsed
+			break
+		}
+		return &watermark{Text: t}, nil
+	default:
+		return nil, errors.Errorf("unsupported updated-at type %T", value)
+	}
+	return &watermark{Int: &i}, nil
+}
+
+// Equal returns true if the watermarks have the same value.
+func (w *watermark) Equal(o *watermark) bool {
+	return !w.Less(o) && !o.Less(w)
+}
+
+// Less returns true if the watermark should be sorted before the
+// other. Watermarks of different types are not comparable.
+func (w *watermark) Less(o *watermark) bool {
+	switch {
+	case w.Int != nil && o.Int != nil:
+		return *w.Int < *o.Int
+	case w.Time != nil && o.Time != nil:
+		return w.Time.Before(*o.Time)
+	case w.Int == nil && w.Time == nil && o.Int == nil && o.Time == nil:
+		return strings.Compare(w.Text, o.Text) < 0
+	default:
+		return false
+	}
+}
+
+// HLC converts the watermark into a timestamp for a mutation.
+func (w *watermark) HLC() (hlc.Time, error) {
+	switch {
+	case w.Int != nil:
+		return hlc.New(*w.Int, 0), nil
+	case w.Time != nil:
+		return hlc.New(w.Time.UnixNano(), 0), nil
+	}
+	for _, layout := range textLayouts {
+		if ts, err := time.Parse(layout, w.Text); err == nil {
+			return hlc.New(ts.UnixNano(), 0), nil
+		}
+	}
+	return hlc.Zero(), errors.Errorf("cannot interpret updated-at value %q as a time", w.Text)
+}
+
+// Value returns the watermark as a query argument.
+func (w *watermark) Value() any {
+	switch {
+	case w.Int != nil:
+		return *w.Int
+	case w.Time != nil:
+		return *w.Time
+	default:
+		return w.Text
+	}
+}
+
+// A consistentPoint contains a watermark for each table that has been
+// polled. The map keys are the quoted names of the source tables.
+type consistentPoint struct {
+	Marks map[string]*watermark `json:"m,omitempty"`
+}
+
+var _ stamp.Stamp = (*consistentPoint)(nil)
+
+// Less implements stamp.Stamp. A consistentPoint is less than another
+// if at least one of its watermarks is behind and none are ahead.
+func (c *consistentPoint) Less(other stamp.Stamp) bool {
+	o := other.(*consistentPoint)
+	less := false
+	for name, mark := range c.Marks {
+		oMark, ok := o.Marks[name]
+		if !ok || oMark.Less(mark) {
+			return false
+		}
+		if mark.Less(oMark) {
+			less = true
+		}
+	}
+	for name := range o.Marks {
+		if _, ok := c.Marks[name]; !ok {
+			less = true
+		}
+	}
+	return less
+}
+
+// Mark returns the watermark for the table, or nil if the table has
+// not been polled.
+func (c *consistentPoint) Mark(table string) *watermark {
+	return c.Marks[table]
+}
+
+// WithMark returns a copy of the consistentPoint with an updated
+// watermark for the table.
+func (c *consistentPoint) WithMark(table string, mark *watermark) *consistentPoint {
+	ret := &consistentPoint{Marks: make(map[string]*watermark, len(c.Marks)+1)}
+	for k, v := range c.Marks {
+		ret.Marks[k] = v
+	}
+	ret.Marks[table] = mark
+	return ret
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pollinglogical
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatermark(t *testing.T) {
+	ts := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tcs := []struct {
+		value    any
+		expected any // The query argument.
+		hlc      hlc.Time
+		wantErr  string
+	}{
+		{value: int64(42), expected: int64(42), hlc: hlc.New(42, 0)},
+		{value: int32(42), expected: int64(42), hlc: hlc.New(42, 0)},
+		{value: float64(42), expected: int64(42), hlc: hlc.New(42, 0)},
+		{value: []byte("42"), expected: int64(42), hlc: hlc.New(42, 0)},
+		{value: ts, expected: ts, hlc: hlc.New(ts.UnixNano(), 0)},
+		{
+			value:    []byte("2023-10-01 12:00:00.000000"),
+			expected: "2023-10-01 12:00:00.000000",
+			hlc:      hlc.New(ts.UnixNano(), 0),
+		},
+		{value: "hello", expected: "hello", wantErr: "cannot interpret"},
+		{value: nil, wantErr: "NULL"},
+		{value: 1.5, wantErr: "not an integer"},
+		{value: true, wantErr: "unsupported updated-at type bool"},
+	}
+	for _, tc := range tcs {
+		t.Run(fmt.Sprintf("%T %v", tc.value, tc.value), func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
+
+			mark, err := newWatermark(tc.value)
+			if tc.expected == nil {
+				r.ErrorContains(err, tc.wantErr)
+				return
+			}
+			r.NoError(err)
+			a.Equal(tc.expected, mark.Value())
+
+			// Ensure that the watermark survives being persisted.
+			data, err := json.Marshal(mark)
+			r.NoError(err)
+			var decoded watermark
+			r.NoError(json.Unmarshal(data, &decoded))
+			a.True(mark.Equal(&decoded))
+
+			h, err := mark.HLC()
+			if tc.wantErr != "" {
+				a.ErrorContains(err, tc.wantErr)
+				return
+			}
+			r.NoError(err)
+			a.Equal(tc.hlc, h)
+		})
+	}
+}
+
+func TestConsistentPointLess(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+
+	mark := func(i int64) *watermark {
+		w, err := newWatermark(i)
+		r.NoError(err)
+		return w
+	}
+
+	zero := &consistentPoint{}
+	one := zero.WithMark("a", mark(1))
+	two := one.WithMark("a", mark(2))
+	both := two.WithMark("b", mark(1))
+	diverged := both.WithMark("a", mark(1)).WithMark("b", mark(2))
+
+	a.Nil(zero.Mark("a"))
+	a.Equal(mark(1), one.Mark("a"))
+	a.Equal(mark(2), two.Mark("a"), "WithMark should not modify the receiver")
+
+	a.True(zero.Less(one))
+	a.True(one.Less(two))
+	a.True(two.Less(both))
+	a.False(two.Less(one))
+	a.False(one.Less(one))
+	a.False(both.Less(diverged), "diverged watermarks are not ordered")
+	a.False(diverged.Less(both), "diverged watermarks are not ordered")
+
+	// Ensure that the consistent point survives being persisted.
+	data, err := json.Marshal(both)
+	r.NoError(err)
+	var decoded consistentPoint
+	r.NoError(json.Unmarshal(data, &decoded))
+	a.False(both.Less(&decoded))
+	a.False(decoded.Less(both))
+}
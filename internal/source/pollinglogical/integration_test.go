@@ -0,0 +1,169 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pollinglogical
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/require"
+)
+
+type fixtureConfig struct {
+	immediate bool
+}
+
+func TestPollingLogical(t *testing.T) {
+	t.Run("consistent", func(t *testing.T) { testPollingLogical(t, &fixtureConfig{}) })
+	t.Run("immediate", func(t *testing.T) {
+		testPollingLogical(t, &fixtureConfig{immediate: true})
+	})
+}
+
+func testPollingLogical(t *testing.T, fc *fixtureConfig) {
+	const batchSize = 10
+	const rowCount = 25
+	r := require.New(t)
+
+	fixture, cancel, err := all.NewFixture()
+	r.NoError(err)
+	defer cancel()
+
+	ctx := fixture.Context
+	sourceSchema := fixture.SourceSchema.Schema()
+
+	// An integer row version is used, since the syntax for timestamp
+	// literals varies between products.
+	const schema = "CREATE TABLE %s (pk INT PRIMARY KEY, val VARCHAR(32), updated_at INT)"
+	tgt, err := fixture.CreateTargetTable(ctx, schema)
+	r.NoError(err)
+	src := ident.NewTable(sourceSchema, tgt.Name().Table())
+	_, err = fixture.SourcePool.ExecContext(ctx, fmt.Sprintf(schema, src))
+	r.NoError(err)
+	tombstones := ident.NewTable(sourceSchema, ident.New(tgt.Name().Table().Raw()+"_tombstones"))
+	_, err = fixture.SourcePool.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE %s (table_name VARCHAR(256), row_key VARCHAR(256), updated_at INT)",
+		tombstones))
+	r.NoError(err)
+
+	exec := func(q string, args ...any) {
+		t.Helper()
+		_, err := fixture.SourcePool.ExecContext(ctx, fmt.Sprintf(q, args...))
+		r.NoError(err)
+	}
+	waitFor := func(query string, expected int) {
+		t.Helper()
+		for {
+			var count int
+			r.NoError(fixture.TargetPool.QueryRowContext(ctx,
+				fmt.Sprintf(query, tgt.Name())).Scan(&count))
+			if count == expected {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				r.NoError(ctx.Err())
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	// Every row has the same version, which is more than will fit in
+	// a single batch.
+	for i := 0; i < rowCount; i++ {
+		exec("INSERT INTO %s (pk, val, updated_at) VALUES (%d, 'v%d', 1)", src, i, i)
+	}
+
+	config := &Config{
+		BaseConfig: logical.BaseConfig{
+			ApplyTimeout:  2 * time.Minute, // Increase to make using the debugger easier.
+			Immediate:     fc.immediate,
+			RetryDelay:    time.Nanosecond,
+			StagingConn:   fixture.StagingPool.ConnectionString,
+			StagingSchema: fixture.StagingDB.Schema(),
+			TargetConn:    fixture.TargetPool.ConnectionString,
+		},
+		LoopConfig: logical.LoopConfig{
+			LoopName:     "pollingtest",
+			TargetSchema: fixture.TargetSchema.Schema(),
+		},
+		BatchSize:            batchSize,
+		PollInterval:         10 * time.Millisecond,
+		SourceConn:           fixture.SourcePool.ConnectionString,
+		Tables:               []string{src.String()},
+		TombstoneKeyColumn:   ident.New("row_key"),
+		TombstoneTable:       tombstones.String(),
+		TombstoneTableColumn: ident.New("table_name"),
+		UpdatedAtColumn:      ident.New("updated_at"),
+	}
+	r.NoError(config.Preflight())
+
+	repl, cancelRepl, err := Start(ctx, config)
+	r.NoError(err)
+	defer cancelRepl()
+
+	waitFor("SELECT count(*) FROM %s", rowCount)
+
+	// Update some rows while the loop is running.
+	for i := 0; i < rowCount; i += 2 {
+		exec("UPDATE %s SET val = 'updated', updated_at = %d WHERE pk = %d", src, 2+i, i)
+	}
+	waitFor("SELECT count(*) FROM %s WHERE val = 'updated'", (rowCount+1)/2)
+
+	// Delete some rows and record tombstones for them. The final
+	// tombstone refers to a row that still exists, so it is ignored.
+	const deletes = 5
+	for i := 0; i < deletes; i++ {
+		exec("DELETE FROM %s WHERE pk = %d", src, i)
+		exec("INSERT INTO %s (table_name, row_key, updated_at) VALUES ('%s', '[%d]', %d)",
+			tombstones, tgt.Name().Table().Raw(), i, 100+i)
+	}
+	exec("INSERT INTO %s (table_name, row_key, updated_at) VALUES ('%s', '[%d]', %d)",
+		tombstones, tgt.Name().Table().Raw(), rowCount-1, 200)
+	waitFor("SELECT count(*) FROM %s", rowCount-deletes)
+
+	// Verify that the watermarks have been persisted.
+	for {
+		cp, updated := repl.Loop.GetConsistentPoint()
+		mark := cp.(*consistentPoint).Mark(tombstones.String())
+		if mark != nil && *mark.Int == 200 {
+			r.Equal(int64(2+rowCount-1), *cp.(*consistentPoint).Mark(src.String()).Int)
+			break
+		}
+		select {
+		case <-updated:
+		case <-ctx.Done():
+			r.NoError(ctx.Err())
+		}
+	}
+	waitFor("SELECT count(*) FROM %s", rowCount-deletes)
+
+	sinktest.CheckDiagnostics(ctx, t, repl.Diagnostics)
+
+	cancelRepl()
+	select {
+	case <-ctx.Done():
+		r.Fail("cancelRepl timed out")
+	case <-repl.Loop.Stopped():
+		// OK
+	}
+}
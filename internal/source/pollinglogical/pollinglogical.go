@@ -0,0 +1,509 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package pollinglogical contains a logical-replication loop for
+// databases that cannot provide a replication feed. Tables are queried
+// repeatedly for rows whose updated-at column has advanced past a
+// high-water mark.
+package pollinglogical
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/stamp"
+	"github.com/cockroachdb/cdc-sink/internal/util/stdlogical"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// PollingLogical is a query-based replication loop.
+type PollingLogical struct {
+	Diagnostics *diag.Diagnostics
+	Loop        *logical.Loop
+}
+
+var (
+	_ stdlogical.HasDiagnostics = (*PollingLogical)(nil)
+	_ stdlogical.HasStoppable   = (*PollingLogical)(nil)
+)
+
+// GetDiagnostics implements [stdlogical.HasDiagnostics].
+func (l *PollingLogical) GetDiagnostics() *diag.Diagnostics {
+	return l.Diagnostics
+}
+
+// GetStoppable implements [stdlogical.HasStoppable].
+func (l *PollingLogical) GetStoppable() types.Stoppable {
+	return l.Loop
+}
+
+// Dialect polls tables in a SQL database.
+type Dialect struct {
+	batchSize       int                      // Limit query response size.
+	byName          *ident.Map[*sourceTable] // Look up tables named by tombstones.
+	ignoreUnmapped  bool                     // Skip tombstones for unknown tables.
+	pollInterval    time.Duration            // Delay once all tables are read.
+	source          *types.SourcePool        // Access to the source database.
+	tables          []*sourceTable           // The tables to poll, in order.
+	tombstone       ident.Table              // Optional source of deletions.
+	tombstoneKey    ident.Ident              // Column containing a JSON key.
+	tombstoneTable  ident.Ident              // Column containing a table name.
+	updatedAtColumn ident.Ident              // Order-by column in queries.
+	watchers        types.Watchers           // Target schema access.
+}
+
+var (
+	_ diag.Diagnostic = (*Dialect)(nil)
+	_ logical.Dialect = (*Dialect)(nil)
+)
+
+// A sourceTable is polled for updated rows.
+type sourceTable struct {
+	// The source columns that correspond to the target table's primary
+	// key. This is refreshed whenever ReadInto is called.
+	keys   []ident.Ident
+	name   ident.Table // The table in the source database.
+	target ident.Table // The table in the target database.
+}
+
+// A pollBatch is the sole message type sent by ReadInto. It contains
+// the mutations read by a single query and the consistent point to
+// record once they have been applied.
+type pollBatch struct {
+	cp   *consistentPoint
+	data []logical.TableData
+}
+
+// polledRows contains the results of a query.
+type polledRows struct {
+	columns *ident.Map[int] // Column names to offsets in rows.
+	marks   []*watermark    // The updated-at value of each row.
+	names   []string        // The column names, as returned by the source.
+	rows    [][]any
+}
+
+// Diagnostic implements [diag.Diagnostic].
+func (d *Dialect) Diagnostic(_ context.Context) any {
+	type Payload struct {
+		BatchSize       int
+		PollInterval    time.Duration
+		Tables          []ident.Table
+		Tombstone       ident.Table
+		UpdatedAtColumn ident.Ident
+	}
+	ret := &Payload{
+		BatchSize:       d.batchSize,
+		PollInterval:    d.pollInterval,
+		Tables:          make([]ident.Table, len(d.tables)),
+		Tombstone:       d.tombstone,
+		UpdatedAtColumn: d.updatedAtColumn,
+	}
+	for idx, tbl := range d.tables {
+		ret.Tables[idx] = tbl.name
+	}
+	return ret
+}
+
+// ReadInto implements logical.Dialect. Each table is queried in turn
+// for a batch of rows with an updated-at value greater than the
+// table's watermark. Once no table has any new rows, ReadInto waits
+// for the polling interval before trying again.
+func (d *Dialect) ReadInto(
+	ctx context.Context, ch chan<- logical.Message, state logical.State,
+) error {
+	// Refresh the primary keys, in case the schema has changed.
+	for _, tbl := range d.tables {
+		if err := d.describe(ctx, tbl); err != nil {
+			return err
+		}
+	}
+
+	initial, _ := state.GetConsistentPoint()
+	cp := initial.(*consistentPoint)
+
+	// Helper for interruptible send idiom.
+	send := func(msg *pollBatch) error {
+		cp = msg.cp
+		select {
+		case ch <- msg:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for {
+		var progress bool
+
+		// Tombstones are read first, so that a row that is deleted
+		// while the tables are being read will be deleted in the next
+		// pass. A row that has since been re-inserted will not be
+		// deleted; see readTombstones.
+		if !d.tombstone.Empty() {
+			msg, err := d.readTombstones(ctx, cp)
+			if err != nil {
+				return err
+			}
+			if msg != nil {
+				if err := send(msg); err != nil {
+					return err
+				}
+				progress = true
+			}
+		}
+
+		for _, tbl := range d.tables {
+			msg, err := d.readTable(ctx, tbl, cp)
+			if err != nil {
+				return err
+			}
+			if msg != nil {
+				if err := send(msg); err != nil {
+					return err
+				}
+				progress = true
+			}
+		}
+
+		if progress {
+			// Continue immediately, since there may be more rows.
+			select {
+			case <-state.Stopping():
+				return nil
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-time.After(d.pollInterval):
+		case <-state.Stopping():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Process implements logical.Dialect.
+func (d *Dialect) Process(
+	ctx context.Context, ch <-chan logical.Message, events logical.Events,
+) error {
+	for msg := range ch {
+		// Each message is self-contained, so there is no state to
+		// discard if ReadInto is restarted.
+		if logical.IsRollback(msg) {
+			continue
+		}
+		b, ok := msg.(*pollBatch)
+		if !ok {
+			panic(fmt.Sprintf("unimplemented type %T", msg))
+		}
+		if err := logical.ApplyBatch(ctx, events, b.cp, b.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ZeroStamp implements logical.Dialect.
+func (d *Dialect) ZeroStamp() stamp.Stamp {
+	return &consistentPoint{}
+}
+
+// describe determines the source columns that correspond to the primary
+// key of the target table.
+func (d *Dialect) describe(ctx context.Context, tbl *sourceTable) error {
+	rows, err := d.source.QueryContext(ctx,
+		fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", tbl.name))
+	if err != nil {
+		return errors.Wrapf(err, "could not query source table %s", tbl.name)
+	}
+	names, err := rows.Columns()
+	_ = rows.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	sourceCols := &ident.Map[ident.Ident]{}
+	for _, name := range names {
+		sourceCols.Put(ident.New(name), ident.New(name))
+	}
+	if _, ok := sourceCols.Get(d.updatedAtColumn); !ok {
+		return errors.Errorf("source table %s has no column %s", tbl.name, d.updatedAtColumn)
+	}
+
+	watcher, err := d.watchers.Get(ctx, tbl.target.Schema())
+	if err != nil {
+		return err
+	}
+	targetCols, ok := watcher.Get().Columns.Get(tbl.target)
+	if !ok {
+		return errors.Errorf("target table %s not found", tbl.target)
+	}
+	tbl.keys = tbl.keys[:0]
+	for _, col := range targetCols {
+		if !col.Primary {
+			continue
+		}
+		sourceCol, ok := sourceCols.Get(col.Name)
+		if !ok {
+			return errors.Errorf("source table %s has no column for primary key %s",
+				tbl.name, col.Name)
+		}
+		tbl.keys = append(tbl.keys, sourceCol)
+	}
+	if len(tbl.keys) == 0 {
+		return errors.Errorf("target table %s has no primary key", tbl.target)
+	}
+	return nil
+}
+
+// poll reads a batch of rows from the table whose updated-at values are
+// greater than the mark.
+func (d *Dialect) poll(
+	ctx context.Context, table ident.Table, cols []ident.Ident, mark *watermark,
+) (*polledRows, error) {
+	q, args, err := pollQuery(d.source.Product, table, cols, d.updatedAtColumn, ">", mark, d.batchSize)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := d.query(ctx, q, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(ret.rows) < d.batchSize {
+		return ret, nil
+	}
+
+	// The rows at the end of a full batch may share an updated-at
+	// value with rows that were not returned. They are discarded and
+	// will be read again in the next batch.
+	last := ret.marks[len(ret.marks)-1]
+	keep := len(ret.rows)
+	for keep > 0 && ret.marks[keep-1].Equal(last) {
+		keep--
+	}
+	if keep > 0 {
+		ret.marks = ret.marks[:keep]
+		ret.rows = ret.rows[:keep]
+		return ret, nil
+	}
+
+	// Every row in the batch has the same updated-at value, so we have
+	// to read all such rows at once to be able to advance the mark.
+	q, args, err = pollQuery(d.source.Product, table, cols, d.updatedAtColumn, "=", last, 0)
+	if err != nil {
+		return nil, err
+	}
+	return d.query(ctx, q, args)
+}
+
+// query executes a query generated by pollQuery.
+func (d *Dialect) query(ctx context.Context, q string, args []any) (*polledRows, error) {
+	rows, err := d.source.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, q)
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ret := &polledRows{columns: &ident.Map[int]{}, names: names}
+	for idx, name := range names {
+		ret.columns.Put(ident.New(name), idx)
+	}
+	markIdx, ok := ret.columns.Get(d.updatedAtColumn)
+	if !ok {
+		return nil, errors.Errorf("query did not return column %s", d.updatedAtColumn)
+	}
+
+	for rows.Next() {
+		row := make([]any, len(names))
+		ptrs := make([]any, len(names))
+		for idx := range row {
+			ptrs[idx] = &row[idx]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		mark, err := newWatermark(row[markIdx])
+		if err != nil {
+			return nil, err
+		}
+		// Some drivers return textual values as byte slices.
+		for idx, value := range row {
+			if b, ok := value.([]byte); ok {
+				row[idx] = string(b)
+			}
+		}
+		ret.marks = append(ret.marks, mark)
+		ret.rows = append(ret.rows, row)
+	}
+	return ret, errors.WithStack(rows.Err())
+}
+
+// readTable returns a batch of upserts, or nil if the table has no new
+// rows.
+func (d *Dialect) readTable(
+	ctx context.Context, tbl *sourceTable, cp *consistentPoint,
+) (*pollBatch, error) {
+	markKey := tbl.name.String()
+	polled, err := d.poll(ctx, tbl.name, nil, cp.Mark(markKey))
+	if err != nil {
+		return nil, errors.Wrap(err, tbl.name.String())
+	}
+	if len(polled.rows) == 0 {
+		return nil, nil
+	}
+	log.Tracef("received %d rows from %s", len(polled.rows), tbl.name)
+
+	keyIdx := make([]int, len(tbl.keys))
+	for idx, key := range tbl.keys {
+		var ok bool
+		if keyIdx[idx], ok = polled.columns.Get(key); !ok {
+			return nil, errors.Errorf("query of %s did not return key column %s", tbl.name, key)
+		}
+	}
+
+	muts := make([]types.Mutation, len(polled.rows))
+	for idx, row := range polled.rows {
+		data := make(map[string]any, len(row))
+		for colIdx, value := range row {
+			data[polled.names[colIdx]] = value
+		}
+		key := make([]any, len(keyIdx))
+		for i, colIdx := range keyIdx {
+			key[i] = row[colIdx]
+		}
+		ts, err := polled.marks[idx].HLC()
+		if err != nil {
+			return nil, err
+		}
+		muts[idx].Time = ts
+		if muts[idx].Data, err = json.Marshal(data); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if muts[idx].Key, err = json.Marshal(key); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return &pollBatch{
+		cp: cp.WithMark(markKey, polled.marks[len(polled.marks)-1]),
+		data: []logical.TableData{{
+			Muts:   muts,
+			Source: tbl.name.Table(),
+			Target: tbl.target,
+		}},
+	}, nil
+}
+
+// readTombstones returns a batch of deletions, or nil if there are no
+// new tombstones. A tombstone is ignored if the source table contains
+// a row with the same key, since the row must have been re-inserted.
+func (d *Dialect) readTombstones(ctx context.Context, cp *consistentPoint) (*pollBatch, error) {
+	markKey := d.tombstone.String()
+	cols := []ident.Ident{d.tombstoneTable, d.tombstoneKey, d.updatedAtColumn}
+	polled, err := d.poll(ctx, d.tombstone, cols, cp.Mark(markKey))
+	if err != nil {
+		return nil, errors.Wrap(err, d.tombstone.String())
+	}
+	if len(polled.rows) == 0 {
+		return nil, nil
+	}
+	log.Tracef("received %d tombstones from %s", len(polled.rows), d.tombstone)
+
+	ret := &pollBatch{cp: cp.WithMark(markKey, polled.marks[len(polled.marks)-1])}
+	byTable := make(map[*sourceTable]int)
+	for idx, row := range polled.rows {
+		tableName, _ := row[0].(string)
+		keyText, _ := row[1].(string)
+		parsed, err := ident.ParseTable(tableName)
+		if err != nil {
+			return nil, err
+		}
+		tbl, ok := d.byName.Get(parsed.Table())
+		if !ok {
+			if d.ignoreUnmapped {
+				log.Tracef("ignoring tombstone for unmapped table %q", tableName)
+				continue
+			}
+			return nil, errors.Errorf("tombstone for unmapped table %q", tableName)
+		}
+
+		var key []any
+		dec := json.NewDecoder(strings.NewReader(keyText))
+		dec.UseNumber()
+		if err := dec.Decode(&key); err != nil {
+			return nil, errors.Wrapf(err, "could not decode tombstone key %q", keyText)
+		}
+		args := make([]any, len(key))
+		for i, value := range key {
+			if num, ok := value.(json.Number); ok {
+				args[i] = num.String()
+			} else {
+				args[i] = value
+			}
+		}
+
+		q, args, err := existsQuery(d.source.Product, tbl.name, tbl.keys, args)
+		if err != nil {
+			return nil, errors.Wrapf(err, "tombstone key %q for %s", keyText, tbl.name)
+		}
+		var exists int
+		err = d.source.QueryRowContext(ctx, q, args...).Scan(&exists)
+		if err == nil {
+			log.Tracef("ignoring tombstone for live row %s in %s", keyText, tbl.name)
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.Wrap(err, q)
+		}
+
+		ts, err := polled.marks[idx].HLC()
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := json.Marshal(key)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		dataIdx, ok := byTable[tbl]
+		if !ok {
+			dataIdx = len(ret.data)
+			byTable[tbl] = dataIdx
+			ret.data = append(ret.data, logical.TableData{
+				Source: tbl.name.Table(),
+				Target: tbl.target,
+			})
+		}
+		ret.data[dataIdx].Muts = append(ret.data[dataIdx].Muts,
+			types.Mutation{Key: encoded, Time: ts})
+	}
+	return ret, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pollinglogical
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+)
+
+// placeholders returns a function that generates the n'th (1-based)
+// parameter reference for the product.
+func placeholders(product types.Product) (func(int) string, error) {
+	switch product {
+	case types.ProductCockroachDB, types.ProductPostgreSQL:
+		return func(idx int) string { return fmt.Sprintf("$%d", idx) }, nil
+	case types.ProductMySQL:
+		return func(int) string { return "?" }, nil
+	case types.ProductOracle:
+		return func(idx int) string { return fmt.Sprintf(":%d", idx) }, nil
+	default:
+		return nil, errors.Errorf("unimplemented product: %s", product)
+	}
+}
+
+// limitClause returns a clause to restrict the number of rows returned
+// by a query.
+func limitClause(product types.Product, limit int) string {
+	if product == types.ProductOracle {
+		return fmt.Sprintf(" FETCH FIRST %d ROWS ONLY", limit)
+	}
+	return fmt.Sprintf(" LIMIT %d", limit)
+}
+
+// pollQuery returns a query that selects cols (or all columns if cols
+// is empty) from rows in the table whose updated-at column compares to
+// the mark using op, in updated-at order. If mark is nil, all rows with
+// a non-NULL updated-at value are selected. If limit is non-zero, at
+// most that many rows are returned.
+func pollQuery(
+	product types.Product,
+	table ident.Table,
+	cols []ident.Ident,
+	updatedAt ident.Ident,
+	op string,
+	mark *watermark,
+	limit int,
+) (string, []any, error) {
+	ref, err := placeholders(product)
+	if err != nil {
+		return "", nil, err
+	}
+
+	names := "*"
+	if len(cols) > 0 {
+		parts := make([]string, len(cols))
+		for idx, col := range cols {
+			parts[idx] = col.String()
+		}
+		names = strings.Join(parts, ", ")
+	}
+
+	var sb strings.Builder
+	var args []any
+	fmt.Fprintf(&sb, "SELECT %s FROM %s WHERE ", names, table)
+	if mark == nil {
+		fmt.Fprintf(&sb, "%s IS NOT NULL", updatedAt)
+	} else {
+		args = append(args, mark.Value())
+		fmt.Fprintf(&sb, "%s %s %s", updatedAt, op, ref(len(args)))
+	}
+	fmt.Fprintf(&sb, " ORDER BY %s", updatedAt)
+	if limit > 0 {
+		sb.WriteString(limitClause(product, limit))
+	}
+	return sb.String(), args, nil
+}
+
+// existsQuery returns a query that returns a row if the table contains
+// a row with the given key.
+func existsQuery(
+	product types.Product, table ident.Table, keys []ident.Ident, values []any,
+) (string, []any, error) {
+	if len(keys) != len(values) {
+		return "", nil, errors.Errorf("expecting %d key values, had %d", len(keys), len(values))
+	}
+	ref, err := placeholders(product)
+	if err != nil {
+		return "", nil, err
+	}
+
+	terms := make([]string, len(keys))
+	for idx, key := range keys {
+		terms[idx] = fmt.Sprintf("%s = %s", key, ref(idx+1))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "SELECT 1 FROM %s WHERE %s", table, strings.Join(terms, " AND "))
+	sb.WriteString(limitClause(product, 1))
+	return sb.String(), values, nil
+}
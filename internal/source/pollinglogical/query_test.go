@@ -0,0 +1,112 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package pollinglogical
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollQuery(t *testing.T) {
+	tbl := ident.NewTable(ident.MustSchema(ident.New("db"), ident.New("public")), ident.New("tbl"))
+	updatedAt := ident.New("updated_at")
+	version := int64(42)
+	mark := &watermark{Int: &version}
+
+	tcs := []struct {
+		name     string
+		product  types.Product
+		cols     []ident.Ident
+		op       string
+		mark     *watermark
+		limit    int
+		expected string
+		args     []any
+	}{
+		{
+			name:    "initial",
+			product: types.ProductCockroachDB,
+			limit:   10,
+			expected: `SELECT * FROM "db"."public"."tbl" WHERE "updated_at" IS NOT NULL ` +
+				`ORDER BY "updated_at" LIMIT 10`,
+		},
+		{
+			name:    "after",
+			product: types.ProductPostgreSQL,
+			op:      ">",
+			mark:    mark,
+			limit:   10,
+			expected: `SELECT * FROM "db"."public"."tbl" WHERE "updated_at" > $1 ` +
+				`ORDER BY "updated_at" LIMIT 10`,
+			args: []any{version},
+		},
+		{
+			name:    "ties_mysql",
+			product: types.ProductMySQL,
+			cols:    []ident.Ident{ident.New("a"), updatedAt},
+			op:      "=",
+			mark:    mark,
+			expected: `SELECT "a", "updated_at" FROM "db"."public"."tbl" WHERE "updated_at" = ? ` +
+				`ORDER BY "updated_at"`,
+			args: []any{version},
+		},
+		{
+			name:    "oracle",
+			product: types.ProductOracle,
+			op:      ">",
+			mark:    mark,
+			limit:   10,
+			expected: `SELECT * FROM "db"."public"."tbl" WHERE "updated_at" > :1 ` +
+				`ORDER BY "updated_at" FETCH FIRST 10 ROWS ONLY`,
+			args: []any{version},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			q, args, err := pollQuery(tc.product, tbl, tc.cols, updatedAt, tc.op, tc.mark, tc.limit)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, q)
+			assert.Equal(t, tc.args, args)
+		})
+	}
+
+	_, _, err := pollQuery(types.ProductUnknown, tbl, nil, updatedAt, ">", mark, 0)
+	assert.ErrorContains(t, err, "unimplemented product")
+}
+
+func TestExistsQuery(t *testing.T) {
+	a := assert.New(t)
+	r := require.New(t)
+	tbl := ident.NewTable(ident.MustSchema(ident.New("db")), ident.New("tbl"))
+	keys := []ident.Ident{ident.New("a"), ident.New("b")}
+
+	q, args, err := existsQuery(types.ProductPostgreSQL, tbl, keys, []any{"1", "x"})
+	r.NoError(err)
+	a.Equal(`SELECT 1 FROM "db"."tbl" WHERE "a" = $1 AND "b" = $2 LIMIT 1`, q)
+	a.Equal([]any{"1", "x"}, args)
+
+	q, _, err = existsQuery(types.ProductOracle, tbl, keys, []any{"1", "x"})
+	r.NoError(err)
+	a.Equal(`SELECT 1 FROM "db"."tbl" WHERE "a" = :1 AND "b" = :2 FETCH FIRST 1 ROWS ONLY`, q)
+
+	_, _, err = existsQuery(types.ProductMySQL, tbl, keys, []any{"1"})
+	a.ErrorContains(err, "expecting 2 key values, had 1")
+}
@@ -0,0 +1,68 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package publish contains a changefeed server that publishes resolved
+// mutations to Kafka, rather than applying them to the target
+// database.
+package publish
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/source/server"
+	"github.com/cockroachdb/cdc-sink/internal/target/kafka"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// Config combines the configuration of the changefeed server with that
+// of the Kafka producer. The target database is used only to discover
+// the tables whose mutations are published; nothing is written to it.
+type Config struct {
+	Kafka  kafka.Config
+	Server server.Config
+}
+
+var _ logical.Config = (*Config)(nil)
+
+// Base implements logical.Config.
+func (c *Config) Base() *logical.BaseConfig {
+	return c.Server.Base()
+}
+
+// Bind adds flags to the set.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	c.Kafka.Bind(f)
+	c.Server.Bind(f)
+}
+
+// Preflight implements logical.Config.
+func (c *Config) Preflight() error {
+	// The producer discards any mutation at or before the time of the
+	// latest published window. Backfill mode applies mutations in table
+	// order, so it is disabled.
+	c.Server.CDC.BackfillWindow = 0
+
+	if err := c.Server.Preflight(); err != nil {
+		return err
+	}
+	if err := c.Kafka.Preflight(); err != nil {
+		return err
+	}
+	if c.Server.CDC.Immediate {
+		return errors.New("immediate mode is not supported when publishing to Kafka")
+	}
+	return nil
+}
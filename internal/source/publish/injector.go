@@ -0,0 +1,52 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build wireinject
+// +build wireinject
+
+package publish
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/source/server"
+	"github.com/cockroachdb/cdc-sink/internal/staging"
+	"github.com/cockroachdb/cdc-sink/internal/target/kafka"
+	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/google/wire"
+)
+
+// NewServer creates a changefeed server that publishes resolved
+// mutations to Kafka.
+func NewServer(ctx context.Context, config *Config) (*server.Server, func(), error) {
+	panic(wire.Build(
+		cdc.Set,
+		diag.New,
+		kafka.Set,
+		logical.Set,
+		schemawatch.Set,
+		script.Set,
+		server.Set,
+		staging.Set,
+		wire.Bind(new(logical.Config), new(*Config)),
+		wire.FieldsOf(new(*Config), "Kafka", "Server"),
+		wire.FieldsOf(new(*server.Config), "CDC"),
+	))
+}
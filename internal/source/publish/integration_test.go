@@ -0,0 +1,157 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package publish
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/kafkafake"
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/source/server"
+	"github.com/cockroachdb/cdc-sink/internal/target/kafka"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPublish sends changefeed payloads to the server and verifies
+// that each resolved window is published to the fake Kafka broker.
+func TestPublish(t *testing.T) {
+	r := require.New(t)
+
+	fixture, cancel, err := all.NewFixture()
+	r.NoError(err)
+	defer cancel()
+
+	ctx := fixture.Context
+
+	tbl, err := fixture.CreateTargetTable(ctx,
+		`CREATE TABLE %s (pk INT PRIMARY KEY, v VARCHAR(2048))`)
+	r.NoError(err)
+	topic := tbl.Name().Table().Raw()
+
+	broker := kafkafake.New(t)
+	defer broker.Close()
+
+	cfg := &Config{
+		Kafka: kafka.Config{
+			Brokers:         []string{broker.Addr()},
+			TransactionalID: "publish-test",
+			Version:         kafkafake.Version.String(),
+		},
+		Server: server.Config{
+			CDC: cdc.Config{
+				BaseConfig: logical.BaseConfig{
+					StagingConn:   fixture.StagingPool.ConnectionString,
+					StagingSchema: fixture.StagingDB.Schema(),
+					TargetConn:    fixture.TargetPool.ConnectionString,
+				},
+				MetaTableName: ident.New("resolved_timestamps"),
+			},
+			BindAddr:    "127.0.0.1:0",
+			DisableAuth: true,
+		},
+	}
+	pub, cancel, err := newTestFixture(ctx, cfg, broker.NewProducer(true /* transactional */))
+	r.NoError(err)
+	defer cancel()
+
+	// The table was created before the server's Watcher.
+	watcher, err := pub.Watchers.Get(ctx, tbl.Name().Schema())
+	r.NoError(err)
+	r.NoError(watcher.Refresh(ctx, fixture.TargetPool))
+
+	post := func(body string) {
+		t.Helper()
+		u := url.URL{
+			Scheme: "http",
+			Host:   pub.Listener.Addr().String(),
+			Path:   ident.Join(tbl.Name().Schema(), ident.Raw, '/'),
+		}
+		resp, err := http.Post(u.String(), "application/json", strings.NewReader(body))
+		r.NoError(err)
+		r.NoError(resp.Body.Close())
+		r.Equal(http.StatusOK, resp.StatusCode)
+	}
+	type marker struct {
+		Counts   map[string]int `json:"counts"`
+		Resolved hlc.Time       `json:"resolved"`
+	}
+	waitForMarkers := func(expected int) []marker {
+		t.Helper()
+		for {
+			recs := broker.Records(cfg.Kafka.MarkerTopic)
+			if len(recs) >= expected {
+				ret := make([]marker, len(recs))
+				for idx, rec := range recs {
+					r.NoError(json.Unmarshal(rec.Value, &ret[idx]))
+				}
+				return ret
+			}
+			select {
+			case <-ctx.Done():
+				r.NoError(ctx.Err())
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	// Publish two upserts.
+	post(fmt.Sprintf(`{ "payload" : [
+  { "after" : { "pk" : 1, "v" : "one" }, "key" : [ 1 ], "topic" : %[1]q, "updated" : "10.0" },
+  { "after" : { "pk" : 2, "v" : "two" }, "key" : [ 2 ], "topic" : %[1]q, "updated" : "10.0" }
+] }`, topic))
+	post(`{ "resolved" : "20.0" }`)
+
+	markers := waitForMarkers(1)
+	r.Len(markers, 1)
+	r.Equal(marker{Counts: map[string]int{topic: 2}, Resolved: hlc.New(10, 0)}, markers[0])
+
+	recs := broker.Records(topic)
+	r.Len(recs, 2)
+	r.Equal(`[1]`, string(recs[0].Key))
+	r.JSONEq(`{"after":{"pk":1,"v":"one"},"key":[1],"updated":"10.0000000000"}`, string(recs[0].Value))
+	r.Equal(`[2]`, string(recs[1].Key))
+
+	// Publish a delete in a later window.
+	post(fmt.Sprintf(`{ "payload" : [
+  { "after" : null, "key" : [ 1 ], "topic" : %[1]q, "updated" : "30.0" }
+] }`, topic))
+	post(`{ "resolved" : "40.0" }`)
+
+	markers = waitForMarkers(2)
+	r.Len(markers, 2)
+	r.Equal(marker{Counts: map[string]int{topic: 1}, Resolved: hlc.New(30, 0)}, markers[1])
+
+	recs = broker.Records(topic)
+	r.Len(recs, 3)
+	r.JSONEq(`{"after":null,"key":[1],"updated":"30.0000000000"}`, string(recs[2].Value))
+
+	// Nothing is written to the target database.
+	var count int
+	r.NoError(fixture.TargetPool.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT count(*) FROM %s", tbl.Name())).Scan(&count))
+	r.Zero(count)
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build wireinject
+// +build wireinject
+
+package publish
+
+import (
+	"context"
+	"net"
+
+	"github.com/IBM/sarama"
+	"github.com/cockroachdb/cdc-sink/internal/script"
+	"github.com/cockroachdb/cdc-sink/internal/source/cdc"
+	"github.com/cockroachdb/cdc-sink/internal/source/logical"
+	"github.com/cockroachdb/cdc-sink/internal/source/server"
+	"github.com/cockroachdb/cdc-sink/internal/staging"
+	"github.com/cockroachdb/cdc-sink/internal/target/kafka"
+	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/google/wire"
+)
+
+type testFixture struct {
+	Listener net.Listener
+	Server   *server.Server
+	Watchers types.Watchers
+}
+
+// We want this to be as close as possible to NewServer, except that
+// the Kafka producer is supplied by the test.
+func newTestFixture(context.Context, *Config, sarama.SyncProducer) (*testFixture, func(), error) {
+	panic(wire.Build(
+		cdc.Set,
+		diag.New,
+		kafka.ProvideProducer,
+		logical.Set,
+		schemawatch.Set,
+		script.Set,
+		server.Set,
+		staging.Set,
+		wire.Bind(new(logical.Config), new(*Config)),
+		wire.Bind(new(types.Appliers), new(*kafka.Producer)),
+		wire.FieldsOf(new(*Config), "Kafka", "Server"),
+		wire.FieldsOf(new(*server.Config), "CDC"),
+		wire.Struct(new(testFixture), "*"),
+	))
+}
@@ -108,7 +108,8 @@ func NewServer(ctx context.Context, config *Config) (*Server, func(), error) {
 		return nil, nil, err
 	}
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, cleanup8, err := apply.ProvideFactory(targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	applyConfig := logical.ProvideApplyConfig(baseConfig)
+	appliers, cleanup8, err := apply.ProvideFactory(targetStatements, applyConfig, configs, diagnostics, dlQs, targetPool, watchers)
 	if err != nil {
 		cleanup7()
 		cleanup6()
@@ -171,7 +172,7 @@ func NewServer(ctx context.Context, config *Config) (*Server, func(), error) {
 	}
 	metaTable := cdc.ProvideMetaTable(cdcConfig)
 	stagers := stage.ProvideFactory(stagingPool, stagingSchema)
-	resolvers, cleanup10, err := cdc.ProvideResolvers(ctx, cdcConfig, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
+	resolvers, cleanup10, err := cdc.ProvideResolvers(ctx, appliers, cdcConfig, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
 	if err != nil {
 		cleanup9()
 		cleanup8()
@@ -184,14 +185,31 @@ func NewServer(ctx context.Context, config *Config) (*Server, func(), error) {
 		cleanup()
 		return nil, nil, err
 	}
+	debeziumTransactions := cdc.ProvideDebeziumTransactions(memoMemo, stagingPool)
+	avroRegistry, err := cdc.ProvideSchemaRegistry(cdcConfig)
+	if err != nil {
+		cleanup10()
+		cleanup9()
+		cleanup8()
+		cleanup7()
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
 	handler := &cdc.Handler{
 		Authenticator: authenticator,
 		Config:        cdcConfig,
 		Immediate:     immediate,
+		Registry:      avroRegistry,
 		Resolvers:     resolvers,
 		StagingPool:   stagingPool,
 		Stores:        stagers,
 		TargetPool:    targetPool,
+		Transactions:  debeziumTransactions,
 	}
 	serveMux := ProvideMux(handler, stagingPool, targetPool)
 	tlsConfig, err := ProvideTLSConfig(config)
@@ -309,7 +327,8 @@ func newTestFixture(contextContext context.Context, config *Config) (*testFixtur
 		return nil, nil, err
 	}
 	dlQs := dlq.ProvideDLQs(dlqConfig, targetPool, watchers)
-	appliers, cleanup8, err := apply.ProvideFactory(targetStatements, configs, diagnostics, dlQs, targetPool, watchers)
+	applyConfig := logical.ProvideApplyConfig(baseConfig)
+	appliers, cleanup8, err := apply.ProvideFactory(targetStatements, applyConfig, configs, diagnostics, dlQs, targetPool, watchers)
 	if err != nil {
 		cleanup7()
 		cleanup6()
@@ -372,7 +391,7 @@ func newTestFixture(contextContext context.Context, config *Config) (*testFixtur
 	}
 	metaTable := cdc.ProvideMetaTable(cdcConfig)
 	stagers := stage.ProvideFactory(stagingPool, stagingSchema)
-	resolvers, cleanup10, err := cdc.ProvideResolvers(contextContext, cdcConfig, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
+	resolvers, cleanup10, err := cdc.ProvideResolvers(contextContext, appliers, cdcConfig, typesLeases, factory, metaTable, stagingPool, stagers, watchers)
 	if err != nil {
 		cleanup9()
 		cleanup8()
@@ -385,14 +404,31 @@ func newTestFixture(contextContext context.Context, config *Config) (*testFixtur
 		cleanup()
 		return nil, nil, err
 	}
+	debeziumTransactions := cdc.ProvideDebeziumTransactions(memoMemo, stagingPool)
+	avroRegistry, err := cdc.ProvideSchemaRegistry(cdcConfig)
+	if err != nil {
+		cleanup10()
+		cleanup9()
+		cleanup8()
+		cleanup7()
+		cleanup6()
+		cleanup5()
+		cleanup4()
+		cleanup3()
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
 	handler := &cdc.Handler{
 		Authenticator: authenticator,
 		Config:        cdcConfig,
 		Immediate:     immediate,
+		Registry:      avroRegistry,
 		Resolvers:     resolvers,
 		StagingPool:   stagingPool,
 		Stores:        stagers,
 		TargetPool:    targetPool,
+		Transactions:  debeziumTransactions,
 	}
 	serveMux := ProvideMux(handler, stagingPool, targetPool)
 	tlsConfig, err := ProvideTLSConfig(config)
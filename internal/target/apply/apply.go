@@ -22,6 +22,7 @@ package apply
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"sync"
@@ -34,6 +35,7 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/util/merge"
 	"github.com/cockroachdb/cdc-sink/internal/util/metrics"
 	"github.com/cockroachdb/cdc-sink/internal/util/msort"
+	"github.com/cockroachdb/cdc-sink/internal/util/objstore"
 	"github.com/cockroachdb/cdc-sink/internal/util/pjson"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -42,15 +44,19 @@ import (
 
 // apply will upsert mutations and deletions into a target table.
 type apply struct {
-	cache   *types.TargetStatements
-	dlqs    types.DLQs
-	product types.Product
-	target  ident.Table
+	cache        *types.TargetStatements
+	dlqs         types.DLQs
+	loader       *loader // Vended by factory.GetBulk.
+	product      types.Product
+	stage        objstore.Bucket // Optional staging area for bulk loads.
+	stageOptions string          // Extra options for COPY from the stage.
+	target       ident.Table
 
 	conflicts prometheus.Counter
 	deletes   prometheus.Counter
 	durations prometheus.Observer
 	errors    prometheus.Counter
+	isolated  prometheus.Counter
 	resolves  prometheus.Counter
 	upserts   prometheus.Counter
 
@@ -94,18 +100,22 @@ func (f *factory) newApply(
 
 	labelValues := metrics.TableValues(target)
 	a := &apply{
-		cache:   f.cache,
-		dlqs:    f.dlqs,
-		product: product,
-		target:  target,
+		cache:        f.cache,
+		dlqs:         f.dlqs,
+		product:      product,
+		stage:        f.stage,
+		stageOptions: f.stageOptions,
+		target:       target,
 
 		conflicts: applyConflicts.WithLabelValues(labelValues...),
 		deletes:   applyDeletes.WithLabelValues(labelValues...),
 		durations: applyDurations.WithLabelValues(labelValues...),
 		errors:    applyErrors.WithLabelValues(labelValues...),
+		isolated:  applyIsolated.WithLabelValues(labelValues...),
 		resolves:  applyResolves.WithLabelValues(labelValues...),
 		upserts:   applyUpserts.WithLabelValues(labelValues...),
 	}
+	a.loader = &loader{a}
 
 	errs := make(chan error, 1)
 	go func(ctx context.Context, errs chan<- error) {
@@ -124,7 +134,9 @@ func (f *factory) newApply(
 		if configData.Merger != nil {
 			switch a.product {
 			case types.ProductCockroachDB:
+			case types.ProductOracle:
 			case types.ProductPostgreSQL:
+			case types.ProductSQLServer:
 			default:
 				// Work items in https://github.com/cockroachdb/cdc-sink/issues/487
 				errs <- errors.Errorf("merge operation not implemented for %s", a.product)
@@ -168,8 +180,29 @@ func (f *factory) newApply(
 	return a, cancel, nil
 }
 
-// Apply applies the mutations to the target table.
+// Apply applies the mutations to the target table. If the table has
+// been configured with an error DLQ, a batch which cannot be applied
+// will be bisected to isolate the failing mutations.
 func (a *apply) Apply(ctx context.Context, tx types.TargetQuerier, muts []types.Mutation) error {
+	a.mu.RLock()
+	errorDLQ := ""
+	if a.mu.templates != nil {
+		errorDLQ = a.mu.templates.ErrorDLQ
+	}
+	a.mu.RUnlock()
+
+	if errorDLQ == "" || !a.canIsolate(tx) {
+		return a.applyMuts(ctx, tx, muts, false)
+	}
+	return a.isolate(ctx, tx, errorDLQ, msort.UniqueByKey(muts))
+}
+
+// applyMuts will bulk-load the mutations if the table has been
+// configured to do so, or if preferLoad is set. Otherwise, mutations
+// are applied with batched SQL statements.
+func (a *apply) applyMuts(
+	ctx context.Context, tx types.TargetQuerier, muts []types.Mutation, preferLoad bool,
+) error {
 	start := time.Now()
 	deletes, r := batches.Mutation()
 	defer r()
@@ -198,6 +231,14 @@ func (a *apply) Apply(ctx context.Context, tx types.TargetQuerier, muts []types.
 		return errors.Errorf("no ColumnData available for %s", a.target)
 	}
 
+	if pool, ok := a.canLoadLocked(tx, preferLoad); ok {
+		if err := a.loadLocked(ctx, pool, muts); err != nil {
+			return countError(err)
+		}
+		a.durations.Observe(time.Since(start).Seconds())
+		return nil
+	}
+
 	// Accumulate mutations and flush incrementally.
 	for i := range muts {
 		if muts[i].IsDelete() {
@@ -244,7 +285,7 @@ func (a *apply) deleteLocked(
 		return err
 	}
 
-	allArgs := make([]any, 0, len(a.mu.templates.PKDelete)*len(muts))
+	allArgs := make([]any, 0, a.mu.templates.DeleteParameterCount*len(muts))
 	for i, keyGroup := range keyGroups {
 		if len(keyGroup) != len(a.mu.templates.PKDelete) {
 			return errors.Errorf(
@@ -257,6 +298,9 @@ func (a *apply) deleteLocked(
 				string(muts[i].Key), muts[i].Time)
 		}
 		allArgs = append(allArgs, keyGroup...)
+		if a.mu.templates.Versioned {
+			allArgs = append(allArgs, versionOf(muts[i].Time))
+		}
 	}
 
 	for idx, arg := range allArgs {
@@ -266,39 +310,52 @@ func (a *apply) deleteLocked(
 		}
 	}
 
-	stmt, err := a.cache.Prepare(ctx,
+	affected, err := a.deleteArgsLocked(ctx, db, allArgs, len(muts))
+	if err != nil {
+		return err
+	}
+
+	a.deletes.Add(float64(affected))
+	log.WithFields(log.Fields{
+		"applied":  affected,
+		"proposed": len(muts),
+		"target":   a.target,
+	}).Debug("deleted rows")
+	return nil
+}
+
+// deleteArgsLocked executes the delete template with row-major key
+// arguments and returns the number of rows that were deleted.
+func (a *apply) deleteArgsLocked(
+	ctx context.Context, db types.TargetQuerier, allArgs []any, rowCount int,
+) (int64, error) {
+	stmt, err := a.prepareLocked(ctx,
 		db,
-		fmt.Sprintf("delete-%s-%d-%d", a.target, a.mu.gen, len(muts)),
+		fmt.Sprintf("delete-%s-%d-%d", a.target, a.mu.gen, rowCount),
 		func() (string, error) {
-			return a.mu.templates.deleteExpr(len(muts))
+			return a.mu.templates.deleteExpr(rowCount)
 		})
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	if a.mu.templates.BulkDelete {
-		allArgs, err = toColumns(len(a.mu.templates.PKDelete), len(muts), allArgs)
+		allArgs, err = toColumns(a.mu.templates.DeleteParameterCount, rowCount, allArgs)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
 
 	tag, err := stmt.ExecContext(ctx, allArgs...)
 	if err != nil {
-		return errors.WithStack(err)
+		return 0, errors.WithStack(err)
 	}
-	affected, err := tag.RowsAffected()
-	if err != nil {
-		return errors.WithStack(err)
+	// A deletion is always recorded as a new row.
+	if a.mu.templates.Versioned {
+		return int64(rowCount), nil
 	}
-
-	a.deletes.Add(float64(affected))
-	log.WithFields(log.Fields{
-		"applied":  affected,
-		"proposed": len(muts),
-		"target":   a.target,
-	}).Debug("deleted rows")
-	return nil
+	affected, err := tag.RowsAffected()
+	return affected, errors.WithStack(err)
 }
 
 // upsertLocked decodes the mutations into property bags and calls
@@ -325,6 +382,16 @@ func (a *apply) upsertLocked(
 // upsertArgsLocked shuffles the contents of the property bags into the
 // arguments that will be passed to the SQL command.
 func (a *apply) upsertArgsLocked(bags []*merge.Bag) ([]any, error) {
+	allArgs, err := a.upsertRowArgsLocked(bags)
+	if err != nil {
+		return nil, err
+	}
+	return a.upsertColumnArgsLocked(allArgs, len(bags))
+}
+
+// upsertRowArgsLocked returns the upsert arguments in a row-major
+// layout, regardless of whether the target supports bulk transfers.
+func (a *apply) upsertRowArgsLocked(bags []*merge.Bag) ([]any, error) {
 	// Allocate a slice for all mutation data. We'll reset the length
 	// once we know how many elements we actually have.
 	allArgs := make([]any, a.mu.templates.UpsertParameterCount*len(bags))
@@ -420,13 +487,16 @@ func (a *apply) upsertArgsLocked(bags []*merge.Bag) ([]any, error) {
 
 		argIdx += a.mu.templates.UpsertParameterCount
 	}
-	allArgs = allArgs[:argIdx]
+	return allArgs[:argIdx], nil
+}
 
-	// Pivot to columnar data layout if the target supports a
-	// bulk-transfer statement.
+// upsertColumnArgsLocked pivots row-major upsert arguments to a
+// columnar data layout if the target supports a bulk-transfer
+// statement.
+func (a *apply) upsertColumnArgsLocked(allArgs []any, rowCount int) ([]any, error) {
 	if a.mu.templates.BulkUpsert {
 		var err error
-		allArgs, err = toColumns(a.mu.templates.UpsertParameterCount, len(bags), allArgs)
+		allArgs, err = toColumns(a.mu.templates.UpsertParameterCount, rowCount, allArgs)
 		if err != nil {
 			return nil, err
 		}
@@ -451,17 +521,48 @@ func (a *apply) upsertBagsLocked(
 	if len(bags) == 0 {
 		return nil
 	}
+
+	// The rows which are read back to find conflicts are locked until
+	// the upsert has been executed, so the statements must share a
+	// transaction.
+	if a.mu.templates.conflicts != nil && mode != applyUnconditional && a.mu.templates.Merger != nil {
+		if pool, ok := db.(txBeginner); ok {
+			return a.upsertBagsInTxLocked(ctx, pool, mode, muts, bags)
+		}
+	}
 	start := time.Now()
 
 	// Converts the property bags into the expected argument layout.
-	allArgs, err := a.upsertArgsLocked(bags)
+	rowArgs, err := a.upsertRowArgsLocked(bags)
+	if err != nil {
+		return err
+	}
+	// Record the time of each mutation in the trailing parameter.
+	if a.mu.templates.Versioned {
+		for idx := range bags {
+			rowArgs[(idx+1)*a.mu.templates.UpsertParameterCount-1] = versionOf(muts[idx].Time)
+		}
+	}
+	allArgs, err := a.upsertColumnArgsLocked(rowArgs, len(bags))
 	if err != nil {
 		return err
 	}
 
+	// Emulate an upsert for targets that lack one by first removing
+	// any existing rows that have the same keys.
+	if a.mu.templates.DeleteBeforeUpsert {
+		keyArgs, err := a.upsertKeysLocked(rowArgs, len(bags))
+		if err != nil {
+			return err
+		}
+		if _, err := a.deleteArgsLocked(ctx, db, keyArgs, len(bags)); err != nil {
+			return err
+		}
+	}
+
 	// Get a prepared statement handle that's attached to the
 	// transaction.
-	stmt, err := a.cache.Prepare(ctx,
+	stmt, err := a.prepareLocked(ctx,
 		db,
 		fmt.Sprintf("upsert-%s-%d-%d-%v", a.target, a.mu.gen, len(bags), mode),
 		func() (string, error) {
@@ -485,6 +586,10 @@ func (a *apply) upsertBagsLocked(
 		if err != nil {
 			return errors.WithStack(err)
 		}
+		// An update is always recorded as a new row.
+		if a.mu.templates.Versioned {
+			upserted = int64(len(bags))
+		}
 		// Conflicts could be non-zero if CAS is enabled and there's no
 		// merge function. If CAS is not enabled, or we're applying
 		// unconditionally, we expect to see all rows upserted and this
@@ -509,10 +614,144 @@ func (a *apply) upsertBagsLocked(
 		return nil
 	}
 
-	conflictingRows, err := stmt.QueryContext(ctx, allArgs...)
+	// Targets whose conditional upsert can't return the blocking rows
+	// read them back before executing the upsert.
+	var conflictingRows *sql.Rows
+	if a.mu.templates.conflicts != nil {
+		if err := a.lockLocked(ctx, db, rowArgs, len(bags)); err != nil {
+			return err
+		}
+		conflictStmt, err := a.prepareLocked(ctx,
+			db,
+			fmt.Sprintf("conflicts-%s-%d-%d", a.target, a.mu.gen, len(bags)),
+			func() (string, error) {
+				return a.mu.templates.conflictsExpr(len(bags))
+			})
+		if err != nil {
+			return err
+		}
+		conflictingRows, err = conflictStmt.QueryContext(ctx, rowArgs...)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	} else {
+		conflictingRows, err = stmt.QueryContext(ctx, allArgs...)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	conflicts, conflictMuts, err := a.readConflictsLocked(conflictingRows, muts, bags)
+	if err != nil {
+		return err
+	}
+	if a.mu.templates.conflicts != nil {
+		if _, err := stmt.ExecContext(ctx, allArgs...); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	a.upserts.Add(float64(len(bags)))
+	log.WithFields(log.Fields{
+		"conflicts": len(conflicts),
+		"duration":  time.Since(start),
+		"proposed":  len(bags),
+		"target":    a.target,
+		"upserted":  len(bags),
+	}).Trace("conditionally upserted rows")
+
+	// All rows were successfully applied.
+	if len(conflicts) == 0 {
+		return nil
+	}
+	a.conflicts.Add(float64(len(conflicts)))
+
+	// Call the merge function on each conflict to generate a
+	// replacement row, add to a DLQ, or drop entirely.
+	fixups := make([]*merge.Bag, 0, len(conflicts))
+	for idx, c := range conflicts {
+		resolution, err := merger.Merge(ctx, c)
+		switch {
+		case err != nil:
+			return err
+		case resolution == nil:
+			return errors.New("merge implementation returned nil *Resolution")
+		case resolution.Drop:
+			// No action needed.
+		case resolution.DLQ != "":
+			// Locate the requested DLQ and add the mutation.
+			q, err := a.dlqs.Get(ctx, a.target.Schema(), resolution.DLQ)
+			if err != nil {
+				return err
+			}
+			if err := q.Enqueue(ctx, db, conflictMuts[idx]); err != nil {
+				return err
+			}
+		case resolution.Apply != nil:
+			fixups = append(fixups, resolution.Apply)
+		default:
+			return errors.New("merge implementation returned zero-valued *Resolution")
+		}
+	}
+
+	a.resolves.Add(float64(len(fixups)))
+	log.WithField("resolves", len(fixups)).Trace("resolved merge conflicts")
+
+	// We'll recurse into this function, but apply unconditionally.
+	return a.upsertBagsLocked(ctx, db, applyUnconditional, nil, fixups)
+}
+
+// A txBeginner is implemented by [sql.DB] and [types.TargetPool].
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// upsertBagsInTxLocked calls upsertBagsLocked within a transaction.
+func (a *apply) upsertBagsInTxLocked(
+	ctx context.Context, pool txBeginner, mode applyMode, muts []types.Mutation, bags []*merge.Bag,
+) error {
+	tx, err := pool.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := a.upsertBagsLocked(ctx, tx, mode, muts, bags); err != nil {
+		return err
+	}
+	return errors.WithStack(tx.Commit())
+}
+
+// lockLocked locks the rows in the target table which have the same
+// keys as the rows to be upserted. This prevents the rows which are
+// read back by the conflicts query from being changed by another
+// writer before the upsert is executed. The locks are held until the
+// enclosing transaction ends.
+func (a *apply) lockLocked(ctx context.Context, db types.TargetQuerier, rowArgs []any, rowCount int) error {
+	stmt, err := a.prepareLocked(ctx,
+		db,
+		fmt.Sprintf("lock-%s-%d-%d", a.target, a.mu.gen, rowCount),
+		func() (string, error) {
+			return a.mu.templates.lockExpr(rowCount)
+		})
+	if err != nil {
+		return err
+	}
+	// Oracle acquires the locks when the query is executed, so there's
+	// no need to read the rows.
+	rows, err := stmt.QueryContext(ctx, rowArgs...)
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	return errors.WithStack(rows.Close())
+}
+
+// readConflictsLocked reads the rows which blocked a conditional
+// upsert. Each row consists of an index into the muts and bags slices,
+// followed by the current values of the target columns. The rows will
+// be closed.
+func (a *apply) readConflictsLocked(
+	conflictingRows *sql.Rows, muts []types.Mutation, bags []*merge.Bag,
+) ([]*merge.Conflict, []types.Mutation, error) {
 	defer conflictingRows.Close()
 
 	// Read the conflicting rows back to generate the conflicts to resolve.
@@ -531,7 +770,7 @@ func (a *apply) upsertBagsLocked(
 			scanPtrs[i+1] = &blockingData[i]
 		}
 		if err := conflictingRows.Scan(scanPtrs...); err != nil {
-			return errors.WithStack(err)
+			return nil, nil, errors.WithStack(err)
 		}
 
 		// The conflict will have at least the blocking data and the
@@ -553,7 +792,7 @@ func (a *apply) upsertBagsLocked(
 			if !bytes.Equal(conflictingMut.Before, []byte("null")) {
 				c.Before = a.newBagLocked()
 				if err := c.Before.UnmarshalJSON(conflictingMut.Before); err != nil {
-					return errors.WithStack(err)
+					return nil, nil, errors.WithStack(err)
 				}
 			}
 		}
@@ -563,57 +802,29 @@ func (a *apply) upsertBagsLocked(
 	}
 	// Final or no-rows error check.
 	if err := conflictingRows.Err(); err != nil {
-		return errors.WithStack(err)
+		return nil, nil, errors.WithStack(err)
 	}
+	return conflicts, conflictMuts, nil
+}
 
-	a.upserts.Add(float64(len(bags)))
-	log.WithFields(log.Fields{
-		"conflicts": len(conflicts),
-		"duration":  time.Since(start),
-		"proposed":  len(bags),
-		"target":    a.target,
-		"upserted":  len(bags),
-	}).Trace("conditionally upserted rows")
-
-	// All rows were successfully applied.
-	if len(conflicts) == 0 {
-		return nil
-	}
-	a.conflicts.Add(float64(len(conflicts)))
-
-	// Call the merge function on each conflict to generate a
-	// replacement row, add to a DLQ, or drop entirely.
-	fixups := make([]*merge.Bag, 0, len(conflicts))
-	for idx, c := range conflicts {
-		resolution, err := merger.Merge(ctx, c)
-		switch {
-		case err != nil:
-			return err
-		case resolution == nil:
-			return errors.New("merge implementation returned nil *Resolution")
-		case resolution.Drop:
-			// No action needed.
-		case resolution.DLQ != "":
-			// Locate the requested DLQ and add the mutation.
-			q, err := a.dlqs.Get(ctx, a.target.Schema(), resolution.DLQ)
-			if err != nil {
-				return err
-			}
-			if err := q.Enqueue(ctx, db, conflictMuts[idx]); err != nil {
-				return err
+// upsertKeysLocked extracts the primary-key values from row-major
+// upsert arguments, in the order expected by the delete template.
+func (a *apply) upsertKeysLocked(allArgs []any, rowCount int) ([]any, error) {
+	pks := a.mu.templates.PKDelete
+	ret := make([]any, 0, len(pks)*rowCount)
+	for row := 0; row < rowCount; row++ {
+		offset := row * a.mu.templates.UpsertParameterCount
+		for _, pk := range pks {
+			pos := a.mu.templates.Positions.GetZero(pk.Name)
+			if pos.UpsertIndex < 0 {
+				return nil, errors.Errorf(
+					"primary key column %s in %s does not receive a value from the payload",
+					pk.Name, a.target)
 			}
-		case resolution.Apply != nil:
-			fixups = append(fixups, resolution.Apply)
-		default:
-			return errors.New("merge implementation returned zero-valued *Resolution")
+			ret = append(ret, allArgs[offset+pos.UpsertIndex])
 		}
 	}
-
-	a.resolves.Add(float64(len(fixups)))
-	log.WithField("resolves", len(fixups)).Trace("resolved merge conflicts")
-
-	// We'll recurse into this function, but apply unconditionally.
-	return a.upsertBagsLocked(ctx, db, applyUnconditional, nil, fixups)
+	return ret, nil
 }
 
 // newBagLocked constructs a new property bag using cached metadata.
@@ -707,6 +918,43 @@ func (a *apply) validate(configData *applycfg.Config, schemaData []types.ColData
 	return nil
 }
 
+// A statement is implemented by [*sql.Stmt] and by directStatement.
+type statement interface {
+	ExecContext(ctx context.Context, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, args ...any) (*sql.Rows, error)
+}
+
+// directStatement executes SQL without preparing it.
+type directStatement struct {
+	db types.TargetQuerier
+	q  string
+}
+
+func (s *directStatement) ExecContext(ctx context.Context, args ...any) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.q, args...)
+}
+
+func (s *directStatement) QueryContext(ctx context.Context, args ...any) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.q, args...)
+}
+
+// prepareLocked returns a statement for the SQL produced by gen. The
+// ClickHouse driver treats every prepared statement as a single-use
+// batch of rows to insert, so the SQL for Versioned targets is not
+// prepared.
+func (a *apply) prepareLocked(
+	ctx context.Context, db types.TargetQuerier, key string, gen func() (string, error),
+) (statement, error) {
+	if !a.mu.templates.Versioned {
+		return a.cache.Prepare(ctx, db, key, gen)
+	}
+	q, err := gen()
+	if err != nil {
+		return nil, err
+	}
+	return &directStatement{db, q}, nil
+}
+
 // toColumns converts a linear slice of arguments into columnar values.
 func toColumns(columns, rows int, data []any) ([]any, error) {
 	if rows*columns != len(data) {
@@ -35,11 +35,14 @@ import (
 // The columnMapping also contains data about the target schema that we
 // want to memoize.
 type columnMapping struct {
+	BulkLoad             bool                         // Stage data in files and load with COPY.
 	Conditions           []types.ColData              // The version-like fields for CAS ops.
 	Columns              []types.ColData              // All columns named in an upsert statement.
 	Data                 []types.ColData              // Non-PK, non-ignored columns.
 	Deadlines            types.Deadlines              // Allow too-old data to just be dropped.
 	DeleteParameterCount int                          // The number of SQL arguments.
+	DeletedCol           types.ColData                // The deletion flag of a Versioned target.
+	ErrorDLQ             string                       // Isolate unapplyable mutations into this DLQ.
 	Exprs                *ident.Map[string]           // Value-replacement expressions.
 	ExtrasColIdx         int                          // Position of the extras column, or -1 if unconfigured.
 	Ignore               ident.Idents                 // Named columns to ignore in the input.
@@ -51,6 +54,8 @@ type columnMapping struct {
 	Renames              *ident.Map[ident.Ident]      // External (source) names to target names.
 	TableName            ident.Table                  // The target table.
 	UpsertParameterCount int                          // The number of SQL arguments.
+	VersionCol           types.ColData                // The row version of a Versioned target.
+	Versioned            bool                         // Append versioned rows instead of upserting.
 }
 
 // positionalColumn augments ColData with the offset of the positional
@@ -66,14 +71,17 @@ func newColumnMapping(
 	cfg *applycfg.Config, cols []types.ColData, product types.Product, table ident.Table,
 ) (*columnMapping, error) {
 	ret := &columnMapping{
+		BulkLoad:     cfg.BulkLoad,
 		Conditions:   make([]types.ColData, len(cfg.CASColumns)),
 		Deadlines:    &ident.Map[time.Duration]{},
+		ErrorDLQ:     cfg.ErrorDLQ,
 		Exprs:        &ident.Map[string]{},
 		ExtrasColIdx: -1,
 		Positions:    &ident.Map[positionalColumn]{},
 		Product:      product,
 		Renames:      &ident.Map[ident.Ident]{},
 		TableName:    table,
+		Versioned:    product == types.ProductClickHouse,
 	}
 
 	// Ensure that merge behavior is enabled only if there's something
@@ -113,7 +121,15 @@ func newColumnMapping(
 		// We also determine whether the column appears in an upsert
 		// statement at all.
 		willUpsert := false
-		if col.Ignored {
+		if ret.Versioned && ident.Equal(col.Name, versionColumn) {
+			// The version is derived from the mutation's timestamp,
+			// not from the payload.
+			ret.VersionCol = col
+			ret.Ignore = append(ret.Ignore, col.Name)
+		} else if ret.Versioned && ident.Equal(col.Name, deletedColumn) {
+			ret.DeletedCol = col
+			ret.Ignore = append(ret.Ignore, col.Name)
+		} else if col.Ignored {
 			// col.Ignored is true for generated columns. That field is
 			// driven by inspecting the target schema.
 			ret.Ignore = append(ret.Ignore, col.Name)
@@ -177,6 +193,12 @@ func newColumnMapping(
 	}
 	ret.DeleteParameterCount = len(ret.PKDelete)
 	ret.UpsertParameterCount = currentParameterIndex
+	// Each row of a Versioned target has a trailing parameter that
+	// holds the version of the mutation.
+	if ret.Versioned {
+		ret.DeleteParameterCount++
+		ret.UpsertParameterCount++
+	}
 
 	// We also allow the user to force non-existent columns to be
 	// ignored (e.g. to drop a column).
@@ -0,0 +1,53 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/util/objstore"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+// Config controls the bulk-loading behavior of tables that have been
+// configured to use it.
+type Config struct {
+	// Extra options to append to a COPY FROM an object store, such as
+	// the credentials or IAM role the target should use.
+	BulkCopyOptions string
+	// If set, files are uploaded to this object store and loaded by the
+	// target database. Otherwise, files are streamed to the target.
+	BulkStageURL string
+}
+
+// Bind adds configuration flags to the set.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	f.StringVar(&c.BulkCopyOptions, "bulkCopyOptions", "",
+		"additional options for a COPY from the bulk staging area (e.g. IAM_ROLE 'arn:...')")
+	f.StringVar(&c.BulkStageURL, "bulkStageURL", "",
+		"an s3:// URL to stage bulk-loaded files in; Redshift tables are not bulk-loaded without one")
+}
+
+// Preflight validates the configuration.
+func (c *Config) Preflight() error {
+	if c.BulkStageURL == "" {
+		return nil
+	}
+	if _, err := objstore.Open(c.BulkStageURL); err != nil {
+		return errors.Wrap(err, "bulkStageURL")
+	}
+	return nil
+}
@@ -24,17 +24,20 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/objstore"
 	"github.com/pkg/errors"
 )
 
 // factory vends singleton instance of apply.
 type factory struct {
-	cache    *types.TargetStatements
-	configs  *applycfg.Configs
-	dlqs     types.DLQs
-	product  types.Product
-	watchers types.Watchers
-	mu       struct {
+	cache        *types.TargetStatements
+	configs      *applycfg.Configs
+	dlqs         types.DLQs
+	product      types.Product
+	stage        objstore.Bucket // May be nil.
+	stageOptions string
+	watchers     types.Watchers
+	mu           struct {
 		sync.RWMutex
 		cleanup   []func()
 		instances *ident.TableMap[*apply]
@@ -73,6 +76,20 @@ func (f *factory) Get(_ context.Context, table ident.Table) (types.Applier, erro
 	return f.getOrCreateUnlocked(f.product, table)
 }
 
+// GetBulk returns an Applier that will bulk-load mutations into the
+// table, if the target database supports it.
+func (f *factory) GetBulk(_ context.Context, table ident.Table) (types.Applier, error) {
+	ret := f.getUnlocked(table)
+	if ret == nil {
+		var err error
+		ret, err = f.getOrCreateUnlocked(f.product, table)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret.loader, nil
+}
+
 // getOrCreateUnlocked takes a write-lock.
 func (f *factory) getOrCreateUnlocked(product types.Product, table ident.Table) (*apply, error) {
 	f.mu.Lock()
@@ -0,0 +1,127 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/retry"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// isolateSavepoint is the name of the savepoint used to roll back a
+// failed attempt within a transaction.
+const isolateSavepoint = "cdc_sink_isolate"
+
+// canIsolate returns true if failed attempts to apply mutations can be
+// rolled back without aborting the enclosing transaction. Mutations
+// applied outside a transaction are committed by each statement, so
+// they can always be isolated.
+func (a *apply) canIsolate(tx types.TargetQuerier) bool {
+	if _, ok := tx.(*sql.Tx); !ok {
+		return true
+	}
+	// Neither Redshift nor ClickHouse support savepoints.
+	return a.product != types.ProductRedshift && a.product != types.ProductClickHouse
+}
+
+// isolate bisects a batch of mutations which cannot be applied until
+// the individual mutations which fail have been found. Those mutations
+// are written to the named DLQ, along with the error, and the
+// remainder of the batch is applied. The mutations must already be
+// de-duplicated.
+//
+// Errors caused by a transaction or connection failure are returned
+// to the caller, since they do not indicate a problem with the data.
+func (a *apply) isolate(
+	ctx context.Context, tx types.TargetQuerier, dlqName string, muts []types.Mutation,
+) error {
+	if len(muts) == 0 {
+		return nil
+	}
+	var err error
+	if txErr := a.withSavepoint(ctx, tx, func() error {
+		err = a.applyMuts(ctx, tx, muts, false)
+		return err
+	}); txErr != nil {
+		return txErr
+	}
+	if err == nil || ctx.Err() != nil || retry.IsRetryable(err) {
+		return err
+	}
+
+	if len(muts) > 1 {
+		mid := len(muts) / 2
+		if err := a.isolate(ctx, tx, dlqName, muts[:mid]); err != nil {
+			return err
+		}
+		return a.isolate(ctx, tx, dlqName, muts[mid:])
+	}
+
+	q, dlqErr := a.dlqs.Get(ctx, a.target.Schema(), dlqName)
+	if dlqErr != nil {
+		return dlqErr
+	}
+	if dlqErr := q.EnqueueError(ctx, tx, muts[0], err); dlqErr != nil {
+		return dlqErr
+	}
+	a.isolated.Inc()
+	log.WithError(err).WithFields(log.Fields{
+		"dlq":    dlqName,
+		"key":    string(muts[0].Key),
+		"target": a.target,
+	}).Warn("mutation could not be applied and was sent to a dead-letter queue")
+	return nil
+}
+
+// withSavepoint invokes the callback. If the querier is a transaction
+// and the callback returns an error, the callback's effects will be
+// rolled back so that the transaction may continue to be used. The
+// callback's error is not returned; an error is returned only if the
+// savepoint could not be managed, in which case the transaction is no
+// longer usable.
+func (a *apply) withSavepoint(ctx context.Context, tx types.TargetQuerier, fn func() error) error {
+	sqlTx, ok := tx.(*sql.Tx)
+	if !ok {
+		_ = fn()
+		return nil
+	}
+	// SQL Server uses its own syntax for savepoints.
+	create, rollback := "SAVEPOINT ", "ROLLBACK TO SAVEPOINT "
+	if a.product == types.ProductSQLServer {
+		create, rollback = "SAVE TRANSACTION ", "ROLLBACK TRANSACTION "
+	}
+	if _, err := sqlTx.ExecContext(ctx, create+isolateSavepoint); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := sqlTx.ExecContext(ctx, rollback+isolateSavepoint); rbErr != nil {
+			return errors.Wrapf(rbErr, "could not roll back after error: %v", err)
+		}
+		return nil
+	}
+	// Oracle and SQL Server release savepoints when the transaction
+	// ends.
+	if a.product == types.ProductOracle || a.product == types.ProductSQLServer {
+		return nil
+	}
+	_, err := sqlTx.ExecContext(ctx, "RELEASE SAVEPOINT "+isolateSavepoint)
+	return errors.WithStack(err)
+}
@@ -0,0 +1,349 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+// This file contains the bulk-loading support, which stages upserts in
+// a CSV file, COPYs the file into a scratch table, and then merges the
+// scratch table into the target with a single statement.
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/batches"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/merge"
+	"github.com/cockroachdb/cdc-sink/internal/util/pjson"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	loadAlias = "s"             // The alias of the scratch table in the load templates.
+	loadTable = "cdc_sink_load" // A per-connection temporary table.
+)
+
+// loadColumn returns the name of the scratch-table column that holds
+// the value for the 1-based substitution parameter.
+func loadColumn(param int) string {
+	return fmt.Sprintf("p%d", param)
+}
+
+// loader is a [types.Applier] which will bulk-load mutations into the
+// target table, even if the table has not been configured to do so.
+// Instances are vended by [factory.GetBulk].
+type loader struct {
+	*apply
+}
+
+var _ types.Applier = (*loader)(nil)
+
+// Apply implements [types.Applier].
+func (l *loader) Apply(ctx context.Context, tx types.TargetQuerier, muts []types.Mutation) error {
+	return l.applyMuts(ctx, tx, muts, true)
+}
+
+// canLoadLocked returns the target pool if the mutations can be
+// bulk-loaded. Bulk-loading requires a dedicated connection, so it is
+// only available when the caller is not already in a transaction.
+func (a *apply) canLoadLocked(
+	tx types.TargetQuerier, preferLoad bool,
+) (*types.TargetPool, bool) {
+	tmpl := a.mu.templates
+	if tmpl.load == nil || !(preferLoad || tmpl.BulkLoad) {
+		return nil, false
+	}
+	// The merge statement is unconditional.
+	if len(tmpl.Conditions) > 0 || tmpl.Deadlines.Len() > 0 {
+		return nil, false
+	}
+	// Redshift cannot COPY FROM STDIN, so we'll fall back to the
+	// statement templates if there's no staging area to upload to.
+	if a.product == types.ProductRedshift && a.stage == nil {
+		return nil, false
+	}
+	pool, ok := tx.(*types.TargetPool)
+	return pool, ok
+}
+
+// loadLocked applies deletions using the usual DELETE statements and
+// bulk-loads the upserts. The mutations must already be de-duplicated.
+func (a *apply) loadLocked(
+	ctx context.Context, pool *types.TargetPool, muts []types.Mutation,
+) error {
+	var deletes, upserts []types.Mutation
+	for i := range muts {
+		if muts[i].IsDelete() {
+			deletes = append(deletes, muts[i])
+		} else {
+			upserts = append(upserts, muts[i])
+		}
+	}
+
+	if err := batches.Batch(len(deletes), func(begin, end int) error {
+		return a.deleteLocked(ctx, pool, deletes[begin:end])
+	}); err != nil {
+		return err
+	}
+	if len(upserts) == 0 {
+		return nil
+	}
+	start := time.Now()
+
+	bags := make([]*merge.Bag, len(upserts))
+	if err := pjson.Decode(ctx, bags,
+		func(i int) []byte {
+			bags[i] = a.newBagLocked()
+			return upserts[i].Data
+		},
+	); err != nil {
+		return err
+	}
+	allArgs, err := a.upsertArgsLocked(bags)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "cdc-sink-load-*.csv")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+	if err := a.writeLoadFileLocked(file, allArgs); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer conn.Close()
+
+	var upserted int64
+	if err := conn.Raw(func(driverConn any) error {
+		pgxConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.Errorf("bulk loading requires a pgx connection, had %T", driverConn)
+		}
+		var err error
+		upserted, err = a.copyLocked(ctx, pgxConn.Conn(), file)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	a.upserts.Add(float64(upserted))
+	log.WithFields(log.Fields{
+		"duration": time.Since(start),
+		"proposed": len(upserts),
+		"target":   a.target,
+		"upserted": upserted,
+	}).Debug("bulk-loaded rows")
+	return nil
+}
+
+// copyLocked loads the file into the scratch table and merges it into
+// the target table within a single transaction.
+func (a *apply) copyLocked(ctx context.Context, conn *pgx.Conn, file *os.File) (int64, error) {
+	mergeSQL, err := a.mu.templates.loadExpr()
+	if err != nil {
+		return 0, err
+	}
+	scratch := ident.New(loadTable)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Scope the setting to the transaction, so that it doesn't leak to
+	// other users of the pooled connection.
+	if a.product == types.ProductCockroachDB {
+		if _, err := tx.Exec(ctx, "SET LOCAL experimental_enable_temp_tables = 'on'"); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, a.loadTableDDLLocked()); err != nil {
+		return 0, errors.Wrap(err, "could not create scratch table")
+	}
+
+	// Redshift can only COPY from external storage, so we'll upload
+	// the file to the staging area and point the database at it.
+	if a.stage != nil && a.product == types.ProductRedshift {
+		name := fmt.Sprintf("%s/%s.csv", a.target.Raw(), uuid.NewString())
+		if err := a.stage.Put(ctx, name, file); err != nil {
+			return 0, err
+		}
+		defer func() {
+			if err := a.stage.Delete(context.Background(), name); err != nil {
+				log.WithError(err).WithField("object", a.stage.URL(name)).Warn(
+					"could not remove staged file")
+			}
+		}()
+
+		q := fmt.Sprintf("COPY %s FROM '%s' %s CSV EMPTYASNULL",
+			scratch, strings.ReplaceAll(a.stage.URL(name), "'", "''"), a.stageOptions)
+		if _, err := tx.Exec(ctx, q); err != nil {
+			return 0, errors.Wrap(err, "could not copy from staged file")
+		}
+	} else {
+		q := fmt.Sprintf("COPY %s FROM STDIN WITH CSV", scratch)
+		if _, err := tx.Conn().PgConn().CopyFrom(ctx, file, q); err != nil {
+			return 0, errors.Wrap(err, "could not copy into scratch table")
+		}
+	}
+
+	tag, err := tx.Exec(ctx, mergeSQL)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf("DROP TABLE %s", scratch)); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// loadTableDDLLocked returns the CREATE statement for the scratch
+// table, which has one text column per substitution parameter.
+func (a *apply) loadTableDDLLocked() string {
+	colType := "TEXT"
+	if a.product == types.ProductRedshift {
+		// Redshift's TEXT is an alias for VARCHAR(256).
+		colType = "VARCHAR(65535)"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "CREATE TEMP TABLE %s (", ident.New(loadTable))
+	for param := 1; param <= a.mu.templates.UpsertParameterCount; param++ {
+		if param > 1 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%s %s", loadColumn(param), colType)
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// writeLoadFileLocked writes the row-major upsert arguments as CSV.
+// Non-null values are always quoted so that the target can
+// distinguish a NULL from an empty string.
+func (a *apply) writeLoadFileLocked(w io.Writer, allArgs []any) error {
+	cols := make([]types.ColData, a.mu.templates.UpsertParameterCount)
+	if len(cols) == 0 {
+		return errors.Errorf("no values to load into %s", a.target)
+	}
+	// Validity slots are left as zero values.
+	_ = a.mu.templates.Positions.Range(func(_ ident.Ident, pos positionalColumn) error {
+		if pos.UpsertIndex >= 0 {
+			cols[pos.UpsertIndex] = pos.ColData
+		}
+		return nil
+	})
+
+	out := bufio.NewWriter(w)
+	for idx, arg := range allArgs {
+		colIdx := idx % len(cols)
+		if colIdx > 0 {
+			_ = out.WriteByte(',')
+		}
+		if arg != nil {
+			text, err := loadText(cols[colIdx], arg)
+			if err != nil {
+				return errors.Wrapf(err, "column %s", cols[colIdx].Name)
+			}
+			_ = out.WriteByte('"')
+			_, _ = out.WriteString(strings.ReplaceAll(text, `"`, `""`))
+			_ = out.WriteByte('"')
+		}
+		if colIdx == len(cols)-1 {
+			_ = out.WriteByte('\n')
+		}
+	}
+	return errors.WithStack(out.Flush())
+}
+
+// arrayEscaper escapes an element of an array literal.
+var arrayEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// loadText converts a reified upsert argument into the text form that
+// the target will cast to the column's type.
+func loadText(col types.ColData, value any) (string, error) {
+	switch t := value.(type) {
+	case string:
+		return t, nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	case []any:
+		// Array columns use the array-literal syntax, while JSON arrays
+		// fall through to the default case.
+		if !strings.HasSuffix(col.Type, "[]") {
+			break
+		}
+		var sb strings.Builder
+		sb.WriteByte('{')
+		for idx, elt := range t {
+			if idx > 0 {
+				sb.WriteByte(',')
+			}
+			switch elt.(type) {
+			case nil:
+				sb.WriteString("NULL")
+			case []any:
+				// Nested arrays aren't quoted.
+				text, err := loadText(col, elt)
+				if err != nil {
+					return "", err
+				}
+				sb.WriteString(text)
+			default:
+				text, err := loadText(types.ColData{}, elt)
+				if err != nil {
+					return "", err
+				}
+				sb.WriteByte('"')
+				sb.WriteString(arrayEscaper.Replace(text))
+				sb.WriteByte('"')
+			}
+		}
+		sb.WriteByte('}')
+		return sb.String(), nil
+	}
+	buf, err := json.Marshal(value)
+	return string(buf), errors.WithStack(err)
+}
@@ -0,0 +1,95 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/objstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanLoad(t *testing.T) {
+	r := require.New(t)
+	stage, err := objstore.OpenDir(t.TempDir())
+	r.NoError(err)
+
+	tcs := []struct {
+		product  types.Product
+		stage    objstore.Bucket
+		expected bool
+	}{
+		{types.ProductCockroachDB, nil, true},
+		{types.ProductPostgreSQL, nil, true},
+		{types.ProductRedshift, nil, false},
+		{types.ProductRedshift, stage, true},
+	}
+
+	for idx, tc := range tcs {
+		t.Run(fmt.Sprintf("%d", idx), func(t *testing.T) {
+			a := &apply{product: tc.product, stage: tc.stage}
+			a.mu.templates = &templates{
+				columnMapping: &columnMapping{Deadlines: &ident.Map[time.Duration]{}},
+				load:          template.New("load"),
+			}
+			pool := &types.TargetPool{}
+
+			found, ok := a.canLoadLocked(pool, true)
+			require.Equal(t, tc.expected, ok)
+			if ok {
+				require.Same(t, pool, found)
+			}
+
+			// Loading requires a dedicated connection.
+			_, ok = a.canLoadLocked((*sql.Tx)(nil), true)
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestLoadText(t *testing.T) {
+	tcs := []struct {
+		colType  string
+		value    any
+		expected string
+	}{
+		{"STRING", "hello", "hello"},
+		{"STRING", "", ""},
+		{"BOOL", true, "true"},
+		{"INT8", json.Number("42"), "42"},
+		{"JSONB", map[string]any{"a": []any{1, "b"}}, `{"a":[1,"b"]}`},
+		{"JSONB", []any{"a", nil}, `["a",null]`},
+		{"STRING[]", []any{"a", nil, `q"b\`}, `{"a",NULL,"q\"b\\"}`},
+		{"INT8[]", []any{[]any{json.Number("1")}, []any{}}, `{{"1"},{}}`},
+	}
+
+	for idx, tc := range tcs {
+		t.Run(fmt.Sprintf("%d", idx), func(t *testing.T) {
+			r := require.New(t)
+			s, err := loadText(types.ColData{Type: tc.colType}, tc.value)
+			r.NoError(err)
+			r.Equal(tc.expected, s)
+		})
+	}
+}
@@ -40,6 +40,10 @@ var (
 		Name: "apply_errors_total",
 		Help: "the number of times an error was encountered while applying mutations",
 	}, metrics.TableLabels)
+	applyIsolated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "apply_isolated_total",
+		Help: "the number of mutations which could not be applied and were sent to an error DLQ",
+	}, metrics.TableLabels)
 	applyResolves = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "apply_resolves_total",
 		Help: "the number of rows that experienced a CAS conflict and which were resolved",
@@ -21,6 +21,7 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/diag"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/objstore"
 	"github.com/google/wire"
 )
 
@@ -34,6 +35,7 @@ var Set = wire.NewSet(
 // instances.
 func ProvideFactory(
 	cache *types.TargetStatements,
+	cfg *Config,
 	configs *applycfg.Configs,
 	diags *diag.Diagnostics,
 	dlqs types.DLQs,
@@ -41,11 +43,19 @@ func ProvideFactory(
 	watchers types.Watchers,
 ) (types.Appliers, func(), error) {
 	f := &factory{
-		cache:    cache,
-		configs:  configs,
-		dlqs:     dlqs,
-		product:  target.Product,
-		watchers: watchers,
+		cache:        cache,
+		configs:      configs,
+		dlqs:         dlqs,
+		product:      target.Product,
+		stageOptions: cfg.BulkCopyOptions,
+		watchers:     watchers,
+	}
+	if cfg.BulkStageURL != "" {
+		var err error
+		f.stage, err = objstore.Open(cfg.BulkStageURL)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 	f.mu.instances = &ident.TableMap[*apply]{}
 	if err := diags.Register("apply", f); err != nil {
@@ -66,6 +66,16 @@ var (
 		// using whitespace-consuming template markers.
 		"sp": func() string { return " " },
 
+		// seq returns the integers 1 through n, inclusive. This is
+		// used to refer to every substitution parameter in a statement.
+		"seq": func(n int) []int {
+			ret := make([]int, n)
+			for i := range ret {
+				ret[i] = i + 1
+			}
+			return ret
+		},
+
 		// qualify is used with the "join" template to emit a list of
 		// qualified database identifiers. The prefix is prepended to
 		// each column's name using a dot separator.
@@ -92,10 +102,14 @@ var (
 		},
 	}
 
-	tmplCRDB *template.Template
-	tmplOra  *template.Template
-	tmplMy   *template.Template
-	tmplPG   *template.Template
+	tmplCH       *template.Template
+	tmplCRDB     *template.Template
+	tmplOra      *template.Template
+	tmplMy       *template.Template
+	tmplPG       *template.Template
+	tmplRedshift *template.Template
+	tmplSQLite   *template.Template
+	tmplMSSQL    *template.Template
 )
 
 // The error handling in this init function is panicky, since this
@@ -151,6 +165,22 @@ func loadTemplates(from fs.FS, override string) error {
 		return err
 	}
 	tmplPG, err = load("pg")
+	if err != nil {
+		return err
+	}
+	tmplRedshift, err = load("redshift")
+	if err != nil {
+		return err
+	}
+	tmplSQLite, err = load("sqlite")
+	if err != nil {
+		return err
+	}
+	tmplMSSQL, err = load("mssql")
+	if err != nil {
+		return err
+	}
+	tmplCH, err = load("clickhouse")
 	return err
 }
 
@@ -164,12 +194,22 @@ type templates struct {
 	BulkDelete bool
 	BulkUpsert bool
 
+	// If true, the target has no upsert-like statement. The apply code
+	// will execute the delete template for the keys being upserted
+	// before executing the upsert template, which is a plain INSERT.
+	// This requires row-major (i.e. non-bulk) upsert arguments.
+	DeleteBeforeUpsert bool
+
 	conditional *template.Template
+	conflicts   *template.Template // May be nil; see conflictsExpr.
 	delete      *template.Template
+	load        *template.Template // May be nil if unsupported.
 	upsert      *template.Template
 
 	// The variables below here are updated during evaluation.
 	ForDelete bool // True if we only iterate over PKs to delete
+	ForLoad   bool // True if values are read from the LoadTable.
+	ForLock   bool // True if the conflicts query only locks target rows.
 	RowCount  int  // The number of rows to be applied.
 }
 
@@ -180,9 +220,38 @@ func newTemplates(mapping *columnMapping) (*templates, error) {
 	ret := &templates{columnMapping: mapping}
 
 	switch mapping.Product {
+	case types.ProductClickHouse:
+		// ClickHouse has no upsert, so the templates append rows to
+		// the table with the version of the mutation. The table's
+		// engine must collapse rows with the same key.
+		if len(mapping.Conditions) > 0 || mapping.Deadlines.Len() > 0 {
+			return nil, errors.Errorf(
+				"compare-and-set and deadlines are not supported for %s: %s",
+				mapping.Product, mapping.TableName)
+		}
+		if len(mapping.PKDelete) == 0 {
+			return nil, errors.Errorf("table %s must declare a sorting key", mapping.TableName)
+		}
+		if mapping.VersionCol.Name.Empty() || mapping.DeletedCol.Name.Empty() {
+			return nil, errors.Errorf("table %s must have %s and %s columns",
+				mapping.TableName, versionColumn, deletedColumn)
+		}
+		// See versionOf.
+		switch mapping.VersionCol.Type {
+		case "UInt128", "UInt256":
+		default:
+			return nil, errors.Errorf("column %s in %s must be a UInt128 or UInt256, not %s",
+				mapping.VersionCol.Name, mapping.TableName, mapping.VersionCol.Type)
+		}
+		ret.BulkDelete = true
+		ret.BulkUpsert = true
+		ret.delete = tmplCH.Lookup("delete.tmpl")
+		ret.upsert = tmplCH.Lookup("upsert.tmpl")
+
 	case types.ProductCockroachDB:
 		ret.conditional = tmplCRDB.Lookup("conditional.tmpl")
 		ret.delete = tmplCRDB.Lookup("delete.tmpl")
+		ret.load = tmplCRDB.Lookup("load.tmpl")
 		ret.upsert = tmplCRDB.Lookup("upsert.tmpl")
 
 	case types.ProductMySQL:
@@ -193,7 +262,11 @@ func newTemplates(mapping *columnMapping) (*templates, error) {
 	case types.ProductOracle:
 		// Bulk execution of DELETE not supported. See:
 		// github.com/sijms/go-ora/v2/command.go
+		//
+		// The MERGE statement can't return the rows that block a
+		// conditional upsert, so they are read back separately.
 		ret.BulkUpsert = true
+		ret.conflicts = tmplOra.Lookup("conflicts.tmpl")
 		ret.delete = tmplOra.Lookup("delete.tmpl")
 		ret.upsert = tmplOra.Lookup("upsert.tmpl")
 		ret.conditional = ret.upsert
@@ -201,12 +274,57 @@ func newTemplates(mapping *columnMapping) (*templates, error) {
 	case types.ProductPostgreSQL:
 		ret.conditional = tmplPG.Lookup("conditional.tmpl")
 		ret.delete = tmplPG.Lookup("delete.tmpl")
+		ret.load = tmplPG.Lookup("load.tmpl")
 		ret.upsert = tmplPG.Lookup("upsert.tmpl")
 
+	case types.ProductRedshift:
+		// Redshift doesn't support the data-modifying CTEs that the
+		// conditional templates rely upon, so we reject any
+		// configuration that would require them.
+		if len(mapping.Conditions) > 0 || mapping.Deadlines.Len() > 0 {
+			return nil, errors.Errorf(
+				"compare-and-set and deadlines are not supported for %s: %s",
+				mapping.Product, mapping.TableName)
+		}
+		// PRIMARY KEY constraints are informational in Redshift, but
+		// we need them to know which rows to replace.
+		if len(mapping.PKDelete) == 0 {
+			return nil, errors.Errorf("table %s must declare a PRIMARY KEY", mapping.TableName)
+		}
+		ret.DeleteBeforeUpsert = true
+		ret.delete = tmplRedshift.Lookup("delete.tmpl")
+		ret.load = tmplRedshift.Lookup("load.tmpl")
+		ret.upsert = tmplRedshift.Lookup("upsert.tmpl")
+
+	case types.ProductSQLite:
+		ret.conditional = tmplSQLite.Lookup("conditional.tmpl")
+		ret.delete = tmplSQLite.Lookup("delete.tmpl")
+		ret.upsert = tmplSQLite.Lookup("upsert.tmpl")
+
+	case types.ProductSQLServer:
+		// The go-mssqldb driver doesn't support binding slices of
+		// values, so bulk execution is not supported.
+		ret.conditional = tmplMSSQL.Lookup("conditional.tmpl")
+		ret.delete = tmplMSSQL.Lookup("delete.tmpl")
+		ret.upsert = tmplMSSQL.Lookup("upsert.tmpl")
+
 	default:
 		return nil, errors.Errorf("unsupported product %s", mapping.Product)
 	}
 
+	if mapping.BulkLoad {
+		if ret.load == nil {
+			return nil, errors.Errorf("bulk loading is not supported for %s: %s",
+				mapping.Product, mapping.TableName)
+		}
+		// The merge from the scratch table is unconditional.
+		if len(mapping.Conditions) > 0 || mapping.Deadlines.Len() > 0 {
+			return nil, errors.Errorf(
+				"bulk loading cannot be combined with compare-and-set or deadlines: %s",
+				mapping.TableName)
+		}
+	}
+
 	return ret, nil
 }
 
@@ -223,10 +341,15 @@ type varPair struct {
 	// indicates that the varPair has a constant expression which
 	// does not depend on an injected value.
 	Param int
+	// The SQL text used to refer to Param: $1, :ref1, ?1, @p1, r.1, or a
+	// column in the LoadTable.
+	Ref string
 	// A 1-based parameter for a boolean value to indicate if a value
 	// for the column was present in the original payload. A zero
 	// value means the template should always expect a value.
 	ValidityParam int
+	// The SQL text used to refer to ValidityParam.
+	ValidityRef string
 }
 
 // Vars is a generator function that returns windows of 1-based
@@ -239,6 +362,29 @@ func (t *templates) Vars() ([][]varPair, error) {
 		cols = t.PKDelete
 	}
 
+	var ref func(param int) string
+	switch {
+	case t.ForLoad:
+		ref = func(param int) string { return fmt.Sprintf("%s.%s", loadAlias, loadColumn(param)) }
+	case t.Product == types.ProductClickHouse:
+		// The parameters are zipped into a tuple; see the templates.
+		ref = func(param int) string { return fmt.Sprintf("r.%d", param) }
+	case t.Product == types.ProductCockroachDB,
+		t.Product == types.ProductPostgreSQL,
+		t.Product == types.ProductRedshift:
+		ref = func(param int) string { return fmt.Sprintf("$%d", param) }
+	case t.Product == types.ProductMySQL:
+		ref = func(int) string { return "?" }
+	case t.Product == types.ProductOracle:
+		ref = func(param int) string { return fmt.Sprintf(":ref%d", param) }
+	case t.Product == types.ProductSQLite:
+		ref = func(param int) string { return fmt.Sprintf("?%d", param) }
+	case t.Product == types.ProductSQLServer:
+		ref = func(param int) string { return fmt.Sprintf("@p%d", param) }
+	default:
+		return nil, errors.Errorf("unimplemented product %s", t.Product)
+	}
+
 	for row := range ret {
 		ret[row] = make([]varPair, len(cols))
 		for colIdx, col := range cols {
@@ -259,22 +405,19 @@ func (t *templates) Vars() ([][]varPair, error) {
 				// ValidityIndex is optional.
 				if positions.ValidityIndex >= 0 {
 					vp.ValidityParam = offset + positions.ValidityIndex + 1
+					vp.ValidityRef = ref(vp.ValidityParam)
 				}
 			}
+			vp.Ref = ref(vp.Param)
 
 			if pattern, ok := t.Exprs.Get(col.Name); ok {
-				var reference string
-				switch t.Product {
-				case types.ProductCockroachDB, types.ProductPostgreSQL:
-					reference = fmt.Sprintf("$%d", vp.Param)
-				case types.ProductMySQL:
-					reference = "?"
-				case types.ProductOracle:
-					reference = fmt.Sprintf(":ref%d", vp.Param)
-				default:
-					return nil, errors.Errorf("unimplemented product %s", t.Product)
+				reference := vp.Ref
+				if t.ForLoad {
+					// The scratch table only contains text, so we cast
+					// the value to approximate the type inference that
+					// the database performs for a substitution param.
+					reference = fmt.Sprintf("(%s::%s)", reference, col.Type)
 				}
-
 				vp.Expr = strings.ReplaceAll(pattern, applycfg.SubstitutionToken, reference)
 			}
 
@@ -284,6 +427,54 @@ func (t *templates) Vars() ([][]varPair, error) {
 	return ret, nil
 }
 
+// VersionParam returns the 1-based substitution parameter that holds
+// the row version for a Versioned target. Those targets use bulk
+// templates, so this is the last parameter of the only row.
+func (t *templates) VersionParam() int {
+	if t.ForDelete {
+		return t.DeleteParameterCount
+	}
+	return t.UpsertParameterCount
+}
+
+// conflictsExpr returns a query that reads back the rows in the target
+// table which would block a conditional upsert. This is used for
+// targets whose conditional upsert statement cannot return those rows.
+// The query always uses row-major arguments.
+func (t *templates) conflictsExpr(rowCount int) (string, error) {
+	if t.conflicts == nil {
+		return "", errors.Errorf("conflict read-back is not supported for %s", t.Product)
+	}
+
+	// Make a copy that we can tweak.
+	cpy := *t
+	cpy.RowCount = rowCount
+
+	var buf strings.Builder
+	err := t.conflicts.Execute(&buf, &cpy)
+	return buf.String(), errors.WithStack(err)
+}
+
+// lockExpr returns a query that locks the rows in the target table
+// which have the same keys as the proposed rows. This is used for
+// targets that read back conflicting rows with conflictsExpr, so that
+// the conflicts cannot change before the upsert is executed. The query
+// uses the same arguments as conflictsExpr.
+func (t *templates) lockExpr(rowCount int) (string, error) {
+	if t.conflicts == nil {
+		return "", errors.Errorf("conflict read-back is not supported for %s", t.Product)
+	}
+
+	// Make a copy that we can tweak.
+	cpy := *t
+	cpy.ForLock = true
+	cpy.RowCount = rowCount
+
+	var buf strings.Builder
+	err := t.conflicts.Execute(&buf, &cpy)
+	return buf.String(), errors.WithStack(err)
+}
+
 func (t *templates) deleteExpr(rowCount int) (string, error) {
 	if t.BulkDelete {
 		rowCount = 1
@@ -299,6 +490,29 @@ func (t *templates) deleteExpr(rowCount int) (string, error) {
 	return buf.String(), errors.WithStack(err)
 }
 
+// loadExpr returns the statement(s) that merge the contents of the
+// LoadTable into the target table.
+func (t *templates) loadExpr() (string, error) {
+	if t.load == nil {
+		return "", errors.Errorf("bulk loading is not supported for %s", t.Product)
+	}
+
+	// Make a copy that we can tweak.
+	cpy := *t
+	cpy.ForLoad = true
+	cpy.RowCount = 1
+
+	var buf strings.Builder
+	err := t.load.Execute(&buf, &cpy)
+	return buf.String(), errors.WithStack(err)
+}
+
+// LoadTable returns the name of the per-connection scratch table that
+// bulk-loaded data is copied into.
+func (t *templates) LoadTable() ident.Ident {
+	return ident.New(loadTable)
+}
+
 // upsertExpr may return a conditional sql statement, unless forceUpsert
 // is set to true.
 func (t *templates) upsertExpr(rowCount int, mode applyMode) (string, error) {
@@ -17,6 +17,7 @@
 package apply
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -27,6 +28,8 @@ import (
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/applycfg"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/merge"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -302,6 +305,112 @@ func TestQueryTemplatesOra(t *testing.T) {
 	}
 }
 
+func TestQueryTemplatesRedshift(t *testing.T) {
+	global := &templateGlobal{
+		cols: []types.ColData{
+			{
+				Name:    ident.New("pk0"),
+				Primary: true,
+				Type:    "CHARACTER VARYING(256)",
+			},
+			{
+				Name:    ident.New("pk1"),
+				Primary: true,
+				Type:    "BIGINT",
+			},
+			{
+				Name: ident.New("val0"),
+				Type: "CHARACTER VARYING(256)",
+			},
+			{
+				Name: ident.New("val1"),
+				Type: "CHARACTER VARYING(256)",
+			},
+			{
+				Ignored: true,
+				Name:    ident.New("ignored_val"),
+				Primary: false,
+				Type:    "BIGINT",
+			},
+			// Field types with special handling
+			{
+				Name: ident.New("geom"),
+				Type: "GEOMETRY",
+			},
+			{
+				Name: ident.New("doc"),
+				Type: "SUPER",
+			},
+			{
+				Name:        ident.New("has_default"),
+				Type:        "BIGINT",
+				DefaultExpr: "expr()",
+			},
+		},
+		dir:     "redshift",
+		product: types.ProductRedshift,
+		tableID: ident.NewTable(
+			ident.MustSchema(ident.New("database"), ident.New("schema")),
+			ident.New("table")),
+	}
+
+	tcs := []*templateTestCase{
+		{
+			name: "base",
+		},
+		{
+			name: "ignore",
+			cfg: &applycfg.Config{
+				Ignore: ident.MapOf[bool](
+					ident.New("geom"), true,
+					ident.New("doc"), true,
+				),
+			},
+		},
+		{
+			// Changing the source names should have no effect on the
+			// SQL that gets generated; we only care about the different
+			// value when looking up values in the incoming mutation.
+			name: "source_names",
+			cfg: &applycfg.Config{
+				SourceNames: ident.MapOf[applycfg.SourceColumn](
+					ident.New("val1"), ident.New("valRenamed"),
+					ident.New("unknown"), ident.New("is ok"),
+				),
+			},
+		},
+		{
+			// Verify user-configured expressions, with zero, one, and
+			// multiple uses of the substitution position.
+			name: "expr",
+			cfg: &applycfg.Config{
+				Exprs: ident.MapOf[string](
+					ident.New("val0"), `'fixed'`, // Doesn't consume a parameter slot.
+					ident.New("val1"), `$0||'foobar'`,
+					ident.New("pk1"), `$0+$0`,
+				),
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			checkTemplate(t, global, tc)
+		})
+	}
+
+	// Conditional upserts aren't available in Redshift.
+	t.Run("cas", func(t *testing.T) {
+		r := require.New(t)
+		cfg := applycfg.NewConfig()
+		cfg.CASColumns = []ident.Ident{ident.New("val1")}
+		mapping, err := newColumnMapping(cfg, global.cols, global.product, global.tableID)
+		r.NoError(err)
+		_, err = newTemplates(mapping)
+		r.ErrorContains(err, "not supported")
+	})
+}
+
 func TestQueryTemplatesPG(t *testing.T) {
 	t.Run("crdb", func(t *testing.T) { testQueryTemplates(t, types.ProductCockroachDB) })
 	t.Run("pg", func(t *testing.T) { testQueryTemplates(t, types.ProductPostgreSQL) })
@@ -453,6 +562,370 @@ func testQueryTemplates(t *testing.T, product types.Product) {
 	}
 }
 
+func TestQueryTemplatesSQLite(t *testing.T) {
+	global := &templateGlobal{
+		cols: []types.ColData{
+			{
+				Name:    ident.New("pk0"),
+				Primary: true,
+				Type:    "TEXT",
+			},
+			{
+				Name:    ident.New("pk1"),
+				Primary: true,
+				Type:    "INTEGER",
+			},
+			{
+				Name: ident.New("val0"),
+				Type: "TEXT",
+			},
+			{
+				Name: ident.New("val1"),
+				Type: "TEXT",
+			},
+			{
+				Ignored: true,
+				Name:    ident.New("ignored_val"),
+				Primary: false,
+				Type:    "INTEGER",
+			},
+			{
+				Name:        ident.New("has_default"),
+				Type:        "INTEGER",
+				DefaultExpr: "expr()",
+			},
+		},
+		dir:     "sqlite",
+		product: types.ProductSQLite,
+		tableID: ident.NewTable(
+			ident.MustSchema(ident.New("main")),
+			ident.New("table")),
+	}
+
+	tcs := []*templateTestCase{
+		{
+			name: "base",
+		},
+		{
+			name: "cas",
+			cfg: &applycfg.Config{
+				CASColumns: []ident.Ident{ident.New("val1"), ident.New("val0")},
+			},
+		},
+		{
+			name: "deadline",
+			cfg: &applycfg.Config{
+				Deadlines: ident.MapOf[time.Duration](
+					ident.New("val1"), time.Second,
+					ident.New("val0"), time.Hour,
+				),
+			},
+		},
+		{
+			name: "casDeadline",
+			cfg: &applycfg.Config{
+				CASColumns: []ident.Ident{ident.New("val1"), ident.New("val0")},
+				Deadlines: ident.MapOf[time.Duration](
+					ident.New("val0"), time.Hour,
+					ident.New("val1"), time.Second,
+				),
+			},
+		},
+		{
+			// This ignore setup results in a PK-only table.
+			name: "ignore",
+			cfg: &applycfg.Config{
+				Ignore: ident.MapOf[bool](
+					"val0", true,
+					"val1", true,
+				)},
+		},
+		{
+			// Changing the source names should have no effect on the
+			// SQL that gets generated; we only care about the different
+			// value when looking up values in the incoming mutation.
+			name: "source names",
+			cfg: &applycfg.Config{
+				SourceNames: ident.MapOf[applycfg.SourceColumn](
+					ident.New("val1"), ident.New("val1Renamed"),
+					ident.New("unknown"), ident.New("is ok"),
+				),
+			},
+		},
+		{
+			// Verify user-configured expressions, with zero, one, and
+			// multiple uses of the substitution position.
+			name: "expr",
+			cfg: &applycfg.Config{
+				Exprs: ident.MapOf[string](
+					ident.New("val0"), `'fixed'`, // Doesn't consume a parameter slot.
+					ident.New("val1"), `$0||'foobar'`,
+					ident.New("pk1"), `$0+$0`,
+				),
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			checkTemplate(t, global, tc)
+		})
+	}
+}
+
+func TestQueryTemplatesMSSQL(t *testing.T) {
+	global := &templateGlobal{
+		cols: []types.ColData{
+			{
+				Name:    ident.New("pk0"),
+				Primary: true,
+				Type:    "VARCHAR(256)",
+			},
+			{
+				Name:    ident.New("pk1"),
+				Primary: true,
+				Type:    "INT",
+			},
+			{
+				Name: ident.New("val0"),
+				Type: "VARCHAR(256)",
+			},
+			{
+				Name: ident.New("val1"),
+				Type: "VARCHAR(256)",
+			},
+			{
+				Ignored: true,
+				Name:    ident.New("ignored_val"),
+				Primary: false,
+				Type:    "INT",
+			},
+			{
+				Name:        ident.New("has_default"),
+				Type:        "BIGINT",
+				DefaultExpr: "expr()",
+			},
+		},
+		dir:     "mssql",
+		product: types.ProductSQLServer,
+		tableID: ident.NewTable(
+			ident.MustSchema(ident.New("database"), ident.New("schema")),
+			ident.New("table")),
+	}
+
+	tcs := []*templateTestCase{
+		{
+			name: "base",
+		},
+		{
+			name: "cas",
+			cfg: &applycfg.Config{
+				CASColumns: []ident.Ident{ident.New("val1"), ident.New("val0")},
+			},
+		},
+		{
+			name: "deadline",
+			cfg: &applycfg.Config{
+				Deadlines: ident.MapOf[time.Duration](
+					ident.New("val1"), time.Second,
+					ident.New("val0"), time.Hour,
+				),
+			},
+		},
+		{
+			name: "casDeadline",
+			cfg: &applycfg.Config{
+				CASColumns: []ident.Ident{ident.New("val1"), ident.New("val0")},
+				Deadlines: ident.MapOf[time.Duration](
+					ident.New("val0"), time.Hour,
+					ident.New("val1"), time.Second,
+				),
+			},
+		},
+		{
+			// A merge function requires the blocking rows to be
+			// returned from the conditional upsert.
+			name: "casMerge",
+			cfg: &applycfg.Config{
+				CASColumns: []ident.Ident{ident.New("val1"), ident.New("val0")},
+				Deadlines: ident.MapOf[time.Duration](
+					ident.New("val0"), time.Hour,
+				),
+				Merger: merge.Func(func(context.Context, *merge.Conflict) (*merge.Resolution, error) {
+					return nil, errors.New("unused")
+				}),
+			},
+		},
+		{
+			// This ignore setup results in a PK-only table.
+			name: "ignore",
+			cfg: &applycfg.Config{
+				Ignore: ident.MapOf[bool](
+					"val0", true,
+					"val1", true,
+				)},
+		},
+		{
+			// Changing the source names should have no effect on the
+			// SQL that gets generated; we only care about the different
+			// value when looking up values in the incoming mutation.
+			name: "source names",
+			cfg: &applycfg.Config{
+				SourceNames: ident.MapOf[applycfg.SourceColumn](
+					ident.New("val1"), ident.New("val1Renamed"),
+					ident.New("unknown"), ident.New("is ok"),
+				),
+			},
+		},
+		{
+			// Verify user-configured expressions, with zero, one, and
+			// multiple uses of the substitution position.
+			name: "expr",
+			cfg: &applycfg.Config{
+				Exprs: ident.MapOf[string](
+					ident.New("val0"), `'fixed'`, // Doesn't consume a parameter slot.
+					ident.New("val1"), `$0+'foobar'`,
+					ident.New("pk1"), `$0+$0`,
+				),
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			checkTemplate(t, global, tc)
+		})
+	}
+}
+
+func TestQueryTemplatesClickHouse(t *testing.T) {
+	global := &templateGlobal{
+		cols: []types.ColData{
+			{
+				Name:    ident.New("pk0"),
+				Primary: true,
+				Type:    "String",
+			},
+			{
+				Name:    ident.New("pk1"),
+				Primary: true,
+				Type:    "Int64",
+			},
+			{
+				Name: ident.New("val0"),
+				Type: "Nullable(String)",
+			},
+			{
+				Name: ident.New("val1"),
+				Type: "Nullable(String)",
+			},
+			{
+				Ignored: true,
+				Name:    ident.New("ignored_val"),
+				Primary: false,
+				Type:    "Int64",
+			},
+			{
+				Name:        ident.New("has_default"),
+				Type:        "Int64",
+				DefaultExpr: "expr()",
+			},
+			// Populated by the templates, not the payload.
+			{
+				Name: ident.New("version"),
+				Type: "UInt128",
+			},
+			{
+				Name: ident.New("is_deleted"),
+				Type: "UInt8",
+			},
+		},
+		dir:     "clickhouse",
+		product: types.ProductClickHouse,
+		tableID: ident.NewTable(
+			ident.MustSchema(ident.New("database")),
+			ident.New("table")),
+	}
+
+	tcs := []*templateTestCase{
+		{
+			name: "base",
+		},
+		{
+			// This ignore setup results in a PK-only table.
+			name: "ignore",
+			cfg: &applycfg.Config{
+				Ignore: ident.MapOf[bool](
+					"val0", true,
+					"val1", true,
+					"has_default", true,
+				)},
+		},
+		{
+			// Changing the source names should have no effect on the
+			// SQL that gets generated; we only care about the different
+			// value when looking up values in the incoming mutation.
+			name: "source_names",
+			cfg: &applycfg.Config{
+				SourceNames: ident.MapOf[applycfg.SourceColumn](
+					ident.New("val1"), ident.New("valRenamed"),
+					ident.New("unknown"), ident.New("is ok"),
+				),
+			},
+		},
+		{
+			// Verify user-configured expressions, with zero, one, and
+			// multiple uses of the substitution position.
+			name: "expr",
+			cfg: &applycfg.Config{
+				Exprs: ident.MapOf[string](
+					ident.New("val0"), `'fixed'`, // Doesn't consume a parameter slot.
+					ident.New("val1"), `concat($0, 'foobar')`,
+					ident.New("pk1"), `$0+$0`,
+				),
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			checkTemplate(t, global, tc)
+		})
+	}
+
+	// Conditional upserts aren't available in ClickHouse.
+	t.Run("cas", func(t *testing.T) {
+		r := require.New(t)
+		cfg := applycfg.NewConfig()
+		cfg.CASColumns = []ident.Ident{ident.New("val1")}
+		mapping, err := newColumnMapping(cfg, global.cols, global.product, global.tableID)
+		r.NoError(err)
+		_, err = newTemplates(mapping)
+		r.ErrorContains(err, "not supported")
+	})
+
+	// The version and deletion-flag columns are required.
+	t.Run("unversioned", func(t *testing.T) {
+		r := require.New(t)
+		cols := global.cols[:len(global.cols)-1]
+		mapping, err := newColumnMapping(applycfg.NewConfig(), cols, global.product, global.tableID)
+		r.NoError(err)
+		_, err = newTemplates(mapping)
+		r.ErrorContains(err, "must have")
+	})
+
+	// The version won't fit in a 64-bit column.
+	t.Run("narrow_version", func(t *testing.T) {
+		r := require.New(t)
+		cols := append([]types.ColData(nil), global.cols...)
+		cols[len(cols)-2].Type = "UInt64"
+		mapping, err := newColumnMapping(applycfg.NewConfig(), cols, global.product, global.tableID)
+		r.NoError(err)
+		_, err = newTemplates(mapping)
+		r.ErrorContains(err, "must be a UInt128 or UInt256")
+	})
+}
+
 type templateGlobal struct {
 	cols    []types.ColData
 	dir     string
@@ -496,6 +969,40 @@ func checkTemplate(t *testing.T, global *templateGlobal, tc *templateTestCase) {
 			fmt.Sprintf("testdata/%s/%s.delete.sql", global.dir, tc.name),
 			s)
 	})
+
+	// Conflicts are only read back from targets that require a
+	// separate query to do so.
+	if tmpls.conflicts != nil && (len(mapping.Conditions) > 0 || mapping.Deadlines.Len() > 0) {
+		t.Run("conflicts", func(t *testing.T) {
+			r := require.New(t)
+			s, err := tmpls.conflictsExpr(2)
+			r.NoError(err)
+			checkFile(t,
+				fmt.Sprintf("testdata/%s/%s.conflicts.sql", global.dir, tc.name),
+				s)
+		})
+
+		t.Run("lock", func(t *testing.T) {
+			r := require.New(t)
+			s, err := tmpls.lockExpr(2)
+			r.NoError(err)
+			checkFile(t,
+				fmt.Sprintf("testdata/%s/%s.lock.sql", global.dir, tc.name),
+				s)
+		})
+	}
+
+	// The bulk-load merge is unconditional.
+	if tmpls.load != nil && len(mapping.Conditions) == 0 && mapping.Deadlines.Len() == 0 {
+		t.Run("load", func(t *testing.T) {
+			r := require.New(t)
+			s, err := tmpls.loadExpr()
+			r.NoError(err)
+			checkFile(t,
+				fmt.Sprintf("testdata/%s/%s.load.sql", global.dir, tc.name),
+				s)
+		})
+	}
 }
 
 func checkFile(t *testing.T, path string, contents string) {
@@ -0,0 +1,3 @@
+INSERT INTO "database"."table" ("pk0","pk1","val0","val1","has_default","version","is_deleted")
+SELECT CAST(r.1 AS String),CAST(r.2 AS Int64),CAST(r.3 AS Nullable(String)),CAST(r.4 AS Nullable(String)),if(r.5, CAST(r.6 AS Int64), expr()),CAST(r.7 AS UInt128),0
+FROM (SELECT arrayJoin(arrayZip($1,$2,$3,$4,$5,$6,$7)) AS r)
\ No newline at end of file
@@ -0,0 +1,3 @@
+INSERT INTO "database"."table" ("pk0","pk1","val0","val1","has_default","version","is_deleted")
+SELECT CAST(r.1 AS String),CAST(r.2+r.2 AS Int64),CAST('fixed' AS Nullable(String)),CAST(concat(r.3, 'foobar') AS Nullable(String)),if(r.4, CAST(r.5 AS Int64), expr()),CAST(r.6 AS UInt128),0
+FROM (SELECT arrayJoin(arrayZip($1,$2,$3,$4,$5,$6)) AS r)
\ No newline at end of file
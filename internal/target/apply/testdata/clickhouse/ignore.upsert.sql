@@ -0,0 +1,3 @@
+INSERT INTO "database"."table" ("pk0","pk1","version","is_deleted")
+SELECT CAST(r.1 AS String),CAST(r.2 AS Int64),CAST(r.3 AS UInt128),0
+FROM (SELECT arrayJoin(arrayZip($1,$2,$3)) AS r)
\ No newline at end of file
@@ -0,0 +1,19 @@
+DECLARE @applied TABLE ("__idx__" INT);
+WITH data ("__idx__","pk0","pk1","val0","val1","has_default") AS (SELECT * FROM (VALUES
+(0,CAST(@p1 AS VARCHAR(256)),CAST(@p2 AS INT),CAST(@p3 AS VARCHAR(256)),CAST(@p4 AS VARCHAR(256)),CASE WHEN @p5 IS NOT NULL THEN CAST(@p6 AS BIGINT) ELSE expr() END),
+(1,CAST(@p7 AS VARCHAR(256)),CAST(@p8 AS INT),CAST(@p9 AS VARCHAR(256)),CAST(@p10 AS VARCHAR(256)),CASE WHEN @p11 IS NOT NULL THEN CAST(@p12 AS BIGINT) ELSE expr() END)
+) AS v ("__idx__","pk0","pk1","val0","val1","has_default")),
+deadlined AS (SELECT * FROM data WHERE("val0" > DATEADD(SECOND, -3600, SYSDATETIMEOFFSET())))
+MERGE INTO "database"."schema"."table" WITH (HOLDLOCK) AS t
+USING deadlined AS x
+ON (t."pk0" = x."pk0" AND t."pk1" = x."pk1")
+WHEN MATCHED AND ((x."val1" > t."val1") OR (x."val1" = t."val1" AND x."val0" > t."val0")) THEN UPDATE SET "val0" = x."val0", "val1" = x."val1", "has_default" = x."has_default"
+WHEN NOT MATCHED THEN INSERT ("pk0","pk1","val0","val1","has_default") VALUES (x."pk0", x."pk1", x."val0", x."val1", x."has_default")
+OUTPUT x."__idx__" INTO @applied;
+SELECT x."__idx__", t."pk0",t."pk1",t."val0",t."val1",t."has_default" FROM "database"."schema"."table" AS t
+JOIN (VALUES
+(0,CAST(@p1 AS VARCHAR(256)),CAST(@p2 AS INT)),
+(1,CAST(@p7 AS VARCHAR(256)),CAST(@p8 AS INT))
+) AS x ("__idx__","pk0","pk1")
+ON (t."pk0" = x."pk0" AND t."pk1" = x."pk1")
+WHERE x."__idx__" NOT IN (SELECT "__idx__" FROM @applied)
\ No newline at end of file
@@ -0,0 +1,10 @@
+WITH data ("__idx__","pk0","pk1","val0","val1","has_default") AS (SELECT * FROM (VALUES
+(0,CAST(@p1 AS VARCHAR(256)),CAST(@p2 AS INT),CAST(@p3 AS VARCHAR(256)),CAST(@p4 AS VARCHAR(256)),CASE WHEN @p5 IS NOT NULL THEN CAST(@p6 AS BIGINT) ELSE expr() END),
+(1,CAST(@p7 AS VARCHAR(256)),CAST(@p8 AS INT),CAST(@p9 AS VARCHAR(256)),CAST(@p10 AS VARCHAR(256)),CASE WHEN @p11 IS NOT NULL THEN CAST(@p12 AS BIGINT) ELSE expr() END)
+) AS v ("__idx__","pk0","pk1","val0","val1","has_default")),
+deadlined AS (SELECT * FROM data WHERE("val0" > DATEADD(SECOND, -3600, SYSDATETIMEOFFSET()))AND("val1" > DATEADD(SECOND, -1, SYSDATETIMEOFFSET())))
+MERGE INTO "database"."schema"."table" WITH (HOLDLOCK) AS t
+USING deadlined AS x
+ON (t."pk0" = x."pk0" AND t."pk1" = x."pk1")
+WHEN MATCHED THEN UPDATE SET "val0" = x."val0", "val1" = x."val1", "has_default" = x."has_default"
+WHEN NOT MATCHED THEN INSERT ("pk0","pk1","val0","val1","has_default") VALUES (x."pk0", x."pk1", x."val0", x."val1", x."has_default");
\ No newline at end of file
@@ -0,0 +1,8 @@
+MERGE INTO "database"."schema"."table" WITH (HOLDLOCK) AS t
+USING (VALUES
+(CAST(@p1 AS VARCHAR(256)),CAST(@p2+@p2 AS INT),CAST('fixed' AS VARCHAR(256)),CAST(@p3+'foobar' AS VARCHAR(256)),CASE WHEN @p4 IS NOT NULL THEN CAST(@p5 AS BIGINT) ELSE expr() END),
+(CAST(@p6 AS VARCHAR(256)),CAST(@p7+@p7 AS INT),CAST('fixed' AS VARCHAR(256)),CAST(@p8+'foobar' AS VARCHAR(256)),CASE WHEN @p9 IS NOT NULL THEN CAST(@p10 AS BIGINT) ELSE expr() END)
+) AS x ("pk0","pk1","val0","val1","has_default")
+ON (t."pk0" = x."pk0" AND t."pk1" = x."pk1")
+WHEN MATCHED THEN UPDATE SET "val0" = x."val0", "val1" = x."val1", "has_default" = x."has_default"
+WHEN NOT MATCHED THEN INSERT ("pk0","pk1","val0","val1","has_default") VALUES (x."pk0", x."pk1", x."val0", x."val1", x."has_default");
\ No newline at end of file
@@ -0,0 +1,6 @@
+DELETE t FROM "database"."schema"."table" AS t
+JOIN (VALUES
+(CAST(@p1 AS VARCHAR(256)),CAST(@p2 AS INT)),
+(CAST(@p3 AS VARCHAR(256)),CAST(@p4 AS INT))
+) AS x ("pk0","pk1")
+ON (t."pk0" = x."pk0" AND t."pk1" = x."pk1")
\ No newline at end of file
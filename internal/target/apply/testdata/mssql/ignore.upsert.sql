@@ -0,0 +1,8 @@
+MERGE INTO "database"."schema"."table" WITH (HOLDLOCK) AS t
+USING (VALUES
+(CAST(@p1 AS VARCHAR(256)),CAST(@p2 AS INT),CASE WHEN @p3 IS NOT NULL THEN CAST(@p4 AS BIGINT) ELSE expr() END),
+(CAST(@p5 AS VARCHAR(256)),CAST(@p6 AS INT),CASE WHEN @p7 IS NOT NULL THEN CAST(@p8 AS BIGINT) ELSE expr() END)
+) AS x ("pk0","pk1","has_default")
+ON (t."pk0" = x."pk0" AND t."pk1" = x."pk1")
+WHEN MATCHED THEN UPDATE SET "has_default" = x."has_default"
+WHEN NOT MATCHED THEN INSERT ("pk0","pk1","has_default") VALUES (x."pk0", x."pk1", x."has_default");
\ No newline at end of file
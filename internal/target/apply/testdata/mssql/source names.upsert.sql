@@ -0,0 +1,8 @@
+MERGE INTO "database"."schema"."table" WITH (HOLDLOCK) AS t
+USING (VALUES
+(CAST(@p1 AS VARCHAR(256)),CAST(@p2 AS INT),CAST(@p3 AS VARCHAR(256)),CAST(@p4 AS VARCHAR(256)),CASE WHEN @p5 IS NOT NULL THEN CAST(@p6 AS BIGINT) ELSE expr() END),
+(CAST(@p7 AS VARCHAR(256)),CAST(@p8 AS INT),CAST(@p9 AS VARCHAR(256)),CAST(@p10 AS VARCHAR(256)),CASE WHEN @p11 IS NOT NULL THEN CAST(@p12 AS BIGINT) ELSE expr() END)
+) AS x ("pk0","pk1","val0","val1","has_default")
+ON (t."pk0" = x."pk0" AND t."pk1" = x."pk1")
+WHEN MATCHED THEN UPDATE SET "val0" = x."val0", "val1" = x."val1", "has_default" = x."has_default"
+WHEN NOT MATCHED THEN INSERT ("pk0","pk1","val0","val1","has_default") VALUES (x."pk0", x."pk1", x."val0", x."val1", x."has_default");
\ No newline at end of file
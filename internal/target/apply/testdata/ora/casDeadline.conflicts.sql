@@ -0,0 +1,15 @@
+WITH data ("__idx__", "pk0","pk1","val0","val1","has_default") AS (
+SELECT 0, CAST(:p1 AS VARCHAR(256)), CAST(:p2 AS INT), CAST(:p3 AS VARCHAR(256)), CAST(:p4 AS VARCHAR(256)), CASE WHEN :p5 IS NOT NULL THEN CAST(:p6 AS INT8) ELSE expr() END FROM DUAL UNION ALL 
+SELECT 1, CAST(:p7 AS VARCHAR(256)), CAST(:p8 AS INT), CAST(:p9 AS VARCHAR(256)), CAST(:p10 AS VARCHAR(256)), CASE WHEN :p11 IS NOT NULL THEN CAST(:p12 AS INT8) ELSE expr() END FROM DUAL
+),
+deadlined AS (SELECT * FROM data WHERE("val0"> (CURRENT_TIMESTAMP - NUMTODSINTERVAL(3600, 'SECOND')))AND("val1"> (CURRENT_TIMESTAMP - NUMTODSINTERVAL(1, 'SECOND')))),
+active AS (
+SELECT "pk0","pk1", "table"."val1","table"."val0"
+FROM "schema"."table" JOIN deadlined USING ("pk0","pk1")),
+action AS (
+SELECT deadlined."__idx__" FROM deadlined
+LEFT JOIN active USING ("pk0","pk1") WHERE active."val1" IS NULL OR
+(deadlined."val1",deadlined."val0") > (active."val1",active."val0"))
+SELECT data."__idx__", t."pk0",t."pk1",t."val0",t."val1",t."has_default" FROM "schema"."table" t
+JOIN data ON (t."pk0" = data."pk0" AND t."pk1" = data."pk1")
+WHERE data."__idx__" NOT IN (SELECT "__idx__" FROM action)
\ No newline at end of file
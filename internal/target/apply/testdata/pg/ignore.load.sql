@@ -0,0 +1,6 @@
+INSERT INTO "database"."schema"."table" (
+"pk0","pk1","val0","val1","enum","has_default"
+) SELECT s.p1::STRING,s.p2::INT8,s.p3::STRING,s.p4::STRING,s.p5::"database"."schema"."MyEnum",CASE WHEN s.p6::BOOLEAN THEN s.p7::INT8 ELSE expr() END
+FROM "cdc_sink_load" AS s
+ON CONFLICT ( "pk0","pk1" )
+DO UPDATE SET ("val0","val1","enum","has_default") = ROW(excluded."val0",excluded."val1",excluded."enum",excluded."has_default")
\ No newline at end of file
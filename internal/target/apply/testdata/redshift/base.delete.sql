@@ -0,0 +1,3 @@
+DELETE FROM "database"."schema"."table" WHERE
+("pk0"=$1::CHARACTER VARYING(256) AND "pk1"=$2::BIGINT) OR
+("pk0"=$3::CHARACTER VARYING(256) AND "pk1"=$4::BIGINT)
\ No newline at end of file
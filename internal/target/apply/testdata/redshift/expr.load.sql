@@ -0,0 +1,6 @@
+DELETE FROM "database"."schema"."table" USING "cdc_sink_load" AS s WHERE
+"table"."pk0"=s.p1::CHARACTER VARYING(256) AND "table"."pk1"=((s.p2::BIGINT)+(s.p2::BIGINT))::BIGINT;
+INSERT INTO "database"."schema"."table" (
+"pk0","pk1","val0","val1","geom","doc","has_default"
+) SELECT s.p1::CHARACTER VARYING(256),((s.p2::BIGINT)+(s.p2::BIGINT))::BIGINT,('fixed')::CHARACTER VARYING(256),((s.p3::CHARACTER VARYING(256))||'foobar')::CHARACTER VARYING(256),ST_GeomFromGeoJSON(s.p4),JSON_PARSE(s.p5),CASE WHEN s.p6::BOOLEAN THEN s.p7::BIGINT ELSE expr() END
+FROM "cdc_sink_load" AS s
\ No newline at end of file
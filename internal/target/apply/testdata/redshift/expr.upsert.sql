@@ -0,0 +1,5 @@
+INSERT INTO "database"."schema"."table" (
+"pk0","pk1","val0","val1","geom","doc","has_default"
+) VALUES
+($1::CHARACTER VARYING(256),($2+$2)::BIGINT,('fixed')::CHARACTER VARYING(256),($3||'foobar')::CHARACTER VARYING(256),ST_GeomFromGeoJSON($4),JSON_PARSE($5),CASE WHEN $6::BOOLEAN THEN $7::BIGINT ELSE expr() END),
+($8::CHARACTER VARYING(256),($9+$9)::BIGINT,('fixed')::CHARACTER VARYING(256),($10||'foobar')::CHARACTER VARYING(256),ST_GeomFromGeoJSON($11),JSON_PARSE($12),CASE WHEN $13::BOOLEAN THEN $14::BIGINT ELSE expr() END)
\ No newline at end of file
@@ -0,0 +1,6 @@
+DELETE FROM "database"."schema"."table" USING "cdc_sink_load" AS s WHERE
+"table"."pk0"=s.p1::CHARACTER VARYING(256) AND "table"."pk1"=s.p2::BIGINT;
+INSERT INTO "database"."schema"."table" (
+"pk0","pk1","val0","val1","has_default"
+) SELECT s.p1::CHARACTER VARYING(256),s.p2::BIGINT,s.p3::CHARACTER VARYING(256),s.p4::CHARACTER VARYING(256),CASE WHEN s.p5::BOOLEAN THEN s.p6::BIGINT ELSE expr() END
+FROM "cdc_sink_load" AS s
\ No newline at end of file
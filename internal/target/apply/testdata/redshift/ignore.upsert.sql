@@ -0,0 +1,5 @@
+INSERT INTO "database"."schema"."table" (
+"pk0","pk1","val0","val1","has_default"
+) VALUES
+($1::CHARACTER VARYING(256),$2::BIGINT,$3::CHARACTER VARYING(256),$4::CHARACTER VARYING(256),CASE WHEN $5::BOOLEAN THEN $6::BIGINT ELSE expr() END),
+($7::CHARACTER VARYING(256),$8::BIGINT,$9::CHARACTER VARYING(256),$10::CHARACTER VARYING(256),CASE WHEN $11::BOOLEAN THEN $12::BIGINT ELSE expr() END)
\ No newline at end of file
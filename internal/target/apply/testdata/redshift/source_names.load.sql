@@ -0,0 +1,6 @@
+DELETE FROM "database"."schema"."table" USING "cdc_sink_load" AS s WHERE
+"table"."pk0"=s.p1::CHARACTER VARYING(256) AND "table"."pk1"=s.p2::BIGINT;
+INSERT INTO "database"."schema"."table" (
+"pk0","pk1","val0","val1","geom","doc","has_default"
+) SELECT s.p1::CHARACTER VARYING(256),s.p2::BIGINT,s.p3::CHARACTER VARYING(256),s.p4::CHARACTER VARYING(256),ST_GeomFromGeoJSON(s.p5),JSON_PARSE(s.p6),CASE WHEN s.p7::BOOLEAN THEN s.p8::BIGINT ELSE expr() END
+FROM "cdc_sink_load" AS s
\ No newline at end of file
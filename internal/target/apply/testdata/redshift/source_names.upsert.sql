@@ -0,0 +1,5 @@
+INSERT INTO "database"."schema"."table" (
+"pk0","pk1","val0","val1","geom","doc","has_default"
+) VALUES
+($1::CHARACTER VARYING(256),$2::BIGINT,$3::CHARACTER VARYING(256),$4::CHARACTER VARYING(256),ST_GeomFromGeoJSON($5),JSON_PARSE($6),CASE WHEN $7::BOOLEAN THEN $8::BIGINT ELSE expr() END),
+($9::CHARACTER VARYING(256),$10::BIGINT,$11::CHARACTER VARYING(256),$12::CHARACTER VARYING(256),ST_GeomFromGeoJSON($13),JSON_PARSE($14),CASE WHEN $15::BOOLEAN THEN $16::BIGINT ELSE expr() END)
\ No newline at end of file
@@ -0,0 +1,9 @@
+WITH data("pk0","pk1","val0","val1","has_default") AS (
+VALUES
+(?1,?2,?3,?4,CASE WHEN ?5 THEN ?6 ELSE expr() END),
+(?7,?8,?9,?10,CASE WHEN ?11 THEN ?12 ELSE expr() END))
+INSERT INTO "main"."table" ("pk0","pk1","val0","val1","has_default")
+SELECT "pk0","pk1","val0","val1","has_default" FROM data WHERE true
+ON CONFLICT ( "pk0","pk1" )
+DO UPDATE SET "val0"=excluded."val0","val1"=excluded."val1","has_default"=excluded."has_default"
+WHERE (excluded."val1",excluded."val0") > ("table"."val1","table"."val0")
\ No newline at end of file
@@ -0,0 +1,3 @@
+DELETE FROM "main"."table" WHERE ("pk0","pk1")IN(VALUES
+(?1,(?2+?2)),
+(?3,(?4+?4)))
\ No newline at end of file
@@ -0,0 +1,7 @@
+INSERT INTO "main"."table" (
+"pk0","pk1","val0","val1","has_default"
+) VALUES
+(?1,(?2+?2),('fixed'),(?3||'foobar'),CASE WHEN ?4 THEN ?5 ELSE expr() END),
+(?6,(?7+?7),('fixed'),(?8||'foobar'),CASE WHEN ?9 THEN ?10 ELSE expr() END)
+ON CONFLICT ( "pk0","pk1" )
+DO UPDATE SET "val0"=excluded."val0","val1"=excluded."val1","has_default"=excluded."has_default"
\ No newline at end of file
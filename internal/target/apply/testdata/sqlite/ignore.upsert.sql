@@ -0,0 +1,7 @@
+INSERT INTO "main"."table" (
+"pk0","pk1","has_default"
+) VALUES
+(?1,?2,CASE WHEN ?3 THEN ?4 ELSE expr() END),
+(?5,?6,CASE WHEN ?7 THEN ?8 ELSE expr() END)
+ON CONFLICT ( "pk0","pk1" )
+DO UPDATE SET "has_default"=excluded."has_default"
\ No newline at end of file
@@ -0,0 +1,7 @@
+INSERT INTO "main"."table" (
+"pk0","pk1","val0","val1","has_default"
+) VALUES
+(?1,?2,?3,?4,CASE WHEN ?5 THEN ?6 ELSE expr() END),
+(?7,?8,?9,?10,CASE WHEN ?11 THEN ?12 ELSE expr() END)
+ON CONFLICT ( "pk0","pk1" )
+DO UPDATE SET "val0"=excluded."val0","val1"=excluded."val1","has_default"=excluded."has_default"
\ No newline at end of file
@@ -0,0 +1,47 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"math/big"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+)
+
+// Some targets, such as ClickHouse, lack an efficient means of
+// updating or deleting individual rows. Instead, every mutation is
+// appended to the target table as a new row, along with a version and
+// a deletion flag. The table is expected to use an engine, such as
+// ReplacingMergeTree(version, is_deleted), that retains only the row
+// with the greatest version for each key.
+var (
+	// deletedColumn is set to 1 in rows which record a deletion.
+	deletedColumn = ident.New("is_deleted")
+	// versionColumn receives a value derived from the timestamp of the
+	// mutation.
+	versionColumn = ident.New("version")
+)
+
+// versionOf encodes a timestamp as an unsigned integer which sorts in
+// the same order as the timestamp. The logical counter occupies the
+// low 32 bits, so the value requires a 128-bit column. The value is
+// returned as a string, since it exceeds the range of native types.
+func versionOf(ts hlc.Time) string {
+	v := new(big.Int).Lsh(big.NewInt(ts.Nanos()), 32)
+	return v.Or(v, big.NewInt(int64(ts.Logical()))).String()
+}
@@ -0,0 +1,52 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package apply
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionOf(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal("0", versionOf(hlc.Zero()))
+	r.Equal("4294967297", versionOf(hlc.New(1, 1)))
+	// 1700000000000000000 << 32 | 42
+	r.Equal("7301444403200000000000000042", versionOf(hlc.New(1_700_000_000_000_000_000, 42)))
+
+	// The encoding must preserve the order of the timestamps.
+	times := []hlc.Time{
+		hlc.New(1, 0),
+		hlc.New(1, 1),
+		hlc.New(1, 1<<31-1),
+		hlc.New(2, 0),
+		hlc.New(1_700_000_000_000_000_000, 0),
+		hlc.New(1_700_000_000_000_000_000, 1),
+		hlc.New(1_700_000_000_000_000_001, 0),
+	}
+	for i := 1; i < len(times); i++ {
+		prev, ok := new(big.Int).SetString(versionOf(times[i-1]), 10)
+		r.True(ok)
+		next, ok := new(big.Int).SetString(versionOf(times[i]), 10)
+		r.True(ok)
+		r.Equal(-1, prev.Cmp(next), "%s vs %s", times[i-1], times[i])
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package ddl translates schema changes that are observed in a source
+// database into statements that are executed in the target database.
+package ddl
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+)
+
+// Kind describes the type of schema change.
+type Kind int
+
+// The schema changes that may be propagated.
+const (
+	AddColumn Kind = iota + 1
+	DropColumn
+	WidenColumn
+)
+
+// String is for debugging use.
+func (k Kind) String() string {
+	switch k {
+	case AddColumn:
+		return "add"
+	case DropColumn:
+		return "drop"
+	case WidenColumn:
+		return "widen"
+	default:
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+}
+
+// Column is a product-neutral description of a column in a source
+// table.
+type Column struct {
+	Name    ident.Ident
+	Primary bool
+	Type    Type
+}
+
+// A Change describes a single modification to a table.
+type Change struct {
+	Kind   Kind
+	Table  ident.Table
+	Column ident.Ident
+	Type   Type // The new column type for AddColumn and WidenColumn.
+}
+
+// String is for debugging use.
+func (c Change) String() string {
+	if c.Kind == DropColumn {
+		return fmt.Sprintf("%s %s.%s", c.Kind, c.Table, c.Column)
+	}
+	return fmt.Sprintf("%s %s.%s %s", c.Kind, c.Table, c.Column, c.Type)
+}
+
+// UnsupportedError is returned when a schema change cannot be
+// propagated automatically and requires operator intervention.
+type UnsupportedError struct {
+	Table  ident.Table
+	Reason string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("unsupported schema change in %s: %s; "+
+		"apply the change to the target manually and restart cdc-sink",
+		e.Table, e.Reason)
+}
+
+// IsUnsupported returns the UnsupportedError if err is or wraps an
+// UnsupportedError.
+func IsUnsupported(err error) (unsupported *UnsupportedError, ok bool) {
+	return unsupported, errors.As(err, &unsupported)
+}
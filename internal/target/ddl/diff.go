@@ -0,0 +1,122 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ddl
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+)
+
+// Diff compares two definitions of a source table and returns the
+// changes that must be made to the target table. An UnsupportedError
+// will be returned if the differences cannot be expressed as a
+// sequence of column additions, drops, and type widenings.
+//
+// A definition that both adds and removes columns is rejected, since
+// it cannot be distinguished from a column rename. Executing a rename
+// as a drop and an add would discard the column's data.
+func Diff(table ident.Table, before, after []Column) ([]Change, error) {
+	var beforeCols, afterCols ident.Map[Column]
+	for _, col := range before {
+		beforeCols.Put(col.Name, col)
+	}
+	for _, col := range after {
+		afterCols.Put(col.Name, col)
+	}
+
+	unsupported := func(format string, args ...any) error {
+		return &UnsupportedError{Table: table, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	var adds, drops, widens []Change
+	for _, col := range before {
+		next, ok := afterCols.Get(col.Name)
+		if !ok {
+			if col.Primary {
+				return nil, unsupported("primary key column %s was dropped", col.Name)
+			}
+			drops = append(drops, Change{Kind: DropColumn, Table: table, Column: col.Name})
+			continue
+		}
+		if next.Primary != col.Primary {
+			return nil, unsupported("primary key membership of column %s changed", col.Name)
+		}
+		if next.Type == col.Type {
+			continue
+		}
+		if !next.Type.Widens(col.Type) {
+			return nil, unsupported("type of column %s changed from %s to %s",
+				col.Name, col.Type, next.Type)
+		}
+		widens = append(widens, Change{Kind: WidenColumn, Table: table, Column: col.Name, Type: next.Type})
+	}
+	for _, col := range after {
+		if _, ok := beforeCols.Get(col.Name); ok {
+			continue
+		}
+		if col.Primary {
+			return nil, unsupported("primary key column %s was added", col.Name)
+		}
+		if col.Type.Kind == TypeUnknown {
+			return nil, unsupported("column %s was added with an unsupported type", col.Name)
+		}
+		adds = append(adds, Change{Kind: AddColumn, Table: table, Column: col.Name, Type: col.Type})
+	}
+	if len(adds) > 0 && len(drops) > 0 {
+		return nil, unsupported("columns were both added and dropped, which may be a rename")
+	}
+
+	ret := make([]Change, 0, len(drops)+len(widens)+len(adds))
+	ret = append(ret, drops...)
+	ret = append(ret, widens...)
+	ret = append(ret, adds...)
+	return ret, nil
+}
+
+// reconcile returns the changes that must be made to the existing
+// target columns to match the next definition of a source table.
+// Source columns that are missing from the target will be added, even
+// if they were already present in the previous definition, so that
+// changes made while cdc-sink was not running are propagated. The
+// previous definition, which may be nil, is used to detect type
+// changes and dropped columns. Columns which exist only in the target
+// are left alone, since they may have been created there deliberately.
+func reconcile(
+	table ident.Table, existing []types.ColData, prev, next []Column,
+) ([]Change, error) {
+	var prevCols, nextCols ident.Map[Column]
+	for _, col := range prev {
+		prevCols.Put(col.Name, col)
+	}
+	for _, col := range next {
+		nextCols.Put(col.Name, col)
+	}
+
+	// Reconstruct the definition of the source table that the target
+	// currently reflects.
+	var before []Column
+	for _, col := range existing {
+		if def, ok := prevCols.Get(col.Name); ok {
+			before = append(before, def)
+		} else if def, ok := nextCols.Get(col.Name); ok {
+			before = append(before, def)
+		}
+	}
+	return Diff(table, before, next)
+}
@@ -0,0 +1,250 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ddl
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	table := ident.NewTable(ident.MustSchema(ident.New("db"), ident.Public), ident.New("tbl"))
+	pk := Column{Name: ident.New("pk"), Primary: true, Type: Type{Kind: TypeInt32}}
+	val := Column{Name: ident.New("val"), Type: Type{Kind: TypeString, Length: 16}}
+	base := []Column{pk, val}
+
+	tcs := []struct {
+		name        string
+		after       []Column
+		expected    []Change
+		unsupported bool
+	}{
+		{
+			name:  "unchanged",
+			after: base,
+		},
+		{
+			name: "add",
+			after: []Column{pk, val,
+				{Name: ident.New("extra"), Type: Type{Kind: TypeJSON}}},
+			expected: []Change{
+				{Kind: AddColumn, Table: table, Column: ident.New("extra"), Type: Type{Kind: TypeJSON}},
+			},
+		},
+		{
+			name:  "drop",
+			after: []Column{pk},
+			expected: []Change{
+				{Kind: DropColumn, Table: table, Column: ident.New("val")},
+			},
+		},
+		{
+			name: "widen",
+			after: []Column{
+				{Name: ident.New("pk"), Primary: true, Type: Type{Kind: TypeInt64}},
+				{Name: ident.New("val"), Type: Type{Kind: TypeString}},
+			},
+			expected: []Change{
+				{Kind: WidenColumn, Table: table, Column: ident.New("pk"), Type: Type{Kind: TypeInt64}},
+				{Kind: WidenColumn, Table: table, Column: ident.New("val"), Type: Type{Kind: TypeString}},
+			},
+		},
+		{
+			name: "case insensitive",
+			after: []Column{pk,
+				{Name: ident.New("VAL"), Type: Type{Kind: TypeString, Length: 16}}},
+		},
+		{
+			name: "narrow",
+			after: []Column{pk,
+				{Name: ident.New("val"), Type: Type{Kind: TypeString, Length: 8}}},
+			unsupported: true,
+		},
+		{
+			name: "change type",
+			after: []Column{pk,
+				{Name: ident.New("val"), Type: Type{Kind: TypeInt64}}},
+			unsupported: true,
+		},
+		{
+			name: "rename",
+			after: []Column{pk,
+				{Name: ident.New("renamed"), Type: Type{Kind: TypeString, Length: 16}}},
+			unsupported: true,
+		},
+		{
+			name:        "drop pk",
+			after:       []Column{val},
+			unsupported: true,
+		},
+		{
+			name: "add pk",
+			after: []Column{pk, val,
+				{Name: ident.New("pk2"), Primary: true, Type: Type{Kind: TypeInt32}}},
+			unsupported: true,
+		},
+		{
+			name: "add unknown",
+			after: []Column{pk, val,
+				{Name: ident.New("geo")}},
+			unsupported: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
+
+			changes, err := Diff(table, base, tc.after)
+			if tc.unsupported {
+				unsupported, ok := IsUnsupported(err)
+				r.True(ok, "expected UnsupportedError, got %v", err)
+				a.Equal(table, unsupported.Table)
+				return
+			}
+			r.NoError(err)
+			a.Equal(len(tc.expected), len(changes))
+			for idx := range tc.expected {
+				a.Equal(tc.expected[idx].String(), changes[idx].String())
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	table := ident.NewTable(ident.MustSchema(ident.New("db"), ident.Public), ident.New("tbl"))
+	pk := Column{Name: ident.New("pk"), Primary: true, Type: Type{Kind: TypeInt32}}
+	val := Column{Name: ident.New("val"), Type: Type{Kind: TypeString, Length: 16}}
+	extra := Column{Name: ident.New("extra"), Type: Type{Kind: TypeJSON}}
+	wide := Column{Name: ident.New("val"), Type: Type{Kind: TypeString}}
+
+	target := func(cols ...Column) []types.ColData {
+		ret := make([]types.ColData, len(cols))
+		for idx, col := range cols {
+			ret[idx] = types.ColData{Name: col.Name, Primary: col.Primary, Type: "ANY"}
+		}
+		return ret
+	}
+	audit := Column{Name: ident.New("audit")}
+
+	tcs := []struct {
+		name     string
+		existing []types.ColData
+		prev     []Column
+		next     []Column
+		expected []Change
+	}{
+		{
+			name:     "unchanged",
+			existing: target(pk, val),
+			next:     []Column{pk, val},
+		},
+		{
+			// The column was added to the source while we weren't
+			// running, so there's no previous definition.
+			name:     "added while stopped",
+			existing: target(pk, val),
+			next:     []Column{pk, val, extra},
+			expected: []Change{
+				{Kind: AddColumn, Table: table, Column: extra.Name, Type: extra.Type},
+			},
+		},
+		{
+			// The column was added before the first definition that
+			// we saw after a restart.
+			name:     "added before first definition",
+			existing: target(pk, val),
+			prev:     []Column{pk, val, extra},
+			next:     []Column{pk, val, extra},
+			expected: []Change{
+				{Kind: AddColumn, Table: table, Column: extra.Name, Type: extra.Type},
+			},
+		},
+		{
+			name:     "widen",
+			existing: target(pk, val),
+			prev:     []Column{pk, val},
+			next:     []Column{pk, wide},
+			expected: []Change{
+				{Kind: WidenColumn, Table: table, Column: wide.Name, Type: wide.Type},
+			},
+		},
+		{
+			name:     "drop",
+			existing: target(pk, val),
+			prev:     []Column{pk, val},
+			next:     []Column{pk},
+			expected: []Change{
+				{Kind: DropColumn, Table: table, Column: val.Name},
+			},
+		},
+		{
+			// Unknown target-only columns are never dropped.
+			name:     "target only",
+			existing: target(pk, val, audit),
+			prev:     []Column{pk, val},
+			next:     []Column{pk, val},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
+
+			changes, err := reconcile(table, tc.existing, tc.prev, tc.next)
+			r.NoError(err)
+			a.Equal(len(tc.expected), len(changes))
+			for idx := range tc.expected {
+				a.Equal(tc.expected[idx].String(), changes[idx].String())
+			}
+		})
+	}
+}
+
+func TestWidens(t *testing.T) {
+	tcs := []struct {
+		from, to Type
+		expected bool
+	}{
+		{Type{Kind: TypeInt16}, Type{Kind: TypeInt64}, true},
+		{Type{Kind: TypeInt64}, Type{Kind: TypeInt32}, false},
+		{Type{Kind: TypeFloat32}, Type{Kind: TypeFloat64}, true},
+		{Type{Kind: TypeInt32}, Type{Kind: TypeNumeric}, true},
+		{Type{Kind: TypeInt32}, Type{Kind: TypeNumeric, Precision: 10}, true},
+		{Type{Kind: TypeInt64}, Type{Kind: TypeNumeric, Precision: 10}, false},
+		{Type{Kind: TypeNumeric, Precision: 10, Scale: 2}, Type{Kind: TypeNumeric, Precision: 12, Scale: 4}, true},
+		{Type{Kind: TypeNumeric, Precision: 10, Scale: 2}, Type{Kind: TypeNumeric, Precision: 10, Scale: 4}, false},
+		{Type{Kind: TypeNumeric}, Type{Kind: TypeNumeric, Precision: 38}, false},
+		{Type{Kind: TypeString, Length: 8}, Type{Kind: TypeString, Length: 16}, true},
+		{Type{Kind: TypeString, Length: 8}, Type{Kind: TypeString}, true},
+		{Type{Kind: TypeString}, Type{Kind: TypeString, Length: 8}, false},
+		{Type{Kind: TypeBytes, Length: 8}, Type{Kind: TypeString}, false},
+		{Type{Kind: TypeTimestamp}, Type{Kind: TypeTimestampTZ}, false},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.from.String()+"->"+tc.to.String(), func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.to.Widens(tc.from))
+		})
+	}
+}
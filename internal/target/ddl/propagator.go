@@ -0,0 +1,193 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ddl
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/cockroachdb/cdc-sink/internal/util/metrics"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// A Propagator applies schema changes to the target database and
+// records the changes which could not be applied.
+type Propagator struct {
+	targetPool *types.TargetPool
+	watchers   types.Watchers
+
+	mu struct {
+		sync.Mutex
+		applied []string                // Recently executed statements.
+		paused  *ident.TableMap[string] // Tables blocked by an unsupported change.
+	}
+}
+
+var _ diag.Diagnostic = (*Propagator)(nil)
+
+// maxApplied limits the number of statements retained for diagnostics.
+const maxApplied = 32
+
+// Apply executes the changes in the target database. Changes which
+// have already been applied, e.g. because the source replayed a
+// message, will be skipped. Once the changes have been executed, the
+// schema watcher for the affected databases will be refreshed before
+// Apply returns.
+func (p *Propagator) Apply(ctx context.Context, changes []Change) error {
+	var refresh ident.SchemaMap[types.Watcher]
+	for _, change := range changes {
+		watcher, err := p.watchers.Get(ctx, change.Table.Schema())
+		if err != nil {
+			return err
+		}
+		existing, ok := watcher.Get().Columns.Get(change.Table)
+		if !ok {
+			log.WithField("change", change).Warn("ignoring schema change for table not in target")
+			continue
+		}
+		if !p.needed(change, existing) {
+			log.WithField("change", change).Debug("schema change already applied")
+			continue
+		}
+
+		stmts, err := Statements(p.targetPool.Product, change)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range stmts {
+			if _, err := p.targetPool.ExecContext(ctx, stmt); err != nil {
+				return errors.Wrapf(err, "could not apply schema change: %s", stmt)
+			}
+			log.WithField("sql", stmt).Info("applied schema change")
+			p.recordApplied(stmt)
+		}
+		ddlApplied.WithLabelValues(metrics.TableValues(change.Table)...).Inc()
+		refresh.Put(change.Table.Schema(), watcher)
+	}
+
+	return refresh.Range(func(_ ident.Schema, watcher types.Watcher) error {
+		return watcher.Refresh(ctx, p.targetPool)
+	})
+}
+
+// Diagnostic implements [diag.Diagnostic].
+func (p *Propagator) Diagnostic(_ context.Context) any {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	paused := make(map[string]string, p.mu.paused.Len())
+	_ = p.mu.paused.Range(func(table ident.Table, reason string) error {
+		paused[table.Raw()] = reason
+		return nil
+	})
+	return map[string]any{
+		"applied": append([]string(nil), p.mu.applied...),
+		"paused":  paused,
+	}
+}
+
+// Exists returns true if the table is present in the target.
+func (p *Propagator) Exists(ctx context.Context, table ident.Table) (bool, error) {
+	watcher, err := p.watchers.Get(ctx, table.Schema())
+	if err != nil {
+		return false, err
+	}
+	_, ok := watcher.Get().Columns.Get(table)
+	return ok, nil
+}
+
+// Pause records an unsupported schema change so that it can be
+// reported as a diagnostic.
+func (p *Propagator) Pause(err *UnsupportedError) {
+	log.WithError(err).Error("replication paused")
+	ddlPaused.WithLabelValues(metrics.TableValues(err.Table)...).Set(1)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mu.paused.Put(err.Table, err.Error())
+}
+
+// Plan returns the changes that must be made to the target table to
+// match the next definition of a source table. The previous definition
+// of the source table may be nil if it is not known. See [reconcile]
+// for details. If the table was paused by an unsupported change, it
+// will be resumed once its definition can be reconciled.
+func (p *Propagator) Plan(
+	ctx context.Context, table ident.Table, prev, next []Column,
+) ([]Change, error) {
+	watcher, err := p.watchers.Get(ctx, table.Schema())
+	if err != nil {
+		return nil, err
+	}
+	existing, ok := watcher.Get().Columns.Get(table)
+	if !ok {
+		return nil, nil
+	}
+	changes, err := reconcile(table, existing, prev, next)
+	if err != nil {
+		return nil, err
+	}
+	p.resume(table)
+	return changes, nil
+}
+
+// resume clears the paused state of a table.
+func (p *Propagator) resume(table ident.Table) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.mu.paused.Get(table); !ok {
+		return
+	}
+	p.mu.paused.Delete(table)
+	ddlPaused.WithLabelValues(metrics.TableValues(table)...).Set(0)
+	log.WithField("table", table).Info("replication resumed")
+}
+
+// needed returns true if the change has not yet been applied to the
+// existing target columns.
+func (p *Propagator) needed(change Change, existing []types.ColData) bool {
+	found := false
+	for _, col := range existing {
+		if ident.Equal(col.Name, change.Column) {
+			found = true
+			break
+		}
+	}
+	switch change.Kind {
+	case AddColumn:
+		return !found
+	case DropColumn, WidenColumn:
+		// A widening is always re-applied if the column exists, since
+		// the target types are product-specific and cannot be compared.
+		return found
+	default:
+		return false
+	}
+}
+
+func (p *Propagator) recordApplied(stmt string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mu.applied = append(p.mu.applied, stmt)
+	if over := len(p.mu.applied) - maxApplied; over > 0 {
+		p.mu.applied = append(p.mu.applied[:0], p.mu.applied[over:]...)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ddl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseResume(t *testing.T) {
+	r := require.New(t)
+	table := ident.NewTable(ident.MustSchema(ident.New("db"), ident.Public), ident.New("tbl"))
+
+	p := &Propagator{}
+	p.mu.paused = &ident.TableMap[string]{}
+	paused := func() map[string]string {
+		return p.Diagnostic(context.Background()).(map[string]any)["paused"].(map[string]string)
+	}
+
+	p.Pause(&UnsupportedError{Table: table, Reason: "testing"})
+	r.Contains(paused(), table.Raw())
+
+	p.resume(table)
+	r.Empty(paused())
+
+	// Resuming an unpaused table is a no-op.
+	p.resume(table)
+	r.Empty(paused())
+}
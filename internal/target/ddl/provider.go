@@ -0,0 +1,44 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ddl
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/diag"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/google/wire"
+)
+
+// Set is used by Wire.
+var Set = wire.NewSet(
+	ProvidePropagator,
+)
+
+// ProvidePropagator is called by Wire.
+func ProvidePropagator(
+	pool *types.TargetPool, watchers types.Watchers, d *diag.Diagnostics,
+) (*Propagator, error) {
+	ret := &Propagator{
+		targetPool: pool,
+		watchers:   watchers,
+	}
+	ret.mu.paused = &ident.TableMap[string]{}
+	if err := d.Register("ddl", ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
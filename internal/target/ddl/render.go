@@ -0,0 +1,273 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ddl
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/pkg/errors"
+)
+
+// Statements returns the SQL statements necessary to apply the change
+// to a target database. An UnsupportedError will be returned if the
+// target product is unable to perform the change.
+func Statements(product types.Product, change Change) ([]string, error) {
+	if change.Kind == DropColumn {
+		switch product {
+		case types.ProductCockroachDB, types.ProductMySQL, types.ProductOracle,
+			types.ProductPostgreSQL, types.ProductRedshift:
+			return []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", change.Table, change.Column)}, nil
+		default:
+			return nil, errors.Errorf("unimplemented product: %s", product)
+		}
+	}
+
+	typ, err := typeSQL(product, change.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	switch change.Kind {
+	case AddColumn:
+		switch product {
+		case types.ProductCockroachDB, types.ProductMySQL,
+			types.ProductPostgreSQL, types.ProductRedshift:
+			return []string{fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+				change.Table, change.Column, typ)}, nil
+		case types.ProductOracle:
+			return []string{fmt.Sprintf("ALTER TABLE %s ADD (%s %s)",
+				change.Table, change.Column, typ)}, nil
+		}
+
+	case WidenColumn:
+		switch product {
+		case types.ProductCockroachDB, types.ProductPostgreSQL:
+			return []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s",
+				change.Table, change.Column, typ)}, nil
+		case types.ProductMySQL:
+			return []string{fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s",
+				change.Table, change.Column, typ)}, nil
+		case types.ProductOracle:
+			return []string{fmt.Sprintf("ALTER TABLE %s MODIFY (%s %s)",
+				change.Table, change.Column, typ)}, nil
+		case types.ProductRedshift:
+			// Redshift can only increase the length of a VARCHAR.
+			if change.Type.Kind != TypeString {
+				return nil, &UnsupportedError{
+					Table:  change.Table,
+					Reason: fmt.Sprintf("%s cannot change the type of column %s to %s", product, change.Column, typ),
+				}
+			}
+			return []string{fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s",
+				change.Table, change.Column, typ)}, nil
+		}
+
+	default:
+		return nil, errors.Errorf("unimplemented change kind: %s", change.Kind)
+	}
+	return nil, errors.Errorf("unimplemented product: %s", product)
+}
+
+// typeSQL returns the product-specific name of the type.
+func typeSQL(product types.Product, t Type) (string, error) {
+	var ret string
+	switch product {
+	case types.ProductCockroachDB, types.ProductPostgreSQL:
+		ret = typeSQLPG(t)
+	case types.ProductMySQL:
+		ret = typeSQLMySQL(t)
+	case types.ProductOracle:
+		ret = typeSQLOracle(t)
+	case types.ProductRedshift:
+		ret = typeSQLRedshift(t)
+	default:
+		return "", errors.Errorf("unimplemented product: %s", product)
+	}
+	if ret == "" {
+		return "", errors.Errorf("%s has no equivalent for type %s", product, t)
+	}
+	return ret, nil
+}
+
+func typeSQLPG(t Type) string {
+	switch t.Kind {
+	case TypeBool:
+		return "BOOL"
+	case TypeInt16:
+		return "INT2"
+	case TypeInt32:
+		return "INT4"
+	case TypeInt64:
+		return "INT8"
+	case TypeFloat32:
+		return "FLOAT4"
+	case TypeFloat64:
+		return "FLOAT8"
+	case TypeNumeric:
+		if t.Precision == 0 {
+			return "NUMERIC"
+		}
+		return fmt.Sprintf("NUMERIC(%d,%d)", t.Precision, t.Scale)
+	case TypeString:
+		if t.Length == 0 {
+			return "TEXT"
+		}
+		return fmt.Sprintf("VARCHAR(%d)", t.Length)
+	case TypeBytes:
+		return "BYTEA"
+	case TypeDate:
+		return "DATE"
+	case TypeTime:
+		return "TIME"
+	case TypeTimestamp:
+		return "TIMESTAMP"
+	case TypeTimestampTZ:
+		return "TIMESTAMPTZ"
+	case TypeJSON:
+		return "JSONB"
+	case TypeUUID:
+		return "UUID"
+	default:
+		return ""
+	}
+}
+
+func typeSQLMySQL(t Type) string {
+	switch t.Kind {
+	case TypeBool:
+		return "BOOLEAN"
+	case TypeInt16:
+		return "SMALLINT"
+	case TypeInt32:
+		return "INT"
+	case TypeInt64:
+		return "BIGINT"
+	case TypeFloat32:
+		return "FLOAT"
+	case TypeFloat64:
+		return "DOUBLE"
+	case TypeNumeric:
+		if t.Precision == 0 {
+			return "DECIMAL(65,30)"
+		}
+		return fmt.Sprintf("DECIMAL(%d,%d)", t.Precision, t.Scale)
+	case TypeString:
+		if t.Length == 0 {
+			return "LONGTEXT"
+		}
+		return fmt.Sprintf("VARCHAR(%d)", t.Length)
+	case TypeBytes:
+		return "LONGBLOB"
+	case TypeDate:
+		return "DATE"
+	case TypeTime:
+		return "TIME(6)"
+	case TypeTimestamp, TypeTimestampTZ:
+		return "DATETIME(6)"
+	case TypeJSON:
+		return "JSON"
+	case TypeUUID:
+		return "CHAR(36)"
+	default:
+		return ""
+	}
+}
+
+func typeSQLOracle(t Type) string {
+	switch t.Kind {
+	case TypeBool:
+		return "NUMBER(1)"
+	case TypeInt16:
+		return "NUMBER(5)"
+	case TypeInt32:
+		return "NUMBER(10)"
+	case TypeInt64:
+		return "NUMBER(19)"
+	case TypeFloat32:
+		return "BINARY_FLOAT"
+	case TypeFloat64:
+		return "BINARY_DOUBLE"
+	case TypeNumeric:
+		if t.Precision == 0 {
+			return "NUMBER"
+		}
+		return fmt.Sprintf("NUMBER(%d,%d)", t.Precision, t.Scale)
+	case TypeString:
+		if t.Length == 0 || t.Length > 4000 {
+			return "CLOB"
+		}
+		return fmt.Sprintf("VARCHAR2(%d CHAR)", t.Length)
+	case TypeBytes:
+		return "BLOB"
+	case TypeDate:
+		return "DATE"
+	case TypeTimestamp:
+		return "TIMESTAMP"
+	case TypeTimestampTZ:
+		return "TIMESTAMP WITH TIME ZONE"
+	case TypeJSON:
+		return "CLOB"
+	case TypeUUID:
+		return "VARCHAR2(36)"
+	default:
+		return ""
+	}
+}
+
+func typeSQLRedshift(t Type) string {
+	switch t.Kind {
+	case TypeBool:
+		return "BOOLEAN"
+	case TypeInt16:
+		return "SMALLINT"
+	case TypeInt32:
+		return "INTEGER"
+	case TypeInt64:
+		return "BIGINT"
+	case TypeFloat32:
+		return "REAL"
+	case TypeFloat64:
+		return "DOUBLE PRECISION"
+	case TypeNumeric:
+		if t.Precision == 0 || t.Precision > 38 {
+			return "NUMERIC(38,10)"
+		}
+		return fmt.Sprintf("NUMERIC(%d,%d)", t.Precision, t.Scale)
+	case TypeString:
+		if t.Length == 0 || t.Length > 65535 {
+			return "VARCHAR(65535)"
+		}
+		return fmt.Sprintf("VARCHAR(%d)", t.Length)
+	case TypeBytes:
+		return "VARBYTE"
+	case TypeDate:
+		return "DATE"
+	case TypeTime:
+		return "TIME"
+	case TypeTimestamp:
+		return "TIMESTAMP"
+	case TypeTimestampTZ:
+		return "TIMESTAMPTZ"
+	case TypeJSON:
+		return "SUPER"
+	case TypeUUID:
+		return "CHAR(36)"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ddl
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatements(t *testing.T) {
+	table := ident.NewTable(ident.MustSchema(ident.New("db"), ident.Public), ident.New("tbl"))
+	col := ident.New("col")
+	add := Change{Kind: AddColumn, Table: table, Column: col, Type: Type{Kind: TypeString, Length: 32}}
+	drop := Change{Kind: DropColumn, Table: table, Column: col}
+	widen := Change{Kind: WidenColumn, Table: table, Column: col, Type: Type{Kind: TypeInt64}}
+	widenString := Change{Kind: WidenColumn, Table: table, Column: col, Type: Type{Kind: TypeString}}
+
+	tcs := []struct {
+		product     types.Product
+		change      Change
+		expected    string
+		unsupported bool
+	}{
+		{types.ProductCockroachDB, add, `ALTER TABLE "db"."public"."tbl" ADD COLUMN "col" VARCHAR(32)`, false},
+		{types.ProductCockroachDB, drop, `ALTER TABLE "db"."public"."tbl" DROP COLUMN "col"`, false},
+		{types.ProductCockroachDB, widen, `ALTER TABLE "db"."public"."tbl" ALTER COLUMN "col" TYPE INT8`, false},
+		{types.ProductPostgreSQL, widenString, `ALTER TABLE "db"."public"."tbl" ALTER COLUMN "col" TYPE TEXT`, false},
+		{types.ProductMySQL, add, `ALTER TABLE "db"."public"."tbl" ADD COLUMN "col" VARCHAR(32)`, false},
+		{types.ProductMySQL, widen, `ALTER TABLE "db"."public"."tbl" MODIFY COLUMN "col" BIGINT`, false},
+		{types.ProductOracle, add, `ALTER TABLE "db"."public"."tbl" ADD ("col" VARCHAR2(32 CHAR))`, false},
+		{types.ProductOracle, widen, `ALTER TABLE "db"."public"."tbl" MODIFY ("col" NUMBER(19))`, false},
+		{types.ProductRedshift, add, `ALTER TABLE "db"."public"."tbl" ADD COLUMN "col" VARCHAR(32)`, false},
+		{types.ProductRedshift, widenString, `ALTER TABLE "db"."public"."tbl" ALTER COLUMN "col" TYPE VARCHAR(65535)`, false},
+		{types.ProductRedshift, widen, "", true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.product.String()+" "+tc.change.String(), func(t *testing.T) {
+			a := assert.New(t)
+			r := require.New(t)
+
+			stmts, err := Statements(tc.product, tc.change)
+			if tc.unsupported {
+				_, ok := IsUnsupported(err)
+				r.True(ok, "expected UnsupportedError, got %v", err)
+				return
+			}
+			r.NoError(err)
+			a.Equal([]string{tc.expected}, stmts)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ddl
+
+import "fmt"
+
+// TypeKind is a product-neutral category of column type.
+type TypeKind int
+
+// The kinds of types that can be propagated to a target.
+const (
+	TypeUnknown TypeKind = iota
+	TypeBool
+	TypeInt16
+	TypeInt32
+	TypeInt64
+	TypeFloat32
+	TypeFloat64
+	TypeNumeric
+	TypeString
+	TypeBytes
+	TypeDate
+	TypeTime
+	TypeTimestamp
+	TypeTimestampTZ
+	TypeJSON
+	TypeUUID
+)
+
+// Type is a product-neutral description of a column type.
+type Type struct {
+	Kind TypeKind
+	// The maximum length of a TypeString or TypeBytes. A zero value
+	// indicates that the length is unbounded.
+	Length int
+	// The precision of a TypeNumeric. A zero value indicates that the
+	// precision is unbounded.
+	Precision int
+	// The scale of a TypeNumeric.
+	Scale int
+}
+
+// integerDigits is the number of decimal digits required to represent
+// any value of the integral types.
+var integerDigits = map[TypeKind]int{
+	TypeInt16: 5,
+	TypeInt32: 10,
+	TypeInt64: 19,
+}
+
+// String returns a product-neutral representation of the type.
+func (t Type) String() string {
+	switch t.Kind {
+	case TypeBool:
+		return "bool"
+	case TypeInt16:
+		return "int2"
+	case TypeInt32:
+		return "int4"
+	case TypeInt64:
+		return "int8"
+	case TypeFloat32:
+		return "float4"
+	case TypeFloat64:
+		return "float8"
+	case TypeNumeric:
+		if t.Precision == 0 {
+			return "numeric"
+		}
+		return fmt.Sprintf("numeric(%d,%d)", t.Precision, t.Scale)
+	case TypeString:
+		if t.Length == 0 {
+			return "text"
+		}
+		return fmt.Sprintf("varchar(%d)", t.Length)
+	case TypeBytes:
+		if t.Length == 0 {
+			return "bytes"
+		}
+		return fmt.Sprintf("bytes(%d)", t.Length)
+	case TypeDate:
+		return "date"
+	case TypeTime:
+		return "time"
+	case TypeTimestamp:
+		return "timestamp"
+	case TypeTimestampTZ:
+		return "timestamptz"
+	case TypeJSON:
+		return "json"
+	case TypeUUID:
+		return "uuid"
+	default:
+		return "unknown"
+	}
+}
+
+// Widens returns true if every value of the other type can be
+// represented by the receiver without loss.
+func (t Type) Widens(other Type) bool {
+	if t == other || t.Kind == TypeUnknown {
+		return false
+	}
+	switch t.Kind {
+	case TypeInt32:
+		return other.Kind == TypeInt16
+	case TypeInt64:
+		return other.Kind == TypeInt16 || other.Kind == TypeInt32
+	case TypeFloat64:
+		return other.Kind == TypeFloat32
+	case TypeNumeric:
+		if t.Precision == 0 {
+			return other.Kind == TypeNumeric || integerDigits[other.Kind] > 0
+		}
+		if digits := integerDigits[other.Kind]; digits > 0 {
+			return t.Precision-t.Scale >= digits
+		}
+		return other.Kind == TypeNumeric &&
+			other.Precision != 0 &&
+			t.Scale >= other.Scale &&
+			t.Precision-t.Scale >= other.Precision-other.Scale
+	case TypeString, TypeBytes:
+		return other.Kind == t.Kind &&
+			(t.Length == 0 || (other.Length != 0 && t.Length >= other.Length))
+	default:
+		return false
+	}
+}
@@ -0,0 +1,454 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package dlq
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// An Entry is a single row in a dead-letter queue.
+type Entry struct {
+	// The error which prevented the mutation from being applied, if
+	// the DLQ table has an error_text column and it was recorded.
+	Error string
+	Name  string
+	// The Key field is not populated, since the DLQ table does not
+	// record the target table. See [Admin.Replay].
+	Mutation types.Mutation
+}
+
+// A Summary describes the contents of a single dead-letter queue.
+type Summary struct {
+	Name   string
+	Count  int64
+	Oldest time.Time
+	Newest time.Time
+}
+
+// ReplayOptions controls the behavior of [Admin.Replay].
+type ReplayOptions struct {
+	// If non-empty, entries which cannot be applied will be moved to a
+	// dead-letter queue with this name. Otherwise, they are left in
+	// place.
+	FailedName string
+	// An optional function to transform mutations before they are
+	// applied, e.g. a userscript map function. If the function returns
+	// false, the entry will be discarded.
+	Map func(ctx context.Context, mut types.Mutation) (types.Mutation, bool, error)
+	// The dead-letter queue to replay.
+	Name string
+	// The table to apply the mutations to.
+	Target ident.Table
+}
+
+// ReplayResult summarizes the outcome of [Admin.Replay].
+type ReplayResult struct {
+	Applied int // Entries applied to the target table.
+	Dropped int // Entries filtered by the map function.
+	Failed  int // Entries which could not be applied.
+	Skipped int // Entries which do not represent a mutation.
+}
+
+// Admin provides tools for inspecting and replaying the contents of
+// dead-letter queues.
+type Admin struct {
+	appliers   types.Appliers
+	cfg        *Config
+	targetPool *types.TargetPool
+	watchers   types.Watchers
+}
+
+// List returns a summary of the dead-letter queues in the schema.
+func (a *Admin) List(ctx context.Context, schema ident.Schema) ([]*Summary, error) {
+	q := fmt.Sprintf(`SELECT dlq_name, count(*), min(source_nanos), max(source_nanos)
+FROM %s GROUP BY dlq_name ORDER BY dlq_name`, a.table(schema))
+	rows, err := a.targetPool.QueryContext(ctx, q)
+	if err != nil {
+		return nil, errors.Wrap(err, q)
+	}
+	defer rows.Close()
+
+	var ret []*Summary
+	for rows.Next() {
+		var oldest, newest int64
+		s := &Summary{}
+		if err := rows.Scan(&s.Name, &s.Count, &oldest, &newest); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		s.Oldest = time.Unix(0, oldest).UTC()
+		s.Newest = time.Unix(0, newest).UTC()
+		ret = append(ret, s)
+	}
+	return ret, errors.WithStack(rows.Err())
+}
+
+// Purge deletes all entries in the named dead-letter queue and returns
+// the number of rows which were deleted.
+func (a *Admin) Purge(ctx context.Context, schema ident.Schema, name string) (int64, error) {
+	q := fmt.Sprintf(`DELETE FROM %s WHERE dlq_name = %s`, a.table(schema), a.param(1))
+	res, err := a.targetPool.ExecContext(ctx, q, name)
+	if err != nil {
+		return 0, errors.Wrap(err, q)
+	}
+	count, err := res.RowsAffected()
+	return count, errors.WithStack(err)
+}
+
+// Read invokes the callback with the entries of the named dead-letter
+// queue, in time order. Returning [ErrStop] from the callback will end
+// the iteration without error.
+func (a *Admin) Read(
+	ctx context.Context, schema ident.Schema, name string, fn func(*Entry) error,
+) error {
+	withError, err := a.hasErrorText(ctx, schema)
+	if err != nil {
+		return err
+	}
+	errorExpr := "NULL"
+	if withError {
+		errorExpr = "error_text"
+	}
+	q := fmt.Sprintf(`SELECT source_nanos, source_logical, data_after, data_before, %s
+FROM %s WHERE dlq_name = %s ORDER BY source_nanos, source_logical`,
+		errorExpr, a.table(schema), a.param(1))
+	rows, err := a.targetPool.QueryContext(ctx, q, name)
+	if err != nil {
+		return errors.Wrap(err, q)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var nanos, logical int64
+		var after, before string
+		var errText sql.NullString
+		if err := rows.Scan(&nanos, &logical, &after, &before, &errText); err != nil {
+			return errors.WithStack(err)
+		}
+		entry := &Entry{
+			Error: errText.String,
+			Name:  name,
+			Mutation: types.Mutation{
+				Time: hlc.New(nanos, int(logical)),
+			},
+		}
+		// See discussion in dlq.Enqueue.
+		if after != "null" {
+			entry.Mutation.Data = json.RawMessage(after)
+		}
+		if before != "null" {
+			entry.Mutation.Before = json.RawMessage(before)
+		}
+		if err := fn(entry); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return errors.WithStack(rows.Err())
+}
+
+// ErrStop may be returned from the callback passed to [Admin.Read].
+var ErrStop = errors.New("stop")
+
+// Replay re-applies the entries in a dead-letter queue to the target
+// table. The entries are applied in time order, with all entries that
+// share a timestamp applied in a single transaction. Entries are
+// deleted from the queue once they have been applied, except for
+// truncation markers, which are skipped and retained. The Key of each
+// mutation is reconstructed from the target table's primary key
+// columns.
+//
+// Entries which cannot be applied are logged and are either left in
+// place or moved to [ReplayOptions.FailedName]. An error is returned
+// only if the dead-letter queue cannot be read.
+func (a *Admin) Replay(ctx context.Context, opts *ReplayOptions) (*ReplayResult, error) {
+	schema := opts.Target.Schema()
+	applier, err := a.appliers.Get(ctx, opts.Target)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := a.watchers.Get(ctx, schema)
+	if err != nil {
+		return nil, err
+	}
+	cols, ok := watcher.Get().Columns.Get(opts.Target)
+	if !ok {
+		return nil, errors.Errorf("table %s not found in target", opts.Target)
+	}
+	var pks []ident.Ident
+	for _, col := range cols {
+		if col.Primary {
+			pks = append(pks, col.Name)
+		}
+	}
+
+	// Read the entries before modifying the table, since some products
+	// do not allow writes while a cursor is open.
+	var entries []*Entry
+	if err := a.Read(ctx, schema, opts.Name, func(entry *Entry) error {
+		entries = append(entries, entry)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	ret := &ReplayResult{}
+	for len(entries) > 0 {
+		// Find all entries that share the same timestamp.
+		end := 1
+		for end < len(entries) && entries[end].Mutation.Time == entries[0].Mutation.Time {
+			end++
+		}
+		group := entries[:end]
+		entries = entries[end:]
+
+		applied, dropped, skipped, err := a.replayGroup(ctx, opts, applier, pks, group)
+		if err == nil {
+			ret.Applied += applied
+			ret.Dropped += dropped
+			ret.Skipped += skipped
+			continue
+		}
+		if ctx.Err() != nil {
+			return ret, ctx.Err()
+		}
+		ret.Failed += len(group)
+		log.WithError(err).WithFields(log.Fields{
+			"dlq":  opts.Name,
+			"time": group[0].Mutation.Time,
+		}).Warn("could not replay dead-letter entries")
+
+		if opts.FailedName == "" {
+			continue
+		}
+		if moveErr := a.move(ctx, schema, opts.Name, opts.FailedName,
+			group[0].Mutation.Time, err); moveErr != nil {
+			return ret, moveErr
+		}
+	}
+	return ret, nil
+}
+
+// replayGroup applies entries which share a timestamp in a single
+// transaction.
+func (a *Admin) replayGroup(
+	ctx context.Context,
+	opts *ReplayOptions,
+	applier types.Applier,
+	pks []ident.Ident,
+	group []*Entry,
+) (applied, dropped, skipped int, err error) {
+	muts := make([]types.Mutation, 0, len(group))
+	var markers []*Entry
+	for _, entry := range group {
+		mut := entry.Mutation
+		if isTruncate(mut) {
+			markers = append(markers, entry)
+			continue
+		}
+		if opts.Map != nil {
+			var keep bool
+			mut, keep, err = opts.Map(ctx, mut)
+			if err != nil {
+				return 0, 0, 0, err
+			}
+			if !keep {
+				dropped++
+				continue
+			}
+		}
+		mut.Key, err = rebuildKey(mut, pks)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		muts = append(muts, mut)
+	}
+
+	tx, err := a.targetPool.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, 0, errors.WithStack(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Delete the entries before applying the mutations, since a merge
+	// function may send them back to the same dead-letter queue.
+	t := group[0].Mutation.Time
+	q := fmt.Sprintf(`DELETE FROM %s WHERE dlq_name = %s AND source_nanos = %s AND source_logical = %s`,
+		a.table(opts.Target.Schema()), a.param(1), a.param(2), a.param(3))
+	if _, err := tx.ExecContext(ctx, q, opts.Name, t.Nanos(), t.Logical()); err != nil {
+		return 0, 0, 0, errors.Wrap(err, q)
+	}
+	// The DLQ table has no unique key, so put back any truncation
+	// markers that were deleted alongside the replayed entries. They
+	// must be retained for the operator to act upon.
+	for _, marker := range markers {
+		if err := a.restore(ctx, tx, opts.Target.Schema(), marker); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	if len(muts) > 0 {
+		if err := applier.Apply(ctx, tx, muts); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, 0, errors.WithStack(err)
+	}
+	return len(muts), dropped, len(markers), nil
+}
+
+// restore re-inserts an entry that was read from a dead-letter queue.
+func (a *Admin) restore(ctx context.Context, tx *sql.Tx, schema ident.Schema, entry *Entry) error {
+	withError, err := a.hasErrorText(ctx, schema)
+	if err != nil {
+		return err
+	}
+	// See discussion in dlq.Enqueue.
+	after := string(entry.Mutation.Data)
+	if len(after) == 0 {
+		after = "null"
+	}
+	before := string(entry.Mutation.Before)
+	if len(before) == 0 {
+		before = "null"
+	}
+	t := entry.Mutation.Time
+	q := qBase
+	args := []any{entry.Name, t.Nanos(), t.Logical(), after, before}
+	if withError {
+		q = qBaseError
+		var errText *string
+		if entry.Error != "" {
+			errText = &entry.Error
+		}
+		args = append(args, errText)
+	}
+	params := make([]string, len(args))
+	for idx := range params {
+		params[idx] = a.param(idx + 1)
+	}
+	q = fmt.Sprintf(q, a.table(schema)) + "(" + strings.Join(params, ", ") + ")"
+	_, err = tx.ExecContext(ctx, q, args...)
+	return errors.Wrap(err, q)
+}
+
+// move renames the entries in a dead-letter queue with the given
+// timestamp. The cause will be recorded if the DLQ table has an
+// error_text column.
+func (a *Admin) move(
+	ctx context.Context, schema ident.Schema, from, to string, t hlc.Time, cause error,
+) error {
+	withError, err := a.hasErrorText(ctx, schema)
+	if err != nil {
+		return err
+	}
+	args := []any{to, from, t.Nanos(), t.Logical()}
+	set := fmt.Sprintf("dlq_name = %s", a.param(1))
+	if withError {
+		set += fmt.Sprintf(", error_text = %s", a.param(5))
+		args = append(args, truncateErrorText(cause.Error()))
+	}
+	q := fmt.Sprintf(`UPDATE %s SET %s WHERE dlq_name = %s AND source_nanos = %s AND source_logical = %s`,
+		a.table(schema), set, a.param(2), a.param(3), a.param(4))
+	_, err = a.targetPool.ExecContext(ctx, q, args...)
+	return errors.Wrap(err, q)
+}
+
+// hasErrorText returns true if the DLQ table has the optional
+// error_text column.
+func (a *Admin) hasErrorText(ctx context.Context, schema ident.Schema) (bool, error) {
+	watcher, err := a.watchers.Get(ctx, schema)
+	if err != nil {
+		return false, err
+	}
+	cols, ok := watcher.Get().Columns.Get(a.table(schema))
+	if !ok {
+		return false, errors.Errorf("dead-letter queue table %s not found", a.table(schema))
+	}
+	for _, col := range cols {
+		if ident.Equal(col.Name, errorTextColumn) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// param returns the product-specific placeholder for the one-based
+// argument index.
+func (a *Admin) param(idx int) string {
+	return param(a.targetPool.Product, idx)
+}
+
+// table returns the name of the DLQ table within the schema.
+func (a *Admin) table(schema ident.Schema) ident.Table {
+	return ident.NewTable(schema, a.cfg.TableName)
+}
+
+// isTruncate returns true if the mutation is a marker written by a
+// source when a truncation could not be applied.
+func isTruncate(mut types.Mutation) bool {
+	var marker struct {
+		Operation string `json:"operation"`
+		Table     string `json:"table"`
+	}
+	if len(mut.Data) == 0 || len(mut.Before) > 0 || json.Unmarshal(mut.Data, &marker) != nil {
+		return false
+	}
+	return marker.Operation == "truncate" && marker.Table != ""
+}
+
+// rebuildKey constructs the JSON array of primary key values from the
+// mutation's data, or its before data if the mutation is a deletion.
+func rebuildKey(mut types.Mutation, pks []ident.Ident) (json.RawMessage, error) {
+	src := mut.Data
+	if mut.IsDelete() {
+		src = mut.Before
+	}
+	if len(src) == 0 {
+		return nil, errors.New("deletion has no before data to reconstruct the key from")
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var values ident.Map[json.RawMessage]
+	for k, v := range raw {
+		values.Put(ident.New(k), v)
+	}
+	key := make([]json.RawMessage, len(pks))
+	for idx, pk := range pks {
+		v, ok := values.Get(pk)
+		if !ok {
+			return nil, errors.Errorf("missing primary key column %s", pk)
+		}
+		key[idx] = v
+	}
+	ret, err := json.Marshal(key)
+	return ret, errors.WithStack(err)
+}
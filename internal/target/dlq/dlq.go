@@ -21,9 +21,12 @@ package dlq
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
@@ -35,15 +38,77 @@ Consider using the following schema:
 
 `
 
+// maxErrorText limits the length of the error text that will be
+// recorded, since some products have a maximum VARCHAR length.
+const maxErrorText = 4096
+
 type dlq struct {
-	name string
-	stmt *sql.Stmt
+	name      string
+	product   types.Product
+	stmt      *sql.Stmt
+	table     ident.Table
+	withError bool // The statement accepts an error_text argument.
 }
 
 var _ types.DLQ = (*dlq)(nil)
 
 // Enqueue implements [types.DLQ].
 func (d *dlq) Enqueue(ctx context.Context, tx types.TargetQuerier, mut types.Mutation) error {
+	return d.enqueue(ctx, tx, mut, nil)
+}
+
+// EnqueueError implements [types.DLQ]. The error text will be discarded
+// if the DLQ table does not have an error_text column.
+func (d *dlq) EnqueueError(
+	ctx context.Context, tx types.TargetQuerier, mut types.Mutation, cause error,
+) error {
+	return d.enqueue(ctx, tx, mut, cause)
+}
+
+// EnqueueOnce implements [types.DLQ]. The data of any existing entries
+// with the same timestamp is compared as JSON values, since the target
+// may not preserve the formatting of the data.
+func (d *dlq) EnqueueOnce(ctx context.Context, tx types.TargetQuerier, mut types.Mutation) error {
+	var want any
+	if len(mut.Data) > 0 {
+		if err := json.Unmarshal(mut.Data, &want); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	q := fmt.Sprintf(`SELECT data_after FROM %s WHERE dlq_name = %s AND source_nanos = %s AND source_logical = %s`,
+		d.table, param(d.product, 1), param(d.product, 2), param(d.product, 3))
+	rows, err := tx.QueryContext(ctx, q, d.name, mut.Time.Nanos(), mut.Time.Logical())
+	if err != nil {
+		return errors.Wrap(err, q)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var after string
+		if err := rows.Scan(&after); err != nil {
+			return errors.WithStack(err)
+		}
+		var found any
+		if err := json.Unmarshal([]byte(after), &found); err != nil {
+			return errors.WithStack(err)
+		}
+		if reflect.DeepEqual(want, found) {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	// Close the cursor, since some products do not allow writes while
+	// one is open.
+	_ = rows.Close()
+
+	return d.enqueue(ctx, tx, mut, nil)
+}
+
+func (d *dlq) enqueue(
+	ctx context.Context, tx types.TargetQuerier, mut types.Mutation, cause error,
+) error {
 	stmt := d.stmt
 	// Bind the prepared statement to the current transaction.
 	if sqlTx, ok := tx.(*sql.Tx); ok {
@@ -60,10 +125,55 @@ func (d *dlq) Enqueue(ctx context.Context, tx types.TargetQuerier, mut types.Mut
 	if len(before) == 0 {
 		before = "null"
 	}
-	_, err := stmt.ExecContext(ctx, d.name, mut.Time.Nanos(), mut.Time.Logical(), after, before)
+	args := []any{d.name, mut.Time.Nanos(), mut.Time.Logical(), after, before}
+	if d.withError {
+		var errText *string
+		if cause != nil {
+			s := truncateErrorText(cause.Error())
+			errText = &s
+		}
+		args = append(args, errText)
+	}
+	_, err := stmt.ExecContext(ctx, args...)
 	return errors.WithStack(err)
 }
 
+// withName returns a copy of the dlq that will write entries with the
+// given name. The prepared statement is shared.
+func (d *dlq) withName(name string) *dlq {
+	ret := *d
+	ret.name = name
+	return &ret
+}
+
+// param returns the product-specific placeholder for the one-based
+// argument index.
+func param(product types.Product, idx int) string {
+	switch product {
+	case types.ProductMySQL, types.ProductSQLite:
+		return "?"
+	case types.ProductOracle:
+		return fmt.Sprintf(":%d", idx)
+	case types.ProductSQLServer:
+		return fmt.Sprintf("@p%d", idx)
+	default:
+		return fmt.Sprintf("$%d", idx)
+	}
+}
+
+// truncateErrorText limits the string to maxErrorText bytes, without
+// splitting a multibyte character.
+func truncateErrorText(s string) string {
+	if len(s) <= maxErrorText {
+		return s
+	}
+	s = s[:maxErrorText]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
 type dlqs struct {
 	cfg        *Config
 	targetPool *types.TargetPool
@@ -86,7 +196,7 @@ func (d *dlqs) Get(ctx context.Context, target ident.Schema, name string) (types
 	found, ok := d.mu.validated.Get(tbl)
 	d.mu.RUnlock()
 	if ok {
-		return found, nil
+		return found.withName(name), nil
 	}
 
 	d.mu.Lock()
@@ -94,7 +204,7 @@ func (d *dlqs) Get(ctx context.Context, target ident.Schema, name string) (types
 
 	// Double-check idiom.
 	if found, ok := d.mu.validated.Get(tbl); ok {
-		return found, nil
+		return found.withName(name), nil
 	}
 
 	watcher, err := d.watchers.Get(ctx, target)
@@ -126,15 +236,32 @@ func (d *dlqs) Get(ctx context.Context, target ident.Schema, name string) (types
 			tbl, missing.String())
 	}
 
+	// Record error text only if the user has created the column.
+	_, withError := knownCols.Get(errorTextColumn)
+
 	// The query differs only in the argument syntax.
 	var q string
 	switch d.targetPool.Product {
-	case types.ProductCockroachDB, types.ProductPostgreSQL:
+	case types.ProductCockroachDB, types.ProductPostgreSQL, types.ProductRedshift:
 		q = qBase + argsPG
+		if withError {
+			q = qBaseError + argsPGError
+		}
 	case types.ProductOracle:
 		q = qBase + argsOra
-	case types.ProductMySQL:
+		if withError {
+			q = qBaseError + argsOraError
+		}
+	case types.ProductMySQL, types.ProductSQLite:
 		q = qBase + argsMySQL
+		if withError {
+			q = qBaseError + argsMySQLError
+		}
+	case types.ProductSQLServer:
+		q = qBase + argsMSSQL
+		if withError {
+			q = qBaseError + argsMSSQLError
+		}
 	default:
 		return nil, errors.Errorf("dlq unimplemented for product %s", d.targetPool.Product)
 	}
@@ -147,8 +274,11 @@ func (d *dlqs) Get(ctx context.Context, target ident.Schema, name string) (types
 	}
 
 	ret := &dlq{
-		name: name,
-		stmt: stmt,
+		name:      name,
+		product:   d.targetPool.Product,
+		stmt:      stmt,
+		table:     tbl,
+		withError: withError,
 	}
 	d.mu.validated.Put(tbl, ret)
 	return ret, nil
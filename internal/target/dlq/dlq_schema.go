@@ -34,11 +34,22 @@ var expectedColumns = []ident.Ident{
 	ident.New("data_before"),
 }
 
+// errorTextColumn is optional. If it is present in the DLQ table, the
+// error which prevented a mutation from being applied will be recorded.
+var errorTextColumn = ident.New("error_text")
+
 const (
 	qBase     = `INSERT INTO %s (dlq_name, source_nanos, source_logical, data_after, data_before) VALUES `
 	argsPG    = `($1, $2, $3, $4, $5)`
 	argsMySQL = `(?, ?, ?, ?, ?)`
 	argsOra   = `(:1, :2, :3, :4, :5)`
+	argsMSSQL = `(@p1, @p2, @p3, @p4, @p5)`
+
+	qBaseError     = `INSERT INTO %s (dlq_name, source_nanos, source_logical, data_after, data_before, error_text) VALUES `
+	argsPGError    = `($1, $2, $3, $4, $5, $6)`
+	argsMySQLError = `(?, ?, ?, ?, ?, ?)`
+	argsOraError   = `(:1, :2, :3, :4, :5, :6)`
+	argsMSSQLError = `(@p1, @p2, @p3, @p4, @p5, @p6)`
 )
 
 // These constants define a plausible reference schema that can be used
@@ -51,7 +62,8 @@ dlq_name TEXT NOT NULL,
 source_nanos INT8 NOT NULL,
 source_logical INT8 NOT NULL,
 data_after JSONB NOT NULL,
-data_before JSONB NOT NULL
+data_before JSONB NOT NULL,
+error_text TEXT
 )`
 	basicMySQLSchema = `CREATE TABLE %[1]s (
 event binary(16) DEFAULT (uuid()) PRIMARY KEY,
@@ -59,7 +71,8 @@ dlq_name TEXT NOT NULL,
 source_nanos INT8 NOT NULL,
 source_logical INT8 NOT NULL,
 data_after JSON NOT NULL,
-data_before JSON NOT NULL
+data_before JSON NOT NULL,
+error_text TEXT
 )`
 	basicOraSchema = `CREATE TABLE %[1]s (
 event INTEGER GENERATED ALWAYS AS IDENTITY,
@@ -67,7 +80,8 @@ dlq_name VARCHAR(256) NOT NULL,
 source_nanos INTEGER NOT NULL,
 source_logical INTEGER NOT NULL,
 data_after CLOB NOT NULL,
-data_before CLOB NOT NULL
+data_before CLOB NOT NULL,
+error_text CLOB
 )`
 	basicPGSchema = `CREATE TABLE %[1]s (
 event SERIAL PRIMARY KEY,
@@ -75,7 +89,38 @@ dlq_name TEXT NOT NULL,
 source_nanos INT8 NOT NULL,
 source_logical INT8 NOT NULL,
 data_after JSONB NOT NULL,
-data_before JSONB NOT NULL
+data_before JSONB NOT NULL,
+error_text TEXT
+)`
+	// Redshift has no JSON column type that accepts a bare string, so
+	// the payloads are stored as text.
+	basicRedshiftSchema = `CREATE TABLE %[1]s (
+dlq_name VARCHAR(256) NOT NULL,
+source_nanos INT8 NOT NULL,
+source_logical INT8 NOT NULL,
+data_after VARCHAR(65535) NOT NULL,
+data_before VARCHAR(65535) NOT NULL,
+error_text VARCHAR(65535)
+)`
+	basicSQLiteSchema = `CREATE TABLE %[1]s (
+event INTEGER PRIMARY KEY,
+dlq_name TEXT NOT NULL,
+source_nanos INTEGER NOT NULL,
+source_logical INTEGER NOT NULL,
+data_after TEXT NOT NULL,
+data_before TEXT NOT NULL,
+error_text TEXT
+)`
+	// The payloads are stored as text, since SQL Server validates JSON
+	// with functions rather than with a column type.
+	basicSQLServerSchema = `CREATE TABLE %[1]s (
+event BIGINT IDENTITY PRIMARY KEY,
+dlq_name NVARCHAR(256) NOT NULL,
+source_nanos BIGINT NOT NULL,
+source_logical BIGINT NOT NULL,
+data_after NVARCHAR(MAX) NOT NULL,
+data_before NVARCHAR(MAX) NOT NULL,
+error_text NVARCHAR(MAX)
 )`
 )
 
@@ -87,4 +132,7 @@ var BasicSchemas = map[types.Product]string{
 	types.ProductPostgreSQL:  basicPGSchema,
 	types.ProductMySQL:       basicMySQLSchema,
 	types.ProductOracle:      basicOraSchema,
+	types.ProductRedshift:    basicRedshiftSchema,
+	types.ProductSQLite:      basicSQLiteSchema,
+	types.ProductSQLServer:   basicSQLServerSchema,
 }
@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
 	"github.com/cockroachdb/cdc-sink/internal/util/ident"
@@ -69,6 +70,43 @@ func TestDLQ(t *testing.T) {
 	r.Equal(len(muts), ct)
 }
 
+// TestEnqueueOnce verifies that re-recording an entry with the same
+// time and data does not create a duplicate.
+func TestEnqueueOnce(t *testing.T) {
+	r := require.New(t)
+
+	fixture, cancel, err := all.NewFixture()
+	r.NoError(err)
+	defer cancel()
+
+	ctx := fixture.Context
+
+	dlTable, err := fixture.CreateDLQTable(ctx)
+	r.NoError(err)
+
+	out, err := fixture.DLQs.Get(ctx, fixture.TargetSchema.Schema(), "my_dlq")
+	r.NoError(err)
+
+	marker := types.Mutation{
+		Data: []byte(`{"lsn":"0/16B3748","operation":"truncate","table":"t"}`),
+		Time: hlc.New(123, 0),
+	}
+	other := marker
+	other.Data = []byte(`{"lsn":"0/16B3748","operation":"truncate","table":"u"}`)
+
+	// The data is compared as JSON, so whitespace doesn't matter.
+	replayed := marker
+	replayed.Data = []byte(`{ "table": "t", "operation": "truncate", "lsn": "0/16B3748" }`)
+
+	for _, mut := range []types.Mutation{marker, other, replayed, marker} {
+		r.NoError(out.EnqueueOnce(ctx, fixture.TargetPool.DB, mut))
+	}
+
+	var ct int
+	r.NoError(fixture.TargetPool.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s", dlTable)).Scan(&ct))
+	r.Equal(2, ct)
+}
+
 // TestMissingColumns verifies the error-reporting behavior if the DLQ
 // table exists, but does not contain the required columns.
 func TestMissingColumns(t *testing.T) {
@@ -108,3 +146,105 @@ func TestMissingTable(t *testing.T) {
 
 	r.ErrorContains(err, "must be created")
 }
+
+// TestAdmin enqueues entries into a DLQ and then verifies that they can
+// be listed, replayed into a target table, and purged.
+func TestAdmin(t *testing.T) {
+	r := require.New(t)
+
+	fixture, cancel, err := all.NewFixture()
+	r.NoError(err)
+	defer cancel()
+
+	ctx := fixture.Context
+	schema := fixture.TargetSchema.Schema()
+
+	_, err = fixture.CreateDLQTable(ctx)
+	r.NoError(err)
+	tgt, err := fixture.CreateTargetTable(ctx, "CREATE TABLE %s (pk INT PRIMARY KEY, v INT)")
+	r.NoError(err)
+
+	out, err := fixture.DLQs.Get(ctx, schema, "replay_me")
+	r.NoError(err)
+
+	muts := []types.Mutation{
+		{Data: []byte(`{"pk":1,"v":1}`), Time: hlc.New(100, 0)},
+		{Data: []byte(`{"pk":2,"v":2}`), Time: hlc.New(100, 0)},
+		// A deletion must be keyed from the before data.
+		{Before: []byte(`{"pk":1,"v":1}`), Time: hlc.New(200, 0)},
+		// Truncation markers are skipped, but must survive the replay
+		// of other entries that share their timestamp.
+		{Data: []byte(`{"operation":"truncate","table":"foo"}`), Time: hlc.New(300, 0)},
+		{Data: []byte(`{"pk":3,"v":3}`), Time: hlc.New(300, 0)},
+		// This entry has no primary key, so it cannot be applied.
+		{Data: []byte(`{"v":3}`), Time: hlc.New(400, 0)},
+	}
+	for _, mut := range muts {
+		r.NoError(out.Enqueue(ctx, fixture.TargetPool.DB, mut))
+	}
+
+	admin := dlq.ProvideAdmin(fixture.DLQConfig, fixture.TargetPool, fixture.Appliers, fixture.Watchers)
+
+	summaries, err := admin.List(ctx, schema)
+	r.NoError(err)
+	r.Len(summaries, 1)
+	r.Equal("replay_me", summaries[0].Name)
+	r.Equal(int64(len(muts)), summaries[0].Count)
+
+	// Verify that the mutation data is restored and that the callback
+	// can stop the iteration.
+	var read []*dlq.Entry
+	r.NoError(admin.Read(ctx, schema, "replay_me", func(entry *dlq.Entry) error {
+		if len(read) == 3 {
+			return dlq.ErrStop
+		}
+		read = append(read, entry)
+		return nil
+	}))
+	r.Len(read, 3)
+	r.Equal(hlc.New(200, 0), read[2].Mutation.Time)
+	r.True(read[2].Mutation.IsDelete())
+	r.JSONEq(`{"pk":1,"v":1}`, string(read[2].Mutation.Before))
+
+	res, err := admin.Replay(ctx, &dlq.ReplayOptions{
+		FailedName: "replay_failed",
+		Name:       "replay_me",
+		Target:     tgt.Name(),
+	})
+	r.NoError(err)
+	r.Equal(&dlq.ReplayResult{Applied: 4, Failed: 1, Skipped: 1}, res)
+
+	ct, err := tgt.RowCount(ctx)
+	r.NoError(err)
+	r.Equal(2, ct)
+
+	// The failed entry should have been moved to another queue and the
+	// truncation marker should have been retained.
+	summaries, err = admin.List(ctx, schema)
+	r.NoError(err)
+	r.Len(summaries, 2)
+	r.Equal("replay_failed", summaries[0].Name)
+	r.Equal(int64(1), summaries[0].Count)
+	r.Equal("replay_me", summaries[1].Name)
+	r.Equal(int64(1), summaries[1].Count)
+
+	var kept []*dlq.Entry
+	r.NoError(admin.Read(ctx, schema, "replay_me", func(entry *dlq.Entry) error {
+		kept = append(kept, entry)
+		return nil
+	}))
+	r.Len(kept, 1)
+	r.Equal(hlc.New(300, 0), kept[0].Mutation.Time)
+	r.JSONEq(`{"operation":"truncate","table":"foo"}`, string(kept[0].Mutation.Data))
+
+	purged, err := admin.Purge(ctx, schema, "replay_failed")
+	r.NoError(err)
+	r.Equal(int64(1), purged)
+	purged, err = admin.Purge(ctx, schema, "replay_me")
+	r.NoError(err)
+	r.Equal(int64(1), purged)
+
+	summaries, err = admin.List(ctx, schema)
+	r.NoError(err)
+	r.Empty(summaries)
+}
@@ -22,7 +22,20 @@ import (
 )
 
 // Set is used by Wire.
-var Set = wire.NewSet(ProvideDLQs)
+var Set = wire.NewSet(ProvideAdmin, ProvideDLQs)
+
+// ProvideAdmin is called by Wire to construct the DLQ administration
+// tools.
+func ProvideAdmin(
+	cfg *Config, pool *types.TargetPool, appliers types.Appliers, watchers types.Watchers,
+) *Admin {
+	return &Admin{
+		appliers:   appliers,
+		cfg:        cfg,
+		targetPool: pool,
+		watchers:   watchers,
+	}
+}
 
 // ProvideDLQs is called by Wire to construct the DLQs instance.
 func ProvideDLQs(cfg *Config, pool *types.TargetPool, watchers types.Watchers) types.DLQs {
@@ -0,0 +1,139 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"crypto/tls"
+
+	"github.com/IBM/sarama"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultClientID    = "cdc-sink"
+	defaultMarkerTopic = "cdc_sink_resolved"
+)
+
+// Config contains the configuration necessary for publishing resolved
+// mutations to Kafka topics. Brokers and a TransactionalID are
+// mandatory.
+type Config struct {
+	Brokers         []string // Bootstrap addresses of the Kafka cluster.
+	ClientID        string   // Identifies this process to the brokers.
+	MarkerTopic     string   // Receives a commit marker for each window.
+	SASLUser        string   // Enables SASL/PLAIN authentication.
+	SASLPassword    string   // Used with SASLUser.
+	TLS             bool     // Connect to the brokers using TLS.
+	TopicPrefix     string   // Prepended to the name of each table's topic.
+	TransactionalID string   // Must be stable across restarts.
+	Version         string   // The Kafka protocol version to use.
+
+	// The fields below are extracted by Preflight.
+
+	version sarama.KafkaVersion
+}
+
+// Bind adds flags to the set.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	f.StringSliceVar(&c.Brokers, "kafkaTargetBrokers", nil,
+		"the addresses of one or more Kafka brokers to publish to")
+	f.StringVar(&c.ClientID, "kafkaTargetClientID", defaultClientID,
+		"the client id to report to the Kafka brokers")
+	f.StringVar(&c.MarkerTopic, "kafkaMarkerTopic", defaultMarkerTopic,
+		"the Kafka topic that receives a commit marker for each published window")
+	f.StringVar(&c.SASLUser, "kafkaTargetUser", "",
+		"a username for SASL/PLAIN authentication")
+	f.StringVar(&c.SASLPassword, "kafkaTargetPassword", "",
+		"a password for SASL/PLAIN authentication")
+	f.BoolVar(&c.TLS, "kafkaTargetTLS", false,
+		"connect to the Kafka brokers using TLS")
+	f.StringVar(&c.TopicPrefix, "kafkaTopicPrefix", "",
+		"a prefix to add to the name of each table's topic")
+	f.StringVar(&c.TransactionalID, "kafkaTransactionalID", "",
+		"the transactional id of the producer; must be stable across restarts")
+	f.StringVar(&c.Version, "kafkaTargetVersion", "",
+		"the Kafka protocol version to use (e.g. 2.8.0)")
+}
+
+// Preflight updates the configuration with sane defaults or returns an
+// error if there are missing options for which a default cannot be
+// provided.
+func (c *Config) Preflight() error {
+	if len(c.Brokers) == 0 {
+		return errors.New("no Brokers were configured")
+	}
+	if c.ClientID == "" {
+		c.ClientID = defaultClientID
+	}
+	if c.MarkerTopic == "" {
+		c.MarkerTopic = defaultMarkerTopic
+	}
+	if c.SASLPassword != "" && c.SASLUser == "" {
+		return errors.New("a SASL password requires a SASL user")
+	}
+	if c.TransactionalID == "" {
+		return errors.New("no TransactionalID was configured")
+	}
+
+	c.version = sarama.DefaultVersion
+	if c.Version != "" {
+		var err error
+		c.version, err = sarama.ParseKafkaVersion(c.Version)
+		if err != nil {
+			return errors.Wrapf(err, "invalid Kafka version %s", c.Version)
+		}
+	}
+	return nil
+}
+
+// clientConfig returns the configuration to use when reading from the
+// brokers. Preflight must have been called.
+func (c *Config) clientConfig() *sarama.Config {
+	ret := sarama.NewConfig()
+	ret.ClientID = c.ClientID
+	ret.Consumer.Return.Errors = true
+	ret.Version = c.version
+	// Only read the markers from committed windows.
+	if c.version.IsAtLeast(sarama.V0_11_0_0) {
+		ret.Consumer.IsolationLevel = sarama.ReadCommitted
+	}
+	if c.SASLUser != "" {
+		ret.Net.SASL.Enable = true
+		ret.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		ret.Net.SASL.User = c.SASLUser
+		ret.Net.SASL.Password = c.SASLPassword
+	}
+	if c.TLS {
+		ret.Net.TLS.Enable = true
+		ret.Net.TLS.Config = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return ret
+}
+
+// producerConfig returns the configuration for an idempotent,
+// transactional producer. Reusing the TransactionalID fences off any
+// previous instance of the producer.
+func (c *Config) producerConfig() *sarama.Config {
+	ret := c.clientConfig()
+	ret.Net.MaxOpenRequests = 1
+	ret.Producer.Idempotent = true
+	ret.Producer.RequiredAcks = sarama.WaitForAll
+	ret.Producer.Return.Successes = true
+	ret.Producer.Transaction.ID = c.TransactionalID
+	return ret
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/pkg/errors"
+)
+
+// markerIdle bounds the time spent waiting for the tail of the marker
+// topic. The control records written at the end of each Kafka
+// transaction are never delivered to a consumer, so we may not see a
+// message at the last offset of the partition.
+const markerIdle = time.Second
+
+// markerLookback is the number of offsets at the end of the marker
+// topic that are examined to find the latest marker. It must be larger
+// than the number of consecutive transactions that may be aborted.
+const markerLookback = 32
+
+// envelope is written for each mutation. It uses the same wrapped
+// format as a CockroachDB changefeed, so the topics can be consumed by
+// the kafka source.
+type envelope struct {
+	After   json.RawMessage `json:"after"`
+	Before  json.RawMessage `json:"before,omitempty"`
+	Key     json.RawMessage `json:"key"`
+	Updated hlc.Time        `json:"updated"`
+}
+
+// marker is written to the marker topic in the same Kafka transaction
+// as the messages in a window. Consumers will have seen every mutation
+// at or before the Resolved time once they have seen the marker. The
+// Counts field records the number of messages written to each topic.
+type marker struct {
+	Counts   map[string]int `json:"counts"`
+	Resolved hlc.Time       `json:"resolved"`
+}
+
+// readMarker returns the time of the latest marker that has been
+// committed to the marker topic, or zero if there is none.
+func readMarker(ctx context.Context, cfg *Config) (hlc.Time, error) {
+	client, err := sarama.NewClient(cfg.Brokers, cfg.clientConfig())
+	if err != nil {
+		dialFailureCount.Inc()
+		return hlc.Zero(), errors.WithStack(err)
+	}
+	defer func() { _ = client.Close() }()
+	dialSuccessCount.Inc()
+
+	topic := cfg.MarkerTopic
+	parts, err := client.Partitions(topic)
+	if err != nil {
+		if errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+			return hlc.Zero(), nil
+		}
+		return hlc.Zero(), errors.Wrapf(err, "could not list partitions of topic %s", topic)
+	}
+	// The markers must be totally ordered.
+	if len(parts) != 1 {
+		return hlc.Zero(), errors.Errorf(
+			"marker topic %s must have exactly one partition, has %d", topic, len(parts))
+	}
+	oldest, err := client.GetOffset(topic, 0, sarama.OffsetOldest)
+	if err != nil {
+		return hlc.Zero(), errors.Wrapf(err, "could not find oldest offset for %s[0]", topic)
+	}
+	newest, err := client.GetOffset(topic, 0, sarama.OffsetNewest)
+	if err != nil {
+		return hlc.Zero(), errors.Wrapf(err, "could not find newest offset for %s[0]", topic)
+	}
+	if newest <= oldest {
+		return hlc.Zero(), nil
+	}
+	from := newest - markerLookback
+	if from < oldest {
+		from = oldest
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return hlc.Zero(), errors.WithStack(err)
+	}
+	defer func() { _ = consumer.Close() }()
+	pc, err := consumer.ConsumePartition(topic, 0, from)
+	if err != nil {
+		return hlc.Zero(), errors.Wrapf(err, "could not consume %s[0]", topic)
+	}
+	defer func() { _ = pc.Close() }()
+
+	ret := hlc.Zero()
+	for {
+		select {
+		case msg, ok := <-pc.Messages():
+			if !ok {
+				return ret, nil
+			}
+			var m marker
+			if err := json.Unmarshal(msg.Value, &m); err != nil {
+				return hlc.Zero(), errors.Wrapf(err, "could not decode marker at %s[0]@%d", topic, msg.Offset)
+			}
+			if hlc.Compare(m.Resolved, ret) > 0 {
+				ret = m.Resolved
+			}
+			if msg.Offset+1 >= newest {
+				return ret, nil
+			}
+		case err := <-pc.Errors():
+			return hlc.Zero(), errors.WithStack(err)
+		case <-time.After(markerIdle):
+			return ret, nil
+		case <-ctx.Done():
+			return hlc.Zero(), ctx.Err()
+		}
+	}
+}
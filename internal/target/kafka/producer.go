@@ -0,0 +1,256 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package kafka contains a target that publishes resolved mutations to
+// Kafka topics, rather than applying them to a SQL database.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Producer publishes mutations to Kafka. Each table's mutations are
+// written to a topic with the same name, using the mutation's key as
+// the message key.
+//
+// Producer implements types.WindowedAppliers, so the Appliers that it
+// returns do not use the TargetQuerier that is passed to them. Instead,
+// mutations are accumulated into the Window that was opened by Begin.
+// Committing the Window writes its messages, followed by a commit
+// marker, within a single Kafka transaction.
+//
+// The time of the latest marker is read when the Producer is
+// constructed. Mutations at or before that time have already been
+// published and are discarded, so replaying a window after a crash
+// does not duplicate messages. This relies on windows being committed
+// in time order, which is the case for the resolved-timestamp loop
+// unless a backfill is in progress. For the same reason, a Producer
+// will only publish the tables in a single schema.
+type Producer struct {
+	cfg      *Config
+	producer sarama.SyncProducer
+
+	mu struct {
+		sync.Mutex
+		schema    ident.Schema // The schema of the tables being published.
+		window    *Window
+		watermark hlc.Time // The time of the latest committed marker.
+	}
+}
+
+var _ types.WindowedAppliers = (*Producer)(nil)
+
+// newProducer reads the latest marker from the brokers and returns a
+// Producer that will publish using the transactional producer.
+func newProducer(
+	ctx context.Context, cfg *Config, producer sarama.SyncProducer,
+) (*Producer, error) {
+	if !producer.IsTransactional() {
+		return nil, errors.New("the Kafka producer must be transactional")
+	}
+	watermark, err := readMarker(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	log.WithFields(log.Fields{
+		"resolved": watermark,
+		"topic":    cfg.MarkerTopic,
+	}).Info("resuming Kafka producer")
+
+	ret := &Producer{cfg: cfg, producer: producer}
+	ret.mu.watermark = watermark
+	return ret, nil
+}
+
+// Begin implements types.WindowedAppliers. It opens a Window to
+// receive mutations from the Appliers. Only one Window may be open at
+// a time.
+func (p *Producer) Begin(_ context.Context) (types.Window, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mu.window != nil {
+		return nil, errors.New("a Kafka window is already open")
+	}
+	p.mu.window = &Window{p: p, counts: make(map[string]int)}
+	return p.mu.window, nil
+}
+
+// Get implements types.Appliers.
+func (p *Producer) Get(_ context.Context, target ident.Table) (types.Applier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mu.schema.Empty() {
+		p.mu.schema = target.Schema()
+	} else if !ident.Equal(p.mu.schema, target.Schema()) {
+		return nil, errors.Errorf(
+			"the Kafka producer is publishing schema %s, cannot also publish %s",
+			p.mu.schema, target)
+	}
+	return &applier{p: p, topic: p.topic(target)}, nil
+}
+
+// GetBulk implements types.Appliers. Kafka has no bulk-loading path,
+// so this returns the same Applier as Get.
+func (p *Producer) GetBulk(ctx context.Context, target ident.Table) (types.Applier, error) {
+	return p.Get(ctx, target)
+}
+
+// topic returns the name of the topic for the table. Characters that
+// are not legal in topic names are replaced with underscores.
+func (p *Producer) topic(table ident.Table) string {
+	return p.cfg.TopicPrefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, table.Table().Raw())
+}
+
+// A Window accumulates the messages that will be published within a
+// single Kafka transaction.
+type Window struct {
+	p *Producer
+
+	// The fields below are guarded by the Producer's mutex.
+
+	counts   map[string]int
+	msgs     []*sarama.ProducerMessage
+	resolved hlc.Time // The latest time of any mutation in the window.
+}
+
+var _ types.Window = (*Window)(nil)
+
+// Commit publishes the messages in the Window, followed by a commit
+// marker, in a single Kafka transaction. A Window that contains no
+// messages is discarded.
+func (w *Window) Commit() error {
+	p := w.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mu.window != w {
+		return errors.New("the Kafka window is not open")
+	}
+	p.mu.window = nil
+
+	if len(w.msgs) == 0 {
+		return nil
+	}
+	start := time.Now()
+
+	data, err := json.Marshal(&marker{Counts: w.counts, Resolved: w.resolved})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	msgs := append(w.msgs, &sarama.ProducerMessage{
+		Topic: p.cfg.MarkerTopic,
+		Value: sarama.ByteEncoder(data),
+	})
+
+	if err := p.producer.BeginTxn(); err != nil {
+		return errors.Wrap(err, "could not begin Kafka transaction")
+	}
+	if err := p.producer.SendMessages(msgs); err != nil {
+		_ = p.producer.AbortTxn()
+		return errors.Wrap(err, "could not send messages to Kafka")
+	}
+	if err := p.producer.CommitTxn(); err != nil {
+		_ = p.producer.AbortTxn()
+		return errors.Wrap(err, "could not commit Kafka transaction")
+	}
+	p.mu.watermark = w.resolved
+
+	windowDurations.Observe(time.Since(start).Seconds())
+	for topic, count := range w.counts {
+		messageCount.WithLabelValues(topic).Add(float64(count))
+	}
+	log.WithFields(log.Fields{
+		"count":    len(w.msgs),
+		"duration": time.Since(start),
+		"resolved": w.resolved,
+	}).Trace("published window")
+	return nil
+}
+
+// Rollback discards the Window. It is a no-op if the Window has already
+// been committed or rolled back.
+func (w *Window) Rollback() error {
+	p := w.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.mu.window == w {
+		p.mu.window = nil
+	}
+	return nil
+}
+
+// applier adds a table's mutations to the Producer's open Window.
+type applier struct {
+	p     *Producer
+	topic string
+}
+
+var _ types.Applier = (*applier)(nil)
+
+// Apply implements types.Applier. The TargetQuerier is ignored.
+func (a *applier) Apply(_ context.Context, _ types.TargetQuerier, muts []types.Mutation) error {
+	p := a.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w := p.mu.window
+	if w == nil {
+		return errors.New("no Kafka window is open")
+	}
+
+	for _, mut := range muts {
+		if hlc.Compare(mut.Time, p.mu.watermark) <= 0 {
+			skippedCount.WithLabelValues(a.topic).Inc()
+			continue
+		}
+		env := envelope{Before: mut.Before, Key: mut.Key, Updated: mut.Time}
+		if !mut.IsDelete() {
+			env.After = mut.Data
+		}
+		value, err := json.Marshal(&env)
+		if err != nil {
+			return errors.Wrapf(err, "could not encode mutation for topic %s", a.topic)
+		}
+		w.msgs = append(w.msgs, &sarama.ProducerMessage{
+			Topic:     a.topic,
+			Key:       sarama.ByteEncoder(mut.Key),
+			Value:     sarama.ByteEncoder(value),
+			Timestamp: time.Unix(0, mut.Time.Nanos()),
+		})
+		w.counts[a.topic]++
+		if hlc.Compare(mut.Time, w.resolved) > 0 {
+			w.resolved = mut.Time
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/kafkafake"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProducer(t *testing.T) {
+	r := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	broker := kafkafake.New(t)
+	defer broker.Close()
+
+	cfg := &Config{
+		Brokers:         []string{broker.Addr()},
+		TopicPrefix:     "cdc.",
+		TransactionalID: "producer-test",
+		Version:         kafkafake.Version.String(),
+	}
+	r.NoError(cfg.Preflight())
+
+	schema := ident.MustSchema(ident.New("db"), ident.New("public"))
+	tblA := ident.NewTable(schema, ident.New("a"))
+	tblB := ident.NewTable(schema, ident.New("b$c"))
+	const topicA, topicB = "cdc.a", "cdc.b_c"
+
+	upsert := func(pk int, ts hlc.Time) types.Mutation {
+		return types.Mutation{
+			Data: json.RawMessage(fmt.Sprintf(`{"pk":%d}`, pk)),
+			Key:  json.RawMessage(fmt.Sprintf(`[%d]`, pk)),
+			Time: ts,
+		}
+	}
+	apply := func(p *Producer, tbl ident.Table, muts ...types.Mutation) {
+		t.Helper()
+		app, err := p.Get(ctx, tbl)
+		r.NoError(err)
+		r.NoError(app.Apply(ctx, nil, muts))
+	}
+	decode := func(rec kafkafake.Record) envelope {
+		t.Helper()
+		var env envelope
+		r.NoError(json.Unmarshal(rec.Value, &env))
+		return env
+	}
+	markers := func() []marker {
+		t.Helper()
+		var ret []marker
+		for _, rec := range broker.Records(cfg.MarkerTopic) {
+			var m marker
+			r.NoError(json.Unmarshal(rec.Value, &m))
+			ret = append(ret, m)
+		}
+		return ret
+	}
+
+	p, err := newProducer(ctx, cfg, broker.NewProducer(true /* transactional */))
+	r.NoError(err)
+
+	// Applying outside of a window is an error.
+	app, err := p.Get(ctx, tblA)
+	r.NoError(err)
+	r.ErrorContains(app.Apply(ctx, nil, []types.Mutation{upsert(1, hlc.New(1, 0))}),
+		"no Kafka window is open")
+
+	// Publish a window containing upserts and a delete.
+	w, err := p.Begin(ctx)
+	r.NoError(err)
+	_, err = p.Begin(ctx)
+	r.ErrorContains(err, "already open")
+	apply(p, tblA, upsert(1, hlc.New(10, 0)), upsert(2, hlc.New(10, 1)))
+	apply(p, tblB, types.Mutation{Key: json.RawMessage(`[3]`), Time: hlc.New(11, 0)})
+
+	// Nothing is visible until the window is committed.
+	r.Empty(broker.Records(topicA))
+	r.NoError(w.Commit())
+	r.NoError(w.Rollback())
+
+	recs := broker.Records(topicA)
+	r.Len(recs, 2)
+	r.Equal(`[1]`, string(recs[0].Key))
+	r.JSONEq(`{"pk":1}`, string(decode(recs[0]).After))
+	r.Equal(hlc.New(10, 0), decode(recs[0]).Updated)
+	r.Equal(`[2]`, string(recs[1].Key))
+
+	recs = broker.Records(topicB)
+	r.Len(recs, 1)
+	r.Equal(`[3]`, string(recs[0].Key))
+	r.JSONEq(`{"after":null,"key":[3],"updated":"11.0000000000"}`, string(recs[0].Value))
+
+	r.Equal([]marker{{
+		Counts:   map[string]int{topicA: 2, topicB: 1},
+		Resolved: hlc.New(11, 0),
+	}}, markers())
+
+	// A rolled-back window publishes nothing.
+	w, err = p.Begin(ctx)
+	r.NoError(err)
+	apply(p, tblA, upsert(4, hlc.New(12, 0)))
+	r.NoError(w.Rollback())
+	r.ErrorContains(w.Commit(), "not open")
+	r.Len(broker.Records(topicA), 2)
+	r.Len(markers(), 1)
+
+	// An empty window does not write a marker.
+	w, err = p.Begin(ctx)
+	r.NoError(err)
+	r.NoError(w.Commit())
+	r.Len(markers(), 1)
+
+	// Simulate a crash after the Kafka transaction was committed, but
+	// before the resolved timestamp was recorded. A new Producer will
+	// receive the same mutations again, along with newer ones.
+	p, err = newProducer(ctx, cfg, broker.NewProducer(true /* transactional */))
+	r.NoError(err)
+	w, err = p.Begin(ctx)
+	r.NoError(err)
+	apply(p, tblA,
+		upsert(1, hlc.New(10, 0)), upsert(2, hlc.New(10, 1)),
+		upsert(4, hlc.New(12, 0)))
+	apply(p, tblB, types.Mutation{Key: json.RawMessage(`[3]`), Time: hlc.New(11, 0)})
+	r.NoError(w.Commit())
+
+	recs = broker.Records(topicA)
+	r.Len(recs, 3)
+	r.Equal(`[4]`, string(recs[2].Key))
+	r.Len(broker.Records(topicB), 1)
+	r.Equal(marker{
+		Counts:   map[string]int{topicA: 1},
+		Resolved: hlc.New(12, 0),
+	}, markers()[1])
+
+	// Tables in other schemas are rejected.
+	other := ident.MustSchema(ident.New("other"), ident.New("public"))
+	_, err = p.Get(ctx, ident.NewTable(other, ident.New("a")))
+	r.ErrorContains(err, "cannot also publish")
+
+	// A non-transactional producer is rejected.
+	_, err = newProducer(ctx, cfg, broker.NewProducer(false /* transactional */))
+	r.ErrorContains(err, "must be transactional")
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+
+	"github.com/IBM/sarama"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/google/wire"
+	"github.com/pkg/errors"
+)
+
+// Set is used by Wire.
+var Set = wire.NewSet(
+	ProvideProducer,
+	ProvideSyncProducer,
+	wire.Bind(new(types.Appliers), new(*Producer)),
+)
+
+// ProvideProducer is called by Wire to construct a Producer that will
+// publish using the transactional producer.
+func ProvideProducer(
+	ctx context.Context, cfg *Config, producer sarama.SyncProducer,
+) (*Producer, error) {
+	if err := cfg.Preflight(); err != nil {
+		return nil, err
+	}
+	return newProducer(ctx, cfg, producer)
+}
+
+// ProvideSyncProducer is called by Wire to construct a transactional
+// producer that is connected to the brokers. The cancel function
+// closes the producer.
+func ProvideSyncProducer(cfg *Config) (sarama.SyncProducer, func(), error) {
+	if err := cfg.Preflight(); err != nil {
+		return nil, nil, err
+	}
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, cfg.producerConfig())
+	if err != nil {
+		dialFailureCount.Inc()
+		return nil, nil, errors.WithStack(err)
+	}
+	dialSuccessCount.Inc()
+	return producer, func() { _ = producer.Close() }, nil
+}
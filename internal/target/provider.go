@@ -20,6 +20,7 @@ package target
 
 import (
 	"github.com/cockroachdb/cdc-sink/internal/target/apply"
+	"github.com/cockroachdb/cdc-sink/internal/target/ddl"
 	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
 	"github.com/cockroachdb/cdc-sink/internal/target/schemawatch"
 	"github.com/google/wire"
@@ -29,6 +30,7 @@ import (
 // sub-packages.
 var Set = wire.NewSet(
 	apply.Set,
+	ddl.Set,
 	dlq.Set,
 	schemawatch.Set,
 )
@@ -219,6 +219,130 @@ const sqlColumnsQueryPg = `
      AND table_name = $3
 ORDER BY ordered.ordinal_position, column_name`
 
+// Retrieve the primary key columns in their index-order, then append
+// any remaining columns.
+//
+// Redshift exposes a single database per connection, so the
+// information_schema views are not qualified by catalog. The primary
+// key constraints are informational only, but they are still reported
+// and are required to apply deletes. The type of the column is
+// reconstructed from the length or precision information, since the
+// pg_catalog type names are not usable as cast targets (e.g. bpchar).
+// Identity columns are ignored, since they cannot be written to.
+const sqlColumnsQueryRedshift = `
+    WITH pk_constraints AS (
+                  SELECT table_schema,
+                         table_name,
+                         constraint_name
+                    FROM information_schema.table_constraints
+                   WHERE constraint_type = 'PRIMARY KEY'
+                 ),
+         pks AS (
+              SELECT table_schema,
+                     table_name,
+                     column_name,
+                     ordinal_position
+                FROM information_schema.key_column_usage
+                JOIN pk_constraints USING (table_schema, table_name, constraint_name)
+             ),
+         cols AS (
+                SELECT table_schema,
+                       table_name,
+                       column_name,
+                       upper(CASE
+                         WHEN character_maximum_length IS NOT NULL THEN
+                           data_type || '(' || character_maximum_length::VARCHAR || ')'
+                         WHEN data_type = 'numeric' AND numeric_precision IS NOT NULL THEN
+                           data_type || '(' || numeric_precision::VARCHAR || ',' || numeric_scale::VARCHAR || ')'
+                         WHEN data_type = 'USER-DEFINED' THEN udt_name
+                         ELSE data_type
+                       END) AS data_type,
+                       column_default,
+                       COALESCE(column_default LIKE '"identity"(%', false) AS ignored
+                  FROM information_schema.columns
+              )
+  SELECT column_name, pks.ordinal_position IS NOT NULL, data_type, column_default, ignored
+    FROM cols
+    LEFT JOIN pks USING (table_schema, table_name, column_name)
+   WHERE table_schema = $1
+     AND table_name = $2
+ORDER BY COALESCE(pks.ordinal_position, 2048), column_name`
+
+// Retrieve the sorting key columns in their key-order, then append any
+// remaining columns.
+//
+// The sorting key, rather than the primary key, identifies the rows
+// which a ReplacingMergeTree will collapse. The position of a column
+// within the key is found by splitting the key expression, so columns
+// which appear only within an expression sort to the end of the key.
+// MATERIALIZED and ALIAS columns are ignored, since they cannot be
+// inserted into.
+const sqlColumnsQueryClickHouse = `
+  SELECT c.name,
+         c.is_in_sorting_key,
+         c.type,
+         if(c.default_kind = 'DEFAULT', c.default_expression, NULL),
+         c.default_kind IN ('MATERIALIZED', 'ALIAS')
+    FROM system.columns c
+    JOIN system.tables t ON t.database = c.database AND t.name = c.table
+   WHERE c.database = $1
+     AND c.table = $2
+ORDER BY if(c.is_in_sorting_key, indexOf(splitByString(', ', t.sorting_key), c.name), 2048), c.name`
+
+// Retrieve the primary key columns in their index-order, then append
+// any remaining columns.
+//
+// The pk column of pragma_table_info is the 1-based position of the
+// column within the primary key, or zero. Generated columns are not
+// reported by pragma_table_info, so no columns need to be ignored. The
+// declared type is normalized to upper-case, but may be empty, since
+// SQLite doesn't require columns to be typed.
+const sqlColumnsQuerySQLite = `
+  SELECT name, pk > 0, upper(type), dflt_value, 0 AS ignored
+    FROM pragma_table_info(?2, ?1)
+ORDER BY CASE WHEN pk > 0 THEN pk ELSE 2048 END, name`
+
+// Retrieve the primary key columns in their index-order, then append
+// any remaining columns.
+//
+// The catalog views are qualified by the database name, since they
+// only describe the database in which they are defined. The type of the
+// column is reconstructed from the length, precision, or scale
+// information, so that it may be used as a cast target. The max_length
+// of the national character types is reported in bytes. Computed,
+// identity, and rowversion columns are ignored, since they cannot be
+// written to.
+const sqlColumnsQuerySQLServer = `
+  SELECT c.name,
+         CAST(CASE WHEN ic.key_ordinal IS NULL THEN 0 ELSE 1 END AS BIT),
+         upper(CASE
+           WHEN ty.name IN ('binary', 'char', 'varbinary', 'varchar') THEN
+             ty.name + '(' + CASE WHEN c.max_length = -1 THEN 'MAX'
+                                  ELSE CAST(c.max_length AS VARCHAR) END + ')'
+           WHEN ty.name IN ('nchar', 'nvarchar') THEN
+             ty.name + '(' + CASE WHEN c.max_length = -1 THEN 'MAX'
+                                  ELSE CAST(c.max_length / 2 AS VARCHAR) END + ')'
+           WHEN ty.name IN ('decimal', 'numeric') THEN
+             ty.name + '(' + CAST(c.precision AS VARCHAR) + ',' + CAST(c.scale AS VARCHAR) + ')'
+           WHEN ty.name IN ('datetime2', 'datetimeoffset', 'time') THEN
+             ty.name + '(' + CAST(c.scale AS VARCHAR) + ')'
+           ELSE ty.name
+         END),
+         dc.definition,
+         CAST(CASE WHEN c.is_computed = 1 OR c.is_identity = 1 OR ty.name = 'timestamp'
+                   THEN 1 ELSE 0 END AS BIT)
+    FROM %[1]s.sys.columns c
+    JOIN %[1]s.sys.tables t ON t.object_id = c.object_id
+    JOIN %[1]s.sys.schemas s ON s.schema_id = t.schema_id
+    JOIN %[1]s.sys.types ty ON ty.user_type_id = c.user_type_id
+    LEFT JOIN %[1]s.sys.default_constraints dc ON dc.object_id = c.default_object_id
+    LEFT JOIN %[1]s.sys.indexes i ON i.object_id = t.object_id AND i.is_primary_key = 1
+    LEFT JOIN %[1]s.sys.index_columns ic
+           ON ic.object_id = i.object_id AND ic.index_id = i.index_id AND ic.column_id = c.column_id
+   WHERE s.name = @p1
+     AND t.name = @p2
+ORDER BY COALESCE(ic.key_ordinal, 2048), c.name`
+
 // getColumns returns the column names for the primary key columns in
 // their index-order, followed by all other columns that should be
 // mutated.
@@ -239,6 +363,26 @@ func getColumns(
 			parts[1].Raw(),
 			parts[2].Raw(),
 		}
+	case types.ProductRedshift:
+		parts := table.Idents(make([]ident.Ident, 0, 3))
+		if len(parts) != 3 {
+			return nil, errors.Errorf("expecting three table name parts, had %d", len(parts))
+		}
+		stmt = sqlColumnsQueryRedshift
+		args = []any{
+			parts[1].Raw(),
+			parts[2].Raw(),
+		}
+	case types.ProductClickHouse:
+		parts := table.Idents(make([]ident.Ident, 0, 2))
+		if len(parts) != 2 {
+			return nil, errors.Errorf("expecting two table name parts, had %d", len(parts))
+		}
+		stmt = sqlColumnsQueryClickHouse
+		args = []any{
+			parts[0].Raw(),
+			parts[1].Raw(),
+		}
 	case types.ProductMySQL:
 		parts := table.Idents(make([]ident.Ident, 0, 2))
 		if len(parts) != 2 {
@@ -259,6 +403,26 @@ func getColumns(
 			sql.Named("owner", parts[0].Raw()),
 			sql.Named("tbl_name", parts[1].Raw()),
 		}
+	case types.ProductSQLite:
+		parts := table.Idents(make([]ident.Ident, 0, 2))
+		if len(parts) != 2 {
+			return nil, errors.Errorf("expecting two table name parts, had %d", len(parts))
+		}
+		stmt = sqlColumnsQuerySQLite
+		args = []any{
+			parts[0].Raw(),
+			parts[1].Raw(),
+		}
+	case types.ProductSQLServer:
+		parts := table.Idents(make([]ident.Ident, 0, 3))
+		if len(parts) != 3 {
+			return nil, errors.Errorf("expecting three table name parts, had %d", len(parts))
+		}
+		stmt = fmt.Sprintf(sqlColumnsQuerySQLServer, parts[0])
+		args = []any{
+			parts[1].Raw(),
+			parts[2].Raw(),
+		}
 	default:
 		return nil, errors.Errorf("unimplemented: %s", tx.Product)
 	}
@@ -329,6 +493,10 @@ func getColumns(
 				if isArray {
 					column.Type += "[]"
 				}
+			case types.ProductRedshift:
+				if defaultExpr.Valid {
+					column.DefaultExpr = defaultExpr.String
+				}
 			case types.ProductMySQL:
 				if defaultExpr.Valid {
 					// for some types, we might have precision information.
@@ -349,6 +517,10 @@ func getColumns(
 					// Oracle also likes to include some dangling whitespace.
 					column.DefaultExpr = strings.TrimSpace(defaultExpr.String)
 				}
+			case types.ProductClickHouse, types.ProductSQLite, types.ProductSQLServer:
+				if defaultExpr.Valid {
+					column.DefaultExpr = defaultExpr.String
+				}
 			default:
 				return errors.Errorf("unimplemented: %s", tx.Product)
 			}
@@ -357,6 +529,13 @@ func getColumns(
 			columns = append(columns, column)
 		}
 
+		// Redshift and ClickHouse do not synthesize a rowid column,
+		// so a table without a primary key is reported as-is. The
+		// applier will reject it if it needs to locate existing rows.
+		if tx.Product == types.ProductRedshift || tx.Product == types.ProductClickHouse {
+			return nil
+		}
+
 		// It's legal, if unusual, to create a table with no columns.
 		if len(columns) == 0 {
 			columns = []types.ColData{
@@ -300,6 +300,148 @@ GROUP BY
 ORDER BY
   depth, table_name`
 
+// depOrderTemplateRedshift places every table in the schema at the
+// same depth. Redshift accepts foreign-key declarations, but does not
+// enforce them, so there is no ordering that must be respected when
+// applying mutations.
+const depOrderTemplateRedshift = `
+SELECT table_name, 0 AS depth
+  FROM information_schema.tables
+ WHERE table_schema = $1
+   AND table_type = 'BASE TABLE'
+ORDER BY table_name`
+
+// depOrderTemplateClickHouse places every table in the database at the
+// same depth, since ClickHouse has no foreign keys. The filter on the
+// table engine matches tableTemplateClickHouse.
+const depOrderTemplateClickHouse = `
+SELECT name, 0 AS depth
+  FROM system.tables
+ WHERE database = $1
+   AND NOT is_temporary
+   AND engine NOT IN ('Dictionary', 'LiveView', 'MaterializedView', 'View', 'WindowView')
+ORDER BY name`
+
+// depOrderTemplateSQLite computes the "referential depth" of tables
+// based on foreign-key constraints. It has the same structure as
+// depOrderTemplatePg, but SQLite doesn't provide an information_schema,
+// so the tables and their foreign keys are read from the
+// pragma_table_list and pragma_foreign_key_list table-valued functions.
+// A foreign key in SQLite can only refer to a table in the same schema.
+// Table names are compared without regard to case, since the parent
+// table is reported as it was written in the REFERENCES clause.
+const depOrderTemplateSQLite = `
+WITH RECURSIVE
+  tables
+    AS (
+      SELECT name AS table_name
+        FROM pragma_table_list
+       WHERE schema = ?1 COLLATE NOCASE
+         AND type = 'table'
+         AND name NOT LIKE 'sqlite\_%' ESCAPE '\'
+    ),
+  refs
+    AS (
+      SELECT DISTINCT
+        tables.table_name AS child_table_name,
+        fk."table" AS parent_table_name
+      FROM
+        tables, pragma_foreign_key_list(tables.table_name, ?1) AS fk
+      WHERE
+        tables.table_name != fk."table" COLLATE NOCASE
+    ),
+  roots
+    AS (
+      SELECT table_name
+        FROM tables
+       WHERE table_name COLLATE NOCASE NOT IN (SELECT child_table_name FROM refs)
+    ),
+  depths
+    AS (
+      SELECT table_name, 0 AS depth FROM roots
+      UNION ALL
+        SELECT
+          refs.child_table_name,
+          depths.depth + 1
+        FROM
+          depths, refs
+        WHERE
+          refs.parent_table_name = depths.table_name COLLATE NOCASE
+    ),
+  cycle_detect
+    AS (
+      SELECT table_name, -1 AS depth FROM tables
+      UNION ALL
+        SELECT table_name, depth FROM depths
+    )
+SELECT
+  table_name, max(depth) AS depth
+FROM
+  cycle_detect
+GROUP BY
+  table_name
+ORDER BY
+  depth, table_name`
+
+// depOrderTemplateSQLServer computes the "referential depth" of tables
+// based on foreign-key constraints. It has the same structure as
+// depOrderTemplatePg, but the tables and their foreign keys are read
+// from the catalog views, which identify tables by their object ids.
+// The catalog views are qualified by the database name, since they only
+// describe the database in which they are defined. Only references
+// between tables in the requested schema are considered.
+const depOrderTemplateSQLServer = `
+WITH
+  tables
+    AS (
+      SELECT t.object_id, t.name AS table_name
+        FROM %[1]s.sys.tables AS t
+        JOIN %[1]s.sys.schemas AS s ON s.schema_id = t.schema_id
+       WHERE s.name = @p1
+    ),
+  refs
+    AS (
+      SELECT DISTINCT
+        fk.parent_object_id AS child_id,
+        fk.referenced_object_id AS parent_id
+      FROM
+        %[1]s.sys.foreign_keys AS fk
+        JOIN tables AS child ON child.object_id = fk.parent_object_id
+        JOIN tables AS parent ON parent.object_id = fk.referenced_object_id
+      WHERE
+        fk.parent_object_id != fk.referenced_object_id
+    ),
+  roots
+    AS (
+      SELECT object_id
+        FROM tables
+       WHERE object_id NOT IN (SELECT child_id FROM refs)
+    ),
+  depths
+    AS (
+      SELECT object_id, 0 AS depth FROM roots
+      UNION ALL
+        SELECT
+          refs.child_id,
+          depths.depth + 1
+        FROM
+          depths JOIN refs ON refs.parent_id = depths.object_id
+    ),
+  cycle_detect
+    AS (
+      SELECT object_id, -1 AS depth FROM tables
+      UNION ALL
+        SELECT object_id, depth FROM depths
+    )
+SELECT
+  tables.table_name, max(cycle_detect.depth) AS depth
+FROM
+  cycle_detect JOIN tables ON tables.object_id = cycle_detect.object_id
+GROUP BY
+  tables.table_name
+ORDER BY
+  depth, tables.table_name`
+
 // getDependencyOrder returns equivalency groups of tables defined
 // within the given database. The order of the slice will satisfy
 // the (acyclic) foreign-key dependency graph.
@@ -330,6 +472,22 @@ func getDependencyOrder(
 			args = []any{parts[0].Raw(), parts[1].Raw()}
 		}
 
+	case types.ProductRedshift:
+		parts := db.Idents(make([]ident.Ident, 0, 2))
+		if len(parts) != 2 {
+			return nil, errors.Errorf("expecting two schema parts, had %d", len(parts))
+		}
+		stmt = depOrderTemplateRedshift
+		args = []any{parts[1].Raw()}
+
+	case types.ProductClickHouse:
+		parts := db.Idents(make([]ident.Ident, 0, 1))
+		if len(parts) != 1 {
+			return nil, errors.Errorf("expecting one schema parts, had %d", len(parts))
+		}
+		stmt = depOrderTemplateClickHouse
+		args = []any{parts[0].Raw()}
+
 	case types.ProductMySQL:
 		parts := db.Idents(make([]ident.Ident, 0, 1))
 		if len(parts) != 1 {
@@ -341,6 +499,22 @@ func getDependencyOrder(
 	case types.ProductOracle:
 		stmt = depOrderTemplateOra
 		args = []any{sql.Named("owner", db.Raw())}
+
+	case types.ProductSQLite:
+		parts := db.Idents(make([]ident.Ident, 0, 1))
+		if len(parts) != 1 {
+			return nil, errors.Errorf("expecting one schema parts, had %d", len(parts))
+		}
+		stmt = depOrderTemplateSQLite
+		args = []any{parts[0].Raw()}
+
+	case types.ProductSQLServer:
+		parts := db.Idents(make([]ident.Ident, 0, 2))
+		if len(parts) != 2 {
+			return nil, errors.Errorf("expecting two schema parts, had %d", len(parts))
+		}
+		stmt = fmt.Sprintf(depOrderTemplateSQLServer, parts[0])
+		args = []any{parts[1].Raw()}
 	default:
 		return nil, errors.Errorf("getDependencyOrder unimplemented product: %s", tx.Product)
 	}
@@ -24,17 +24,21 @@ package schemawatch
 // Where we do need extra support is tweaking the datatypes used for
 // time when sending to Oracle.  At present, it appears that we need to
 // return time.Time as the specialized driver types in order to get
-// correct treatment of timezones.
+// correct treatment of timezones. SQL Server has similar needs for
+// its time, UUID, and binary types. ClickHouse won't cast RFC 3339
+// strings to its time types, nor objects to strings.
 
 import (
 	"encoding/hex"
 	"encoding/json"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/google/uuid"
+	mssql "github.com/microsoft/go-mssqldb"
 	"github.com/pkg/errors"
 	ora "github.com/sijms/go-ora/v2"
 )
@@ -96,6 +100,75 @@ var (
 			},
 		},
 	}
+
+	// These are evaluated in order, against the type name without
+	// any Nullable() wrapper.
+	clickHouseParseHelpers = []struct {
+		pattern *regexp.Regexp
+		parser  func(any) (any, error)
+	}{
+		{
+			// Try parsing with and without a timezone specifier.
+			pattern: regexp.MustCompile(`^DateTime(64)?(\(.*\))?$`),
+			parser: func(a any) (any, error) {
+				s, ok := a.(string)
+				if !ok {
+					return nil, errors.Errorf("expecting string, got %T", a)
+				}
+				if t, err := time.ParseInLocation(time.RFC3339Nano, s, time.UTC); err == nil {
+					return t, nil
+				}
+				return time.ParseInLocation("2006-01-02T15:04:05.999999999", s, time.UTC)
+			},
+		},
+		{
+			// JSON values are stored as text.
+			pattern: regexp.MustCompile(`^(String|JSON|Object\('json'\))$`),
+			parser: func(a any) (any, error) {
+				if s, ok := a.(string); ok {
+					return s, nil
+				}
+				return coerceJSONString(a)
+			},
+		},
+	}
+
+	// These are evaluated in order.
+	sqlServerParseHelpers = []struct {
+		pattern *regexp.Regexp
+		parser  func(any) (any, error)
+	}{
+		{
+			// The server accepts at most seven fractional digits in a
+			// string, so we send a time.Time, which the driver binds
+			// as a datetimeoffset.
+			pattern: regexp.MustCompile(`^DATETIMEOFFSET(\(\d+\))?$`),
+			parser: func(a any) (any, error) {
+				s, ok := a.(string)
+				if !ok {
+					return nil, errors.Errorf("expecting string, got %T", a)
+				}
+				return time.Parse(time.RFC3339Nano, s)
+			},
+		},
+		{
+			// The driver type reorders the bytes to match the mixed
+			// endianness that the server uses.
+			pattern: regexp.MustCompile(`^UNIQUEIDENTIFIER$`),
+			parser: func(a any) (any, error) {
+				s, ok := a.(string)
+				if !ok {
+					return nil, errors.Errorf("expecting string, got %T", a)
+				}
+				u, err := uuid.Parse(s)
+				return mssql.UniqueIdentifier(u), err
+			},
+		},
+		{
+			pattern: regexp.MustCompile(`^(VAR)?BINARY(\((\d+|MAX)\))?$`),
+			parser:  coerceHexString,
+		},
+	}
 )
 
 // coerce a bit-string into a integer
@@ -121,10 +194,24 @@ func coerceJSON(a any) (any, error) {
 	return json.Marshal(a)
 }
 
+// coerce to json text, for functions that expect a character value
+func coerceJSONString(a any) (any, error) {
+	buf, err := json.Marshal(a)
+	return string(buf), err
+}
+
 func parseHelper(product types.Product, typeName string) func(any) (any, error) {
 	switch product {
 	case types.ProductCockroachDB, types.ProductPostgreSQL:
 		// Just pass through, since we have similar representations.
+	case types.ProductRedshift:
+		// JSON_PARSE() and ST_GeomFromGeoJSON() accept only text.
+		switch typeName {
+		case "GEOMETRY", "SUPER":
+			return coerceJSONString
+		default:
+			return nil
+		}
 	case types.ProductMySQL:
 		// Coerce types to the what the mysql driver expects.
 		switch typeName {
@@ -137,12 +224,38 @@ func parseHelper(product types.Product, typeName string) func(any) (any, error)
 		default:
 			return nil
 		}
+	case types.ProductSQLite:
+		// The driver only binds scalar values. JSON values are stored
+		// as text, which SQLite's JSON functions accept.
+		switch typeName {
+		case "BLOB":
+			return coerceHexString
+		case "JSON", "JSONB":
+			return coerceJSONString
+		default:
+			return nil
+		}
 	case types.ProductOracle:
 		for _, helper := range oraParseHelpers {
 			if helper.pattern.MatchString(typeName) {
 				return helper.parser
 			}
 		}
+	case types.ProductClickHouse:
+		if inner, ok := strings.CutPrefix(typeName, "Nullable("); ok {
+			typeName = strings.TrimSuffix(inner, ")")
+		}
+		for _, helper := range clickHouseParseHelpers {
+			if helper.pattern.MatchString(typeName) {
+				return helper.parser
+			}
+		}
+	case types.ProductSQLServer:
+		for _, helper := range sqlServerParseHelpers {
+			if helper.pattern.MatchString(typeName) {
+				return helper.parser
+			}
+		}
 	}
 	return nil
 }
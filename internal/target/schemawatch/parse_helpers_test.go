@@ -23,6 +23,7 @@ import (
 
 	"github.com/cockroachdb/cdc-sink/internal/types"
 	"github.com/google/uuid"
+	mssql "github.com/microsoft/go-mssqldb"
 	ora "github.com/sijms/go-ora/v2"
 	"github.com/stretchr/testify/require"
 )
@@ -105,3 +106,178 @@ func TestMyParseHelpers(t *testing.T) {
 		})
 	}
 }
+
+func TestRedshiftParseHelpers(t *testing.T) {
+	tcs := []struct {
+		typ      string
+		input    any
+		expected string
+	}{
+		{
+			typ: "SUPER",
+			input: map[string]any{
+				"k": "a",
+				"v": 1,
+			},
+			expected: `{"k":"a","v":1}`,
+		},
+		{
+			typ: "GEOMETRY",
+			input: map[string]any{
+				"type":        "Point",
+				"coordinates": []float64{1, 2},
+			},
+			expected: `{"coordinates":[1,2],"type":"Point"}`,
+		},
+	}
+	for idx, tc := range tcs {
+		t.Run(fmt.Sprintf("%d", idx), func(t *testing.T) {
+			r := require.New(t)
+			helper := parseHelper(types.ProductRedshift, tc.typ)
+			r.NotNil(helper)
+			ret, err := helper(tc.input)
+			r.NoError(err)
+			r.Equal(tc.expected, ret)
+		})
+	}
+	require.Nil(t, parseHelper(types.ProductRedshift, "BIGINT"))
+}
+
+func TestSQLiteParseHelpers(t *testing.T) {
+	tcs := []struct {
+		typ      string
+		input    any
+		expected any
+	}{
+		{
+			typ:      "BLOB",
+			input:    `\x68656c6c6f`,
+			expected: []byte("hello"),
+		},
+		{
+			typ: "JSON",
+			input: map[string]any{
+				"k": "a",
+				"v": 1,
+			},
+			expected: `{"k":"a","v":1}`,
+		},
+		{
+			typ:      "JSONB",
+			input:    []any{"a", 1},
+			expected: `["a",1]`,
+		},
+	}
+	for idx, tc := range tcs {
+		t.Run(fmt.Sprintf("%d", idx), func(t *testing.T) {
+			r := require.New(t)
+			helper := parseHelper(types.ProductSQLite, tc.typ)
+			r.NotNil(helper)
+			ret, err := helper(tc.input)
+			r.NoError(err)
+			r.Equal(tc.expected, ret)
+		})
+	}
+	require.Nil(t, parseHelper(types.ProductSQLite, "INTEGER"))
+}
+
+func TestClickHouseParseHelpers(t *testing.T) {
+	tcs := []struct {
+		typ      string
+		input    any
+		expected any
+	}{
+		{
+			typ:      "DateTime64(6, 'UTC')",
+			input:    "2023-10-05T12:34:56.123456+02:00",
+			expected: time.Date(2023, 10, 5, 10, 34, 56, 123456000, time.UTC),
+		},
+		{
+			typ:      "Nullable(DateTime)",
+			input:    "2023-10-05T12:34:56",
+			expected: time.Date(2023, 10, 5, 12, 34, 56, 0, time.UTC),
+		},
+		{
+			typ:      "String",
+			input:    "hello",
+			expected: "hello",
+		},
+		{
+			typ: "Nullable(String)",
+			input: map[string]any{
+				"k": "a",
+				"v": 1,
+			},
+			expected: `{"k":"a","v":1}`,
+		},
+		{
+			typ:      "JSON",
+			input:    []any{"a", 1},
+			expected: `["a",1]`,
+		},
+	}
+	for idx, tc := range tcs {
+		t.Run(fmt.Sprintf("%d", idx), func(t *testing.T) {
+			r := require.New(t)
+			helper := parseHelper(types.ProductClickHouse, tc.typ)
+			r.NotNil(helper)
+			ret, err := helper(tc.input)
+			r.NoError(err)
+			if expected, ok := tc.expected.(time.Time); ok {
+				r.True(expected.Equal(ret.(time.Time)))
+				return
+			}
+			r.Equal(tc.expected, ret)
+		})
+	}
+	require.Nil(t, parseHelper(types.ProductClickHouse, "Nullable(Int64)"))
+}
+
+func TestSQLServerParseHelpers(t *testing.T) {
+	tcs := []struct {
+		typ      string
+		input    any
+		expected any
+	}{
+		{
+			typ:      "DATETIMEOFFSET(7)",
+			input:    "2023-10-05T12:34:56.123456789+02:00",
+			expected: time.Date(2023, 10, 5, 12, 34, 56, 123456789, time.FixedZone("", 2*60*60)),
+		},
+		{
+			typ:      "UNIQUEIDENTIFIER",
+			input:    "C847E52A-2612-4B98-9835-B0F0A9FCCD2F",
+			expected: mssql.UniqueIdentifier(uuid.MustParse("C847E52A-2612-4B98-9835-B0F0A9FCCD2F")),
+		},
+		{
+			typ:      "VARBINARY(16)",
+			input:    `\x68656c6c6f`,
+			expected: []byte("hello"),
+		},
+		{
+			typ:      "VARBINARY(MAX)",
+			input:    `\x68656c6c6f`,
+			expected: []byte("hello"),
+		},
+		{
+			typ:      "BINARY(5)",
+			input:    `\x68656c6c6f`,
+			expected: []byte("hello"),
+		},
+	}
+	for idx, tc := range tcs {
+		t.Run(fmt.Sprintf("%d", idx), func(t *testing.T) {
+			r := require.New(t)
+			helper := parseHelper(types.ProductSQLServer, tc.typ)
+			r.NotNil(helper)
+			ret, err := helper(tc.input)
+			r.NoError(err)
+			if expected, ok := tc.expected.(time.Time); ok {
+				r.True(expected.Equal(ret.(time.Time)))
+				return
+			}
+			r.Equal(tc.expected, ret)
+		})
+	}
+	require.Nil(t, parseHelper(types.ProductSQLServer, "NVARCHAR(256)"))
+}
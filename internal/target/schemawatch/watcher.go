@@ -218,6 +218,12 @@ const (
 SELECT table_catalog, table_schema, table_name
   FROM %s.information_schema.tables
  WHERE table_catalog = $1
+   AND table_schema ILIKE $2
+   AND table_type = 'BASE TABLE'`
+	tableTemplateRedshift = `
+SELECT table_catalog, table_schema, table_name
+  FROM information_schema.tables
+ WHERE table_catalog ILIKE $1
    AND table_schema ILIKE $2
    AND table_type = 'BASE TABLE'`
 	tableTemplateMySQL = `
@@ -227,6 +233,23 @@ WHERE upper(table_schema) = upper(?)
 AND table_type = 'BASE TABLE'`
 	tableTemplateOracle = `
 SELECT OWNER, NULL, TABLE_NAME FROM ALL_TABLES WHERE UPPER(OWNER) = UPPER(:owner)`
+	tableTemplateSQLite = `
+SELECT schema, NULL, name
+  FROM pragma_table_list
+ WHERE schema = ? COLLATE NOCASE
+   AND type = 'table'
+   AND name NOT LIKE 'sqlite\_%' ESCAPE '\'`
+	tableTemplateClickHouse = `
+SELECT database, '', name
+  FROM system.tables
+ WHERE database = $1
+   AND NOT is_temporary
+   AND engine NOT IN ('Dictionary', 'LiveView', 'MaterializedView', 'View', 'WindowView')`
+	tableTemplateSQLServer = `
+SELECT TABLE_CATALOG, TABLE_SCHEMA, TABLE_NAME
+  FROM %s.INFORMATION_SCHEMA.TABLES
+ WHERE TABLE_SCHEMA = @p1
+   AND TABLE_TYPE = 'BASE TABLE'`
 )
 
 func (w *watcher) getTables(ctx context.Context, tx *types.TargetPool) (*types.SchemaData, error) {
@@ -258,10 +281,28 @@ func (w *watcher) getTables(ctx context.Context, tx *types.TargetPool) (*types.S
 			rows, err = tx.QueryContext(ctx,
 				fmt.Sprintf(tableTemplateCrdb, ident.New(dbNameRaw)), dbNameRaw, parts[1].Raw())
 
+		case types.ProductRedshift:
+			parts := w.schema.Idents(make([]ident.Ident, 0, 2))
+			if len(parts) != 2 {
+				return errors.Errorf("expecting a schema with 2 parts, got %v", parts)
+			}
+			rows, err = tx.QueryContext(ctx, tableTemplateRedshift, parts[0].Raw(), parts[1].Raw())
+
+		case types.ProductClickHouse:
+			rows, err = tx.QueryContext(ctx, tableTemplateClickHouse, w.schema.Raw())
 		case types.ProductMySQL:
 			rows, err = tx.QueryContext(ctx, tableTemplateMySQL, w.schema.Raw())
 		case types.ProductOracle:
 			rows, err = tx.QueryContext(ctx, tableTemplateOracle, w.schema.Raw())
+		case types.ProductSQLite:
+			rows, err = tx.QueryContext(ctx, tableTemplateSQLite, w.schema.Raw())
+		case types.ProductSQLServer:
+			parts := w.schema.Idents(make([]ident.Ident, 0, 2))
+			if len(parts) != 2 {
+				return errors.Errorf("expecting a schema with 2 parts, got %v", parts)
+			}
+			rows, err = tx.QueryContext(ctx,
+				fmt.Sprintf(tableTemplateSQLServer, parts[0]), parts[1].Raw())
 
 		default:
 			return errors.Errorf("unimplemented product: %s", tx.Product)
@@ -0,0 +1,100 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package verify
+
+import (
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+)
+
+const (
+	defaultChunkSize = 1000
+	defaultMaxReport = 100
+)
+
+// Config controls the behavior of a Verifier.
+type Config struct {
+	// Only compare chunk checksums, without identifying the individual
+	// rows that differ.
+	ChecksumOnly bool
+	// The number of rows to compare at once.
+	ChunkSize int
+	// The maximum number of keys to report for each kind of difference
+	// in each table.
+	MaxReport int
+	// If set, mutations which would bring the target into agreement
+	// with the source are written to a dead-letter queue named
+	// <Repair>_<table>.
+	Repair string
+	// The schema in the source database which contains the tables to
+	// verify. Defaults to TargetSchema.
+	SourceSchema ident.Schema
+	// Restrict verification to the named tables. If empty, all tables
+	// in the target schema are verified.
+	Tables []ident.Ident
+	// The schema in the target database which contains the tables to
+	// verify.
+	TargetSchema ident.Schema
+
+	tableNames []string // Bound to a flag and copied into Tables.
+}
+
+// Bind adds configuration flags to the set.
+func (c *Config) Bind(f *pflag.FlagSet) {
+	f.BoolVar(&c.ChecksumOnly, "checksumOnly", false,
+		"only report the key ranges of chunks whose checksums differ")
+	f.IntVar(&c.ChunkSize, "chunkSize", defaultChunkSize,
+		"the number of rows to compare at once")
+	f.IntVar(&c.MaxReport, "maxReport", defaultMaxReport,
+		"the maximum number of keys to report for each kind of difference in a table")
+	f.StringVar(&c.Repair, "repairDLQ", "",
+		"write repair mutations to dead-letter queues named <repairDLQ>_<table>")
+	f.Var(ident.NewSchemaFlag(&c.SourceSchema), "sourceSchema",
+		"the SQL database schema in the source database; defaults to --targetSchema")
+	f.Var(ident.NewSchemaFlag(&c.TargetSchema), "targetSchema",
+		"the SQL database schema in the target database to verify")
+	f.StringSliceVar(&c.tableNames, "table", nil,
+		"restrict verification to the named tables")
+}
+
+// Preflight ensures that unset configuration options have sane defaults
+// and returns an error if the Config is missing any fields for which a
+// default cannot be provided.
+func (c *Config) Preflight() error {
+	if c.ChunkSize == 0 {
+		c.ChunkSize = defaultChunkSize
+	} else if c.ChunkSize < 0 {
+		return errors.New("chunkSize must be >= 0")
+	}
+	if c.MaxReport == 0 {
+		c.MaxReport = defaultMaxReport
+	} else if c.MaxReport < 0 {
+		return errors.New("maxReport must be >= 0")
+	}
+	if c.TargetSchema.Empty() {
+		return errors.New("no target schema specified")
+	}
+	if c.SourceSchema.Empty() {
+		c.SourceSchema = c.TargetSchema
+	}
+	for _, name := range c.tableNames {
+		c.Tables = append(c.Tables, ident.New(name))
+	}
+	c.tableNames = nil
+	return nil
+}
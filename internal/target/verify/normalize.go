@@ -0,0 +1,178 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package verify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+)
+
+// A kind describes how the values in a column are compared.
+type kind int
+
+const (
+	kindString  kind = iota
+	kindBool         // Accepts 0/1 and t/f representations.
+	kindChar         // Fixed-width strings, which may be space-padded.
+	kindJSON         // Compared without regard to whitespace or key order.
+	kindNumeric      // Compared without regard to trailing zeros.
+	kindTime         // Dates and timestamps are compared in UTC.
+)
+
+// kindOf returns the kind of column, based on the name of its type in
+// the target database.
+func kindOf(typ string) kind {
+	typ = strings.ToLower(typ)
+	switch {
+	case strings.Contains(typ, "bool"), typ == "tinyint(1)":
+		return kindBool
+	case strings.Contains(typ, "json"):
+		return kindJSON
+	case strings.Contains(typ, "date"), strings.Contains(typ, "timestamp"):
+		return kindTime
+	case strings.Contains(typ, "interval"), strings.Contains(typ, "point"):
+		return kindString
+	case strings.Contains(typ, "int"),
+		strings.Contains(typ, "dec"),
+		strings.Contains(typ, "double"),
+		strings.Contains(typ, "float"),
+		strings.Contains(typ, "num"),
+		strings.Contains(typ, "real"),
+		strings.Contains(typ, "serial"):
+		return kindNumeric
+	case strings.Contains(typ, "var"),
+		strings.Contains(typ, "varying"),
+		strings.Contains(typ, "text"),
+		strings.Contains(typ, "clob"):
+		return kindString
+	case strings.Contains(typ, "char"), typ == "bpchar":
+		return kindChar
+	default:
+		return kindString
+	}
+}
+
+// timeLayouts are used to parse textual date and time values.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// A normalizer converts the values read from the source and target
+// databases into a canonical form, so that equivalent values from
+// different products will compare as equal.
+type normalizer struct {
+	// Oracle does not distinguish between empty strings and NULL.
+	emptyIsNull bool
+}
+
+// newNormalizer returns a normalizer that accounts for the behaviors
+// of the given products.
+func newNormalizer(products ...types.Product) *normalizer {
+	ret := &normalizer{}
+	for _, product := range products {
+		if product == types.ProductOracle {
+			ret.emptyIsNull = true
+		}
+	}
+	return ret
+}
+
+// normalize returns the canonical representation of a value, or nil
+// if the value is NULL.
+func (n *normalizer) normalize(k kind, value any) *string {
+	var s string
+	switch t := value.(type) {
+	case nil:
+		return nil
+	case bool:
+		s = strconv.FormatBool(t)
+	case []byte:
+		s = string(t)
+	case float32:
+		s = strconv.FormatFloat(float64(t), 'g', -1, 32)
+	case float64:
+		s = strconv.FormatFloat(t, 'g', -1, 64)
+	case int64:
+		s = strconv.FormatInt(t, 10)
+	case string:
+		s = t
+	case time.Time:
+		s = t.UTC().Format(time.RFC3339Nano)
+	default:
+		s = fmt.Sprint(t)
+	}
+
+	switch k {
+	case kindBool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			s = strconv.FormatBool(b)
+		}
+	case kindChar:
+		s = strings.TrimRight(s, " ")
+	case kindJSON:
+		dec := json.NewDecoder(strings.NewReader(s))
+		dec.UseNumber()
+		var data any
+		if err := dec.Decode(&data); err == nil {
+			// Object keys are sorted when marshaled.
+			var buf bytes.Buffer
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(data); err == nil {
+				s = strings.TrimSpace(buf.String())
+			}
+		}
+	case kindNumeric:
+		var r big.Rat
+		if _, ok := r.SetString(s); ok {
+			s = r.RatString()
+		}
+	case kindTime:
+		for _, layout := range timeLayouts {
+			if ts, err := time.Parse(layout, s); err == nil {
+				s = ts.UTC().Format(time.RFC3339Nano)
+				break
+			}
+		}
+	}
+
+	if s == "" && n.emptyIsNull {
+		return nil
+	}
+	return &s
+}
+
+// jsonValue converts a value read from a database into a form that can
+// be used in a mutation.
+func jsonValue(value any) any {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package verify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKindOf(t *testing.T) {
+	tcs := []struct {
+		typ  string
+		kind kind
+	}{
+		{"BOOL", kindBool},
+		{"tinyint(1)", kindBool},
+		{"CHAR(4)", kindChar},
+		{"bpchar", kindChar},
+		{"JSONB", kindJSON},
+		{"DATE", kindTime},
+		{"TIMESTAMP WITH TIME ZONE", kindTime},
+		{"INT8", kindNumeric},
+		{"DECIMAL(10,2)", kindNumeric},
+		{"NUMBER", kindNumeric},
+		{"double precision", kindNumeric},
+		{"INTERVAL", kindString},
+		{"POINT", kindString},
+		{"STRING", kindString},
+		{"VARCHAR(32)", kindString},
+		{"VARCHAR2(32)", kindString},
+		{"character varying", kindString},
+		{"UUID", kindString},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.typ, func(t *testing.T) {
+			assert.Equal(t, tc.kind, kindOf(tc.typ))
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	ts := time.Date(2023, 1, 2, 3, 4, 5, 6000, time.FixedZone("", 3600))
+
+	tcs := []struct {
+		name     string
+		kind     kind
+		a, b     any
+		oracle   bool
+		expected *string
+	}{
+		{name: "nil", kind: kindString},
+		{name: "bool", kind: kindBool, a: true, b: []byte("1"), expected: ptr("true")},
+		{name: "char", kind: kindChar, a: "ab  ", b: []byte("ab"), expected: ptr("ab")},
+		{
+			name:     "json",
+			kind:     kindJSON,
+			a:        `{"b":1.0,"a":[1,2]}`,
+			b:        []byte(`{ "a": [1, 2], "b": 1.0 }`),
+			expected: ptr(`{"a":[1,2],"b":1.0}`),
+		},
+		{name: "int", kind: kindNumeric, a: int64(42), b: []byte("42"), expected: ptr("42")},
+		{name: "decimal", kind: kindNumeric, a: "1.50", b: float64(1.5), expected: ptr("3/2")},
+		{name: "empty", kind: kindString, a: "", b: []byte{}, expected: ptr("")},
+		{name: "empty_oracle", kind: kindString, a: "", oracle: true},
+		{
+			name:     "time",
+			kind:     kindTime,
+			a:        ts,
+			b:        "2023-01-02 02:04:05.000006",
+			expected: ptr("2023-01-02T02:04:05.000006Z"),
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			a := assert.New(t)
+			n := newNormalizer(types.ProductCockroachDB)
+			if tc.oracle {
+				n = newNormalizer(types.ProductCockroachDB, types.ProductOracle)
+			}
+			a.Equal(tc.expected, n.normalize(tc.kind, tc.a))
+			a.Equal(tc.expected, n.normalize(tc.kind, tc.b))
+		})
+	}
+}
+
+func ptr(s string) *string { return &s }
@@ -0,0 +1,149 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package verify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+)
+
+// ClickHouse targets have additional columns which record the version
+// of each row. These match the columns created by the apply package.
+var (
+	deletedColumn = ident.New("is_deleted")
+	versionColumn = ident.New("version")
+)
+
+// comparedColumns returns the columns of a target table which should
+// be compared with the source. Ignored columns are omitted, as are the
+// versioning columns of a ClickHouse table, since they do not exist in
+// the source.
+func comparedColumns(target types.Product, cols []types.ColData) []types.ColData {
+	var ret []types.ColData
+	for _, col := range cols {
+		if col.Ignored {
+			continue
+		}
+		if target == types.ProductClickHouse &&
+			(ident.Equal(col.Name, versionColumn) || ident.Equal(col.Name, deletedColumn)) {
+			continue
+		}
+		ret = append(ret, col)
+	}
+	return ret
+}
+
+// readQuery returns a query that reads rows from the table whose
+// primary keys are greater than lower and not greater than upper, in
+// primary-key order. Either bound may be nil. If limit is non-zero, at
+// most that many rows are returned. The primary-key columns must be
+// the first elements of cols.
+//
+// Not all products support row-value comparisons, so the bounds are
+// expanded into equivalent boolean expressions.
+func readQuery(
+	product types.Product,
+	table ident.Table,
+	cols []types.ColData,
+	pkCount int,
+	lower, upper []any,
+	limit int,
+) (string, []any, error) {
+	var ref func(idx int) string
+	switch product {
+	case types.ProductClickHouse, types.ProductCockroachDB, types.ProductPostgreSQL,
+		types.ProductRedshift:
+		ref = func(idx int) string { return fmt.Sprintf("$%d", idx) }
+	case types.ProductMySQL:
+		ref = func(int) string { return "?" }
+	case types.ProductOracle:
+		ref = func(idx int) string { return fmt.Sprintf(":%d", idx) }
+	case types.ProductSQLite:
+		ref = func(idx int) string { return fmt.Sprintf("?%d", idx) }
+	case types.ProductSQLServer:
+		ref = func(idx int) string { return fmt.Sprintf("@p%d", idx) }
+	default:
+		return "", nil, errors.Errorf("unimplemented product: %s", product)
+	}
+
+	names := make([]string, len(cols))
+	for idx, col := range cols {
+		names[idx] = col.Name.String()
+	}
+	pks := names[:pkCount]
+
+	var args []any
+	// next returns a reference to a new parameter.
+	next := func(value any) string {
+		args = append(args, value)
+		return ref(len(args))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "SELECT %s FROM %s", strings.Join(names, ", "), table)
+	// Collapse the versioned rows and omit those which record a
+	// deletion.
+	if product == types.ProductClickHouse {
+		sb.WriteString(" FINAL")
+	}
+
+	var where []string
+	if lower != nil {
+		where = append(where, greaterThan(pks, lower, next))
+	}
+	if upper != nil {
+		where = append(where, "NOT "+greaterThan(pks, upper, next))
+	}
+	if len(where) > 0 {
+		fmt.Fprintf(&sb, " WHERE %s", strings.Join(where, " AND "))
+	}
+	fmt.Fprintf(&sb, " ORDER BY %s", strings.Join(pks, ", "))
+
+	if limit > 0 {
+		switch product {
+		case types.ProductOracle:
+			fmt.Fprintf(&sb, " FETCH FIRST %d ROWS ONLY", limit)
+		case types.ProductSQLServer:
+			// SQL Server requires an OFFSET clause before a FETCH.
+			fmt.Fprintf(&sb, " OFFSET 0 ROWS FETCH NEXT %d ROWS ONLY", limit)
+		default:
+			fmt.Fprintf(&sb, " LIMIT %d", limit)
+		}
+	}
+	return sb.String(), args, nil
+}
+
+// greaterThan returns a boolean expression that is equivalent to
+// (a, b, c) > (x, y, z), i.e.
+//
+//	(a > x OR (a = x AND b > y) OR (a = x AND b = y AND c > z))
+func greaterThan(names []string, values []any, next func(any) string) string {
+	terms := make([]string, len(names))
+	for i := range names {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("%s = %s", names[j], next(values[j])))
+		}
+		parts = append(parts, fmt.Sprintf("%s > %s", names[i], next(values[i])))
+		terms[i] = "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package verify
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadQuery(t *testing.T) {
+	tbl := ident.NewTable(ident.MustSchema(ident.New("db"), ident.New("public")), ident.New("tbl"))
+	cols := []types.ColData{
+		{Name: ident.New("a"), Primary: true},
+		{Name: ident.New("b"), Primary: true},
+		{Name: ident.New("v")},
+	}
+
+	tcs := []struct {
+		name         string
+		product      types.Product
+		lower, upper []any
+		limit        int
+		expected     string
+		args         []any
+	}{
+		{
+			name:     "unbounded",
+			product:  types.ProductCockroachDB,
+			expected: `SELECT "a", "b", "v" FROM "db"."public"."tbl" ORDER BY "a", "b"`,
+		},
+		{
+			name:    "lower",
+			product: types.ProductPostgreSQL,
+			lower:   []any{1, 2},
+			limit:   10,
+			expected: `SELECT "a", "b", "v" FROM "db"."public"."tbl" ` +
+				`WHERE (("a" > $1) OR ("a" = $2 AND "b" > $3)) ` +
+				`ORDER BY "a", "b" LIMIT 10`,
+			args: []any{1, 1, 2},
+		},
+		{
+			name:    "both_mysql",
+			product: types.ProductMySQL,
+			lower:   []any{1, 2},
+			upper:   []any{3, 4},
+			expected: `SELECT "a", "b", "v" FROM "db"."public"."tbl" ` +
+				`WHERE (("a" > ?) OR ("a" = ? AND "b" > ?)) ` +
+				`AND NOT (("a" > ?) OR ("a" = ? AND "b" > ?)) ` +
+				`ORDER BY "a", "b"`,
+			args: []any{1, 1, 2, 3, 3, 4},
+		},
+		{
+			name:    "oracle",
+			product: types.ProductOracle,
+			upper:   []any{3, 4},
+			limit:   10,
+			expected: `SELECT "a", "b", "v" FROM "db"."public"."tbl" ` +
+				`WHERE NOT (("a" > :1) OR ("a" = :2 AND "b" > :3)) ` +
+				`ORDER BY "a", "b" FETCH FIRST 10 ROWS ONLY`,
+			args: []any{3, 3, 4},
+		},
+		{
+			name:    "sqlite",
+			product: types.ProductSQLite,
+			lower:   []any{1, 2},
+			limit:   10,
+			expected: `SELECT "a", "b", "v" FROM "db"."public"."tbl" ` +
+				`WHERE (("a" > ?1) OR ("a" = ?2 AND "b" > ?3)) ` +
+				`ORDER BY "a", "b" LIMIT 10`,
+			args: []any{1, 1, 2},
+		},
+		{
+			name:    "clickhouse",
+			product: types.ProductClickHouse,
+			lower:   []any{1, 2},
+			limit:   10,
+			expected: `SELECT "a", "b", "v" FROM "db"."public"."tbl" FINAL ` +
+				`WHERE (("a" > $1) OR ("a" = $2 AND "b" > $3)) ` +
+				`ORDER BY "a", "b" LIMIT 10`,
+			args: []any{1, 1, 2},
+		},
+		{
+			name:    "sqlserver",
+			product: types.ProductSQLServer,
+			lower:   []any{1, 2},
+			upper:   []any{3, 4},
+			limit:   10,
+			expected: `SELECT "a", "b", "v" FROM "db"."public"."tbl" ` +
+				`WHERE (("a" > @p1) OR ("a" = @p2 AND "b" > @p3)) ` +
+				`AND NOT (("a" > @p4) OR ("a" = @p5 AND "b" > @p6)) ` +
+				`ORDER BY "a", "b" OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY`,
+			args: []any{1, 1, 2, 3, 3, 4},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			q, args, err := readQuery(tc.product, tbl, cols, 2, tc.lower, tc.upper, tc.limit)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, q)
+			assert.Equal(t, tc.args, args)
+		})
+	}
+}
+
+func TestComparedColumns(t *testing.T) {
+	cols := []types.ColData{
+		{Name: ident.New("pk"), Primary: true},
+		{Name: ident.New("v")},
+		{Name: ident.New("ignored"), Ignored: true},
+		{Name: ident.New("version")},
+		{Name: ident.New("is_deleted")},
+	}
+	names := func(cols []types.ColData) []string {
+		ret := make([]string, len(cols))
+		for idx, col := range cols {
+			ret[idx] = col.Name.Raw()
+		}
+		return ret
+	}
+
+	// The versioning columns only exist in ClickHouse targets.
+	assert.Equal(t, []string{"pk", "v", "version", "is_deleted"},
+		names(comparedColumns(types.ProductCockroachDB, cols)))
+	assert.Equal(t, []string{"pk", "v"},
+		names(comparedColumns(types.ProductClickHouse, cols)))
+
+	// The source must not be asked for the versioning columns.
+	q, _, err := readQuery(types.ProductPostgreSQL,
+		ident.NewTable(ident.MustSchema(ident.New("db"), ident.New("public")), ident.New("tbl")),
+		comparedColumns(types.ProductClickHouse, cols), 1, nil, nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT "pk", "v" FROM "db"."public"."tbl" ORDER BY "pk"`, q)
+}
@@ -0,0 +1,475 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package verify compares the rows in a source database with those in
+// a target database to determine whether the two are in sync.
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/hlc"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// ChunkRange describes a range of primary keys. The keys are encoded
+// as JSON arrays. An empty From is before the first key in the table
+// and an empty To is after the last key in the table.
+type ChunkRange struct {
+	From string // Exclusive
+	To   string // Inclusive
+}
+
+func (r ChunkRange) String() string {
+	from, to := r.From, r.To
+	if from == "" {
+		from = "start"
+	}
+	if to == "" {
+		to = "end"
+	}
+	return fmt.Sprintf("(%s, %s]", from, to)
+}
+
+// A Report describes the differences found in a single table.
+type Report struct {
+	Table            ident.Table  // The table in the target database.
+	SourceRows       int64        // The number of rows in the source.
+	TargetRows       int64        // The number of rows in the target.
+	Chunks           int          // The number of chunks compared.
+	MismatchedChunks []ChunkRange // The chunks whose checksums differ.
+
+	// Keys that are present in the source, but not the target. At
+	// most Config.MaxReport keys are retained; MissingCount is the
+	// total number.
+	Missing      []string
+	MissingCount int
+	// Keys that are present in the target, but not the source.
+	Extra      []string
+	ExtraCount int
+	// Keys that are present in both, but whose rows differ.
+	Mismatched      []string
+	MismatchedCount int
+
+	// The number of repair mutations that were enqueued.
+	Repaired int
+}
+
+// OK returns true if no differences were found.
+func (r *Report) OK() bool {
+	return len(r.MismatchedChunks) == 0 &&
+		r.MissingCount == 0 &&
+		r.ExtraCount == 0 &&
+		r.MismatchedCount == 0
+}
+
+// A Verifier compares the tables in a source and target database.
+type Verifier struct {
+	cfg      *Config
+	dlqCfg   *dlq.Config
+	dlqs     types.DLQs
+	source   *types.SourcePool
+	target   *types.TargetPool
+	watchers types.Watchers
+}
+
+// Verify compares each table in the target schema, or those named in
+// Config.Tables, with the source database.
+func (v *Verifier) Verify(ctx context.Context) ([]*Report, error) {
+	watcher, err := v.watchers.Get(ctx, v.cfg.TargetSchema)
+	if err != nil {
+		return nil, err
+	}
+	schema := watcher.Get()
+
+	var tables []ident.Table
+	if len(v.cfg.Tables) == 0 {
+		if err := schema.Columns.Range(func(tbl ident.Table, _ []types.ColData) error {
+			if !ident.Equal(tbl.Table(), v.dlqCfg.TableName) {
+				tables = append(tables, tbl)
+			}
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		sort.Slice(tables, func(i, j int) bool {
+			return tables[i].Raw() < tables[j].Raw()
+		})
+	} else {
+		for _, name := range v.cfg.Tables {
+			tables = append(tables, ident.NewTable(v.cfg.TargetSchema, name))
+		}
+	}
+
+	reports := make([]*Report, 0, len(tables))
+	for _, tbl := range tables {
+		cols, ok := schema.Columns.Get(tbl)
+		if !ok {
+			return nil, errors.Errorf("unknown table %s", tbl)
+		}
+		report, err := v.verifyTable(ctx, tbl, cols)
+		if err != nil {
+			return nil, errors.Wrap(err, tbl.String())
+		}
+		log.WithFields(log.Fields{
+			"chunks":     report.Chunks,
+			"extra":      report.ExtraCount,
+			"mismatched": report.MismatchedCount,
+			"missing":    report.MissingCount,
+			"table":      tbl,
+		}).Info("verified table")
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// A row that has been read from either database.
+type row struct {
+	hash   string // A hash of the normalized values.
+	key    string // The normalized primary key, as a JSON array.
+	values []any  // The values as read from the database.
+}
+
+// tableState accumulates differences across chunks. Rows are retained
+// in the missing and extra maps until the end of the table, since the
+// source and target may disagree on the ordering of keys.
+type tableState struct {
+	cols      []types.ColData
+	extra     map[string]*row
+	kinds     []kind
+	maxReport int
+	missing   map[string]*row
+	norm      *normalizer
+	pkCount   int
+	repair    types.DLQ
+	report    *Report
+}
+
+func (v *Verifier) verifyTable(
+	ctx context.Context, tbl ident.Table, allCols []types.ColData,
+) (*Report, error) {
+	st := &tableState{
+		extra:     make(map[string]*row),
+		maxReport: v.cfg.MaxReport,
+		missing:   make(map[string]*row),
+		norm:      newNormalizer(v.source.Product, v.target.Product),
+		report:    &Report{Table: tbl},
+	}
+	for _, col := range comparedColumns(v.target.Product, allCols) {
+		st.cols = append(st.cols, col)
+		st.kinds = append(st.kinds, kindOf(col.Type))
+		if col.Primary {
+			st.pkCount++
+		}
+	}
+	if st.pkCount == 0 {
+		return nil, errors.New("table has no primary key")
+	}
+	if v.cfg.Repair != "" {
+		name := fmt.Sprintf("%s_%s", v.cfg.Repair, tbl.Table().Raw())
+		var err error
+		st.repair, err = v.dlqs.Get(ctx, v.cfg.TargetSchema, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sourceTable := ident.NewTable(v.cfg.SourceSchema, tbl.Table())
+	var lower []any
+	for {
+		// The upper bound of a chunk is determined by the source.
+		src, err := st.read(ctx, v.source.DB, v.source.Product, sourceTable, lower, nil, v.cfg.ChunkSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "source")
+		}
+		var upper []any
+		last := len(src) < v.cfg.ChunkSize
+		if !last {
+			upper = src[len(src)-1].values[:st.pkCount]
+		}
+		tgt, err := st.read(ctx, v.target.DB, v.target.Product, tbl, lower, upper, 0)
+		if err != nil {
+			return nil, errors.Wrap(err, "target")
+		}
+
+		st.report.Chunks++
+		st.report.SourceRows += int64(len(src))
+		st.report.TargetRows += int64(len(tgt))
+		if len(src) != len(tgt) || checksum(src) != checksum(tgt) {
+			st.report.MismatchedChunks = append(st.report.MismatchedChunks, ChunkRange{
+				From: keyJSON(lower),
+				To:   keyJSON(upper),
+			})
+			if !v.cfg.ChecksumOnly {
+				if err := st.diff(ctx, v.target, src, tgt); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if last {
+			break
+		}
+		lower = upper
+	}
+
+	if err := st.finish(ctx, v.target); err != nil {
+		return nil, err
+	}
+	return st.report, nil
+}
+
+// read executes a query built by readQuery and normalizes the results.
+func (st *tableState) read(
+	ctx context.Context,
+	db *sql.DB,
+	product types.Product,
+	tbl ident.Table,
+	lower, upper []any,
+	limit int,
+) ([]*row, error) {
+	q, args, err := readQuery(product, tbl, st.cols, st.pkCount, lower, upper, limit)
+	if err != nil {
+		return nil, err
+	}
+	// Byte slices may be interpreted as binary data by some drivers.
+	for idx, arg := range args {
+		if b, ok := arg.([]byte); ok {
+			args[idx] = string(b)
+		}
+	}
+	rows, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, q)
+	}
+	defer rows.Close()
+
+	var ret []*row
+	for rows.Next() {
+		values := make([]any, len(st.cols))
+		dest := make([]any, len(values))
+		for idx := range values {
+			dest[idx] = &values[idx]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		normalized := make([]*string, len(values))
+		for idx, value := range values {
+			normalized[idx] = st.norm.normalize(st.kinds[idx], value)
+		}
+		key, err := json.Marshal(normalized[:st.pkCount])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		all, err := json.Marshal(normalized)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		hash := sha256.Sum256(all)
+		ret = append(ret, &row{
+			hash:   hex.EncodeToString(hash[:]),
+			key:    string(key),
+			values: values,
+		})
+	}
+	return ret, errors.WithStack(rows.Err())
+}
+
+// diff identifies the individual rows that differ within a chunk.
+func (st *tableState) diff(
+	ctx context.Context, tx types.TargetQuerier, src, tgt []*row,
+) error {
+	srcByKey := make(map[string]*row, len(src))
+	for _, r := range src {
+		srcByKey[r.key] = r
+	}
+	for _, t := range tgt {
+		s, ok := srcByKey[t.key]
+		if ok {
+			delete(srcByKey, t.key)
+		} else if s, ok = st.missing[t.key]; ok {
+			delete(st.missing, t.key)
+		} else {
+			st.extra[t.key] = t
+			continue
+		}
+		if err := st.compare(ctx, tx, s, t); err != nil {
+			return err
+		}
+	}
+	for key, s := range srcByKey {
+		if t, ok := st.extra[key]; ok {
+			delete(st.extra, key)
+			if err := st.compare(ctx, tx, s, t); err != nil {
+				return err
+			}
+			continue
+		}
+		st.missing[key] = s
+	}
+	return nil
+}
+
+// compare records a mismatch if the rows differ.
+func (st *tableState) compare(ctx context.Context, tx types.TargetQuerier, src, tgt *row) error {
+	if src.hash == tgt.hash {
+		return nil
+	}
+	st.report.MismatchedCount++
+	st.report.Mismatched = st.appendKey(st.report.Mismatched, src)
+	return st.enqueueUpsert(ctx, tx, src)
+}
+
+// finish records the rows that were never matched.
+func (st *tableState) finish(ctx context.Context, tx types.TargetQuerier) error {
+	for _, key := range sortedKeys(st.missing) {
+		r := st.missing[key]
+		st.report.MissingCount++
+		st.report.Missing = st.appendKey(st.report.Missing, r)
+		if err := st.enqueueUpsert(ctx, tx, r); err != nil {
+			return err
+		}
+	}
+	for _, key := range sortedKeys(st.extra) {
+		r := st.extra[key]
+		st.report.ExtraCount++
+		st.report.Extra = st.appendKey(st.report.Extra, r)
+		if err := st.enqueueDelete(ctx, tx, r); err != nil {
+			return err
+		}
+	}
+	sort.Strings(st.report.Mismatched)
+	return nil
+}
+
+// appendKey adds the key of the row to the slice, unless the maximum
+// number of keys has already been reported.
+func (st *tableState) appendKey(keys []string, r *row) []string {
+	if len(keys) >= st.maxReport {
+		return keys
+	}
+	return append(keys, keyJSON(r.values[:st.pkCount]))
+}
+
+// enqueueDelete enqueues a deletion of the row. The row is recorded as
+// the before data, from which the DLQ replay tool reconstructs the key.
+func (st *tableState) enqueueDelete(ctx context.Context, tx types.TargetQuerier, r *row) error {
+	if st.repair == nil {
+		return nil
+	}
+	mut, err := st.mutation(r)
+	if err != nil {
+		return err
+	}
+	mut.Before, mut.Data = mut.Data, nil
+	return st.enqueue(ctx, tx, mut)
+}
+
+// enqueueUpsert enqueues an upsert of the row.
+func (st *tableState) enqueueUpsert(ctx context.Context, tx types.TargetQuerier, r *row) error {
+	if st.repair == nil {
+		return nil
+	}
+	mut, err := st.mutation(r)
+	if err != nil {
+		return err
+	}
+	return st.enqueue(ctx, tx, mut)
+}
+
+// mutation returns a mutation whose key and data are populated from
+// the row.
+func (st *tableState) mutation(r *row) (types.Mutation, error) {
+	key, err := json.Marshal(jsonValues(r.values[:st.pkCount]))
+	if err != nil {
+		return types.Mutation{}, errors.WithStack(err)
+	}
+	data := make(map[string]any, len(st.cols))
+	for idx, col := range st.cols {
+		data[col.Name.Raw()] = jsonValue(r.values[idx])
+	}
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return types.Mutation{}, errors.WithStack(err)
+	}
+	return types.Mutation{Data: dataBytes, Key: key}, nil
+}
+
+func (st *tableState) enqueue(ctx context.Context, tx types.TargetQuerier, mut types.Mutation) error {
+	mut.Time = hlc.New(time.Now().UnixNano(), 0)
+	if err := st.repair.Enqueue(ctx, tx, mut); err != nil {
+		return err
+	}
+	st.report.Repaired++
+	return nil
+}
+
+// checksum returns a hash of the rows that does not depend on the
+// order in which they were read.
+func checksum(rows []*row) string {
+	hashes := make([]string, len(rows))
+	for idx, r := range rows {
+		hashes[idx] = r.hash
+	}
+	sort.Strings(hashes)
+	sum := sha256.Sum256([]byte(strings.Join(hashes, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// jsonValues applies jsonValue to each element of the slice.
+func jsonValues(values []any) []any {
+	ret := make([]any, len(values))
+	for idx, value := range values {
+		ret[idx] = jsonValue(value)
+	}
+	return ret
+}
+
+// keyJSON returns a human-readable representation of a key, or an
+// empty string if the key is nil.
+func keyJSON(key []any) string {
+	if key == nil {
+		return ""
+	}
+	buf, err := json.Marshal(jsonValues(key))
+	if err != nil {
+		return fmt.Sprint(key)
+	}
+	return string(buf)
+}
+
+func sortedKeys(m map[string]*row) []string {
+	ret := make([]string, 0, len(m))
+	for key := range m {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,131 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package verify_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/cdc-sink/internal/sinktest/all"
+	"github.com/cockroachdb/cdc-sink/internal/target/dlq"
+	"github.com/cockroachdb/cdc-sink/internal/target/verify"
+	"github.com/cockroachdb/cdc-sink/internal/util/ident"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVerify creates a table in the source and target databases with
+// a known set of differences. It checks that the differences are
+// reported and that replaying the repair queue brings the target into
+// agreement with the source.
+func TestVerify(t *testing.T) {
+	const rowCount = 100
+	r := require.New(t)
+
+	fixture, cancel, err := all.NewFixture()
+	r.NoError(err)
+	defer cancel()
+
+	ctx := fixture.Context
+	sourceSchema := fixture.SourceSchema.Schema()
+	targetSchema := fixture.TargetSchema.Schema()
+
+	// The DLQ table should be ignored by the verifier.
+	_, err = fixture.CreateDLQTable(ctx)
+	r.NoError(err)
+
+	const schema = "CREATE TABLE %s (pk INT PRIMARY KEY, val VARCHAR(32))"
+	tgt, err := fixture.CreateTargetTable(ctx, schema)
+	r.NoError(err)
+	src := ident.NewTable(sourceSchema, tgt.Name().Table())
+	_, err = fixture.SourcePool.ExecContext(ctx, fmt.Sprintf(schema, src))
+	r.NoError(err)
+
+	for i := 0; i < rowCount; i++ {
+		_, err := fixture.SourcePool.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO %s (pk, val) VALUES (%d, 'v%d')", src, i, i))
+		r.NoError(err)
+
+		val := fmt.Sprintf("v%d", i)
+		switch i {
+		case 10:
+			continue // Missing from the target.
+		case 20:
+			val = "mismatched"
+		}
+		_, err = fixture.TargetPool.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO %s (pk, val) VALUES (%d, '%s')", tgt, i, val))
+		r.NoError(err)
+	}
+	_, err = fixture.TargetPool.ExecContext(ctx, fmt.Sprintf(
+		"INSERT INTO %s (pk, val) VALUES (%d, 'extra')", tgt, rowCount))
+	r.NoError(err)
+
+	newVerifier := func(cfg *verify.Config) *verify.Verifier {
+		cfg.SourceSchema = sourceSchema
+		cfg.TargetSchema = targetSchema
+		v, err := verify.ProvideVerifier(cfg, fixture.DLQConfig, fixture.DLQs,
+			fixture.SourcePool, fixture.TargetPool, fixture.Watchers)
+		r.NoError(err)
+		return v
+	}
+
+	// Checksums alone should locate, but not identify, the differences.
+	reports, err := newVerifier(&verify.Config{
+		ChecksumOnly: true,
+		ChunkSize:    7,
+	}).Verify(ctx)
+	r.NoError(err)
+	r.Len(reports, 1)
+	report := reports[0]
+	r.Equal(tgt.Name(), report.Table)
+	r.False(report.OK())
+	r.Equal(int64(rowCount), report.SourceRows)
+	r.Equal(int64(rowCount), report.TargetRows)
+	r.Equal(rowCount/7+1, report.Chunks)
+	// The chunks containing 10, 20 and the extra row.
+	r.Len(report.MismatchedChunks, 3)
+	r.Zero(report.MissingCount)
+
+	reports, err = newVerifier(&verify.Config{
+		ChunkSize: 7,
+		Repair:    "repair",
+	}).Verify(ctx)
+	r.NoError(err)
+	r.Len(reports, 1)
+	report = reports[0]
+	r.Equal(1, report.MissingCount)
+	r.Equal(1, report.ExtraCount)
+	r.Equal(1, report.MismatchedCount)
+	r.Len(report.Missing, 1)
+	r.Len(report.Extra, 1)
+	r.Len(report.Mismatched, 1)
+	r.Equal(3, report.Repaired)
+
+	admin := dlq.ProvideAdmin(fixture.DLQConfig, fixture.TargetPool, fixture.Appliers, fixture.Watchers)
+	res, err := admin.Replay(ctx, &dlq.ReplayOptions{
+		Name:   fmt.Sprintf("repair_%s", tgt.Name().Table().Raw()),
+		Target: tgt.Name(),
+	})
+	r.NoError(err)
+	r.Equal(&dlq.ReplayResult{Applied: 3}, res)
+
+	reports, err = newVerifier(&verify.Config{}).Verify(ctx)
+	r.NoError(err)
+	r.Len(reports, 1)
+	r.True(reports[0].OK())
+	r.Equal(int64(rowCount), reports[0].TargetRows)
+}
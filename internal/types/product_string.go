@@ -13,11 +13,15 @@ func _() {
 	_ = x[ProductMySQL-2]
 	_ = x[ProductOracle-3]
 	_ = x[ProductPostgreSQL-4]
+	_ = x[ProductRedshift-5]
+	_ = x[ProductSQLite-6]
+	_ = x[ProductSQLServer-7]
+	_ = x[ProductClickHouse-8]
 }
 
-const _Product_name = "UnknownCockroachDBMySQLOraclePostgreSQL"
+const _Product_name = "UnknownCockroachDBMySQLOraclePostgreSQLRedshiftSQLiteSQLServerClickHouse"
 
-var _Product_index = [...]uint8{0, 7, 18, 23, 29, 39}
+var _Product_index = [...]uint8{0, 7, 18, 23, 29, 39, 47, 53, 62, 72}
 
 func (i Product) String() string {
 	if i < 0 || i >= Product(len(_Product_index)-1) {
@@ -71,6 +71,46 @@ func TestProductExpand(t *testing.T) {
 			product: ProductMySQL,
 			err:     "expecting exactly one schema part",
 		},
+		{
+			input:    ident.MustSchema(ident.New("foo")),
+			product:  ProductRedshift,
+			expected: ident.MustSchema(ident.New("foo"), ident.Public),
+		},
+		{
+			input:    ident.MustSchema(ident.New("foo"), ident.New("bar")),
+			product:  ProductRedshift,
+			expected: ident.MustSchema(ident.New("foo"), ident.New("bar")),
+		},
+		{
+			input:    ident.MustSchema(ident.New("main")),
+			product:  ProductSQLite,
+			expected: ident.MustSchema(ident.New("main")),
+		},
+		{
+			input:   ident.MustSchema(ident.New("main"), ident.New("bar")),
+			product: ProductSQLite,
+			err:     "expecting exactly one schema part",
+		},
+		{
+			input:    ident.MustSchema(ident.New("foo")),
+			product:  ProductSQLServer,
+			expected: ident.MustSchema(ident.New("foo"), ident.New("dbo")),
+		},
+		{
+			input:    ident.MustSchema(ident.New("foo"), ident.New("bar")),
+			product:  ProductSQLServer,
+			expected: ident.MustSchema(ident.New("foo"), ident.New("bar")),
+		},
+		{
+			input:    ident.MustSchema(ident.New("foo")),
+			product:  ProductClickHouse,
+			expected: ident.MustSchema(ident.New("foo")),
+		},
+		{
+			input:   ident.MustSchema(ident.New("foo"), ident.New("bar")),
+			product: ProductClickHouse,
+			err:     "expecting exactly one schema part",
+		},
 	}
 
 	for idx, tc := range tcs {
@@ -45,6 +45,11 @@ type Applier interface {
 // Appliers is a factory for Applier instances.
 type Appliers interface {
 	Get(ctx context.Context, target ident.Table) (Applier, error)
+	// GetBulk returns an Applier that is optimized for large batches
+	// of mutations, such as those generated by a backfill. It behaves
+	// like the Applier returned from Get if the target does not
+	// support bulk loading.
+	GetBulk(ctx context.Context, target ident.Table) (Applier, error)
 }
 
 // An Authenticator determines if an operation on some schema should be
@@ -62,6 +67,14 @@ type Deadlines = *ident.Map[time.Duration]
 // to the target for offline reconciliation.
 type DLQ interface {
 	Enqueue(ctx context.Context, tx TargetQuerier, mut Mutation) error
+	// EnqueueError is like Enqueue, but also records the error that
+	// prevented the mutation from being applied.
+	EnqueueError(ctx context.Context, tx TargetQuerier, mut Mutation, cause error) error
+	// EnqueueOnce is like Enqueue, but does nothing if the queue
+	// already contains an entry with the same time and data. This
+	// allows a source to re-record an entry when a transaction is
+	// replayed.
+	EnqueueOnce(ctx context.Context, tx TargetQuerier, mut Mutation) error
 }
 
 // DLQs provides named dead-letter queues in the target schema.
@@ -266,8 +279,16 @@ const (
 	ProductMySQL
 	ProductOracle
 	ProductPostgreSQL
+	ProductRedshift
+	ProductSQLite
+	ProductSQLServer
+	ProductClickHouse
 )
 
+// sqlServerDefaultSchema is the schema that SQL Server creates in
+// every database.
+var sqlServerDefaultSchema = ident.New("dbo")
+
 // ExpandSchema validates a Schema against the expected form used by the
 // database. This method may return an alternate value to add missing
 // default namespace elements.
@@ -280,7 +301,7 @@ func (p Product) ExpandSchema(s ident.Schema) (ident.Schema, error) {
 	numParts := len(parts)
 
 	switch p {
-	case ProductCockroachDB, ProductPostgreSQL:
+	case ProductCockroachDB, ProductPostgreSQL, ProductRedshift:
 		switch numParts {
 		case 1:
 			// Add missing "public" identifier.
@@ -292,7 +313,19 @@ func (p Product) ExpandSchema(s ident.Schema) (ident.Schema, error) {
 			return ident.Schema{}, errors.Errorf("unexpected number of schema parts: %d", numParts)
 		}
 
-	case ProductMySQL, ProductOracle:
+	case ProductSQLServer:
+		switch numParts {
+		case 1:
+			// Add missing "dbo" identifier.
+			return ident.NewSchema(parts[0], sqlServerDefaultSchema)
+		case 2:
+			// Fully-specified, return as-is.
+			return s, nil
+		default:
+			return ident.Schema{}, errors.Errorf("unexpected number of schema parts: %d", numParts)
+		}
+
+	case ProductClickHouse, ProductMySQL, ProductOracle, ProductSQLite:
 		if numParts != 1 {
 			return ident.Schema{}, errors.Errorf("expecting exactly one schema part, had %d", numParts)
 		}
@@ -436,6 +469,25 @@ type Watchers interface {
 	Get(ctx context.Context, db ident.Schema) (Watcher, error)
 }
 
+// A Window is returned by WindowedAppliers.
+type Window interface {
+	// Commit publishes the mutations in the Window.
+	Commit() error
+	// Rollback discards the Window. It is a no-op if the Window has
+	// already been committed or rolled back.
+	Rollback() error
+}
+
+// WindowedAppliers is implemented by Appliers that do not write to the
+// target database. Instead, the mutations are accumulated into a
+// Window, which must be opened before the mutations are applied and
+// committed afterwards.
+type WindowedAppliers interface {
+	Appliers
+	// Begin opens a Window to receive mutations.
+	Begin(ctx context.Context) (Window, error)
+}
+
 type noCopy struct{}
 
 func (*noCopy) Lock()   {}
@@ -44,8 +44,10 @@ type (
 type Config struct {
 	// NB: Update TestCopyEquals if adding new fields.
 
+	BulkLoad    bool                      // Stage data in files and load with COPY.
 	CASColumns  TargetColumns             // The columns for compare-and-set operations.
 	Deadlines   *ident.Map[time.Duration] // Deadline-based operation.
+	ErrorDLQ    string                    // Isolate mutations which cannot be applied into a DLQ.
 	Exprs       *ident.Map[string]        // Synthetic or replacement SQL expressions.
 	Extras      TargetColumn              // JSONB column to store unmapped values in.
 	Ignore      *ident.Map[bool]          // Source column names to ignore.
@@ -67,8 +69,10 @@ func NewConfig() *Config {
 func (t *Config) Copy() *Config {
 	ret := NewConfig()
 
+	ret.BulkLoad = t.BulkLoad
 	ret.CASColumns = append(ret.CASColumns, t.CASColumns...)
 	t.Deadlines.CopyInto(ret.Deadlines)
+	ret.ErrorDLQ = t.ErrorDLQ
 	t.Exprs.CopyInto(ret.Exprs)
 	ret.Extras = t.Extras
 	t.Ignore.CopyInto(ret.Ignore)
@@ -86,8 +90,10 @@ func (t *Config) Copy() *Config {
 func (t *Config) Equal(o *Config) bool {
 	return t == o || // Identity or nil-nil.
 		(t != nil) && (o != nil) &&
+			t.BulkLoad == o.BulkLoad &&
 			t.CASColumns.Equal(o.CASColumns) &&
 			t.Deadlines.Equal(o.Deadlines, cmap.Comparator[time.Duration]()) &&
+			t.ErrorDLQ == o.ErrorDLQ &&
 			t.Exprs.Equal(o.Exprs, cmap.Comparator[string]()) &&
 			ident.Equal(t.Extras, o.Extras) &&
 			t.Ignore.Equal(o.Ignore, cmap.Comparator[bool]()) &&
@@ -98,8 +104,10 @@ func (t *Config) Equal(o *Config) bool {
 // IsZero returns true if the Config represents the absence of a
 // configuration.
 func (t *Config) IsZero() bool {
-	return len(t.CASColumns) == 0 &&
+	return !t.BulkLoad &&
+		len(t.CASColumns) == 0 &&
 		t.Deadlines.Len() == 0 &&
+		t.ErrorDLQ == "" &&
 		t.Exprs.Len() == 0 &&
 		t.Extras.Empty() &&
 		t.Ignore.Len() == 0 &&
@@ -110,10 +118,16 @@ func (t *Config) IsZero() bool {
 // Patch applies any non-empty fields from another Config to the
 // receiver and returns the receiver.
 func (t *Config) Patch(other *Config) *Config {
+	if other.BulkLoad {
+		t.BulkLoad = true
+	}
 	t.CASColumns = append(t.CASColumns, other.CASColumns...)
 	if other.Deadlines != nil {
 		other.Deadlines.CopyInto(t.Deadlines)
 	}
+	if other.ErrorDLQ != "" {
+		t.ErrorDLQ = other.ErrorDLQ
+	}
 	if other.Exprs != nil {
 		other.Exprs.CopyInto(t.Exprs)
 	}
@@ -30,8 +30,10 @@ func TestCopyEquals(t *testing.T) {
 	a := assert.New(t)
 
 	cfg := &Config{
+		BulkLoad:   true,
 		CASColumns: TargetColumns{ident.New("cas")},
 		Deadlines:  ident.MapOf[time.Duration](ident.New("dl"), time.Hour),
+		ErrorDLQ:   "errors",
 		Exprs:      ident.MapOf[string]("expr", "foo"),
 		Extras:     ident.New("extras"),
 		Ignore:     ident.MapOf[bool]("ign", true),
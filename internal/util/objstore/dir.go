@@ -0,0 +1,152 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package objstore
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// dirBucket stores objects as files within a local directory.
+type dirBucket struct {
+	root string // An absolute path.
+}
+
+var _ Bucket = (*dirBucket)(nil)
+
+// OpenDir returns a Bucket which stores objects in a local directory.
+func OpenDir(dir string) (Bucket, error) {
+	if dir == "" {
+		return nil, errors.New("a directory must be specified")
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &dirBucket{root: root}, nil
+}
+
+// Delete implements Bucket.
+func (b *dirBucket) Delete(_ context.Context, name string) error {
+	p, err := b.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// Get implements Bucket.
+func (b *dirBucket) Get(_ context.Context, name string) (io.ReadCloser, error) {
+	p, err := b.path(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	return f, errors.WithStack(err)
+}
+
+// List implements Bucket. Subdirectories are traversed, so that the
+// names of nested files will contain slashes.
+func (b *dirBucket) List(_ context.Context, after string, limit int) ([]string, error) {
+	var ret []string
+	err := filepath.WalkDir(b.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// Ignore partially-written files from Put.
+		if strings.HasPrefix(path.Base(rel), ".tmp-") {
+			return nil
+		}
+		if rel > after {
+			ret = append(ret, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// WalkDir uses lexical order per directory, which isn't the same
+	// as lexical order of the complete names.
+	sort.Strings(ret)
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+// Put implements Bucket. The data is written to a temporary file which
+// is then renamed, so readers never observe a partial object.
+func (b *dirBucket) Put(_ context.Context, name string, data io.Reader) error {
+	p, err := b.path(name)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := io.Copy(f, data); err != nil {
+		_ = f.Close()
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(f.Name(), p))
+}
+
+// URL implements Bucket.
+func (b *dirBucket) URL(name string) string {
+	return "file://" + filepath.ToSlash(filepath.Join(b.root, filepath.FromSlash(name)))
+}
+
+// path converts the object name to a local path, rejecting any names
+// which would escape the root directory.
+func (b *dirBucket) path(name string) (string, error) {
+	clean := path.Clean("/" + name)
+	if clean == "/" || clean[1:] != name {
+		return "", errors.Errorf("invalid object name %q", name)
+	}
+	return filepath.Join(b.root, filepath.FromSlash(name)), nil
+}
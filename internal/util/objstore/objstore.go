@@ -0,0 +1,71 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package objstore provides a minimal abstraction over a flat
+// namespace of files, which may be backed by a local directory or by an
+// S3-compatible bucket.
+package objstore
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A Bucket is a flat namespace of objects. Object names use forward
+// slashes as separators, regardless of the underlying storage.
+type Bucket interface {
+	// Delete removes the named object. It is not an error to delete an
+	// object that does not exist.
+	Delete(ctx context.Context, name string) error
+	// Get returns the contents of the named object. If the object does
+	// not exist, the error will satisfy errors.Is(err, fs.ErrNotExist).
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the names of objects which sort strictly after the
+	// given name, in lexicographic order. If limit is greater than
+	// zero, at most limit names will be returned.
+	List(ctx context.Context, after string, limit int) ([]string, error)
+	// Put stores the data under the given name, replacing any existing
+	// object.
+	Put(ctx context.Context, name string, data io.Reader) error
+	// URL returns a location for the object which could be used by an
+	// external system (e.g. a database's COPY command) to access it.
+	URL(name string) string
+}
+
+// Open returns a Bucket for the given URL. A bare path or a file://
+// URL refers to a local directory, which will be created if necessary.
+// An s3:// URL refers to a bucket and optional key prefix in an
+// S3-compatible object store; see [OpenS3] for supported options.
+func Open(rawURL string) (Bucket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse object store URL")
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "":
+		return OpenDir(rawURL)
+	case "file":
+		return OpenDir(u.Path)
+	case "s3":
+		return OpenS3(u)
+	default:
+		return nil, errors.Errorf("unsupported object store scheme: %s", u.Scheme)
+	}
+}
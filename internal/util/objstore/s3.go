@@ -0,0 +1,397 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Environment variables consulted by OpenS3 when the URL does not
+// contain credentials or a region.
+const (
+	envAccessKey    = "AWS_ACCESS_KEY_ID"
+	envRegion       = "AWS_REGION"
+	envSecretKey    = "AWS_SECRET_ACCESS_KEY"
+	envSessionToken = "AWS_SESSION_TOKEN"
+)
+
+// The hash of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Bucket implements Bucket using the S3 REST API, signing requests
+// with AWS Signature Version 4. Only the handful of operations needed
+// by Bucket are supported, which avoids a dependency on a full SDK.
+type s3Bucket struct {
+	accessKey    string
+	bucket       string
+	client       *http.Client
+	endpoint     *url.URL // The scheme and host to connect to.
+	pathStyle    bool     // Place the bucket name in the path.
+	prefix       string   // Prepended to all object names.
+	region       string
+	secretKey    string
+	sessionToken string
+
+	now func() time.Time // Injected for testing.
+}
+
+var _ Bucket = (*s3Bucket)(nil)
+
+// OpenS3 returns a Bucket backed by an S3-compatible object store. The
+// URL has the form
+//
+//	s3://[ACCESS_KEY:SECRET_KEY@]bucket[/prefix][?endpoint=URL&region=REGION]
+//
+// If no endpoint is given, AWS's regional endpoint will be used with
+// virtual-host addressing. Otherwise, path-style addressing is used,
+// which is what S3-compatible stores such as MinIO expect. Credentials
+// and the region default to the standard AWS environment variables.
+func OpenS3(u *url.URL) (Bucket, error) {
+	if u.Host == "" {
+		return nil, errors.New("s3 URL must include a bucket name")
+	}
+	ret := &s3Bucket{
+		accessKey:    os.Getenv(envAccessKey),
+		bucket:       u.Host,
+		client:       http.DefaultClient,
+		prefix:       strings.Trim(u.Path, "/"),
+		region:       os.Getenv(envRegion),
+		secretKey:    os.Getenv(envSecretKey),
+		sessionToken: os.Getenv(envSessionToken),
+		now:          time.Now,
+	}
+	if ret.prefix != "" {
+		ret.prefix += "/"
+	}
+	if u.User != nil {
+		ret.accessKey = u.User.Username()
+		ret.secretKey, _ = u.User.Password()
+		ret.sessionToken = ""
+	}
+	q := u.Query()
+	if region := q.Get("region"); region != "" {
+		ret.region = region
+	}
+	if ret.region == "" {
+		ret.region = "us-east-1"
+	}
+	if endpoint := q.Get("endpoint"); endpoint != "" {
+		e, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not parse s3 endpoint")
+		}
+		if e.Scheme == "" || e.Host == "" {
+			return nil, errors.Errorf("s3 endpoint must be an absolute URL: %s", endpoint)
+		}
+		ret.endpoint = e
+		ret.pathStyle = true
+	} else {
+		ret.endpoint = &url.URL{
+			Scheme: "https",
+			Host:   fmt.Sprintf("%s.s3.%s.amazonaws.com", ret.bucket, ret.region),
+		}
+	}
+	if ret.accessKey == "" || ret.secretKey == "" {
+		return nil, errors.New("s3 credentials must be provided in the URL or environment")
+	}
+	return ret, nil
+}
+
+// Delete implements Bucket.
+func (b *s3Bucket) Delete(ctx context.Context, name string) error {
+	resp, err := b.do(ctx, http.MethodDelete, b.prefix+name, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Get implements Bucket.
+func (b *s3Bucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	resp, err := b.do(ctx, http.MethodGet, b.prefix+name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// listResult is the subset of a ListObjectsV2 response that we use.
+type listResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List implements Bucket.
+func (b *s3Bucket) List(ctx context.Context, after string, limit int) ([]string, error) {
+	var ret []string
+	var token string
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		if b.prefix != "" {
+			q.Set("prefix", b.prefix)
+		}
+		if after != "" {
+			q.Set("start-after", b.prefix+after)
+		}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		if limit > 0 {
+			q.Set("max-keys", strconv.Itoa(limit-len(ret)))
+		}
+
+		resp, err := b.do(ctx, http.MethodGet, "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page listResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not decode s3 list response")
+		}
+		for _, obj := range page.Contents {
+			ret = append(ret, strings.TrimPrefix(obj.Key, b.prefix))
+		}
+		if !page.IsTruncated || (limit > 0 && len(ret) >= limit) {
+			return ret, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// Put implements Bucket. If the data is not an io.ReadSeeker, it will
+// be buffered in memory.
+func (b *s3Bucket) Put(ctx context.Context, name string, data io.Reader) error {
+	body, ok := data.(io.ReadSeeker)
+	if !ok {
+		buf, err := io.ReadAll(data)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		body = bytes.NewReader(buf)
+	}
+	resp, err := b.do(ctx, http.MethodPut, b.prefix+name, nil, body)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// URL implements Bucket.
+func (b *s3Bucket) URL(name string) string {
+	return fmt.Sprintf("s3://%s/%s%s", b.bucket, b.prefix, name)
+}
+
+// s3Error is the error document returned by S3.
+type s3Error struct {
+	Code    string
+	Message string
+}
+
+// do executes a signed request. Any non-2xx response is converted into
+// an error.
+func (b *s3Bucket) do(
+	ctx context.Context, method, key string, query url.Values, body io.ReadSeeker,
+) (*http.Response, error) {
+	u := *b.endpoint
+	if b.pathStyle {
+		u.Path = "/" + b.bucket + "/" + key
+	} else {
+		u.Path = "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	payloadHash := emptyPayloadHash
+	var length int64
+	if body != nil {
+		h := sha256.New()
+		n, err := io.Copy(h, body)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		payloadHash = hex.EncodeToString(h.Sum(nil))
+		length = n
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if body != nil {
+		req.ContentLength = length
+	}
+	b.sign(req, payloadHash, b.now())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var doc s3Error
+	_ = xml.NewDecoder(resp.Body).Decode(&doc)
+	if resp.StatusCode == http.StatusNotFound && doc.Code != "NoSuchBucket" {
+		return nil, errors.Wrapf(fs.ErrNotExist, "s3 %s %s", method, key)
+	}
+	return nil, errors.Errorf("s3 %s %s: %s: %s %s",
+		method, key, resp.Status, doc.Code, doc.Message)
+}
+
+// sign adds the AWS Signature Version 4 headers to the request. All
+// headers already present on the request are signed.
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (b *s3Bucket) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	req.Header.Set("x-amz-date", amzDate)
+	if b.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", b.sessionToken)
+	}
+
+	// Collect the headers to sign, which always includes the host.
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k)
+		canonicalHeaders.WriteByte(':')
+		canonicalHeaders.WriteString(headers[k])
+		canonicalHeaders.WriteByte('\n')
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + b.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+b.secretKey), day)
+	key = hmacSHA256(key, b.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery sorts and encodes the query parameters in the form
+// required by the signing process.
+func canonicalQuery(q url.Values) string {
+	if len(q) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(uriEncode(k, true))
+			sb.WriteByte('=')
+			sb.WriteString(uriEncode(v, true))
+		}
+	}
+	return sb.String()
+}
+
+// uriEncode percent-encodes every byte other than the unreserved
+// characters. Slashes are preserved unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
@@ -93,12 +93,7 @@ func Loop(ctx context.Context, fn func(ctx context.Context, sideEffect *Marker)
 		}
 
 		if pgErr := (*pgconn.PgError)(nil); errors.As(err, &pgErr) {
-			switch pgErr.Code {
-			case "40001": // Serialization Failure
-			case "40003": // Statement Completion Unknown
-			case "08003": // Connection Does Not Exist
-			case "08006": // Connection Failure
-			default:
+			if !IsRetryable(err) {
 				abortedCount.WithLabelValues(pgErr.Code).Inc()
 				return err
 			}
@@ -108,3 +103,20 @@ func Loop(ctx context.Context, fn func(ctx context.Context, sideEffect *Marker)
 		}
 	}
 }
+
+// IsRetryable returns true if the error represents a transaction or
+// connection failure, rather than a problem with the statement or data.
+func IsRetryable(err error) bool {
+	if pgErr := (*pgconn.PgError)(nil); errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001": // Serialization Failure
+		case "40003": // Statement Completion Unknown
+		case "08003": // Connection Does Not Exist
+		case "08006": // Connection Failure
+		default:
+			return false
+		}
+		return true
+	}
+	return false
+}
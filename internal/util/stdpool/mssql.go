@@ -0,0 +1,172 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stdpool
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"time"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// OpenSQLServerAsSource opens a connection to a Microsoft SQL Server
+// database and returns it as a [types.SourcePool]. The connection
+// string is a sqlserver:// URL, as understood by the go-mssqldb driver.
+func OpenSQLServerAsSource(
+	ctx context.Context, connectString string, options ...Option,
+) (*types.SourcePool, func(), error) {
+	var tc TestControls
+	if err := attachOptions(ctx, &tc, options); err != nil {
+		return nil, nil, err
+	}
+
+	return returnOrStop(ctx, func(ctx *stopper.Context) (*types.SourcePool, error) {
+		db, err := sql.Open("sqlserver", connectString)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret := &types.SourcePool{
+			DB: db,
+			PoolInfo: types.PoolInfo{
+				ConnectionString: connectString,
+			},
+		}
+
+		ctx.Go(func() error {
+			<-ctx.Stopping()
+			if err := ret.Close(); err != nil {
+				log.WithError(errors.WithStack(err)).Warn("could not close database connection")
+			}
+			return nil
+		})
+
+	ping:
+		if err := ret.PingContext(ctx); err != nil {
+			if tc.WaitForStartup && isSQLServerStartupError(err) {
+				log.WithError(err).Info("waiting for database to become ready")
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(10 * time.Second):
+					goto ping
+				}
+			}
+			return nil, errors.Wrap(err, "could not ping the database")
+		}
+
+		if err := ret.QueryRowContext(ctx, "SELECT @@VERSION").Scan(&ret.Version); err != nil {
+			return nil, errors.Wrap(err, "could not query version")
+		}
+
+		if err := attachOptions(ctx, ret.DB, options); err != nil {
+			return nil, err
+		}
+
+		if err := attachOptions(ctx, &ret.PoolInfo, options); err != nil {
+			return nil, err
+		}
+
+		return ret, nil
+	})
+}
+
+// OpenSQLServerAsTarget opens a connection to a Microsoft SQL Server
+// database and returns it as a [types.TargetPool]. The connection
+// string is a sqlserver:// URL, as understood by the go-mssqldb driver.
+func OpenSQLServerAsTarget(
+	ctx context.Context, connectString string, options ...Option,
+) (*types.TargetPool, func(), error) {
+	var tc TestControls
+	if err := attachOptions(ctx, &tc, options); err != nil {
+		return nil, nil, err
+	}
+
+	return returnOrStop(ctx, func(ctx *stopper.Context) (*types.TargetPool, error) {
+		db, err := sql.Open("sqlserver", connectString)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret := &types.TargetPool{
+			DB: db,
+			PoolInfo: types.PoolInfo{
+				ConnectionString: connectString,
+				Product:          types.ProductSQLServer,
+			},
+		}
+
+		ctx.Go(func() error {
+			<-ctx.Stopping()
+			if err := ret.Close(); err != nil {
+				log.WithError(errors.WithStack(err)).Warn("could not close database connection")
+			}
+			return nil
+		})
+
+	ping:
+		if err := ret.PingContext(ctx); err != nil {
+			if tc.WaitForStartup && isSQLServerStartupError(err) {
+				log.WithError(err).Info("waiting for database to become ready")
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(10 * time.Second):
+					goto ping
+				}
+			}
+			return nil, errors.Wrap(err, "could not ping the database")
+		}
+
+		if err := ret.QueryRowContext(ctx, "SELECT @@VERSION").Scan(&ret.Version); err != nil {
+			return nil, errors.Wrap(err, "could not query version")
+		}
+
+		if err := attachOptions(ctx, ret.DB, options); err != nil {
+			return nil, err
+		}
+
+		if err := attachOptions(ctx, &ret.PoolInfo, options); err != nil {
+			return nil, err
+		}
+
+		return ret, nil
+	})
+}
+
+// These are reported while a freshly-started container is still
+// running its setup scripts.
+var sqlServerStartupErrors = map[int32]bool{
+	4060:  true, // Cannot open database
+	18456: true, // Login failed
+}
+
+func isSQLServerStartupError(err error) bool {
+	var netErr *net.OpError
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var sErr mssql.Error
+	if !errors.As(err, &sErr) {
+		return false
+	}
+	return sqlServerStartupErrors[sErr.Number]
+}
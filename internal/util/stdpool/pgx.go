@@ -138,6 +138,9 @@ func OpenPgxAsTarget(
 	switch {
 	case strings.HasPrefix(ret.Version, "CockroachDB"):
 		ret.Product = types.ProductCockroachDB
+	case strings.Contains(ret.Version, "Redshift"):
+		// Redshift reports itself as "PostgreSQL 8.0.2 ... Redshift x.y".
+		ret.Product = types.ProductRedshift
 	case strings.HasPrefix(ret.Version, "PostgreSQL"):
 		ret.Product = types.ProductPostgreSQL
 	default:
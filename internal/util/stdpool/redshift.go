@@ -0,0 +1,47 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stdpool
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+)
+
+// OpenRedshiftAsTarget opens a connection to an Amazon Redshift
+// cluster and returns it as a [types.TargetPool].
+//
+// Redshift speaks the PostgreSQL wire protocol, so the connection is
+// made with pgx after rewriting the redshift:// scheme. The returned
+// pool is always identified as [types.ProductRedshift], regardless of
+// the version string reported by the server. This allows a PostgreSQL
+// instance to be used as a stand-in for Redshift when testing.
+func OpenRedshiftAsTarget(
+	ctx context.Context, connectString string, u *url.URL, options ...Option,
+) (*types.TargetPool, func(), error) {
+	pgURL := *u
+	pgURL.Scheme = "postgresql"
+
+	ret, cancel, err := OpenPgxAsTarget(ctx, pgURL.String(), options...)
+	if err != nil {
+		return nil, nil, err
+	}
+	ret.ConnectionString = connectString
+	ret.Product = types.ProductRedshift
+	return ret, cancel, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2023 The Cockroach Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package stdpool
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strings"
+
+	"github.com/cockroachdb/cdc-sink/internal/types"
+	"github.com/cockroachdb/cdc-sink/internal/util/stopper"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	_ "modernc.org/sqlite" // register driver
+)
+
+// sqliteDefaultPragmas are applied to each connection, unless the
+// connection string sets a pragma with the same name. Foreign keys are
+// not enforced by SQLite unless requested, and the busy timeout allows
+// concurrent transactions to wait for the database's write lock.
+var sqliteDefaultPragmas = []struct {
+	name, value string
+}{
+	{"busy_timeout", "busy_timeout(10000)"},
+	{"foreign_keys", "foreign_keys(1)"},
+}
+
+// OpenSQLiteAsTarget opens a SQLite database file and returns it as a
+// [types.TargetPool]. The file is named by the host and path of the
+// connection string, so that both sqlite:///abs/path.db and
+// sqlite://relative/path.db may be used. Query parameters, such as
+// _pragma=journal_mode(wal), are passed through to the driver.
+func OpenSQLiteAsTarget(
+	ctx context.Context, connectString string, u *url.URL, options ...Option,
+) (*types.TargetPool, func(), error) {
+	path := u.Host + u.Path
+	if path == "" {
+		return nil, nil, errors.New("the connection string must contain a database file path")
+	}
+
+	query := u.Query()
+	// Writes are serialized by SQLite, so take the write lock when a
+	// transaction begins, rather than failing to upgrade a read lock.
+	if !query.Has("_txlock") {
+		query.Set("_txlock", "immediate")
+	}
+pragmas:
+	for _, pragma := range sqliteDefaultPragmas {
+		for _, existing := range query["_pragma"] {
+			if strings.HasPrefix(strings.ToLower(existing), pragma.name) {
+				continue pragmas
+			}
+		}
+		query.Add("_pragma", pragma.value)
+	}
+	dsn := "file:" + path + "?" + query.Encode()
+
+	return returnOrStop(ctx, func(ctx *stopper.Context) (*types.TargetPool, error) {
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret := &types.TargetPool{
+			DB: db,
+			PoolInfo: types.PoolInfo{
+				ConnectionString: connectString,
+				Product:          types.ProductSQLite,
+			},
+		}
+
+		ctx.Go(func() error {
+			<-ctx.Stopping()
+			if err := ret.Close(); err != nil {
+				log.WithError(errors.WithStack(err)).Warn("could not close database connection")
+			}
+			return nil
+		})
+
+		if err := ret.Ping(); err != nil {
+			return nil, errors.Wrap(err, "could not open the database")
+		}
+
+		if err := ret.QueryRow("SELECT sqlite_version()").Scan(&ret.Version); err != nil {
+			return nil, errors.Wrap(err, "could not query version")
+		}
+
+		if err := attachOptions(ctx, ret.DB, options); err != nil {
+			return nil, err
+		}
+
+		if err := attachOptions(ctx, &ret.PoolInfo, options); err != nil {
+			return nil, err
+		}
+
+		return ret, nil
+	})
+}
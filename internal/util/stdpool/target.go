@@ -36,12 +36,20 @@ func OpenTarget(
 	}
 
 	switch strings.ToLower(u.Scheme) {
+	case "clickhouse":
+		return OpenClickHouseAsTarget(ctx, connectString, options...)
 	case "mysql":
 		return OpenMySQLAsTarget(ctx, connectString, u, options...)
 	case "pg", "pgx", "postgres", "postgresql":
 		return OpenPgxAsTarget(ctx, connectString, options...)
 	case "ora", "oracle":
 		return OpenOracleAsTarget(ctx, connectString, options...)
+	case "redshift":
+		return OpenRedshiftAsTarget(ctx, connectString, u, options...)
+	case "sqlite":
+		return OpenSQLiteAsTarget(ctx, connectString, u, options...)
+	case "sqlserver":
+		return OpenSQLServerAsTarget(ctx, connectString, options...)
 	default:
 		return nil, nil, errors.Errorf("unknown URL scheme: %s", u.Scheme)
 	}
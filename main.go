@@ -27,15 +27,24 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/cockroachdb/cdc-sink/internal/cmd/dlq"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/dumphelp"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/dumptemplates"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/fslogical"
+	"github.com/cockroachdb/cdc-sink/internal/cmd/kafka"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/licenses"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/mkjwt"
+	"github.com/cockroachdb/cdc-sink/internal/cmd/mongological"
+	"github.com/cockroachdb/cdc-sink/internal/cmd/mssqllogical"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/mylogical"
+	"github.com/cockroachdb/cdc-sink/internal/cmd/objstore"
+	"github.com/cockroachdb/cdc-sink/internal/cmd/oralogical"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/pglogical"
+	"github.com/cockroachdb/cdc-sink/internal/cmd/pollinglogical"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/preflight"
+	"github.com/cockroachdb/cdc-sink/internal/cmd/publish"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/start"
+	"github.com/cockroachdb/cdc-sink/internal/cmd/verify"
 	"github.com/cockroachdb/cdc-sink/internal/cmd/version"
 	"github.com/cockroachdb/cdc-sink/internal/script"
 	"github.com/cockroachdb/cdc-sink/internal/util/logfmt"
@@ -101,16 +110,25 @@ func main() {
 	f.CountVarP(&verbosity, "verbose", "v", "increase logging verbosity to debug; repeat for trace")
 
 	root.AddCommand(
+		dlq.Command(),
 		dumphelp.Command(),
 		dumptemplates.Command(),
 		fslogical.Command(),
+		kafka.Command(),
 		licenses.Command(),
 		mkjwt.Command(),
+		mongological.Command(),
+		mssqllogical.Command(),
 		mylogical.Command(),
+		objstore.Command(),
+		oralogical.Command(),
 		pglogical.Command(),
+		pollinglogical.Command(),
 		preflight.Command(),
+		publish.Command(),
 		script.HelpCommand(),
 		start.Command(),
+		verify.Command(),
 		version.Command(),
 	)
 